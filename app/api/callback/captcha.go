@@ -0,0 +1,95 @@
+package callback
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"federation-backend/app/config"
+	"fmt"
+	"net/http"
+	"net/url"
+	"strings"
+)
+
+// ErrCaptchaFailed - токен капчи отсутствует или не прошёл проверку
+var ErrCaptchaFailed = errors.New("captcha verification failed")
+
+// CaptchaVerifier проверяет токен капчи, полученный формой. Ошибка, отличная
+// от ErrCaptchaFailed, означает, что проверить токен не удалось
+type CaptchaVerifier interface {
+	Verify(ctx context.Context, token, remoteIP string) error
+}
+
+// captchaVerifyURLs - адреса siteverify провайдеров. Протокол у всех трёх
+// одинаковый: POST формы secret, response, remoteip и JSON с полем success
+var captchaVerifyURLs = map[string]string{
+	config.CaptchaRecaptcha: "https://www.google.com/recaptcha/api/siteverify",
+	config.CaptchaHCaptcha:  "https://api.hcaptcha.com/siteverify",
+	config.CaptchaTurnstile: "https://challenges.cloudflare.com/turnstile/v0/siteverify",
+}
+
+type SiteVerifier struct {
+	url    string
+	secret string
+	client *http.Client
+}
+
+// NewCaptchaVerifier возвращает nil, если провайдер не задан
+func NewCaptchaVerifier(cfg config.CaptchaConfig) CaptchaVerifier {
+	if cfg.Provider == "" {
+		return nil
+	}
+	verifyURL := cfg.VerifyURL
+	if verifyURL == "" {
+		verifyURL = captchaVerifyURLs[cfg.Provider]
+	}
+	return &SiteVerifier{
+		url:    verifyURL,
+		secret: cfg.Secret,
+		client: &http.Client{Timeout: cfg.Timeout},
+	}
+}
+
+func (v *SiteVerifier) Verify(ctx context.Context, token, remoteIP string) error {
+	if token == "" {
+		return ErrCaptchaFailed
+	}
+
+	form := url.Values{}
+	form.Set("secret", v.secret)
+	form.Set("response", token)
+	if remoteIP != "" {
+		form.Set("remoteip", remoteIP)
+	}
+
+	request, err := http.NewRequestWithContext(ctx, http.MethodPost, v.url, strings.NewReader(form.Encode()))
+	if err != nil {
+		return err
+	}
+	request.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+
+	response, err := v.client.Do(request)
+	if err != nil {
+		return fmt.Errorf("captcha provider is unavailable: %w", err)
+	}
+	defer response.Body.Close()
+
+	if response.StatusCode != http.StatusOK {
+		return fmt.Errorf("captcha provider returned %s", response.Status)
+	}
+
+	var result struct {
+		Success    bool     `json:"success"`
+		ErrorCodes []string `json:"error-codes"`
+	}
+	if err := json.NewDecoder(response.Body).Decode(&result); err != nil {
+		return fmt.Errorf("failed to decode captcha response: %w", err)
+	}
+	if !result.Success {
+		if len(result.ErrorCodes) > 0 {
+			return fmt.Errorf("%w: %s", ErrCaptchaFailed, strings.Join(result.ErrorCodes, ", "))
+		}
+		return ErrCaptchaFailed
+	}
+	return nil
+}
@@ -0,0 +1,341 @@
+package callback
+
+import (
+	"errors"
+	"federation-backend/app/api/shared/export"
+	"federation-backend/app/api/shared/middleware"
+	"federation-backend/app/db/models"
+	"federation-backend/app/metrics"
+	"log/slog"
+	"math"
+	"net/http"
+	"strconv"
+
+	"github.com/gin-gonic/gin"
+	"gorm.io/gorm"
+)
+
+type Controller struct {
+	service *Service
+	guard   *Guard
+	logger  *slog.Logger
+}
+
+func NewController(db *gorm.DB, notifier Notifier, guard *Guard, logger *slog.Logger) *Controller {
+	return &Controller{
+		service: NewService(db, notifier, logger),
+		guard:   guard,
+		logger:  logger,
+	}
+}
+
+// RegisterPublicRoutes - форма заявки, доступная всем
+func (c *Controller) RegisterPublicRoutes(router *gin.RouterGroup) {
+	router.POST("/", c.Create)
+}
+
+// RegisterStaffRoutes - просмотр и обработка заявок сотрудниками. router
+// должен требовать авторизацию: здесь личные данные заявителей и внутренние
+// заметки
+func (c *Controller) RegisterStaffRoutes(router *gin.RouterGroup) {
+	router.GET("/", c.GetAll)
+	router.GET("/export", c.Export)
+	router.GET("/:id", c.Get)
+	router.PUT("/:id", c.Update)
+	router.DELETE("/:id", c.Delete)
+	router.PUT("/:id/status", c.SetStatus)
+	router.PUT("/:id/assignee", c.Assign)
+	router.GET("/:id/notes", c.GetNotes)
+	router.POST("/:id/notes", c.AddNote)
+	router.DELETE("/:id/notes/:noteId", c.DeleteNote)
+}
+
+// Create - публичная форма заявки, поэтому перед сохранением заявка проходит Guard
+func (c *Controller) Create(ctx *gin.Context) {
+	var dto CreateCallbackDTO
+	if err := ctx.ShouldBindJSON(&dto); err != nil {
+		metrics.CallbackRejections.WithLabelValues(metrics.CallbackRejectValidation).Inc()
+		ctx.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	phone, err := NormalizePhone(dto.Phone)
+	if err != nil {
+		metrics.CallbackRejections.WithLabelValues(metrics.CallbackRejectValidation).Inc()
+		ctx.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	dto.Phone = phone
+
+	err = c.guard.Check(ctx.Request.Context(), Submission{
+		IP:           ctx.ClientIP(),
+		Phone:        dto.Phone,
+		CallbackType: dto.CallbackType,
+		Honeypot:     dto.Website,
+		CaptchaToken: dto.CaptchaToken,
+	})
+	if err != nil {
+		respondRejected(ctx, err)
+		return
+	}
+
+	callback, err := c.service.Create(ctx.Request.Context(), &dto)
+	if err != nil {
+		ctx.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+
+	ctx.JSON(http.StatusCreated, callback)
+}
+
+func (c *Controller) Get(ctx *gin.Context) {
+	id, ok := parseID(ctx, "id")
+	if !ok {
+		return
+	}
+
+	callback, err := c.service.Get(ctx.Request.Context(), id)
+	if err != nil {
+		respondError(ctx, err)
+		return
+	}
+
+	ctx.JSON(http.StatusOK, callback)
+}
+
+// GetAll - список заявок с фильтрами status, callback_type, assignee_id,
+// сортировкой sort и постраничным выводом limit/offset
+func (c *Controller) GetAll(ctx *gin.Context) {
+	query, err := ParseListQuery(ctx.Request.URL.Query())
+	if err != nil {
+		ctx.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	callbacks, total, err := c.service.List(ctx.Request.Context(), query)
+	if err != nil {
+		ctx.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+
+	ctx.Header(middleware.HeaderTotalCount, strconv.FormatInt(total, 10))
+	ctx.JSON(http.StatusOK, callbacks)
+}
+
+// exportHeader - столбцы выгрузки заявок
+var exportHeader = []string{"id", "created_at", "callback_type", "status", "name", "phone", "email", "team_name", "assignee"}
+
+// Export выгружает заявки в csv или xlsx (format) с теми же фильтрами и
+// сортировкой, что и GetAll
+func (c *Controller) Export(ctx *gin.Context) {
+	format, err := export.ParseFormat(ctx.Query("format"))
+	if err != nil {
+		ctx.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	query, err := ParseListQuery(ctx.Request.URL.Query())
+	if err != nil {
+		ctx.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	export.Stream(ctx, c.logger, format, "callbacks", exportHeader, func(write func(row []string) error) error {
+		return c.service.Export(ctx.Request.Context(), query, func(batch []models.CallBack) error {
+			for _, callback := range batch {
+				assignee := ""
+				if callback.Assignee != nil {
+					assignee = callback.Assignee.Username
+				}
+				err := write([]string{
+					strconv.FormatUint(uint64(callback.Id), 10),
+					export.Time(callback.CreatedAt),
+					string(callback.CallbackType),
+					string(callback.Status),
+					callback.Name,
+					callback.Phone,
+					export.Optional(callback.Email),
+					export.Optional(callback.TeamName),
+					assignee,
+				})
+				if err != nil {
+					return err
+				}
+			}
+			return nil
+		})
+	})
+}
+
+func (c *Controller) Update(ctx *gin.Context) {
+	id, ok := parseID(ctx, "id")
+	if !ok {
+		return
+	}
+
+	var dto UpdateCallbackDTO
+	if err := ctx.ShouldBindJSON(&dto); err != nil {
+		ctx.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	callback, err := c.service.Update(ctx.Request.Context(), id, &dto)
+	if err != nil {
+		respondError(ctx, err)
+		return
+	}
+
+	ctx.JSON(http.StatusOK, callback)
+}
+
+func (c *Controller) Delete(ctx *gin.Context) {
+	id, ok := parseID(ctx, "id")
+	if !ok {
+		return
+	}
+
+	if err := c.service.Delete(ctx.Request.Context(), id); err != nil {
+		respondError(ctx, err)
+		return
+	}
+
+	ctx.JSON(http.StatusOK, gin.H{"message": "Callback deleted"})
+}
+
+func (c *Controller) SetStatus(ctx *gin.Context) {
+	id, ok := parseID(ctx, "id")
+	if !ok {
+		return
+	}
+
+	var dto StatusDTO
+	if err := ctx.ShouldBindJSON(&dto); err != nil {
+		ctx.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	callback, err := c.service.SetStatus(ctx.Request.Context(), id, dto.Status)
+	if err != nil {
+		respondError(ctx, err)
+		return
+	}
+
+	ctx.JSON(http.StatusOK, callback)
+}
+
+func (c *Controller) Assign(ctx *gin.Context) {
+	id, ok := parseID(ctx, "id")
+	if !ok {
+		return
+	}
+
+	var dto AssigneeDTO
+	if err := ctx.ShouldBindJSON(&dto); err != nil {
+		ctx.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	callback, err := c.service.Assign(ctx.Request.Context(), id, dto.AssigneeID)
+	if err != nil {
+		respondError(ctx, err)
+		return
+	}
+
+	ctx.JSON(http.StatusOK, callback)
+}
+
+func (c *Controller) GetNotes(ctx *gin.Context) {
+	id, ok := parseID(ctx, "id")
+	if !ok {
+		return
+	}
+
+	notes, err := c.service.Notes(ctx.Request.Context(), id)
+	if err != nil {
+		respondError(ctx, err)
+		return
+	}
+
+	ctx.JSON(http.StatusOK, notes)
+}
+
+func (c *Controller) AddNote(ctx *gin.Context) {
+	id, ok := parseID(ctx, "id")
+	if !ok {
+		return
+	}
+
+	var dto NoteDTO
+	if err := ctx.ShouldBindJSON(&dto); err != nil {
+		ctx.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	note, err := c.service.AddNote(ctx.Request.Context(), id, &dto)
+	if err != nil {
+		respondError(ctx, err)
+		return
+	}
+
+	ctx.JSON(http.StatusCreated, note)
+}
+
+func (c *Controller) DeleteNote(ctx *gin.Context) {
+	id, ok := parseID(ctx, "id")
+	if !ok {
+		return
+	}
+	noteID, ok := parseID(ctx, "noteId")
+	if !ok {
+		return
+	}
+
+	if err := c.service.DeleteNote(ctx.Request.Context(), id, noteID); err != nil {
+		respondError(ctx, err)
+		return
+	}
+
+	ctx.JSON(http.StatusOK, gin.H{"message": "Note deleted"})
+}
+
+func parseID(ctx *gin.Context, param string) (uint, bool) {
+	id, err := strconv.ParseUint(ctx.Param(param), 10, 32)
+	if err != nil {
+		ctx.JSON(http.StatusBadRequest, gin.H{"error": "invalid " + param})
+		return 0, false
+	}
+	return uint(id), true
+}
+
+func respondRejected(ctx *gin.Context, err error) {
+	var rejected *RejectedError
+	if !errors.As(err, &rejected) {
+		ctx.JSON(http.StatusServiceUnavailable, gin.H{"error": err.Error()})
+		return
+	}
+
+	switch rejected.Reason {
+	case metrics.CallbackRejectHoneypot:
+		// Бот не должен понять, что заявка отброшена
+		ctx.JSON(http.StatusCreated, gin.H{"status": "accepted"})
+	case metrics.CallbackRejectIPLimit, metrics.CallbackRejectPhoneLimit:
+		ctx.Header(middleware.HeaderRetryAfter, strconv.Itoa(int(math.Ceil(rejected.RetryAfter.Seconds()))))
+		ctx.JSON(http.StatusTooManyRequests, gin.H{"error": rejected.Error()})
+	case metrics.CallbackRejectDuplicate:
+		ctx.JSON(http.StatusConflict, gin.H{"error": rejected.Error()})
+	default:
+		ctx.JSON(http.StatusBadRequest, gin.H{"error": rejected.Error()})
+	}
+}
+
+func respondError(ctx *gin.Context, err error) {
+	switch {
+	case errors.Is(err, ErrNotFound), errors.Is(err, ErrNoteNotFound):
+		ctx.JSON(http.StatusNotFound, gin.H{"error": err.Error()})
+	case errors.Is(err, ErrInvalidTransition):
+		ctx.JSON(http.StatusConflict, gin.H{"error": err.Error()})
+	case errors.Is(err, ErrAssigneeNotFound), errors.Is(err, ErrAuthorNotFound), errors.Is(err, ErrEmptyNote), errors.Is(err, ErrInvalidPhone):
+		ctx.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+	default:
+		ctx.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+	}
+}
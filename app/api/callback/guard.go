@@ -0,0 +1,144 @@
+package callback
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log/slog"
+	"strings"
+	"time"
+
+	"federation-backend/app/config"
+	"federation-backend/app/db/models"
+	"federation-backend/app/logging"
+	"federation-backend/app/metrics"
+	"federation-backend/app/ratelimit"
+
+	"gorm.io/gorm"
+)
+
+var ErrInvalidPhone = errors.New("phone must contain 10 to 15 digits, optionally starting with +")
+
+// RejectedError - заявка отклонена защитой от спама
+type RejectedError struct {
+	// Reason - одна из metrics.CallbackReject*
+	Reason string
+	// RetryAfter - когда можно повторить, для отказов по лимиту
+	RetryAfter time.Duration
+	Err        error
+}
+
+func (e *RejectedError) Error() string { return e.Err.Error() }
+func (e *RejectedError) Unwrap() error { return e.Err }
+
+// Submission - то, что нужно защите от спама помимо самой заявки
+type Submission struct {
+	IP           string
+	Phone        string
+	CallbackType models.CallbackType
+	// Honeypot - скрытое поле формы, человек его не заполняет
+	Honeypot     string
+	CaptchaToken string
+}
+
+// Guard проверяет заявки с публичной формы: honeypot, лимиты по IP и номеру,
+// капчу и повторную отправку той же заявки
+type Guard struct {
+	db      *gorm.DB
+	limits  ratelimit.Store
+	captcha CaptchaVerifier
+	cfg     config.CallbackConfig
+	logger  *slog.Logger
+}
+
+// NewGuard - captcha может быть nil, тогда капча не проверяется
+func NewGuard(db *gorm.DB, limits ratelimit.Store, captcha CaptchaVerifier, cfg config.CallbackConfig, logger *slog.Logger) *Guard {
+	return &Guard{db: db, limits: limits, captcha: captcha, cfg: cfg, logger: logger}
+}
+
+// Check возвращает *RejectedError, если заявку принимать нельзя. Другие ошибки -
+// сбой проверки (база, хранилище лимитов, провайдер капчи)
+func (g *Guard) Check(ctx context.Context, submission Submission) error {
+	if submission.Honeypot != "" {
+		return g.reject(ctx, submission, metrics.CallbackRejectHoneypot, 0, errors.New("honeypot field is filled"))
+	}
+
+	ipPolicy := ratelimit.Policy{Limit: g.cfg.IPLimit, Period: g.cfg.IPPeriod}
+	if err := g.take(ctx, submission, "callback:ip:"+submission.IP, ipPolicy, metrics.CallbackRejectIPLimit); err != nil {
+		return err
+	}
+
+	// Капча проверяется после лимита по IP, чтобы поток запросов не тратил квоту провайдера
+	if g.captcha != nil {
+		if err := g.captcha.Verify(ctx, submission.CaptchaToken, submission.IP); err != nil {
+			if errors.Is(err, ErrCaptchaFailed) {
+				return g.reject(ctx, submission, metrics.CallbackRejectCaptcha, 0, err)
+			}
+			return err
+		}
+	}
+
+	if g.cfg.DuplicateWindow > 0 {
+		var count int64
+		err := g.db.WithContext(ctx).Model(&models.CallBack{}).
+			Where("phone = ? AND callback_type = ? AND created_at >= ?", submission.Phone, submission.CallbackType, time.Now().Add(-g.cfg.DuplicateWindow)).
+			Count(&count).Error
+		if err != nil {
+			return fmt.Errorf("failed to check duplicates: %w", err)
+		}
+		if count > 0 {
+			return g.reject(ctx, submission, metrics.CallbackRejectDuplicate, 0, errors.New("the same request was already submitted"))
+		}
+	}
+
+	phonePolicy := ratelimit.Policy{Limit: g.cfg.PhoneLimit, Period: g.cfg.PhonePeriod}
+	return g.take(ctx, submission, "callback:phone:"+submission.Phone, phonePolicy, metrics.CallbackRejectPhoneLimit)
+}
+
+func (g *Guard) take(ctx context.Context, submission Submission, key string, policy ratelimit.Policy, reason string) error {
+	if !policy.Enabled() {
+		return nil
+	}
+	decision, err := g.limits.Take(ctx, key, policy)
+	if err != nil {
+		return fmt.Errorf("failed to check rate limit: %w", err)
+	}
+	if !decision.Allowed {
+		return g.reject(ctx, submission, reason, decision.RetryAfter, errors.New("too many requests, try again later"))
+	}
+	return nil
+}
+
+func (g *Guard) reject(ctx context.Context, submission Submission, reason string, retryAfter time.Duration, err error) error {
+	metrics.CallbackRejections.WithLabelValues(reason).Inc()
+	g.logger.WarnContext(ctx, "callback rejected",
+		slog.String("reason", reason),
+		slog.String("client_ip", submission.IP),
+		logging.Error(err),
+	)
+	return &RejectedError{Reason: reason, RetryAfter: retryAfter, Err: err}
+}
+
+// NormalizePhone убирает оформление номера (пробелы, скобки, дефисы, точки) и
+// проверяет, что осталось от 10 до 15 цифр с необязательным + в начале
+func NormalizePhone(raw string) (string, error) {
+	raw = strings.TrimSpace(raw)
+	var normalized strings.Builder
+	digits := 0
+	for i, r := range raw {
+		switch {
+		case r >= '0' && r <= '9':
+			normalized.WriteRune(r)
+			digits++
+		case r == '+' && i == 0:
+			normalized.WriteRune(r)
+		case r == ' ' || r == '-' || r == '(' || r == ')' || r == '.':
+		default:
+			return "", ErrInvalidPhone
+		}
+	}
+	if digits < 10 || digits > 15 {
+		return "", ErrInvalidPhone
+	}
+	return normalized.String(), nil
+}
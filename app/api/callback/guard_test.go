@@ -0,0 +1,222 @@
+package callback
+
+import (
+	"context"
+	"errors"
+	"io"
+	"log/slog"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"federation-backend/app/config"
+	"federation-backend/app/db/models"
+	"federation-backend/app/metrics"
+	"federation-backend/app/ratelimit"
+
+	"gorm.io/driver/sqlite"
+	"gorm.io/gorm"
+	"gorm.io/gorm/logger"
+)
+
+func TestNormalizePhone(t *testing.T) {
+	tests := []struct {
+		raw     string
+		want    string
+		wantErr bool
+	}{
+		{raw: "+7 (912) 345-67-89", want: "+79123456789"},
+		{raw: "8.912.345.67.89", want: "89123456789"},
+		{raw: "  9123456789  ", want: "9123456789"},
+		{raw: "+123456789012345", want: "+123456789012345"},
+		{raw: "912345678", wantErr: true},
+		{raw: "+1234567890123456", wantErr: true},
+		{raw: "7+9123456789", wantErr: true},
+		{raw: "+7 912 345 67 89 доб. 1", wantErr: true},
+		{raw: "", wantErr: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.raw, func(t *testing.T) {
+			got, err := NormalizePhone(tt.raw)
+			if tt.wantErr {
+				if !errors.Is(err, ErrInvalidPhone) {
+					t.Fatalf("NormalizePhone(%q) = %q, %v, want ErrInvalidPhone", tt.raw, got, err)
+				}
+				return
+			}
+			if err != nil || got != tt.want {
+				t.Fatalf("NormalizePhone(%q) = %q, %v, want %q", tt.raw, got, err, tt.want)
+			}
+		})
+	}
+}
+
+// fakeCaptcha возвращает err и считает вызовы
+type fakeCaptcha struct {
+	err   error
+	calls int
+}
+
+func (c *fakeCaptcha) Verify(context.Context, string, string) error {
+	c.calls++
+	return c.err
+}
+
+func TestGuardCheck(t *testing.T) {
+	errProvider := errors.New("provider is down")
+	cfg := config.CallbackConfig{
+		IPLimit:         2,
+		IPPeriod:        time.Hour,
+		PhoneLimit:      1,
+		PhonePeriod:     time.Hour,
+		DuplicateWindow: time.Hour,
+	}
+	submission := Submission{IP: "203.0.113.1", Phone: "+79123456789", CallbackType: models.CallbackRequest}
+
+	tests := []struct {
+		name string
+		cfg  config.CallbackConfig
+		// captcha - nil, если капча выключена
+		captcha *fakeCaptcha
+		// existing - заявка с тем же номером и типом уже есть в базе
+		existing bool
+		// before - заявки, прошедшие проверку до этой
+		before     []Submission
+		submission Submission
+		wantReason string
+		wantErr    error
+		// wantCaptchaCalls - сколько раз вызвана капча за все проверки
+		wantCaptchaCalls int
+	}{
+		{
+			name:       "accepted",
+			cfg:        cfg,
+			submission: submission,
+		},
+		{
+			name:       "honeypot",
+			cfg:        cfg,
+			submission: Submission{IP: submission.IP, Phone: submission.Phone, Honeypot: "http://spam"},
+			wantReason: metrics.CallbackRejectHoneypot,
+		},
+		{
+			name: "ip limit",
+			cfg:  cfg,
+			before: []Submission{
+				{IP: submission.IP, Phone: "+79000000001"},
+				{IP: submission.IP, Phone: "+79000000002"},
+			},
+			submission: submission,
+			wantReason: metrics.CallbackRejectIPLimit,
+		},
+		{
+			name:       "phone limit from another ip",
+			cfg:        cfg,
+			before:     []Submission{{IP: "203.0.113.2", Phone: submission.Phone, CallbackType: models.TeamApplication}},
+			submission: submission,
+			wantReason: metrics.CallbackRejectPhoneLimit,
+		},
+		{
+			name:       "duplicate",
+			cfg:        cfg,
+			existing:   true,
+			submission: submission,
+			wantReason: metrics.CallbackRejectDuplicate,
+		},
+		{
+			name:       "duplicate check disabled",
+			cfg:        config.CallbackConfig{IPLimit: 2, IPPeriod: time.Hour},
+			existing:   true,
+			submission: submission,
+		},
+		{
+			name:             "captcha failed",
+			cfg:              cfg,
+			captcha:          &fakeCaptcha{err: ErrCaptchaFailed},
+			submission:       submission,
+			wantReason:       metrics.CallbackRejectCaptcha,
+			wantCaptchaCalls: 1,
+		},
+		{
+			name:             "captcha provider error is not a rejection",
+			cfg:              cfg,
+			captcha:          &fakeCaptcha{err: errProvider},
+			submission:       submission,
+			wantErr:          errProvider,
+			wantCaptchaCalls: 1,
+		},
+		{
+			name:    "captcha is not asked over the ip limit",
+			cfg:     config.CallbackConfig{IPLimit: 1, IPPeriod: time.Hour},
+			captcha: &fakeCaptcha{},
+			before: []Submission{
+				{IP: submission.IP, Phone: "+79000000001"},
+			},
+			submission:       submission,
+			wantReason:       metrics.CallbackRejectIPLimit,
+			wantCaptchaCalls: 1,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			db := openGuardDB(t)
+			if tt.existing {
+				if err := db.Create(&models.CallBack{Name: "Иван", Phone: submission.Phone, CallbackType: submission.CallbackType}).Error; err != nil {
+					t.Fatal(err)
+				}
+			}
+
+			var captcha CaptchaVerifier
+			if tt.captcha != nil {
+				captcha = tt.captcha
+			}
+			guard := NewGuard(db, ratelimit.NewMemory(), captcha, tt.cfg, slog.New(slog.NewTextHandler(io.Discard, nil)))
+			ctx := context.Background()
+
+			for i, before := range tt.before {
+				if err := guard.Check(ctx, before); err != nil {
+					t.Fatalf("submission %d before: %v", i, err)
+				}
+			}
+			err := guard.Check(ctx, tt.submission)
+
+			var rejected *RejectedError
+			switch {
+			case tt.wantReason != "":
+				if !errors.As(err, &rejected) || rejected.Reason != tt.wantReason {
+					t.Fatalf("error = %v, want rejection %s", err, tt.wantReason)
+				}
+				limited := tt.wantReason == metrics.CallbackRejectIPLimit || tt.wantReason == metrics.CallbackRejectPhoneLimit
+				if limited != (rejected.RetryAfter > 0) {
+					t.Errorf("retry after = %s for %s", rejected.RetryAfter, tt.wantReason)
+				}
+			case tt.wantErr != nil:
+				if !errors.Is(err, tt.wantErr) || errors.As(err, &rejected) {
+					t.Fatalf("error = %v, want %v without rejection", err, tt.wantErr)
+				}
+			case err != nil:
+				t.Fatalf("unexpected error: %v", err)
+			}
+
+			if tt.captcha != nil && tt.captcha.calls != tt.wantCaptchaCalls {
+				t.Errorf("captcha called %d times, want %d", tt.captcha.calls, tt.wantCaptchaCalls)
+			}
+		})
+	}
+}
+
+func openGuardDB(t *testing.T) *gorm.DB {
+	t.Helper()
+	db, err := gorm.Open(sqlite.Open(filepath.Join(t.TempDir(), "callback.db")), &gorm.Config{Logger: logger.Discard})
+	if err != nil {
+		t.Fatalf("open sqlite: %v", err)
+	}
+	sqlDB, _ := db.DB()
+	sqlDB.SetMaxOpenConns(1)
+	t.Cleanup(func() { sqlDB.Close() })
+	if err := db.AutoMigrate(&models.CallBack{}); err != nil {
+		t.Fatalf("migrate: %v", err)
+	}
+	return db
+}
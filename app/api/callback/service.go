@@ -0,0 +1,508 @@
+package callback
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log/slog"
+	"net/url"
+	"slices"
+	"strconv"
+	"strings"
+
+	"federation-backend/app/api/shared/export"
+	database "federation-backend/app/db"
+	"federation-backend/app/db/models"
+	"federation-backend/app/logging"
+
+	"gorm.io/gorm"
+)
+
+var (
+	ErrNotFound          = errors.New("callback not found")
+	ErrNoteNotFound      = errors.New("note not found")
+	ErrAssigneeNotFound  = errors.New("assignee not found")
+	ErrAuthorNotFound    = errors.New("author not found")
+	ErrEmptyNote         = errors.New("note text is empty")
+	ErrInvalidTransition = errors.New("status transition is not allowed")
+)
+
+// transitions - допустимые переходы между статусами заявки.
+// Повторная установка текущего статуса переходом не считается.
+var transitions = map[models.CallbackStatus][]models.CallbackStatus{
+	models.CallbackStatusNew: {
+		models.CallbackStatusInProgress,
+		models.CallbackStatusContacted,
+		models.CallbackStatusAccepted,
+		models.CallbackStatusRejected,
+		models.CallbackStatusSpam,
+	},
+	models.CallbackStatusInProgress: {
+		models.CallbackStatusContacted,
+		models.CallbackStatusAccepted,
+		models.CallbackStatusRejected,
+		models.CallbackStatusSpam,
+	},
+	models.CallbackStatusContacted: {
+		models.CallbackStatusInProgress,
+		models.CallbackStatusAccepted,
+		models.CallbackStatusRejected,
+	},
+	models.CallbackStatusAccepted: {models.CallbackStatusInProgress},
+	models.CallbackStatusRejected: {models.CallbackStatusInProgress},
+	models.CallbackStatusSpam:     {models.CallbackStatusNew},
+}
+
+// sortColumns - поля, по которым можно сортировать список
+var sortColumns = map[string]string{
+	"created_at":    "created_at",
+	"updated_at":    "updated_at",
+	"status":        "status",
+	"callback_type": "callback_type",
+	"name":          "name",
+}
+
+const defaultSort = "-created_at"
+
+type CreateCallbackDTO struct {
+	Name         string              `json:"name" binding:"required,max=100"`
+	Phone        string              `json:"phone" binding:"required,max=30"`
+	Email        *string             `json:"email" binding:"omitempty,email,max=255"`
+	TeamName     *string             `json:"team_name" binding:"omitempty,max=255"`
+	CallbackType models.CallbackType `json:"callback_type" binding:"required,oneof=team_application callback_request"`
+	// Website - honeypot: поле спрятано на форме, его заполняют только боты
+	Website      string `json:"website"`
+	CaptchaToken string `json:"captcha_token"`
+}
+
+type UpdateCallbackDTO struct {
+	Name         *string              `json:"name" binding:"omitempty,max=100"`
+	Phone        *string              `json:"phone" binding:"omitempty,max=30"`
+	Email        *string              `json:"email" binding:"omitempty,email,max=255"`
+	TeamName     *string              `json:"team_name" binding:"omitempty,max=255"`
+	CallbackType *models.CallbackType `json:"callback_type" binding:"omitempty,oneof=team_application callback_request"`
+}
+
+type StatusDTO struct {
+	Status models.CallbackStatus `json:"status" binding:"required,oneof=new in_progress contacted accepted rejected spam"`
+}
+
+// AssigneeDTO - null в assignee_id снимает ответственного
+type AssigneeDTO struct {
+	AssigneeID *uint `json:"assignee_id"`
+}
+
+type NoteDTO struct {
+	Text     string `json:"text" binding:"required"`
+	AuthorID *uint  `json:"author_id"`
+}
+
+// ListQuery - фильтры и сортировка списка заявок
+type ListQuery struct {
+	Statuses   []models.CallbackStatus
+	Types      []models.CallbackType
+	AssigneeID *uint
+	Sort       string
+	Limit      int
+	Offset     int
+}
+
+// ParseListQuery читает фильтры из query-параметров:
+// status и callback_type - через запятую, sort - имя поля, "-" в начале для убывания
+func ParseListQuery(values url.Values) (ListQuery, error) {
+	query := ListQuery{Sort: defaultSort}
+
+	for _, status := range splitList(values["status"]) {
+		if !slices.Contains(models.CallbackStatuses, models.CallbackStatus(status)) {
+			return ListQuery{}, fmt.Errorf("unknown status %q", status)
+		}
+		query.Statuses = append(query.Statuses, models.CallbackStatus(status))
+	}
+
+	for _, callbackType := range splitList(values["callback_type"]) {
+		if !slices.Contains(models.CallbackTypes, models.CallbackType(callbackType)) {
+			return ListQuery{}, fmt.Errorf("unknown callback_type %q", callbackType)
+		}
+		query.Types = append(query.Types, models.CallbackType(callbackType))
+	}
+
+	if raw := values.Get("assignee_id"); raw != "" {
+		id, err := strconv.ParseUint(raw, 10, 32)
+		if err != nil {
+			return ListQuery{}, fmt.Errorf("invalid assignee_id %q", raw)
+		}
+		assigneeID := uint(id)
+		query.AssigneeID = &assigneeID
+	}
+
+	if sort := values.Get("sort"); sort != "" {
+		if _, ok := sortColumns[strings.TrimPrefix(sort, "-")]; !ok {
+			return ListQuery{}, fmt.Errorf("unknown sort field %q", sort)
+		}
+		query.Sort = sort
+	}
+
+	var err error
+	if query.Limit, err = parseNonNegative(values, "limit"); err != nil {
+		return ListQuery{}, err
+	}
+	if query.Offset, err = parseNonNegative(values, "offset"); err != nil {
+		return ListQuery{}, err
+	}
+
+	return query, nil
+}
+
+func splitList(values []string) []string {
+	var result []string
+	for _, value := range values {
+		for _, part := range strings.Split(value, ",") {
+			if part = strings.TrimSpace(part); part != "" {
+				result = append(result, part)
+			}
+		}
+	}
+	return result
+}
+
+func parseNonNegative(values url.Values, key string) (int, error) {
+	raw := values.Get(key)
+	if raw == "" {
+		return 0, nil
+	}
+	n, err := strconv.Atoi(raw)
+	if err != nil || n < 0 {
+		return 0, fmt.Errorf("invalid %s %q", key, raw)
+	}
+	return n, nil
+}
+
+// Notifier ставит в очередь уведомления о новой заявке. Вызывается внутри
+// транзакции создания: заявка и уведомления сохраняются вместе
+type Notifier interface {
+	CallbackCreated(ctx context.Context, callback *models.CallBack) error
+}
+
+type Service struct {
+	db       *gorm.DB
+	notifier Notifier
+	logger   *slog.Logger
+}
+
+// NewService - notifier может быть nil, если уведомления выключены
+func NewService(db *gorm.DB, notifier Notifier, logger *slog.Logger) *Service {
+	return &Service{db: db, notifier: notifier, logger: logger}
+}
+
+// Create сохраняет заявку. Телефон должен быть уже приведён NormalizePhone
+func (s *Service) Create(ctx context.Context, dto *CreateCallbackDTO) (*models.CallBack, error) {
+	callback := models.CallBack{
+		Name:         strings.TrimSpace(dto.Name),
+		Phone:        dto.Phone,
+		Email:        dto.Email,
+		TeamName:     dto.TeamName,
+		CallbackType: dto.CallbackType,
+		Status:       models.CallbackStatusNew,
+	}
+	err := s.db.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		ctx := database.WithTx(ctx, tx)
+		if err := tx.Create(&callback).Error; err != nil {
+			return fmt.Errorf("failed to create callback: %w", err)
+		}
+		if s.notifier != nil {
+			return s.notifier.CallbackCreated(ctx, &callback)
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	s.logger.InfoContext(ctx, "callback created",
+		logging.Entity("callback", callback.Id),
+		slog.String("callback_type", string(callback.CallbackType)),
+	)
+	return &callback, nil
+}
+
+func (s *Service) Get(ctx context.Context, id uint) (*models.CallBack, error) {
+	var callback models.CallBack
+	err := s.db.WithContext(ctx).First(&callback, id).Error
+	if err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, ErrNotFound
+		}
+		return nil, fmt.Errorf("failed to get callback: %w", err)
+	}
+	return &callback, nil
+}
+
+// Filtered применяет фильтры и сортировку ListQuery, без limit/offset
+func (s *Service) Filtered(ctx context.Context, query ListQuery) *gorm.DB {
+	sort := query.Sort
+	if sort == "" {
+		sort = defaultSort
+	}
+	column, direction := strings.TrimPrefix(sort, "-"), "ASC"
+	if strings.HasPrefix(sort, "-") {
+		direction = "DESC"
+	}
+	return s.where(ctx, query).Order(sortColumns[column] + " " + direction).Order("id " + direction)
+}
+
+// where - только фильтры: postgres не даёт считать COUNT(*) с ORDER BY
+func (s *Service) where(ctx context.Context, query ListQuery) *gorm.DB {
+	db := s.db.WithContext(ctx).Model(&models.CallBack{})
+	if len(query.Statuses) > 0 {
+		db = db.Where("status IN ?", query.Statuses)
+	}
+	if len(query.Types) > 0 {
+		db = db.Where("callback_type IN ?", query.Types)
+	}
+	if query.AssigneeID != nil {
+		db = db.Where("assignee_id = ?", *query.AssigneeID)
+	}
+	return db
+}
+
+// List возвращает страницу заявок и общее число подходящих под фильтры
+func (s *Service) List(ctx context.Context, query ListQuery) ([]models.CallBack, int64, error) {
+	var total int64
+	if err := s.where(ctx, query).Count(&total).Error; err != nil {
+		return nil, 0, fmt.Errorf("failed to count callbacks: %w", err)
+	}
+
+	db := s.Filtered(ctx, query)
+	if query.Limit > 0 {
+		db = db.Limit(query.Limit)
+	}
+	if query.Offset > 0 {
+		db = db.Offset(query.Offset)
+	}
+
+	callbacks := []models.CallBack{}
+	if err := db.Find(&callbacks).Error; err != nil {
+		return nil, 0, fmt.Errorf("failed to list callbacks: %w", err)
+	}
+	return callbacks, total, nil
+}
+
+// Export передаёт в fn заявки под фильтрами query порциями по export.BatchSize,
+// с ответственным. limit/offset не учитываются
+func (s *Service) Export(ctx context.Context, query ListQuery, fn func(batch []models.CallBack) error) error {
+	if err := export.Batches(s.Filtered(ctx, query).Preload("Assignee"), export.BatchSize, fn); err != nil {
+		return fmt.Errorf("failed to export callbacks: %w", err)
+	}
+	return nil
+}
+
+func (s *Service) Update(ctx context.Context, id uint, dto *UpdateCallbackDTO) (*models.CallBack, error) {
+	updates := map[string]any{}
+	if dto.Name != nil {
+		updates["name"] = *dto.Name
+	}
+	if dto.Phone != nil {
+		phone, err := NormalizePhone(*dto.Phone)
+		if err != nil {
+			return nil, err
+		}
+		updates["phone"] = phone
+	}
+	if dto.Email != nil {
+		updates["email"] = *dto.Email
+	}
+	if dto.TeamName != nil {
+		updates["team_name"] = *dto.TeamName
+	}
+	if dto.CallbackType != nil {
+		updates["callback_type"] = *dto.CallbackType
+	}
+
+	err := s.db.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		var callback models.CallBack
+		if err := tx.First(&callback, id).Error; err != nil {
+			if errors.Is(err, gorm.ErrRecordNotFound) {
+				return ErrNotFound
+			}
+			return fmt.Errorf("failed to get callback: %w", err)
+		}
+		if len(updates) == 0 {
+			return nil
+		}
+		if err := tx.Model(&callback).Updates(updates).Error; err != nil {
+			return fmt.Errorf("failed to update callback: %w", err)
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	return s.Get(ctx, id)
+}
+
+func (s *Service) Delete(ctx context.Context, id uint) error {
+	err := s.db.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		// SQLite без foreign_keys=ON каскад не выполнит, поэтому заметки удаляем явно
+		if err := tx.Where("callback_id = ?", id).Delete(&models.CallbackNote{}).Error; err != nil {
+			return fmt.Errorf("failed to delete callback notes: %w", err)
+		}
+		result := tx.Delete(&models.CallBack{}, id)
+		if result.Error != nil {
+			return fmt.Errorf("failed to delete callback: %w", result.Error)
+		}
+		if result.RowsAffected == 0 {
+			return ErrNotFound
+		}
+		return nil
+	})
+	if err != nil {
+		return err
+	}
+
+	s.logger.InfoContext(ctx, "callback deleted", logging.Entity("callback", id))
+	return nil
+}
+
+// SetStatus переводит заявку в новый статус, если переход разрешён
+func (s *Service) SetStatus(ctx context.Context, id uint, status models.CallbackStatus) (*models.CallBack, error) {
+	var previous models.CallbackStatus
+	err := s.db.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		var callback models.CallBack
+		if err := tx.First(&callback, id).Error; err != nil {
+			if errors.Is(err, gorm.ErrRecordNotFound) {
+				return ErrNotFound
+			}
+			return fmt.Errorf("failed to get callback: %w", err)
+		}
+
+		previous = callback.Status
+		if previous == status {
+			return nil
+		}
+		if !slices.Contains(transitions[previous], status) {
+			return fmt.Errorf("%w: %s -> %s", ErrInvalidTransition, previous, status)
+		}
+
+		if err := tx.Model(&callback).Update("status", status).Error; err != nil {
+			return fmt.Errorf("failed to update status: %w", err)
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	if previous != status {
+		s.logger.InfoContext(ctx, "callback status changed",
+			logging.Entity("callback", id),
+			slog.String("from", string(previous)),
+			slog.String("to", string(status)),
+		)
+	}
+	return s.Get(ctx, id)
+}
+
+// Assign назначает ответственного сотрудника, nil снимает назначение
+func (s *Service) Assign(ctx context.Context, id uint, assigneeID *uint) (*models.CallBack, error) {
+	err := s.db.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		var callback models.CallBack
+		if err := tx.First(&callback, id).Error; err != nil {
+			if errors.Is(err, gorm.ErrRecordNotFound) {
+				return ErrNotFound
+			}
+			return fmt.Errorf("failed to get callback: %w", err)
+		}
+
+		if assigneeID != nil {
+			if err := s.userExists(database.WithTx(ctx, tx), *assigneeID, ErrAssigneeNotFound); err != nil {
+				return err
+			}
+		}
+
+		if err := tx.Model(&callback).Update("assignee_id", assigneeID).Error; err != nil {
+			return fmt.Errorf("failed to update assignee: %w", err)
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	attrs := []any{logging.Entity("callback", id)}
+	if assigneeID != nil {
+		attrs = append(attrs, slog.Uint64("assignee_id", uint64(*assigneeID)))
+	}
+	s.logger.InfoContext(ctx, "callback assignee changed", attrs...)
+	return s.Get(ctx, id)
+}
+
+func (s *Service) Notes(ctx context.Context, id uint) ([]models.CallbackNote, error) {
+	if err := s.exists(ctx, id); err != nil {
+		return nil, err
+	}
+
+	notes := []models.CallbackNote{}
+	if err := s.db.WithContext(ctx).Where("callback_id = ?", id).Order("created_at, id").Find(&notes).Error; err != nil {
+		return nil, fmt.Errorf("failed to list notes: %w", err)
+	}
+	return notes, nil
+}
+
+func (s *Service) AddNote(ctx context.Context, id uint, dto *NoteDTO) (*models.CallbackNote, error) {
+	text := strings.TrimSpace(dto.Text)
+	if text == "" {
+		return nil, ErrEmptyNote
+	}
+
+	if err := s.exists(ctx, id); err != nil {
+		return nil, err
+	}
+	if dto.AuthorID != nil {
+		if err := s.userExists(ctx, *dto.AuthorID, ErrAuthorNotFound); err != nil {
+			return nil, err
+		}
+	}
+
+	note := models.CallbackNote{CallbackID: id, AuthorID: dto.AuthorID, Text: text}
+	if err := s.db.WithContext(ctx).Create(&note).Error; err != nil {
+		return nil, fmt.Errorf("failed to create note: %w", err)
+	}
+
+	s.logger.InfoContext(ctx, "callback note added", logging.Entity("callback", id), slog.Uint64("note_id", uint64(note.Id)))
+	return &note, nil
+}
+
+func (s *Service) DeleteNote(ctx context.Context, id, noteID uint) error {
+	result := s.db.WithContext(ctx).Where("callback_id = ?", id).Delete(&models.CallbackNote{}, noteID)
+	if result.Error != nil {
+		return fmt.Errorf("failed to delete note: %w", result.Error)
+	}
+	if result.RowsAffected == 0 {
+		return ErrNoteNotFound
+	}
+
+	s.logger.InfoContext(ctx, "callback note deleted", logging.Entity("callback", id), slog.Uint64("note_id", uint64(noteID)))
+	return nil
+}
+
+func (s *Service) exists(ctx context.Context, id uint) error {
+	var count int64
+	if err := s.db.WithContext(ctx).Model(&models.CallBack{}).Where("id = ?", id).Count(&count).Error; err != nil {
+		return fmt.Errorf("failed to get callback: %w", err)
+	}
+	if count == 0 {
+		return ErrNotFound
+	}
+	return nil
+}
+
+func (s *Service) userExists(ctx context.Context, userID uint, notFound error) error {
+	var count int64
+	if err := database.Conn(ctx, s.db).Model(&models.User{}).Where("id = ?", userID).Count(&count).Error; err != nil {
+		return fmt.Errorf("failed to get user: %w", err)
+	}
+	if count == 0 {
+		return notFound
+	}
+	return nil
+}
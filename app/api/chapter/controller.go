@@ -1,8 +1,12 @@
 package chapter
 
 import (
+	"errors"
+	"federation-backend/app/db/models/enums"
+	"log/slog"
 	"net/http"
 	"strconv"
+	"time"
 
 	"github.com/gin-gonic/gin"
 	"gorm.io/gorm"
@@ -12,6 +16,14 @@ type Controller struct {
 	service *Service
 }
 
+// RegisterExtraRoutes - порядок разделов на странице и их содержимое
+func (c *Controller) RegisterExtraRoutes(router *gin.RouterGroup) {
+	router.GET("/page/:page", c.GetByPage)
+	router.PUT("/order", c.Reorder)
+	router.POST("/:id/move", c.Move)
+	router.GET("/:id/usage", c.GetUsage)
+}
+
 func (c *Controller) Create(ctx *gin.Context) {
 	var dto CreateChapterDTO
 	if err := ctx.ShouldBind(&dto); err != nil {
@@ -19,8 +31,8 @@ func (c *Controller) Create(ctx *gin.Context) {
 		return
 	}
 
-	if err := c.service.Create(&dto); err != nil {
-		ctx.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+	if err := c.service.Create(ctx.Request.Context(), &dto); err != nil {
+		respondError(ctx, err)
 		return
 	}
 
@@ -34,9 +46,9 @@ func (c *Controller) Get(ctx *gin.Context) {
 		return
 	}
 
-	chapter, err := c.service.Get(uint(id))
+	chapter, err := c.service.Get(ctx.Request.Context(), uint(id))
 	if err != nil {
-		ctx.JSON(http.StatusNotFound, gin.H{"error": err.Error()})
+		respondError(ctx, err)
 		return
 	}
 
@@ -56,14 +68,16 @@ func (c *Controller) Update(ctx *gin.Context) {
 		return
 	}
 
-	if err := c.service.Update(uint(id), &dto); err != nil {
-		ctx.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+	if err := c.service.Update(ctx.Request.Context(), uint(id), &dto); err != nil {
+		respondError(ctx, err)
 		return
 	}
 
 	ctx.JSON(http.StatusOK, gin.H{"status": "success"})
 }
 
+// Delete удаляет раздел; непустой раздел удаляется только с reassign_to -
+// разделом той же страницы, куда переносится содержимое
 func (c *Controller) Delete(ctx *gin.Context) {
 	id, err := strconv.ParseUint(ctx.Param("id"), 10, 32)
 	if err != nil {
@@ -71,16 +85,33 @@ func (c *Controller) Delete(ctx *gin.Context) {
 		return
 	}
 
-	if err := c.service.Delete(uint(id)); err != nil {
-		ctx.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+	var reassignTo *uint
+	if raw := ctx.Query("reassign_to"); raw != "" {
+		target, err := strconv.ParseUint(raw, 10, 32)
+		if err != nil {
+			ctx.JSON(http.StatusBadRequest, gin.H{"error": "invalid reassign_to"})
+			return
+		}
+		reassignTo = new(uint)
+		*reassignTo = uint(target)
+	}
+
+	if err := c.service.Delete(ctx.Request.Context(), uint(id), reassignTo); err != nil {
+		respondError(ctx, err)
 		return
 	}
 
 	ctx.JSON(http.StatusOK, gin.H{"status": "success"})
 }
 
+// GetAll - все разделы или разделы страницы page в порядке показа
 func (c *Controller) GetAll(ctx *gin.Context) {
-	chapters, err := c.service.GetAll()
+	if page := ctx.Query("page"); page != "" {
+		c.respondPage(ctx, enums.Page(page))
+		return
+	}
+
+	chapters, err := c.service.GetAll(ctx.Request.Context())
 	if err != nil {
 		ctx.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
 		return
@@ -89,8 +120,106 @@ func (c *Controller) GetAll(ctx *gin.Context) {
 	ctx.JSON(http.StatusOK, chapters)
 }
 
-func NewController(db *gorm.DB) *Controller {
+func (c *Controller) GetByPage(ctx *gin.Context) {
+	c.respondPage(ctx, enums.Page(ctx.Param("page")))
+}
+
+func (c *Controller) respondPage(ctx *gin.Context, page enums.Page) {
+	chapters, err := c.service.GetByPage(ctx.Request.Context(), page)
+	if err != nil {
+		ctx.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+
+	ctx.JSON(http.StatusOK, chapters)
+}
+
+// Reorder задаёт порядок всех разделов уровня и отвечает новым списком страницы
+func (c *Controller) Reorder(ctx *gin.Context) {
+	var dto ReorderDTO
+	if err := ctx.ShouldBindJSON(&dto); err != nil {
+		ctx.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	if err := c.service.Reorder(ctx.Request.Context(), &dto); err != nil {
+		respondError(ctx, err)
+		return
+	}
+	c.respondPage(ctx, dto.Page)
+}
+
+// Move ставит раздел перед или после другого раздела уровня и отвечает
+// новым порядком страницы
+func (c *Controller) Move(ctx *gin.Context) {
+	id, err := strconv.ParseUint(ctx.Param("id"), 10, 32)
+	if err != nil {
+		ctx.JSON(http.StatusBadRequest, gin.H{"error": "invalid id"})
+		return
+	}
+
+	var dto MoveDTO
+	if err := ctx.ShouldBindJSON(&dto); err != nil {
+		ctx.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	if err := c.service.Move(ctx.Request.Context(), uint(id), &dto); err != nil {
+		respondError(ctx, err)
+		return
+	}
+
+	chapter, err := c.service.Get(ctx.Request.Context(), uint(id))
+	if err != nil {
+		respondError(ctx, err)
+		return
+	}
+	c.respondPage(ctx, chapter.Page)
+}
+
+// Navigation - дерево меню по страницам с числом опубликованных записей;
+// page ограничивает ответ одной страницей
+func (c *Controller) Navigation(ctx *gin.Context) {
+	tree, err := c.service.Navigation(ctx.Request.Context(), enums.Page(ctx.Query("page")), time.Now())
+	if err != nil {
+		ctx.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+	ctx.JSON(http.StatusOK, tree)
+}
+
+// GetUsage - сколько новостей, альбомов, документов и вложенных разделов в разделе
+func (c *Controller) GetUsage(ctx *gin.Context) {
+	id, err := strconv.ParseUint(ctx.Param("id"), 10, 32)
+	if err != nil {
+		ctx.JSON(http.StatusBadRequest, gin.H{"error": "invalid id"})
+		return
+	}
+
+	usage, err := c.service.Usage(ctx.Request.Context(), uint(id))
+	if err != nil {
+		respondError(ctx, err)
+		return
+	}
+	ctx.JSON(http.StatusOK, usage)
+}
+
+func respondError(ctx *gin.Context, err error) {
+	switch {
+	case errors.Is(err, ErrNotFound):
+		ctx.JSON(http.StatusNotFound, gin.H{"error": err.Error()})
+	case errors.Is(err, ErrInUse), errors.Is(err, ErrHasChildren):
+		ctx.JSON(http.StatusConflict, gin.H{"error": err.Error()})
+	case errors.Is(err, ErrInvalidOrder), errors.Is(err, ErrInvalidMove), errors.Is(err, ErrInvalidReassign),
+		errors.Is(err, ErrParentNotFound), errors.Is(err, ErrPageNotFound), errors.Is(err, ErrInvalidParent), errors.Is(err, ErrParentCycle):
+		ctx.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+	default:
+		ctx.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+	}
+}
+
+func NewController(db *gorm.DB, logger *slog.Logger) *Controller {
 	return &Controller{
-		service: NewService(db),
+		service: NewService(db, logger),
 	}
 }
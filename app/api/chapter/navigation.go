@@ -0,0 +1,121 @@
+package chapter
+
+import (
+	"context"
+	"federation-backend/app/api/document"
+	"federation-backend/app/db/models"
+	"federation-backend/app/db/models/enums"
+	"fmt"
+	"time"
+
+	"gorm.io/gorm"
+)
+
+// NavigationItem - раздел в дереве меню. Published - опубликованные записи
+// самого раздела, Total - вместе с вложенными разделами
+type NavigationItem struct {
+	ID        uint              `json:"id"`
+	Name      string            `json:"name"`
+	BarIdx    uint              `json:"bar_idx"`
+	Published int64             `json:"published"`
+	Total     int64             `json:"total"`
+	Children  []*NavigationItem `json:"children"`
+}
+
+// Navigation строит дерево меню каждой страницы (или только page) на момент
+// at. Опубликованными считаются новости и альбомы с датой не позже at и
+// документы, действующие в at
+func (s *Service) Navigation(ctx context.Context, page enums.Page, at time.Time) (map[enums.Page][]*NavigationItem, error) {
+	db := s.db.WithContext(ctx)
+	var chapters []models.Chapter
+	query := db.Order("bar_idx ASC, id ASC")
+	if page != "" {
+		query = query.Where("page = ?", page)
+	}
+	if err := query.Find(&chapters).Error; err != nil {
+		return nil, fmt.Errorf("failed to get chapters: %w", err)
+	}
+
+	published, err := publishedCounts(db, at.UTC())
+	if err != nil {
+		return nil, err
+	}
+
+	items := make(map[uint]*NavigationItem, len(chapters))
+	for _, chapter := range chapters {
+		items[chapter.Id] = &NavigationItem{
+			ID:        chapter.Id,
+			Name:      chapter.Name,
+			BarIdx:    chapter.BarIdx,
+			Published: published[chapter.Id],
+			Children:  []*NavigationItem{},
+		}
+	}
+
+	// Страницы без разделов тоже попадают в ответ, с пустым списком
+	var pages []enums.Page
+	pagesQuery := db.Model(&models.Page{})
+	if page != "" {
+		pagesQuery = pagesQuery.Where("slug = ?", page)
+	}
+	if err := pagesQuery.Pluck("slug", &pages).Error; err != nil {
+		return nil, fmt.Errorf("failed to get pages: %w", err)
+	}
+	tree := make(map[enums.Page][]*NavigationItem, len(pages))
+	for _, slug := range pages {
+		tree[slug] = []*NavigationItem{}
+	}
+	for _, chapter := range chapters {
+		item := items[chapter.Id]
+		// Раздел без найденного родителя показывается на верхнем уровне
+		if chapter.ParentID != nil {
+			if parent, ok := items[*chapter.ParentID]; ok {
+				parent.Children = append(parent.Children, item)
+				continue
+			}
+		}
+		tree[chapter.Page] = append(tree[chapter.Page], item)
+	}
+	for _, roots := range tree {
+		for _, root := range roots {
+			countTotal(root, map[uint]bool{})
+		}
+	}
+	return tree, nil
+}
+
+func countTotal(item *NavigationItem, visited map[uint]bool) int64 {
+	if visited[item.ID] {
+		return 0
+	}
+	visited[item.ID] = true
+	item.Total = item.Published
+	for _, child := range item.Children {
+		item.Total += countTotal(child, visited)
+	}
+	return item.Total
+}
+
+// publishedCounts - число опубликованных записей по chapter_id
+func publishedCounts(db *gorm.DB, at time.Time) (map[uint]int64, error) {
+	sources := []*gorm.DB{
+		db.Model(&models.News{}).Where("date <= ?", at),
+		db.Model(&models.GalleryItem{}).Where("date <= ?", at),
+		db.Model(&models.Document{}).Scopes(document.EffectiveAt(at)),
+	}
+
+	counts := map[uint]int64{}
+	for _, source := range sources {
+		var rows []struct {
+			ChapterID uint
+			Count     int64
+		}
+		if err := source.Select("chapter_id, COUNT(*) AS count").Group("chapter_id").Scan(&rows).Error; err != nil {
+			return nil, fmt.Errorf("failed to count published items: %w", err)
+		}
+		for _, row := range rows {
+			counts[row.ChapterID] += row.Count
+		}
+	}
+	return counts, nil
+}
@@ -1,68 +1,151 @@
 package chapter
 
 import (
+	"context"
 	"errors"
 	"federation-backend/app/db/models"
 	"federation-backend/app/db/models/enums"
+	"federation-backend/app/logging"
 	"fmt"
+	"log/slog"
+	"slices"
 
 	"gorm.io/gorm"
 )
 
+var (
+	ErrNotFound = errors.New("chapter not found")
+	// ErrInUse - в разделе есть новости, альбомы или документы
+	ErrInUse       = errors.New("chapter has news, gallery items or documents")
+	ErrHasChildren = errors.New("chapter has nested chapters")
+	// ErrInvalidOrder - список порядка не совпадает с разделами уровня
+	ErrInvalidOrder = errors.New("order must list every chapter of the level exactly once")
+	ErrInvalidMove  = errors.New("exactly one of before and after must be set to another chapter of the same level")
+	// ErrInvalidReassign - переносить содержимое можно только в другой раздел той же страницы
+	ErrInvalidReassign = errors.New("reassign target must be another chapter of the same page")
+	ErrParentNotFound  = errors.New("parent chapter not found")
+	ErrPageNotFound    = errors.New("page not found")
+	// ErrInvalidParent - вложенный раздел показывается на странице родителя
+	ErrInvalidParent = errors.New("parent chapter must be on the same page")
+	ErrParentCycle   = errors.New("chapter cannot be nested into itself or its descendant")
+)
+
 type CreateChapterDTO struct {
-	Name   string     `form:"name" binding:"required"`
-	Page   enums.Page `form:"page" binding:"required"`
-	BarIdx *uint      `form:"bar_idx,omitempty"`
+	Name     string     `form:"name" json:"name" binding:"required"`
+	Page     enums.Page `form:"page" json:"page" binding:"required"`
+	BarIdx   *uint      `form:"bar_idx,omitempty" json:"bar_idx"`
+	ParentID *uint      `form:"parent_id" json:"parent_id"`
 }
 
+// UpdateChapterDTO: parent_id=0 переносит раздел на верхний уровень,
+// смена page без parent_id - на верхний уровень новой страницы
 type UpdateChapterDTO struct {
-	Name   *string     `form:"name"`
-	BarIdx *uint       `form:"bar_idx"`
-	Page   *enums.Page `form:"page"`
+	Name     *string     `form:"name" json:"name"`
+	BarIdx   *uint       `form:"bar_idx" json:"bar_idx"`
+	Page     *enums.Page `form:"page" json:"page"`
+	ParentID *uint       `form:"parent_id" json:"parent_id"`
+}
+
+// ReorderDTO - полный порядок разделов одного уровня: ids в порядке показа.
+// Без parent_id - верхний уровень страницы
+type ReorderDTO struct {
+	Page     enums.Page `json:"page" binding:"required"`
+	ParentID *uint      `json:"parent_id"`
+	IDs      []uint     `json:"ids" binding:"required"`
+}
+
+// MoveDTO ставит раздел перед before или после after
+type MoveDTO struct {
+	Before *uint `json:"before"`
+	After  *uint `json:"after"`
+}
+
+// Usage - сколько записей ссылается на раздел
+type Usage struct {
+	News         int64 `json:"news"`
+	GalleryItems int64 `json:"gallery_items"`
+	Documents    int64 `json:"documents"`
+	Children     int64 `json:"children"`
+}
+
+// Total - записи раздела без вложенных разделов
+func (u Usage) Total() int64 {
+	return u.News + u.GalleryItems + u.Documents
+}
+
+func (u Usage) inUse() error {
+	return fmt.Errorf("%w: %d news, %d gallery items, %d documents", ErrInUse, u.News, u.GalleryItems, u.Documents)
+}
+
+// referencing - таблицы, записи которых относятся к разделу через chapter_id
+var referencing = []any{&models.News{}, &models.GalleryItem{}, &models.Document{}}
+
+// level - разделы с общим родителем на одной странице, внутри него
+// bar_idx идут с 1 без пропусков
+type level struct {
+	page     enums.Page
+	parentID *uint
+}
+
+func levelOf(chapter models.Chapter) level {
+	return level{page: chapter.Page, parentID: chapter.ParentID}
+}
+
+func (l level) equal(other level) bool {
+	if l.page != other.page || (l.parentID == nil) != (other.parentID == nil) {
+		return false
+	}
+	return l.parentID == nil || *l.parentID == *other.parentID
+}
+
+func (l level) query(db *gorm.DB) *gorm.DB {
+	db = db.Model(&models.Chapter{}).Where("page = ?", l.page)
+	if l.parentID == nil {
+		return db.Where("parent_id IS NULL")
+	}
+	return db.Where("parent_id = ?", *l.parentID)
 }
 
 type Service struct {
-	db *gorm.DB
+	db     *gorm.DB
+	logger *slog.Logger
 }
 
-func (s *Service) Create(dto interface{}) error {
+func (s *Service) Create(ctx context.Context, dto interface{}) error {
 	createDTO, ok := dto.(*CreateChapterDTO)
 	if !ok {
 		return errors.New("invalid DTO type")
 	}
 
-	return s.db.Transaction(func(tx *gorm.DB) error {
-		// Определяем порядок для новой главы
-		var barIdx uint = 1
-
-		// Если указан явный индекс, проверяем и освобождаем место
-		if createDTO.BarIdx != nil {
-			barIdx = *createDTO.BarIdx
+	return s.db.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		if err := pageExists(tx, createDTO.Page); err != nil {
+			return err
+		}
 
-			// Освобождаем место: сдвигаем существующие элементы вниз
-			if err := tx.Model(&models.Chapter{}).
-				Where("page = ? AND bar_idx >= ?", createDTO.Page, barIdx).
-				Update("bar_idx", gorm.Expr("bar_idx + 1")).Error; err != nil {
-				return fmt.Errorf("failed to reorder chapters: %w", err)
+		target := level{page: createDTO.Page}
+		if createDTO.ParentID != nil {
+			var parent models.Chapter
+			if err := findParent(tx, *createDTO.ParentID, &parent); err != nil {
+				return err
 			}
-		} else {
-			// Если индекс не указан, ставим в конец
-			var maxIdx uint
-			err := tx.Model(&models.Chapter{}).
-				Where("page = ?", createDTO.Page).
-				Select("COALESCE(MAX(bar_idx), 0)").
-				Scan(&maxIdx).Error
-			if err != nil {
-				return fmt.Errorf("failed to get max bar_idx: %w", err)
+			if parent.Page != createDTO.Page {
+				return ErrInvalidParent
 			}
-			barIdx = maxIdx + 1
+			target.parentID = &parent.Id
+		}
+
+		// Без явного индекса глава встаёт в конец, индекс за концом тоже означает конец
+		barIdx, err := insertAt(tx, target, createDTO.BarIdx)
+		if err != nil {
+			return err
 		}
 
 		// Создаем главу
 		chapter := models.Chapter{
-			Name:   createDTO.Name,
-			Page:   createDTO.Page,
-			BarIdx: barIdx,
+			Name:     createDTO.Name,
+			Page:     createDTO.Page,
+			BarIdx:   barIdx,
+			ParentID: target.parentID,
 		}
 
 		if err := tx.Create(&chapter).Error; err != nil {
@@ -73,95 +156,102 @@ func (s *Service) Create(dto interface{}) error {
 	})
 }
 
-func (s *Service) Get(id uint) (models.Chapter, error) {
+func (s *Service) Get(ctx context.Context, id uint) (models.Chapter, error) {
 	var chapter models.Chapter
-	err := s.db.First(&chapter, id).Error
-	if err != nil {
-		if errors.Is(err, gorm.ErrRecordNotFound) {
-			return models.Chapter{}, errors.New("chapter not found")
-		}
-		return models.Chapter{}, fmt.Errorf("failed to get chapter: %w", err)
+	if err := find(s.db.WithContext(ctx), id, &chapter); err != nil {
+		return models.Chapter{}, err
 	}
 	return chapter, nil
 }
 
-func (s *Service) Update(id uint, dto interface{}) error {
+func (s *Service) Update(ctx context.Context, id uint, dto interface{}) error {
 	updateDTO, ok := dto.(*UpdateChapterDTO)
 	if !ok {
 		return errors.New("invalid DTO type")
 	}
 
-	return s.db.Transaction(func(tx *gorm.DB) error {
+	return s.db.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
 		// Получаем текущую главу
 		var chapter models.Chapter
-		if err := tx.First(&chapter, id).Error; err != nil {
-			return fmt.Errorf("chapter not found: %w", err)
+		if err := find(tx, id, &chapter); err != nil {
+			return err
 		}
 
-		oldBarIdx := chapter.BarIdx
-		oldPage := chapter.Page
-		newBarIdx := updateDTO.BarIdx
-		newPage := updateDTO.Page
-
 		// Обновляем поля, если они переданы
 		if updateDTO.Name != nil {
 			chapter.Name = *updateDTO.Name
 		}
 
-		// Обрабатываем изменение порядка и/или страницы
-		if newBarIdx != nil || newPage != nil {
-			// Определяем новые значения
-			targetBarIdx := oldBarIdx
-			targetPage := oldPage
+		current := levelOf(chapter)
+		target, err := s.targetLevel(tx, chapter, updateDTO)
+		if err != nil {
+			return err
+		}
 
-			if newBarIdx != nil {
-				targetBarIdx = *newBarIdx
+		if !target.equal(current) {
+			if target.page != current.page {
+				if err := pageExists(tx, target.page); err != nil {
+					return err
+				}
+
+				// Новости, альбомы и документы показываются только на своей странице,
+				// вложенные разделы - на странице родителя
+				usage, err := countUsage(tx, id)
+				if err != nil {
+					return err
+				}
+				if usage.Total() > 0 {
+					return usage.inUse()
+				}
+				if usage.Children > 0 {
+					return ErrHasChildren
+				}
 			}
-			if newPage != nil {
-				targetPage = *newPage
+
+			// 1. Удаляем главу со старого уровня (сдвигаем остальные вверх)
+			if err := current.query(tx).
+				Where("bar_idx > ?", chapter.BarIdx).
+				Update("bar_idx", gorm.Expr("bar_idx - 1")).Error; err != nil {
+				return fmt.Errorf("failed to reorder old level: %w", err)
 			}
 
-			// Если страница изменилась
-			if newPage != nil && *newPage != oldPage {
-				// 1. Удаляем главу со старой страницы (сдвигаем остальные вверх)
-				if err := tx.Model(&models.Chapter{}).
-					Where("page = ? AND bar_idx > ?", oldPage, oldBarIdx).
-					Update("bar_idx", gorm.Expr("bar_idx - 1")).Error; err != nil {
-					return fmt.Errorf("failed to reorder old page: %w", err)
-				}
+			// 2. Освобождаем место на новом уровне (сдвигаем вниз)
+			barIdx, err := insertAt(tx, target, updateDTO.BarIdx)
+			if err != nil {
+				return err
+			}
 
-				// 2. Освобождаем место на новой странице (сдвигаем вниз)
-				if err := tx.Model(&models.Chapter{}).
-					Where("page = ? AND bar_idx >= ?", targetPage, targetBarIdx).
+			chapter.Page = target.page
+			chapter.ParentID = target.parentID
+			chapter.BarIdx = barIdx
+		} else if updateDTO.BarIdx != nil && *updateDTO.BarIdx != chapter.BarIdx {
+			// Только индекс изменился, уровень тот же
+			oldBarIdx := chapter.BarIdx
+			last, err := lastBarIdx(tx, current)
+			if err != nil {
+				return err
+			}
+			targetBarIdx := min(max(*updateDTO.BarIdx, 1), last)
+			if targetBarIdx < oldBarIdx {
+				// Сдвигаем вниз элементы между новым и старым индексом
+				if err := current.query(tx).
+					Where("bar_idx >= ? AND bar_idx < ?", targetBarIdx, oldBarIdx).
 					Update("bar_idx", gorm.Expr("bar_idx + 1")).Error; err != nil {
-					return fmt.Errorf("failed to reorder new page: %w", err)
+					return fmt.Errorf("failed to reorder chapters up: %w", err)
 				}
-
-				chapter.Page = targetPage
-				chapter.BarIdx = targetBarIdx
-			} else if newBarIdx != nil && *newBarIdx != oldBarIdx {
-				// Только индекс изменился, страница та же
-				if *newBarIdx < oldBarIdx {
-					// Сдвигаем вниз элементы между новым и старым индексом
-					if err := tx.Model(&models.Chapter{}).
-						Where("page = ? AND bar_idx >= ? AND bar_idx < ?", oldPage, *newBarIdx, oldBarIdx).
-						Update("bar_idx", gorm.Expr("bar_idx + 1")).Error; err != nil {
-						return fmt.Errorf("failed to reorder chapters up: %w", err)
-					}
-				} else {
-					// Сдвигаем вверх элементы между старым и новым индексом
-					if err := tx.Model(&models.Chapter{}).
-						Where("page = ? AND bar_idx > ? AND bar_idx <= ?", oldPage, oldBarIdx, *newBarIdx).
-						Update("bar_idx", gorm.Expr("bar_idx - 1")).Error; err != nil {
-						return fmt.Errorf("failed to reorder chapters down: %w", err)
-					}
+			} else if targetBarIdx > oldBarIdx {
+				// Сдвигаем вверх элементы между старым и новым индексом
+				if err := current.query(tx).
+					Where("bar_idx > ? AND bar_idx <= ?", oldBarIdx, targetBarIdx).
+					Update("bar_idx", gorm.Expr("bar_idx - 1")).Error; err != nil {
+					return fmt.Errorf("failed to reorder chapters down: %w", err)
 				}
-				chapter.BarIdx = *newBarIdx
 			}
+			chapter.BarIdx = targetBarIdx
 		}
 
 		// Сохраняем изменения
-		if err := tx.Save(&chapter).Error; err != nil {
+		if err := tx.Omit("Parent").Save(&chapter).Error; err != nil {
 			return fmt.Errorf("failed to update chapter: %w", err)
 		}
 
@@ -169,12 +259,74 @@ func (s *Service) Update(id uint, dto interface{}) error {
 	})
 }
 
-func (s *Service) Delete(id uint) error {
-	return s.db.Transaction(func(tx *gorm.DB) error {
+// targetLevel - уровень, на котором окажется глава после обновления
+func (s *Service) targetLevel(tx *gorm.DB, chapter models.Chapter, dto *UpdateChapterDTO) (level, error) {
+	target := levelOf(chapter)
+	switch {
+	case dto.ParentID != nil && *dto.ParentID == 0:
+		target.parentID = nil
+		if dto.Page != nil {
+			target.page = *dto.Page
+		}
+	case dto.ParentID != nil:
+		var parent models.Chapter
+		if err := findParent(tx, *dto.ParentID, &parent); err != nil {
+			return level{}, err
+		}
+		if dto.Page != nil && *dto.Page != parent.Page {
+			return level{}, ErrInvalidParent
+		}
+		if err := checkCycle(tx, chapter.Id, parent); err != nil {
+			return level{}, err
+		}
+		target = level{page: parent.Page, parentID: &parent.Id}
+	case dto.Page != nil && *dto.Page != chapter.Page:
+		target = level{page: *dto.Page}
+	}
+	return target, nil
+}
+
+// Delete удаляет главу без вложенных разделов. Если в ней есть новости,
+// альбомы или документы, они переносятся в раздел reassignTo той же
+// страницы, без него - ErrInUse
+func (s *Service) Delete(ctx context.Context, id uint, reassignTo *uint) error {
+	return s.db.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
 		// Получаем удаляемую главу
 		var chapter models.Chapter
-		if err := tx.First(&chapter, id).Error; err != nil {
-			return fmt.Errorf("chapter not found: %w", err)
+		if err := find(tx, id, &chapter); err != nil {
+			return err
+		}
+
+		usage, err := countUsage(tx, id)
+		if err != nil {
+			return err
+		}
+		if usage.Children > 0 {
+			return ErrHasChildren
+		}
+		if reassignTo != nil {
+			var target models.Chapter
+			if err := find(tx, *reassignTo, &target); err != nil {
+				if errors.Is(err, ErrNotFound) {
+					return ErrInvalidReassign
+				}
+				return err
+			}
+			if target.Id == chapter.Id || target.Page != chapter.Page {
+				return ErrInvalidReassign
+			}
+			for _, model := range referencing {
+				if err := tx.Model(model).Where("chapter_id = ?", id).
+					Update("chapter_id", target.Id).Error; err != nil {
+					return fmt.Errorf("failed to reassign chapter content: %w", err)
+				}
+			}
+			if usage.Total() > 0 {
+				s.logger.InfoContext(ctx, "chapter content reassigned", logging.Entity("chapter", id), slog.Uint64("target_id", uint64(target.Id)),
+					slog.Int64("news", usage.News), slog.Int64("gallery_items", usage.GalleryItems), slog.Int64("documents", usage.Documents))
+			}
+		} else if usage.Total() > 0 {
+			return usage.inUse()
 		}
 
 		// Удаляем главу
@@ -182,9 +334,9 @@ func (s *Service) Delete(id uint) error {
 			return fmt.Errorf("failed to delete chapter: %w", err)
 		}
 
-		// Сдвигаем остальные главы на странице вверх
-		if err := tx.Model(&models.Chapter{}).
-			Where("page = ? AND bar_idx > ?", chapter.Page, chapter.BarIdx).
+		// Сдвигаем остальные главы уровня вверх
+		if err := levelOf(chapter).query(tx).
+			Where("bar_idx > ?", chapter.BarIdx).
 			Update("bar_idx", gorm.Expr("bar_idx - 1")).Error; err != nil {
 			return fmt.Errorf("failed to reorder after delete: %w", err)
 		}
@@ -193,86 +345,236 @@ func (s *Service) Delete(id uint) error {
 	})
 }
 
-func (s *Service) GetAll() ([]models.Chapter, error) {
+// GetAll возвращает все главы по страницам в порядке показа
+func (s *Service) GetAll(ctx context.Context) ([]models.Chapter, error) {
 	var chapters []models.Chapter
-	if err := s.db.Find(&chapters).Error; err != nil {
+	if err := s.db.WithContext(ctx).Order("page, bar_idx, id").Find(&chapters).Error; err != nil {
 		return nil, fmt.Errorf("failed to get chapters: %w", err)
 	}
 	return chapters, nil
 }
 
-// GetByPage возвращает главы для конкретной страницы в правильном порядке
-func (s *Service) GetByPage(page enums.Page) ([]models.Chapter, error) {
+// GetByPage возвращает главы страницы всех уровней, упорядоченные внутри
+// уровня; дерево собирает Navigation
+func (s *Service) GetByPage(ctx context.Context, page enums.Page) ([]models.Chapter, error) {
 	var chapters []models.Chapter
-	if err := s.db.
+	if err := s.db.WithContext(ctx).
 		Where("page = ?", page).
-		Order("bar_idx ASC").
+		Order("bar_idx ASC, id ASC").
 		Find(&chapters).Error; err != nil {
 		return nil, fmt.Errorf("failed to get chapters by page: %w", err)
 	}
 	return chapters, nil
 }
 
-// Reorder полностью пересортирует порядок глав на странице
-func (s *Service) Reorder(page enums.Page, chapterIDs []uint) error {
-	return s.db.Transaction(func(tx *gorm.DB) error {
-		// Получаем все главы на странице
-		var chapters []models.Chapter
-		if err := tx.Where("page = ?", page).Find(&chapters).Error; err != nil {
-			return fmt.Errorf("failed to get chapters: %w", err)
+// Usage считает новости, альбомы, документы и вложенные разделы главы
+func (s *Service) Usage(ctx context.Context, id uint) (*Usage, error) {
+	db := s.db.WithContext(ctx)
+	if err := find(db, id, &models.Chapter{}); err != nil {
+		return nil, err
+	}
+	return countUsage(db, id)
+}
+
+// Reorder полностью пересортирует порядок глав одного уровня страницы.
+// chapterIDs должен перечислять все главы уровня ровно по одному разу
+func (s *Service) Reorder(ctx context.Context, dto *ReorderDTO) error {
+	return s.db.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		target := level{page: dto.Page, parentID: dto.ParentID}
+		if dto.ParentID != nil {
+			if err := findParent(tx, *dto.ParentID, &models.Chapter{}); err != nil {
+				return err
+			}
 		}
 
-		// Создаем мапу для быстрого доступа
-		chapterMap := make(map[uint]*models.Chapter)
-		for i := range chapters {
-			chapterMap[chapters[i].Id] = &chapters[i]
+		// Получаем все главы уровня
+		chapters, err := byLevel(tx, target)
+		if err != nil {
+			return err
 		}
 
-		// Обновляем индексы согласно новому порядку
-		for newIdx, chapterID := range chapterIDs {
-			if chapter, exists := chapterMap[chapterID]; exists {
-				chapter.BarIdx = uint(newIdx + 1)
-				if err := tx.Save(chapter).Error; err != nil {
-					return fmt.Errorf("failed to update chapter order: %w", err)
-				}
-			}
+		current := make([]uint, 0, len(chapters))
+		for _, chapter := range chapters {
+			current = append(current, chapter.Id)
+		}
+		requested := slices.Clone(dto.IDs)
+		slices.Sort(current)
+		slices.Sort(requested)
+		if !slices.Equal(current, requested) {
+			return ErrInvalidOrder
 		}
 
-		return nil
+		return renumber(tx, chapters, dto.IDs)
 	})
 }
 
-// GetNavbarOrder возвращает порядок элементов в навбаре по страницам
-func (s *Service) GetNavbarOrder() (map[enums.Page][]models.Chapter, error) {
-	chapters, err := s.GetAll()
-	if err != nil {
-		return nil, err
+// Move ставит главу непосредственно перед или после другой главы того же уровня
+func (s *Service) Move(ctx context.Context, id uint, dto *MoveDTO) error {
+	if (dto.Before == nil) == (dto.After == nil) {
+		return ErrInvalidMove
+	}
+	anchor, after := dto.Before, false
+	if dto.After != nil {
+		anchor, after = dto.After, true
+	}
+	if *anchor == id {
+		return ErrInvalidMove
 	}
 
-	result := make(map[enums.Page][]models.Chapter)
-	for _, chapter := range chapters {
-		result[chapter.Page] = append(result[chapter.Page], chapter)
+	return s.db.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		var chapter models.Chapter
+		if err := find(tx, id, &chapter); err != nil {
+			return err
+		}
+		chapters, err := byLevel(tx, levelOf(chapter))
+		if err != nil {
+			return err
+		}
+
+		order := make([]uint, 0, len(chapters))
+		for _, other := range chapters {
+			if other.Id != id {
+				order = append(order, other.Id)
+			}
+		}
+		position := slices.Index(order, *anchor)
+		if position < 0 {
+			return ErrInvalidMove
+		}
+		if after {
+			position++
+		}
+		return renumber(tx, chapters, slices.Insert(order, position, id))
+	})
+}
+
+func find(db *gorm.DB, id uint, chapter *models.Chapter) error {
+	if err := db.First(chapter, id).Error; err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return ErrNotFound
+		}
+		return fmt.Errorf("failed to get chapter: %w", err)
 	}
+	return nil
+}
 
-	// Сортируем каждую страницу по BarIdx
-	for page := range result {
-		// Используем пузырьковую сортировку для простоты
-		chapters := result[page]
-		for i := 0; i < len(chapters); i++ {
-			for j := i + 1; j < len(chapters); j++ {
-				if chapters[i].BarIdx > chapters[j].BarIdx {
-					chapters[i], chapters[j] = chapters[j], chapters[i]
-				}
+func pageExists(db *gorm.DB, page enums.Page) error {
+	var count int64
+	if err := db.Model(&models.Page{}).Where("slug = ?", page).Count(&count).Error; err != nil {
+		return fmt.Errorf("failed to check page: %w", err)
+	}
+	if count == 0 {
+		return ErrPageNotFound
+	}
+	return nil
+}
+
+func findParent(db *gorm.DB, id uint, parent *models.Chapter) error {
+	err := find(db, id, parent)
+	if errors.Is(err, ErrNotFound) {
+		return ErrParentNotFound
+	}
+	return err
+}
+
+// checkCycle проверяет, что parent не сама глава id и не вложен в неё
+func checkCycle(db *gorm.DB, id uint, parent models.Chapter) error {
+	visited := map[uint]bool{}
+	for {
+		if parent.Id == id {
+			return ErrParentCycle
+		}
+		if parent.ParentID == nil || visited[parent.Id] {
+			return nil
+		}
+		visited[parent.Id] = true
+		// Новая структура: First добавил бы к условию id уже загруженной главы
+		var next models.Chapter
+		if err := find(db, *parent.ParentID, &next); err != nil {
+			if errors.Is(err, ErrNotFound) {
+				return nil
 			}
+			return err
+		}
+		parent = next
+	}
+}
+
+func byLevel(db *gorm.DB, l level) ([]models.Chapter, error) {
+	var chapters []models.Chapter
+	if err := l.query(db).Order("bar_idx ASC, id ASC").Find(&chapters).Error; err != nil {
+		return nil, fmt.Errorf("failed to get chapters: %w", err)
+	}
+	return chapters, nil
+}
+
+func lastBarIdx(db *gorm.DB, l level) (uint, error) {
+	var last uint
+	err := l.query(db).
+		Select("COALESCE(MAX(bar_idx), 0)").
+		Scan(&last).Error
+	if err != nil {
+		return 0, fmt.Errorf("failed to get max bar_idx: %w", err)
+	}
+	return last, nil
+}
+
+// insertAt освобождает на уровне место barIdx и возвращает его. Без barIdx
+// или с barIdx за концом место - в конце уровня
+func insertAt(tx *gorm.DB, l level, barIdx *uint) (uint, error) {
+	last, err := lastBarIdx(tx, l)
+	if err != nil {
+		return 0, err
+	}
+	if barIdx == nil || *barIdx > last {
+		return last + 1, nil
+	}
+
+	target := max(*barIdx, 1)
+	if err := l.query(tx).
+		Where("bar_idx >= ?", target).
+		Update("bar_idx", gorm.Expr("bar_idx + 1")).Error; err != nil {
+		return 0, fmt.Errorf("failed to reorder chapters: %w", err)
+	}
+	return target, nil
+}
+
+// renumber выставляет главам уровня bar_idx 1..n в порядке order,
+// записывая только изменившиеся
+func renumber(tx *gorm.DB, chapters []models.Chapter, order []uint) error {
+	current := make(map[uint]uint, len(chapters))
+	for _, chapter := range chapters {
+		current[chapter.Id] = chapter.BarIdx
+	}
+	for i, id := range order {
+		barIdx := uint(i + 1)
+		if current[id] == barIdx {
+			continue
+		}
+		if err := tx.Model(&models.Chapter{}).Where("id = ?", id).Update("bar_idx", barIdx).Error; err != nil {
+			return fmt.Errorf("failed to update chapter order: %w", err)
 		}
-		result[page] = chapters
 	}
+	return nil
+}
 
+func countUsage(db *gorm.DB, id uint) (*Usage, error) {
+	result := &Usage{}
+	counts := []*int64{&result.News, &result.GalleryItems, &result.Documents}
+	for i, model := range referencing {
+		if err := db.Model(model).Where("chapter_id = ?", id).Count(counts[i]).Error; err != nil {
+			return nil, fmt.Errorf("failed to count chapter content: %w", err)
+		}
+	}
+	if err := db.Model(&models.Chapter{}).Where("parent_id = ?", id).Count(&result.Children).Error; err != nil {
+		return nil, fmt.Errorf("failed to count nested chapters: %w", err)
+	}
 	return result, nil
 }
 
-func NewService(db *gorm.DB) *Service {
+func NewService(db *gorm.DB, logger *slog.Logger) *Service {
 	return &Service{
-		db: db,
+		db:     db,
+		logger: logger,
 	}
 }
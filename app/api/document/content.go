@@ -0,0 +1,275 @@
+package document
+
+import (
+	"context"
+	"errors"
+	"federation-backend/app/api/shared/extract"
+	"federation-backend/app/db/models"
+	"federation-backend/app/logging"
+	"fmt"
+	"log/slog"
+	"os"
+	"path/filepath"
+	"strings"
+	"unicode/utf8"
+
+	"gorm.io/gorm"
+)
+
+const (
+	DefaultSearchLimit = 20
+	MaxSearchLimit     = 100
+	// snippetContext - сколько символов текста показывать вокруг совпадения
+	snippetContext = 80
+)
+
+var ErrEmptyQuery = errors.New("search query is empty")
+
+// likeEscaper экранирует спецсимволы LIKE; в запросах используется ESCAPE '!',
+// одинаково понятный MySQL, Postgres и SQLite
+var likeEscaper = strings.NewReplacer("!", "!!", "%", "!%", "_", "!_")
+
+type SearchQuery struct {
+	Query     string
+	ChapterID uint
+	Limit     int
+}
+
+// SearchResult - найденный документ и фрагмент текста вокруг первого совпадения.
+// Snippet пуст, если совпало только название
+type SearchResult struct {
+	Document models.Document `json:"document"`
+	Snippet  string          `json:"snippet"`
+}
+
+type ReindexReport struct {
+	Files   int `json:"files"`
+	Indexed int `json:"indexed"`
+	Failed  int `json:"failed"`
+}
+
+// withContent подгружает сведения о файле без текста: он нужен только поиску
+func withContent(db *gorm.DB) *gorm.DB {
+	return db.Preload("Content", func(db *gorm.DB) *gorm.DB {
+		return db.Omit("text", "search_text")
+	}).Preload("Content.Preview")
+}
+
+// Search ищет документы, в тексте или названии которых есть query.Query,
+// без учёта регистра. Новые документы первыми
+func (s *Service) Search(ctx context.Context, query SearchQuery) ([]SearchResult, error) {
+	needle := strings.ToLower(extract.Normalize(query.Query))
+	if needle == "" {
+		return nil, ErrEmptyQuery
+	}
+	limit := query.Limit
+	if limit <= 0 || limit > MaxSearchLimit {
+		limit = DefaultSearchLimit
+	}
+
+	pattern := "%" + likeEscaper.Replace(needle) + "%"
+	db := withContent(s.db.WithContext(ctx)).Preload("File").Preload("Chapter").
+		Joins("LEFT JOIN document_contents ON document_contents.file_id = documents.file_id").
+		Where("document_contents.search_text LIKE ? ESCAPE '!' OR LOWER(documents.name) LIKE ? ESCAPE '!'", pattern, pattern)
+	if query.ChapterID != 0 {
+		db = db.Where("documents.chapter_id = ?", query.ChapterID)
+	}
+	var documents []models.Document
+	if err := db.Order("documents.id DESC").Limit(limit).Find(&documents).Error; err != nil {
+		return nil, fmt.Errorf("failed to search documents: %w", err)
+	}
+
+	fileIDs := make([]uint, 0, len(documents))
+	for _, document := range documents {
+		fileIDs = append(fileIDs, document.FileID)
+	}
+	var texts []models.DocumentContent
+	err := s.db.WithContext(ctx).Select("file_id", "text", "search_text").
+		Where("file_id IN ?", fileIDs).Find(&texts).Error
+	if err != nil {
+		return nil, fmt.Errorf("failed to read document texts: %w", err)
+	}
+	byFile := make(map[uint]models.DocumentContent, len(texts))
+	for _, text := range texts {
+		byFile[text.FileID] = text
+	}
+
+	results := make([]SearchResult, 0, len(documents))
+	for _, document := range documents {
+		text := byFile[document.FileID]
+		results = append(results, SearchResult{
+			Document: document,
+			Snippet:  snippet(text.Text, text.SearchText, needle),
+		})
+	}
+	return results, nil
+}
+
+// snippet вырезает из text фрагмент вокруг первого вхождения needle в lower.
+// lower - text в нижнем регистре, strings.ToLower меняет символы один к
+// одному, поэтому позиции в символах у них совпадают
+func snippet(text, lower, needle string) string {
+	index := strings.Index(lower, needle)
+	if index < 0 {
+		return ""
+	}
+	runes := []rune(text)
+	start := utf8.RuneCountInString(lower[:index])
+	end := start + utf8.RuneCountInString(needle)
+	from, to := max(0, start-snippetContext), min(len(runes), end+snippetContext)
+	if from >= to {
+		return ""
+	}
+
+	fragment := string(runes[from:to])
+	if from > 0 {
+		fragment = "…" + fragment
+	}
+	if to < len(runes) {
+		fragment += "…"
+	}
+	return fragment
+}
+
+// Reindex заново разбирает файлы текущих версий всех документов, например
+// загруженных до появления разбора
+func (s *Service) Reindex(ctx context.Context) (*ReindexReport, error) {
+	var files []models.File
+	err := s.db.WithContext(ctx).
+		Where("id IN (?)", s.db.Model(&models.Document{}).Select("file_id")).
+		Order("id").Find(&files).Error
+	if err != nil {
+		return nil, fmt.Errorf("failed to get document files: %w", err)
+	}
+
+	report := &ReindexReport{}
+	for _, file := range files {
+		if ctx.Err() != nil {
+			return nil, ctx.Err()
+		}
+		err := s.indexFile(ctx, file)
+		if errors.Is(err, extract.ErrUnsupportedFormat) {
+			continue
+		}
+		report.Files++
+		if err != nil {
+			report.Failed++
+			s.logger.WarnContext(ctx, "failed to index document file", logging.Entity("file", file.Id), logging.Error(err))
+			continue
+		}
+		report.Indexed++
+	}
+
+	s.logger.InfoContext(ctx, "documents reindexed", slog.Int("files", report.Files), slog.Int("failed", report.Failed))
+	return report, nil
+}
+
+// index разбирает только что загруженный файл документа. Ошибка разбора не
+// отменяет загрузку: документ остаётся без сведений, их можно получить позже
+// через Reindex
+func (s *Service) index(ctx context.Context, file models.File) {
+	err := s.indexFile(ctx, file)
+	if err != nil && !errors.Is(err, extract.ErrUnsupportedFormat) {
+		s.logger.WarnContext(ctx, "failed to index document file", logging.Entity("file", file.Id), logging.Error(err))
+	}
+}
+
+// indexFile извлекает из файла метаданные и текст, рисует превью и заменяет
+// ими прежние сведения о файле
+func (s *Service) indexFile(ctx context.Context, file models.File) error {
+	path := s.fileService.GetFilePath(filepath.Base(file.Path))
+	result, err := readFile(path, file.Name)
+	if err != nil {
+		return err
+	}
+
+	content := models.DocumentContent{
+		FileID:     file.Id,
+		PageCount:  result.PageCount,
+		Title:      result.Title,
+		Author:     result.Author,
+		Text:       result.Text,
+		SearchText: strings.ToLower(result.Text),
+	}
+	preview := s.preview(ctx, path, file, result)
+	if preview != nil {
+		content.PreviewID = &preview.Id
+	}
+
+	var previous []models.DocumentContent
+	err = s.db.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		if err := tx.Preload("Preview").Where("file_id = ?", file.Id).Find(&previous).Error; err != nil {
+			return err
+		}
+		if err := tx.Where("file_id = ?", file.Id).Delete(&models.DocumentContent{}).Error; err != nil {
+			return err
+		}
+		return tx.Create(&content).Error
+	})
+	if err != nil {
+		if preview != nil {
+			s.deletePreview(ctx, *preview)
+		}
+		return fmt.Errorf("failed to save document content: %w", err)
+	}
+
+	for _, old := range previous {
+		if old.Preview != nil {
+			s.deletePreview(ctx, *old.Preview)
+		}
+	}
+	return nil
+}
+
+// preview сохраняет изображение первой страницы: миниатюру из самого файла
+// или, для PDF, картинку от config.PreviewCommand. Без превью документ
+// обходится, поэтому ошибки только пишутся в лог
+func (s *Service) preview(ctx context.Context, path string, file models.File, result *extract.Result) *models.File {
+	image, ext := result.Thumbnail, result.ThumbnailExt
+	if image == nil && extract.IsPDF(file.Name) && s.config.PreviewCommand != "" {
+		previewCtx, cancel := context.WithTimeout(ctx, s.config.PreviewTimeout)
+		defer cancel()
+		var err error
+		if image, err = extract.Preview(previewCtx, s.config.PreviewCommand, path, s.config.PreviewWidth); err != nil {
+			s.logger.WarnContext(ctx, "failed to render document preview", logging.Entity("file", file.Id), logging.Error(err))
+			return nil
+		}
+		ext = ".png"
+	}
+	if image == nil {
+		return nil
+	}
+
+	name := strings.TrimSuffix(file.Name, filepath.Ext(file.Name)) + "-preview" + ext
+	saved, err := s.fileService.SaveContent(ctx, name, image)
+	if err != nil {
+		s.logger.WarnContext(ctx, "failed to save document preview", logging.Entity("file", file.Id), logging.Error(err))
+		return nil
+	}
+	return saved
+}
+
+func (s *Service) deletePreview(ctx context.Context, preview models.File) {
+	if err := s.fileService.DeleteFile(ctx, filepath.Base(preview.Path)); err != nil {
+		s.logger.WarnContext(ctx, "failed to delete document preview", logging.Entity("file", preview.Id), logging.Error(err))
+	}
+	if err := s.db.WithContext(ctx).Delete(&models.File{}, preview.Id).Error; err != nil {
+		s.logger.WarnContext(ctx, "failed to delete document preview record", logging.Entity("file", preview.Id), logging.Error(err))
+	}
+}
+
+func readFile(path, name string) (*extract.Result, error) {
+	if !extract.Supported(name) {
+		return nil, extract.ErrUnsupportedFormat
+	}
+	file, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer file.Close()
+	info, err := file.Stat()
+	if err != nil {
+		return nil, err
+	}
+	return extract.Read(file, info.Size(), name)
+}
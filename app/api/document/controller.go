@@ -1,9 +1,13 @@
 package document
 
 import (
-	"federation-backend/app/db/models/enums"
+	"errors"
+	"federation-backend/app/api/shared"
+	"federation-backend/app/config"
+	"log/slog"
 	"net/http"
 	"strconv"
+	"time"
 
 	"github.com/gin-gonic/gin"
 	"gorm.io/gorm"
@@ -13,6 +17,17 @@ type Controller struct {
 	service *Service
 }
 
+// RegisterExtraRoutes - поиск по тексту, документы раздела с архивом редакций
+// и история версий файла
+func (c *Controller) RegisterExtraRoutes(router *gin.RouterGroup) {
+	router.GET("/search", c.Search)
+	router.GET("/chapter/:chapter", c.GetByChapter)
+	router.GET("/chapter/:chapter/archive", c.GetArchive)
+	router.GET("/:id/versions", c.GetVersions)
+	router.GET("/:id/versions/:version/file", c.DownloadVersion)
+	router.POST("/:id/versions/:version/restore", c.RestoreVersion)
+}
+
 func (c *Controller) Create(ctx *gin.Context) {
 	var dto CreateDocumentDTO
 	if err := ctx.ShouldBind(&dto); err != nil {
@@ -20,8 +35,8 @@ func (c *Controller) Create(ctx *gin.Context) {
 		return
 	}
 
-	if err := c.service.Create(&dto); err != nil {
-		ctx.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+	if err := c.service.Create(ctx.Request.Context(), &dto); err != nil {
+		respondError(ctx, err)
 		return
 	}
 
@@ -35,9 +50,9 @@ func (c *Controller) Get(ctx *gin.Context) {
 		return
 	}
 
-	document, err := c.service.Get(uint(id))
+	document, err := c.service.Get(ctx.Request.Context(), uint(id))
 	if err != nil {
-		ctx.JSON(http.StatusNotFound, gin.H{"error": err.Error()})
+		respondError(ctx, err)
 		return
 	}
 
@@ -57,8 +72,8 @@ func (c *Controller) Update(ctx *gin.Context) {
 		return
 	}
 
-	if err := c.service.Update(uint(id), &dto); err != nil {
-		ctx.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+	if err := c.service.Update(ctx.Request.Context(), uint(id), &dto); err != nil {
+		respondError(ctx, err)
 		return
 	}
 
@@ -72,8 +87,8 @@ func (c *Controller) Delete(ctx *gin.Context) {
 		return
 	}
 
-	if err := c.service.Delete(uint(id)); err != nil {
-		ctx.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+	if err := c.service.Delete(ctx.Request.Context(), uint(id)); err != nil {
+		respondError(ctx, err)
 		return
 	}
 
@@ -81,7 +96,7 @@ func (c *Controller) Delete(ctx *gin.Context) {
 }
 
 func (c *Controller) GetAll(ctx *gin.Context) {
-	documents, err := c.service.GetAll()
+	documents, err := c.service.GetAll(ctx.Request.Context())
 	if err != nil {
 		ctx.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
 		return
@@ -89,22 +104,19 @@ func (c *Controller) GetAll(ctx *gin.Context) {
 	ctx.JSON(http.StatusOK, documents)
 }
 
+// GetByChapter - документы раздела, действующие сейчас или на дату at
 func (c *Controller) GetByChapter(ctx *gin.Context) {
-	chapterStr := ctx.Param("chapter")
-	var chapter enums.Doctype
-
-	// Convert string to Doctype enum
-	switch chapterStr {
-	case "rules":
-		chapter = enums.Rules
-	case "regulations":
-		chapter = enums.Regulations
-	default:
-		ctx.JSON(http.StatusBadRequest, gin.H{"error": "invalid chapter type"})
+	chapterID, err := strconv.ParseUint(ctx.Param("chapter"), 10, 32)
+	if err != nil {
+		ctx.JSON(http.StatusBadRequest, gin.H{"error": "invalid chapter"})
+		return
+	}
+	at, ok := parseAt(ctx)
+	if !ok {
 		return
 	}
 
-	documents, err := c.service.GetByChapter(chapter)
+	documents, err := c.service.GetByChapter(ctx.Request.Context(), uint(chapterID), at)
 	if err != nil {
 		ctx.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
 		return
@@ -112,8 +124,162 @@ func (c *Controller) GetByChapter(ctx *gin.Context) {
 	ctx.JSON(http.StatusOK, documents)
 }
 
-func NewController(db *gorm.DB, fileService FileService) *Controller {
+// GetArchive - прошлые редакции раздела по периодам действия
+func (c *Controller) GetArchive(ctx *gin.Context) {
+	chapterID, err := strconv.ParseUint(ctx.Param("chapter"), 10, 32)
+	if err != nil {
+		ctx.JSON(http.StatusBadRequest, gin.H{"error": "invalid chapter"})
+		return
+	}
+	at, ok := parseAt(ctx)
+	if !ok {
+		return
+	}
+
+	periods, err := c.service.Archive(ctx.Request.Context(), uint(chapterID), at)
+	if err != nil {
+		ctx.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+	ctx.JSON(http.StatusOK, periods)
+}
+
+// Search ищет документы по тексту и названию: q - запрос, chapter_id и limit
+// необязательны
+func (c *Controller) Search(ctx *gin.Context) {
+	query := SearchQuery{Query: ctx.Query("q")}
+	if raw := ctx.Query("chapter_id"); raw != "" {
+		chapterID, err := strconv.ParseUint(raw, 10, 32)
+		if err != nil {
+			ctx.JSON(http.StatusBadRequest, gin.H{"error": "invalid chapter_id"})
+			return
+		}
+		query.ChapterID = uint(chapterID)
+	}
+	if raw := ctx.Query("limit"); raw != "" {
+		limit, err := strconv.Atoi(raw)
+		if err != nil || limit <= 0 || limit > MaxSearchLimit {
+			ctx.JSON(http.StatusBadRequest, gin.H{"error": "invalid limit"})
+			return
+		}
+		query.Limit = limit
+	}
+
+	results, err := c.service.Search(ctx.Request.Context(), query)
+	if err != nil {
+		respondError(ctx, err)
+		return
+	}
+	ctx.JSON(http.StatusOK, results)
+}
+
+// Reindex заново извлекает текст, метаданные и превью файлов всех документов
+func (c *Controller) Reindex(ctx *gin.Context) {
+	report, err := c.service.Reindex(ctx.Request.Context())
+	if err != nil {
+		ctx.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+	ctx.JSON(http.StatusOK, report)
+}
+
+func (c *Controller) GetVersions(ctx *gin.Context) {
+	id, err := strconv.ParseUint(ctx.Param("id"), 10, 32)
+	if err != nil {
+		ctx.JSON(http.StatusBadRequest, gin.H{"error": "invalid id"})
+		return
+	}
+
+	versions, err := c.service.Versions(ctx.Request.Context(), uint(id))
+	if err != nil {
+		respondError(ctx, err)
+		return
+	}
+	ctx.JSON(http.StatusOK, versions)
+}
+
+// DownloadVersion отдаёт файл версии под именем, с которым его загрузили
+func (c *Controller) DownloadVersion(ctx *gin.Context) {
+	id, number, ok := parseVersion(ctx)
+	if !ok {
+		return
+	}
+
+	version, err := c.service.Version(ctx.Request.Context(), id, number)
+	if err != nil {
+		respondError(ctx, err)
+		return
+	}
+	ctx.FileAttachment(c.service.FilePath(version), version.File.Name)
+}
+
+func (c *Controller) RestoreVersion(ctx *gin.Context) {
+	id, number, ok := parseVersion(ctx)
+	if !ok {
+		return
+	}
+
+	var dto RestoreDTO
+	// Тело необязательно: без него версия восстанавливается без комментария
+	if ctx.Request.ContentLength != 0 {
+		if err := ctx.ShouldBindJSON(&dto); err != nil {
+			ctx.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			return
+		}
+	}
+
+	version, err := c.service.Restore(ctx.Request.Context(), id, number, &dto)
+	if err != nil {
+		respondError(ctx, err)
+		return
+	}
+	ctx.JSON(http.StatusCreated, version)
+}
+
+// parseAt читает момент времени из query at, по умолчанию - текущий
+func parseAt(ctx *gin.Context) (time.Time, bool) {
+	raw := ctx.Query("at")
+	if raw == "" {
+		return time.Now(), true
+	}
+	at, err := shared.ParseDate(raw)
+	if err != nil {
+		ctx.JSON(http.StatusBadRequest, gin.H{"error": "invalid at"})
+		return time.Time{}, false
+	}
+	return at, true
+}
+
+func parseVersion(ctx *gin.Context) (uint, uint, bool) {
+	id, err := strconv.ParseUint(ctx.Param("id"), 10, 32)
+	if err != nil {
+		ctx.JSON(http.StatusBadRequest, gin.H{"error": "invalid id"})
+		return 0, 0, false
+	}
+	number, err := strconv.ParseUint(ctx.Param("version"), 10, 32)
+	if err != nil || number == 0 {
+		ctx.JSON(http.StatusBadRequest, gin.H{"error": "invalid version"})
+		return 0, 0, false
+	}
+	return uint(id), uint(number), true
+}
+
+func respondError(ctx *gin.Context, err error) {
+	switch {
+	case errors.Is(err, ErrNotFound), errors.Is(err, ErrVersionNotFound):
+		ctx.JSON(http.StatusNotFound, gin.H{"error": err.Error()})
+	case errors.Is(err, ErrCurrentVersion):
+		ctx.JSON(http.StatusConflict, gin.H{"error": err.Error()})
+	case errors.Is(err, ErrUploaderNotFound), errors.Is(err, shared.ErrChapterNotFound), errors.Is(err, shared.ErrChapterKind), errors.Is(err, ErrInvalidPeriod),
+		errors.Is(err, ErrSupersededNotFound), errors.Is(err, ErrSupersedesCycle), errors.Is(err, ErrEmptyQuery):
+		ctx.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+	default:
+		ctx.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+	}
+}
+
+func NewController(db *gorm.DB, fileService FileService, config config.DocumentsConfig, logger *slog.Logger) *Controller {
 	return &Controller{
-		service: NewService(db, fileService),
+		service: NewService(db, fileService, config, logger),
 	}
 }
@@ -1,156 +1,411 @@
 package document
 
 import (
+	"context"
 	"errors"
+	"federation-backend/app/api/shared"
+	"federation-backend/app/config"
+	database "federation-backend/app/db"
 	"federation-backend/app/db/models"
 	"federation-backend/app/db/models/enums"
+	"federation-backend/app/logging"
 	"fmt"
+	"log/slog"
+	"maps"
 	"mime/multipart"
 	"path/filepath"
+	"slices"
+	"time"
 
 	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
 )
 
+var (
+	ErrNotFound           = errors.New("document not found")
+	ErrVersionNotFound    = errors.New("document version not found")
+	ErrUploaderNotFound   = errors.New("uploader not found")
+	ErrCurrentVersion     = errors.New("version is already current")
+	ErrInvalidPeriod      = errors.New("invalid effective period")
+	ErrSupersededNotFound = errors.New("superseded document not found")
+	ErrSupersedesCycle    = errors.New("document cannot supersede itself or its own successor")
+)
+
+// ArchivePeriod - редакции раздела, которые действовали в одном периоде
+type ArchivePeriod struct {
+	EffectiveFrom *time.Time        `json:"effective_from"`
+	EffectiveTo   time.Time         `json:"effective_to"`
+	Documents     []models.Document `json:"documents"`
+}
+
+// Note и UploadedByID описывают версию файла, которую создаёт запрос.
+// Даты действия - в форматах shared.ParseDate
 type CreateDocumentDTO struct {
-	Name    string                `form:"name" binding:"required"`
-	Chapter enums.Doctype         `form:"chapter" binding:"required"`
-	File    *multipart.FileHeader `form:"file" binding:"required"`
+	Name          string                `form:"name" binding:"required"`
+	ChapterID     uint                  `form:"chapter_id" binding:"required"`
+	File          *multipart.FileHeader `form:"file" binding:"required"`
+	Note          string                `form:"note"`
+	UploadedByID  *uint                 `form:"uploaded_by_id"`
+	EffectiveFrom string                `form:"effective_from"`
+	EffectiveTo   string                `form:"effective_to"`
+	SupersedesID  *uint                 `form:"supersedes_id"`
 }
 
+// UpdateDocumentDTO - новый файл добавляется следующей версией, старые остаются.
+// Пустая дата действия снимает ограничение, supersedes_id=0 - ссылку на редакцию
 type UpdateDocumentDTO struct {
-	Name    *string               `form:"name"`
-	Chapter *enums.Doctype        `form:"chapter"`
-	File    *multipart.FileHeader `form:"file"`
+	Name          *string               `form:"name"`
+	ChapterID     *uint                 `form:"chapter_id"`
+	File          *multipart.FileHeader `form:"file"`
+	Note          string                `form:"note"`
+	UploadedByID  *uint                 `form:"uploaded_by_id"`
+	EffectiveFrom *string               `form:"effective_from"`
+	EffectiveTo   *string               `form:"effective_to"`
+	SupersedesID  *uint                 `form:"supersedes_id"`
+}
+
+type RestoreDTO struct {
+	Note         string `json:"note"`
+	UploadedByID *uint  `json:"uploaded_by_id"`
 }
 
 type Service struct {
 	db          *gorm.DB
 	fileService FileService
+	config      config.DocumentsConfig
+	logger      *slog.Logger
 }
 
 type FileService interface {
-	SaveFile(fileHeader *multipart.FileHeader) (*models.File, error)
-	DeleteFile(filename string) error
+	SaveFile(ctx context.Context, fileHeader *multipart.FileHeader) (*models.File, error)
+	SaveContent(ctx context.Context, name string, content []byte) (*models.File, error)
+	DeleteFile(ctx context.Context, filename string) error
+	GetFilePath(filename string) string
 }
 
-func (s *Service) Create(dto interface{}) error {
+func (s *Service) Create(ctx context.Context, dto interface{}) error {
 	createDTO, ok := dto.(*CreateDocumentDTO)
 	if !ok {
 		return errors.New("invalid DTO type")
 	}
 
-	return s.db.Transaction(func(tx *gorm.DB) error {
+	var saved *models.File
+	err := s.db.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		ctx := database.WithTx(ctx, tx)
+		if err := shared.CheckChapter(tx, createDTO.ChapterID, enums.DocumentsPage); err != nil {
+			return err
+		}
+		if err := s.uploaderExists(tx, createDTO.UploadedByID); err != nil {
+			return err
+		}
+		var document models.Document
+		var err error
+		if document.EffectiveFrom, err = parseEffectiveDate("effective_from", createDTO.EffectiveFrom); err != nil {
+			return err
+		}
+		if document.EffectiveTo, err = parseEffectiveDate("effective_to", createDTO.EffectiveTo); err != nil {
+			return err
+		}
+		document.SupersedesID = createDTO.SupersedesID
+		if err := s.checkValidity(tx, &document); err != nil {
+			return err
+		}
+
 		// Save the file first
-		file, err := s.fileService.SaveFile(createDTO.File)
+		file, err := s.fileService.SaveFile(ctx, createDTO.File)
 		if err != nil {
 			return fmt.Errorf("failed to save document file: %w", err)
 		}
 
-		// Create the document with embedded File and additional fields
-		document := models.Document{
-			File:    *file, // Embed the File struct
-			Name:    createDTO.Name,
-			Chapter: createDTO.Chapter,
+		document.Name = createDTO.Name
+		document.FileID = file.Id
+		document.ChapterID = createDTO.ChapterID
+		document.Version = 1
+		version := models.DocumentVersion{
+			Number:       1,
+			FileID:       file.Id,
+			UploadedByID: createDTO.UploadedByID,
+			Note:         createDTO.Note,
 		}
 
-		if err := tx.Create(&document).Error; err != nil {
+		err = tx.Create(&document).Error
+		if err == nil {
+			version.DocumentID = document.Id
+			err = tx.Create(&version).Error
+		}
+		if err == nil {
+			err = s.closeSuperseded(tx, &document)
+		}
+		if err != nil {
 			// Clean up the saved file if document creation fails
-			if deleteErr := s.fileService.DeleteFile(filepath.Base(file.Path)); deleteErr != nil {
-				fmt.Printf("Warning: failed to clean up document file after creation failure: %v\n", deleteErr)
+			if deleteErr := s.fileService.DeleteFile(ctx, filepath.Base(file.Path)); deleteErr != nil {
+				s.logger.WarnContext(ctx, "failed to clean up document file after creation failure", logging.Entity("document", document.Id), logging.Error(deleteErr))
 			}
 			return fmt.Errorf("failed to create document: %w", err)
 		}
 
+		saved = file
 		return nil
 	})
-}
-
-func (s *Service) Get(id uint) (models.Document, error) {
-	var document models.Document
-	err := s.db.First(&document, id).Error
 	if err != nil {
-		if errors.Is(err, gorm.ErrRecordNotFound) {
-			return models.Document{}, errors.New("document not found")
-		}
-		return models.Document{}, fmt.Errorf("failed to get document: %w", err)
+		return err
 	}
-	return document, nil
+
+	s.index(ctx, *saved)
+	return nil
 }
 
-func (s *Service) Update(id uint, dto interface{}) error {
+func (s *Service) Get(ctx context.Context, id uint) (models.Document, error) {
+	return s.find(withContent(s.db.WithContext(ctx)).Preload("File").Preload("Chapter"), id)
+}
+
+func (s *Service) Update(ctx context.Context, id uint, dto interface{}) error {
 	updateDTO, ok := dto.(*UpdateDocumentDTO)
 	if !ok {
 		return errors.New("invalid DTO type")
 	}
 
-	return s.db.Transaction(func(tx *gorm.DB) error {
-		var document models.Document
-		if err := tx.First(&document, id).Error; err != nil {
-			return fmt.Errorf("document not found: %w", err)
+	var saved *models.File
+	err := s.db.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		ctx := database.WithTx(ctx, tx)
+		document, err := s.find(tx, id)
+		if err != nil {
+			return err
 		}
+		previousFrom, previousSupersedes := document.EffectiveFrom, document.SupersedesID
 
 		// Update basic fields if provided
 		if updateDTO.Name != nil {
 			document.Name = *updateDTO.Name
 		}
-		if updateDTO.Chapter != nil {
-			document.Chapter = *updateDTO.Chapter
+		if updateDTO.ChapterID != nil {
+			if err := shared.CheckChapter(tx, *updateDTO.ChapterID, enums.DocumentsPage); err != nil {
+				return err
+			}
+			document.ChapterID = *updateDTO.ChapterID
 		}
-
-		// Handle file update if provided
-		if updateDTO.File != nil {
-			// Save new file first
-			newFile, err := s.fileService.SaveFile(updateDTO.File)
-			if err != nil {
-				return fmt.Errorf("failed to save new document file: %w", err)
+		if updateDTO.EffectiveFrom != nil {
+			if document.EffectiveFrom, err = parseEffectiveDate("effective_from", *updateDTO.EffectiveFrom); err != nil {
+				return err
 			}
-
-			// Store the old file path for cleanup
-			oldFilePath := document.File.Path
-
-			// Update document with new file data
-			document.File = *newFile
-
-			// Save the document
-			if err := tx.Save(&document).Error; err != nil {
-				// Clean up the new file if document update fails
-				if deleteErr := s.fileService.DeleteFile(filepath.Base(newFile.Path)); deleteErr != nil {
-					fmt.Printf("Warning: failed to clean up new document file after update failure: %v\n", deleteErr)
-				}
-				return fmt.Errorf("failed to update document: %w", err)
+		}
+		if updateDTO.EffectiveTo != nil {
+			if document.EffectiveTo, err = parseEffectiveDate("effective_to", *updateDTO.EffectiveTo); err != nil {
+				return err
 			}
-
-			// Delete old file
-			if oldFilePath != "" {
-				filename := filepath.Base(oldFilePath)
-				if deleteErr := s.fileService.DeleteFile(filename); deleteErr != nil {
-					fmt.Printf("Warning: failed to delete old document file: %v\n", deleteErr)
-				}
+		}
+		if updateDTO.SupersedesID != nil {
+			document.SupersedesID = updateDTO.SupersedesID
+			if *updateDTO.SupersedesID == 0 {
+				document.SupersedesID = nil
+			}
+		}
+		if err := s.checkValidity(tx, &document); err != nil {
+			return err
+		}
+		// Конец заменённой редакции двигается, только если сменилась дата
+		// начала или сама ссылка
+		linkChanged := !sameID(previousSupersedes, document.SupersedesID)
+		if linkChanged && previousSupersedes != nil {
+			if err := s.reopenSuperseded(tx, *previousSupersedes, document.Id); err != nil {
+				return err
+			}
+		}
+		if linkChanged || !sameDate(previousFrom, document.EffectiveFrom) {
+			if err := s.closeSuperseded(tx, &document); err != nil {
+				return err
 			}
-		} else {
+		}
+
+		if updateDTO.File == nil {
 			// No file update, just save the document
-			if err := tx.Save(&document).Error; err != nil {
+			if err := tx.Omit(clause.Associations).Save(&document).Error; err != nil {
 				return fmt.Errorf("failed to update document: %w", err)
 			}
+			return nil
+		}
+
+		if err := s.uploaderExists(tx, updateDTO.UploadedByID); err != nil {
+			return err
+		}
+
+		// The new file becomes the next version, the old one stays in history
+		newFile, err := s.fileService.SaveFile(ctx, updateDTO.File)
+		if err != nil {
+			return fmt.Errorf("failed to save new document file: %w", err)
+		}
+
+		version := models.DocumentVersion{
+			FileID:       newFile.Id,
+			UploadedByID: updateDTO.UploadedByID,
+			Note:         updateDTO.Note,
+		}
+		if err := s.addVersion(tx, &document, &version, *newFile); err != nil {
+			// Clean up the new file if document update fails
+			if deleteErr := s.fileService.DeleteFile(ctx, filepath.Base(newFile.Path)); deleteErr != nil {
+				s.logger.WarnContext(ctx, "failed to clean up new document file after update failure", logging.Entity("document", document.Id), logging.Error(deleteErr))
+			}
+			return err
 		}
 
+		s.logger.InfoContext(ctx, "document version added", logging.Entity("document", document.Id), slog.Uint64("version", uint64(version.Number)))
+		saved = newFile
 		return nil
 	})
+	if err != nil || saved == nil {
+		return err
+	}
+
+	s.index(ctx, *saved)
+	return nil
 }
 
-func (s *Service) Delete(id uint) error {
-	return s.db.Transaction(func(tx *gorm.DB) error {
-		var document models.Document
-		if err := tx.First(&document, id).Error; err != nil {
-			return fmt.Errorf("document not found: %w", err)
+// Versions возвращает версии документа, новые первыми
+func (s *Service) Versions(ctx context.Context, id uint) ([]models.DocumentVersion, error) {
+	db := s.db.WithContext(ctx)
+	if _, err := s.find(db, id); err != nil {
+		return nil, err
+	}
+	versions := []models.DocumentVersion{}
+	if err := db.Preload("File").Where("document_id = ?", id).Order("number DESC").Find(&versions).Error; err != nil {
+		return nil, fmt.Errorf("failed to get document versions: %w", err)
+	}
+	return versions, nil
+}
+
+// Version возвращает версию number документа вместе с файлом
+func (s *Service) Version(ctx context.Context, id, number uint) (*models.DocumentVersion, error) {
+	return s.findVersion(s.db.WithContext(ctx), id, number)
+}
+
+// Restore делает файл версии number текущим. История не переписывается:
+// добавляется новая версия с тем же файлом и ссылкой на восстановленную
+func (s *Service) Restore(ctx context.Context, id, number uint, dto *RestoreDTO) (*models.DocumentVersion, error) {
+	var version models.DocumentVersion
+	err := s.db.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		document, err := s.find(tx, id)
+		if err != nil {
+			return err
+		}
+		source, err := s.findVersion(tx, id, number)
+		if err != nil {
+			return err
+		}
+		if source.Number == document.Version {
+			return ErrCurrentVersion
+		}
+		if err := s.uploaderExists(tx, dto.UploadedByID); err != nil {
+			return err
 		}
 
-		// Delete the associated file
-		if document.File.Path != "" {
-			filename := filepath.Base(document.File.Path)
-			if err := s.fileService.DeleteFile(filename); err != nil {
-				fmt.Printf("Warning: failed to delete document file: %v\n", err)
-			}
+		note := dto.Note
+		if note == "" {
+			note = fmt.Sprintf("restored version %d", source.Number)
+		}
+		version = models.DocumentVersion{
+			FileID:       source.FileID,
+			UploadedByID: dto.UploadedByID,
+			Note:         note,
+			RestoredFrom: &source.Number,
+		}
+		return s.addVersion(tx, &document, &version, source.File)
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	s.logger.InfoContext(ctx, "document version restored",
+		logging.Entity("document", id),
+		slog.Uint64("version", uint64(version.Number)),
+		slog.Uint64("restored_from", uint64(number)),
+	)
+	return &version, nil
+}
+
+// addVersion сохраняет version следующей по номеру и делает file текущим файлом документа
+func (s *Service) addVersion(tx *gorm.DB, document *models.Document, version *models.DocumentVersion, file models.File) error {
+	var last uint
+	if err := tx.Model(&models.DocumentVersion{}).Where("document_id = ?", document.Id).
+		Select("COALESCE(MAX(number), 0)").Scan(&last).Error; err != nil {
+		return fmt.Errorf("failed to number document version: %w", err)
+	}
+
+	version.DocumentID = document.Id
+	version.Number = last + 1
+	if err := tx.Create(version).Error; err != nil {
+		return fmt.Errorf("failed to save document version: %w", err)
+	}
+	version.File = file
+
+	document.FileID = file.Id
+	document.Version = version.Number
+	if err := tx.Omit(clause.Associations).Save(document).Error; err != nil {
+		return fmt.Errorf("failed to update document: %w", err)
+	}
+	return nil
+}
+
+func (s *Service) find(db *gorm.DB, id uint) (models.Document, error) {
+	var document models.Document
+	if err := db.First(&document, id).Error; err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return models.Document{}, ErrNotFound
+		}
+		return models.Document{}, fmt.Errorf("failed to get document: %w", err)
+	}
+	return document, nil
+}
+
+func (s *Service) findVersion(db *gorm.DB, id, number uint) (*models.DocumentVersion, error) {
+	var version models.DocumentVersion
+	err := db.Preload("File").Where("document_id = ? AND number = ?", id, number).First(&version).Error
+	if err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, ErrVersionNotFound
+		}
+		return nil, fmt.Errorf("failed to get document version: %w", err)
+	}
+	return &version, nil
+}
+
+func (s *Service) uploaderExists(db *gorm.DB, userID *uint) error {
+	if userID == nil {
+		return nil
+	}
+	var count int64
+	if err := db.Model(&models.User{}).Where("id = ?", *userID).Count(&count).Error; err != nil {
+		return fmt.Errorf("failed to check uploader: %w", err)
+	}
+	if count == 0 {
+		return ErrUploaderNotFound
+	}
+	return nil
+}
+
+// FilePath - путь к файлу версии в хранилище
+func (s *Service) FilePath(version *models.DocumentVersion) string {
+	return s.fileService.GetFilePath(filepath.Base(version.File.Path))
+}
+
+// Delete удаляет документ со всеми версиями и их файлами
+func (s *Service) Delete(ctx context.Context, id uint) error {
+	return s.db.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		ctx := database.WithTx(ctx, tx)
+		document, err := s.find(tx, id)
+		if err != nil {
+			return err
+		}
+
+		var versions []models.DocumentVersion
+		if err := tx.Preload("File").Where("document_id = ?", id).Find(&versions).Error; err != nil {
+			return fmt.Errorf("failed to get document versions: %w", err)
+		}
+		if err := tx.Where("document_id = ?", id).Delete(&models.DocumentVersion{}).Error; err != nil {
+			return fmt.Errorf("failed to delete document versions: %w", err)
+		}
+		// В SQLite у supersedes_id нет внешнего ключа, ссылки снимаются явно
+		if err := tx.Model(&models.Document{}).Where("supersedes_id = ?", id).Update("supersedes_id", nil).Error; err != nil {
+			return fmt.Errorf("failed to unlink later editions: %w", err)
 		}
 
 		// Delete the document record
@@ -158,31 +413,197 @@ func (s *Service) Delete(id uint) error {
 			return fmt.Errorf("failed to delete document: %w", err)
 		}
 
+		// Восстановленные версии ссылаются на тот же файл, он удаляется один раз
+		files := map[uint]models.File{}
+		for _, version := range versions {
+			files[version.FileID] = version.File
+		}
+		var contents []models.DocumentContent
+		if err := tx.Preload("Preview").Where("file_id IN ?", slices.Collect(maps.Keys(files))).Find(&contents).Error; err != nil {
+			return fmt.Errorf("failed to get document contents: %w", err)
+		}
+		for _, content := range contents {
+			if err := tx.Delete(&content).Error; err != nil {
+				return fmt.Errorf("failed to delete document content: %w", err)
+			}
+			if content.Preview != nil {
+				files[content.Preview.Id] = *content.Preview
+			}
+		}
+		for _, file := range files {
+			if err := s.fileService.DeleteFile(ctx, filepath.Base(file.Path)); err != nil {
+				s.logger.WarnContext(ctx, "failed to delete document file", logging.Entity("document", document.Id), logging.Error(err))
+			}
+			if err := tx.Delete(&models.File{}, file.Id).Error; err != nil {
+				s.logger.WarnContext(ctx, "failed to delete document file record", logging.Entity("document", document.Id), logging.Error(err))
+			}
+		}
 		return nil
 	})
 }
 
-func (s *Service) GetAll() ([]models.Document, error) {
+func (s *Service) GetAll(ctx context.Context) ([]models.Document, error) {
 	var documents []models.Document
-	err := s.db.Find(&documents).Error
+	err := withContent(s.db.WithContext(ctx)).Preload("File").Preload("Chapter").Find(&documents).Error
 	if err != nil {
 		return nil, fmt.Errorf("failed to get documents: %w", err)
 	}
 	return documents, nil
 }
 
-func (s *Service) GetByChapter(chapter enums.Doctype) ([]models.Document, error) {
-	var documents []models.Document
-	err := s.db.Where("chapter = ?", chapter).Find(&documents).Error
+// GetByChapter возвращает документы раздела, действующие в момент at
+func (s *Service) GetByChapter(ctx context.Context, chapterID uint, at time.Time) ([]models.Document, error) {
+	documents := []models.Document{}
+	err := withContent(s.db.WithContext(ctx)).Preload("File").
+		Where("chapter_id = ?", chapterID).
+		Scopes(EffectiveAt(at)).
+		Order("effective_from DESC, id").
+		Find(&documents).Error
 	if err != nil {
 		return nil, fmt.Errorf("failed to get documents by chapter: %w", err)
 	}
 	return documents, nil
 }
 
-func NewService(db *gorm.DB, fileService FileService) *Service {
+// EffectiveAt оставляет документы, действующие в момент at
+func EffectiveAt(at time.Time) func(*gorm.DB) *gorm.DB {
+	at = at.UTC()
+	return func(db *gorm.DB) *gorm.DB {
+		return db.Where("documents.effective_from IS NULL OR documents.effective_from <= ?", at).
+			Where("documents.effective_to IS NULL OR documents.effective_to > ?", at)
+	}
+}
+
+// Archive возвращает редакции раздела, которые перестали действовать к
+// моменту at, сгруппированные по периоду действия, последние первыми
+func (s *Service) Archive(ctx context.Context, chapterID uint, at time.Time) ([]ArchivePeriod, error) {
+	var documents []models.Document
+	err := withContent(s.db.WithContext(ctx)).Preload("File").
+		Where("chapter_id = ? AND effective_to <= ?", chapterID, at.UTC()).
+		Order("effective_to DESC, effective_from DESC, id").
+		Find(&documents).Error
+	if err != nil {
+		return nil, fmt.Errorf("failed to get documents archive: %w", err)
+	}
+
+	periods := []ArchivePeriod{}
+	for _, document := range documents {
+		last := len(periods) - 1
+		if last < 0 || !periods[last].EffectiveTo.Equal(*document.EffectiveTo) || !sameDate(periods[last].EffectiveFrom, document.EffectiveFrom) {
+			periods = append(periods, ArchivePeriod{
+				EffectiveFrom: document.EffectiveFrom,
+				EffectiveTo:   *document.EffectiveTo,
+			})
+			last++
+		}
+		periods[last].Documents = append(periods[last].Documents, document)
+	}
+	return periods, nil
+}
+
+// checkValidity проверяет период действия и ссылку на заменённую редакцию
+func (s *Service) checkValidity(db *gorm.DB, document *models.Document) error {
+	if document.EffectiveFrom != nil && document.EffectiveTo != nil && !document.EffectiveTo.After(*document.EffectiveFrom) {
+		return fmt.Errorf("%w: effective_to must be after effective_from", ErrInvalidPeriod)
+	}
+	if document.SupersedesID == nil {
+		return nil
+	}
+
+	// Идём по цепочке заменённых редакций: документ не должен в ней встретиться
+	next := document.SupersedesID
+	for next != nil {
+		if document.Id != 0 && *next == document.Id {
+			return ErrSupersedesCycle
+		}
+		var previous models.Document
+		if err := db.Select("id", "supersedes_id").First(&previous, *next).Error; err != nil {
+			if errors.Is(err, gorm.ErrRecordNotFound) {
+				return ErrSupersededNotFound
+			}
+			return fmt.Errorf("failed to check superseded document: %w", err)
+		}
+		next = previous.SupersedesID
+	}
+	return nil
+}
+
+// closeSuperseded завершает действие заменённой редакции ровно там, где
+// начинает действовать документ (сейчас, если дата начала не задана)
+func (s *Service) closeSuperseded(tx *gorm.DB, document *models.Document) error {
+	if document.SupersedesID == nil {
+		return nil
+	}
+	start := time.Now().UTC()
+	if document.EffectiveFrom != nil {
+		start = *document.EffectiveFrom
+	}
+
+	var previous models.Document
+	if err := tx.First(&previous, *document.SupersedesID).Error; err != nil {
+		return fmt.Errorf("failed to get superseded document: %w", err)
+	}
+	if previous.EffectiveFrom != nil && !start.After(*previous.EffectiveFrom) {
+		return fmt.Errorf("%w: edition must take effect after the one it supersedes", ErrInvalidPeriod)
+	}
+	if err := tx.Model(&previous).Update("effective_to", start).Error; err != nil {
+		return fmt.Errorf("failed to close superseded document: %w", err)
+	}
+	return nil
+}
+
+// reopenSuperseded снимает конец действия с редакции, которую документ
+// documentID больше не заменяет, если её не заменяет другой документ
+func (s *Service) reopenSuperseded(tx *gorm.DB, previousID, documentID uint) error {
+	var successors int64
+	err := tx.Model(&models.Document{}).
+		Where("supersedes_id = ? AND id <> ?", previousID, documentID).
+		Count(&successors).Error
+	if err != nil {
+		return fmt.Errorf("failed to check superseded document: %w", err)
+	}
+	if successors > 0 {
+		return nil
+	}
+	err = tx.Model(&models.Document{}).Where("id = ?", previousID).Update("effective_to", nil).Error
+	if err != nil {
+		return fmt.Errorf("failed to reopen superseded document: %w", err)
+	}
+	return nil
+}
+
+// parseEffectiveDate разбирает дату действия; пустая строка - без ограничения
+func parseEffectiveDate(field, raw string) (*time.Time, error) {
+	if raw == "" {
+		return nil, nil
+	}
+	date, err := shared.ParseDate(raw)
+	if err != nil {
+		return nil, fmt.Errorf("%w: %s: %v", ErrInvalidPeriod, field, err)
+	}
+	date = date.UTC()
+	return &date, nil
+}
+
+func sameID(a, b *uint) bool {
+	if a == nil || b == nil {
+		return a == nil && b == nil
+	}
+	return *a == *b
+}
+
+func sameDate(a, b *time.Time) bool {
+	if a == nil || b == nil {
+		return a == nil && b == nil
+	}
+	return a.Equal(*b)
+}
+
+func NewService(db *gorm.DB, fileService FileService, config config.DocumentsConfig, logger *slog.Logger) *Service {
 	return &Service{
 		db:          db,
 		fileService: fileService,
+		config:      config,
+		logger:      logger,
 	}
 }
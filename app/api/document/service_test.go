@@ -0,0 +1,161 @@
+package document
+
+import (
+	"context"
+	"io"
+	"log/slog"
+	"path/filepath"
+	"slices"
+	"testing"
+	"time"
+
+	"federation-backend/app/config"
+	"federation-backend/app/db/models"
+
+	"gorm.io/driver/sqlite"
+	"gorm.io/gorm"
+	"gorm.io/gorm/logger"
+)
+
+func date(year int, month time.Month, day int) *time.Time {
+	t := time.Date(year, month, day, 0, 0, 0, 0, time.UTC)
+	return &t
+}
+
+func ref[T any](v T) *T {
+	return &v
+}
+
+// Редакции раздела: A заменена B с 2022 года, C - отдельная редакция с 2019
+func TestUpdateSupersedes(t *testing.T) {
+	tests := []struct {
+		name string
+		dto  UpdateDocumentDTO
+		// wantATo и wantCTo - конец действия A и C после обновления B
+		wantATo *time.Time
+		wantCTo *time.Time
+		// wantCurrent - названия действующих документов на момент at
+		at          time.Time
+		wantCurrent []string
+	}{
+		{
+			name:        "rename keeps the boundary",
+			dto:         UpdateDocumentDTO{Name: ref("B, исправленная")},
+			wantATo:     date(2022, 1, 1),
+			at:          *date(2022, 6, 1),
+			wantCurrent: []string{"B, исправленная", "C"},
+		},
+		{
+			name:        "later start moves the boundary later",
+			dto:         UpdateDocumentDTO{EffectiveFrom: ref("2023-01-01")},
+			wantATo:     date(2023, 1, 1),
+			at:          *date(2022, 6, 1),
+			wantCurrent: []string{"A", "C"},
+		},
+		{
+			name:        "earlier start moves the boundary earlier",
+			dto:         UpdateDocumentDTO{EffectiveFrom: ref("2021-03-01")},
+			wantATo:     date(2021, 3, 1),
+			at:          *date(2021, 6, 1),
+			wantCurrent: []string{"B", "C"},
+		},
+		{
+			name:        "removed link reopens the predecessor",
+			dto:         UpdateDocumentDTO{SupersedesID: ref(uint(0))},
+			at:          *date(2022, 6, 1),
+			wantCurrent: []string{"B", "A", "C"},
+		},
+		{
+			name:        "re-pointed link reopens the old predecessor and closes the new one",
+			dto:         UpdateDocumentDTO{SupersedesID: ref(uint(3))},
+			wantCTo:     date(2022, 1, 1),
+			at:          *date(2022, 6, 1),
+			wantCurrent: []string{"B", "A"},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			service, db := newTestService(t)
+			a := createDocument(t, db, models.Document{Name: "A", EffectiveFrom: date(2020, 1, 1), EffectiveTo: date(2022, 1, 1)})
+			b := createDocument(t, db, models.Document{Name: "B", EffectiveFrom: date(2022, 1, 1), SupersedesID: &a.Id})
+			c := createDocument(t, db, models.Document{Name: "C", EffectiveFrom: date(2019, 1, 1)})
+			if c.Id != 3 {
+				t.Fatalf("C has id %d, want 3", c.Id)
+			}
+
+			if err := service.Update(context.Background(), b.Id, &tt.dto); err != nil {
+				t.Fatalf("update: %v", err)
+			}
+
+			for _, want := range []struct {
+				id uint
+				to *time.Time
+			}{{a.Id, tt.wantATo}, {c.Id, tt.wantCTo}} {
+				var got models.Document
+				if err := db.First(&got, want.id).Error; err != nil {
+					t.Fatal(err)
+				}
+				if !sameDate(got.EffectiveTo, want.to) {
+					t.Errorf("%s: effective_to = %v, want %v", got.Name, got.EffectiveTo, want.to)
+				}
+			}
+
+			current, err := service.GetByChapter(context.Background(), 1, tt.at)
+			if err != nil {
+				t.Fatal(err)
+			}
+			var names []string
+			for _, document := range current {
+				names = append(names, document.Name)
+			}
+			if !slices.Equal(names, tt.wantCurrent) {
+				t.Errorf("current at %s = %v, want %v", tt.at.Format(time.DateOnly), names, tt.wantCurrent)
+			}
+		})
+	}
+}
+
+// Правка редакции без даты начала не сверяется с будущей заменённой редакцией
+func TestUpdateWithoutStartIgnoresFuturePredecessor(t *testing.T) {
+	service, db := newTestService(t)
+	future := time.Now().UTC().AddDate(1, 0, 0)
+	a := createDocument(t, db, models.Document{Name: "A", EffectiveFrom: &future})
+	b := createDocument(t, db, models.Document{Name: "B", SupersedesID: &a.Id})
+
+	if err := service.Update(context.Background(), b.Id, &UpdateDocumentDTO{Name: ref("B2")}); err != nil {
+		t.Fatalf("rename: %v", err)
+	}
+	var got models.Document
+	if err := db.First(&got, a.Id).Error; err != nil {
+		t.Fatal(err)
+	}
+	if got.EffectiveTo != nil {
+		t.Errorf("predecessor closed at %v", got.EffectiveTo)
+	}
+}
+
+func newTestService(t *testing.T) (*Service, *gorm.DB) {
+	t.Helper()
+	db, err := gorm.Open(sqlite.Open(filepath.Join(t.TempDir(), "documents.db")), &gorm.Config{Logger: logger.Discard})
+	if err != nil {
+		t.Fatalf("open sqlite: %v", err)
+	}
+	sqlDB, _ := db.DB()
+	sqlDB.SetMaxOpenConns(1)
+	t.Cleanup(func() { sqlDB.Close() })
+	if err := db.AutoMigrate(&models.File{}, &models.Document{}, &models.DocumentContent{}); err != nil {
+		t.Fatalf("migrate: %v", err)
+	}
+	return NewService(db, nil, config.DocumentsConfig{}, slog.New(slog.NewTextHandler(io.Discard, nil))), db
+}
+
+func createDocument(t *testing.T, db *gorm.DB, document models.Document) models.Document {
+	t.Helper()
+	document.ChapterID = 1
+	document.FileID = 1
+	if err := db.Omit("File", "Chapter").Create(&document).Error; err != nil {
+		t.Fatalf("create %s: %v", document.Name, err)
+	}
+	return document
+}
@@ -1,7 +1,11 @@
 package files
 
 import (
+	"errors"
+	"federation-backend/app/api/shared/photo"
+	"federation-backend/app/config"
 	"federation-backend/app/db/models"
+	"log/slog"
 	"net/http"
 	"path/filepath"
 	"strconv"
@@ -22,9 +26,13 @@ func (c *Controller) UploadFile(ctx *gin.Context) {
 		return
 	}
 
-	file, err := c.service.SaveFile(fileHeader)
+	file, err := c.service.SaveFile(ctx.Request.Context(), fileHeader)
 	if err != nil {
-		ctx.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		status := http.StatusInternalServerError
+		if errors.Is(err, photo.ErrInvalidImage) {
+			status = http.StatusBadRequest
+		}
+		ctx.JSON(status, gin.H{"error": err.Error()})
 		return
 	}
 
@@ -52,7 +60,7 @@ func (c *Controller) UploadMultipleFiles(ctx *gin.Context) {
 	var errors []string
 
 	for _, fileHeader := range files {
-		file, err := c.service.SaveFile(fileHeader)
+		file, err := c.service.SaveFile(ctx.Request.Context(), fileHeader)
 		if err != nil {
 			errors = append(errors, err.Error())
 			continue
@@ -87,7 +95,7 @@ func (c *Controller) DeleteFile(ctx *gin.Context) {
 		return
 	}
 
-	if err := c.service.DeleteFile(filename); err != nil {
+	if err := c.service.DeleteFile(ctx.Request.Context(), filename); err != nil {
 		ctx.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
 		return
 	}
@@ -104,7 +112,7 @@ func (c *Controller) GetFileInfo(ctx *gin.Context) {
 	}
 
 	var fileModel models.File // You'll need to define this or use your existing File model
-	if err := c.service.db.First(&fileModel, uint(id)).Error; err != nil {
+	if err := c.service.db.WithContext(ctx.Request.Context()).First(&fileModel, uint(id)).Error; err != nil {
 		ctx.JSON(http.StatusNotFound, gin.H{"error": "file not found"})
 		return
 	}
@@ -115,7 +123,7 @@ func (c *Controller) GetFileInfo(ctx *gin.Context) {
 // GetAllFiles returns all files from database
 func (c *Controller) GetAllFiles(ctx *gin.Context) {
 	var files []models.File // You'll need to define this or use your existing File model
-	if err := c.service.db.Find(&files).Error; err != nil {
+	if err := c.service.db.WithContext(ctx.Request.Context()).Find(&files).Error; err != nil {
 		ctx.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
 		return
 	}
@@ -147,18 +155,20 @@ func (c *Controller) CheckFileExists(ctx *gin.Context) {
 	ctx.JSON(http.StatusOK, gin.H{"exists": true})
 }
 
-// GetStorageInfo returns storage information
+// GetStorageInfo returns storage report: usage per type, free disk space
+// and database rows pointing at missing files
 func (c *Controller) GetStorageInfo(ctx *gin.Context) {
-	// You can implement storage statistics here
-	// For example: total files, storage usage, etc.
-	ctx.JSON(http.StatusOK, gin.H{
-		"storage_path": c.service.storagePath,
-		"status":       "active",
-	})
+	report, err := c.service.StorageReport(ctx.Request.Context())
+	if err != nil {
+		ctx.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+
+	ctx.JSON(http.StatusOK, report)
 }
 
-func NewController(db *gorm.DB, storagePath string) (*Controller, error) {
-	service, err := NewService(db, storagePath)
+func NewController(db *gorm.DB, storagePath string, upload config.UploadConfig, logger *slog.Logger) (*Controller, error) {
+	service, err := NewService(db, storagePath, upload, logger)
 	if err != nil {
 		return nil, err
 	}
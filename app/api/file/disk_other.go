@@ -0,0 +1,7 @@
+//go:build !linux && !darwin
+
+package files
+
+func diskUsage(path string) (*DiskUsage, error) {
+	return nil, errDiskUsageUnsupported
+}
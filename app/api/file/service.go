@@ -1,10 +1,19 @@
 package files
 
 import (
+	"bytes"
+	"context"
 	"errors"
+	"federation-backend/app/api/shared/photo"
+	"federation-backend/app/config"
+	database "federation-backend/app/db"
 	"federation-backend/app/db/models"
+	"federation-backend/app/logging"
+	"federation-backend/app/metrics"
+	"federation-backend/app/tracing"
 	"fmt"
 	"io"
+	"log/slog"
 	"maps"
 	"mime/multipart"
 	"os"
@@ -13,6 +22,7 @@ import (
 	"strings"
 
 	"github.com/google/uuid"
+	"go.opentelemetry.io/otel/attribute"
 	"gorm.io/gorm"
 )
 
@@ -31,56 +41,151 @@ var allowed = map[string]bool{
 type Service struct {
 	db          *gorm.DB
 	storagePath string
+	maxFileSize int64
+	// maxImagePixels - предел размера фотографии для photo.Process
+	maxImagePixels int64
+	logger         *slog.Logger
 }
 
-func NewService(db *gorm.DB, storagePath string) (*Service, error) {
+func NewService(db *gorm.DB, storagePath string, upload config.UploadConfig, logger *slog.Logger) (*Service, error) {
 	if err := os.MkdirAll(storagePath, 0755); err != nil {
 		return nil, fmt.Errorf("failed to create storage directory: %w", err)
 	}
-	return &Service{db: db, storagePath: storagePath}, nil
+	return &Service{
+		db:             db,
+		storagePath:    storagePath,
+		maxFileSize:    upload.MaxFileSize(),
+		maxImagePixels: upload.MaxImagePixels(),
+		logger:         logger,
+	}, nil
 }
 
-func (s *Service) SaveFile(fileHeader *multipart.FileHeader) (*models.File, error) {
-	fileExt := strings.ToLower(filepath.Ext(fileHeader.Filename))
-	if !isAllowedExtension(fileExt) {
-		return nil, errors.New("disallowed file extension for " + fileHeader.Filename + ": " + fileExt + "allowed extensions: " + strings.Join(slices.Collect(maps.Keys(allowed)), ", "))
+func (s *Service) SaveFile(ctx context.Context, fileHeader *multipart.FileHeader) (saved *models.File, err error) {
+	ctx, span := tracing.Start(ctx, "files.SaveFile",
+		attribute.String("file.name", fileHeader.Filename),
+		attribute.Int64("file.size", fileHeader.Size),
+	)
+	defer func() { tracing.End(span, err) }()
+
+	if err := s.check(fileHeader.Filename, fileHeader.Size); err != nil {
+		return nil, err
 	}
 
 	file, err := fileHeader.Open()
 	if err != nil {
+		metrics.FileUploadFailures.WithLabelValues(metrics.UploadFailureStorage).Inc()
 		return nil, fmt.Errorf("failed to open uploaded file: %w", err)
 	}
 	defer file.Close()
 
+	if photo.Supported(fileHeader.Filename) {
+		return s.savePhoto(ctx, fileHeader.Filename, file)
+	}
+	return s.save(ctx, models.File{Name: fileHeader.Filename, Size: fileHeader.Size}, file)
+}
+
+// savePhoto поворачивает фотографию по EXIF и вырезает из неё метаданные с
+// координатами и прочими личными сведениями. Размеры, время съёмки и камера
+// остаются только в записи files
+func (s *Service) savePhoto(ctx context.Context, name string, file io.Reader) (saved *models.File, err error) {
+	content, err := io.ReadAll(file)
+	if err != nil {
+		metrics.FileUploadFailures.WithLabelValues(metrics.UploadFailureStorage).Inc()
+		return nil, fmt.Errorf("failed to read uploaded file: %w", err)
+	}
+
+	_, span := tracing.Start(ctx, "files.ProcessPhoto", attribute.String("file.name", name))
+	processed, info, err := photo.Process(content, name, s.maxImagePixels)
+	tracing.End(span, err)
+	if err != nil {
+		metrics.FileUploadFailures.WithLabelValues(metrics.UploadFailureImage).Inc()
+		return nil, err
+	}
+
+	metadata := models.File{
+		Name:    name,
+		Size:    int64(len(processed)),
+		Width:   &info.Width,
+		Height:  &info.Height,
+		TakenAt: info.TakenAt,
+		Camera:  info.Camera,
+	}
+	return s.save(ctx, metadata, bytes.NewReader(processed))
+}
+
+// SaveContent сохраняет файл, который создал сам сервер, например превью
+// документа. Проверки те же, что и у загрузки
+func (s *Service) SaveContent(ctx context.Context, name string, content []byte) (saved *models.File, err error) {
+	ctx, span := tracing.Start(ctx, "files.SaveContent",
+		attribute.String("file.name", name),
+		attribute.Int("file.size", len(content)),
+	)
+	defer func() { tracing.End(span, err) }()
+
+	if err := s.check(name, int64(len(content))); err != nil {
+		return nil, err
+	}
+	return s.save(ctx, models.File{Name: name, Size: int64(len(content))}, bytes.NewReader(content))
+}
+
+func (s *Service) check(name string, size int64) error {
+	fileExt := strings.ToLower(filepath.Ext(name))
+	if !isAllowedExtension(fileExt) {
+		metrics.FileUploadFailures.WithLabelValues(metrics.UploadFailureExtension).Inc()
+		return errors.New("disallowed file extension for " + name + ": " + fileExt + "allowed extensions: " + strings.Join(slices.Collect(maps.Keys(allowed)), ", "))
+	}
+
+	if s.maxFileSize > 0 && size > s.maxFileSize {
+		metrics.FileUploadFailures.WithLabelValues(metrics.UploadFailureSize).Inc()
+		return fmt.Errorf("file %s is too large: %d bytes, max %d bytes", name, size, s.maxFileSize)
+	}
+	return nil
+}
+
+// save пишет содержимое в хранилище под новым именем и заводит запись в files
+// со сведениями metadata
+func (s *Service) save(ctx context.Context, metadata models.File, file io.Reader) (*models.File, error) {
 	fileID := uuid.New().String()
-	filename := fileID + fileExt
+	filename := fileID + strings.ToLower(filepath.Ext(metadata.Name))
 	path := filepath.Join(s.storagePath, filename)
 
 	dst, err := os.Create(path)
 	if err != nil {
+		metrics.FileUploadFailures.WithLabelValues(metrics.UploadFailureStorage).Inc()
 		return nil, fmt.Errorf("failed to create file: %w", err)
 	}
 	defer dst.Close()
 
-	if _, err := io.Copy(dst, file); err != nil {
+	written, err := io.Copy(dst, file)
+	if err != nil {
 		os.Remove(path) // Clean up
+		metrics.FileUploadFailures.WithLabelValues(metrics.UploadFailureStorage).Inc()
 		return nil, fmt.Errorf("failed to save file: %w", err)
 	}
 
-	metadata := models.File{
-		Name: fileHeader.Filename,
-		Size: fileHeader.Size,
-		Path: filename, // Store only filename, not full path
-	}
-	if err := s.db.Create(&metadata).Error; err != nil {
+	metadata.Path = filename // Store only filename, not full path
+	if err := database.Conn(ctx, s.db).Create(&metadata).Error; err != nil {
 		os.Remove(path) // Clean up
+		metrics.FileUploadFailures.WithLabelValues(metrics.UploadFailureDatabase).Inc()
 		return nil, fmt.Errorf("failed to save metadata: %w", err)
 	}
 
+	metrics.FileUploads.Inc()
+	metrics.FileUploadBytes.Add(float64(written))
+
+	s.logger.InfoContext(ctx, "file saved",
+		logging.Entity("file", metadata.Id),
+		slog.String("name", metadata.Name),
+		slog.Int64("size", metadata.Size),
+	)
+
 	return &metadata, nil
 }
 
-func (s *Service) DeleteFile(filename string) error {
+func (s *Service) DeleteFile(ctx context.Context, filename string) (err error) {
+	ctx, span := tracing.Start(ctx, "files.DeleteFile", attribute.String("file.path", filename))
+	defer func() { tracing.End(span, err) }()
+
 	// Security check - prevent path traversal
 	if strings.Contains(filename, "..") || strings.Contains(filename, "/") || strings.Contains(filename, "\\") {
 		return errors.New("invalid filename")
@@ -94,6 +199,9 @@ func (s *Service) DeleteFile(filename string) error {
 		}
 		return fmt.Errorf("failed to delete file: %w", err)
 	}
+
+	metrics.FileDeletes.Inc()
+	s.logger.InfoContext(ctx, "file deleted", slog.String("path", filename))
 	return nil
 }
 
@@ -0,0 +1,251 @@
+package files
+
+import (
+	"context"
+	"errors"
+	"federation-backend/app/db/models"
+	"federation-backend/app/metrics"
+	"fmt"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+// Типы использования файла - какая сущность на него ссылается
+const (
+	UsageTeamLogo        = "team_logo"
+	UsageGalleryPreview  = "gallery_preview"
+	UsageGalleryImage    = "gallery_image"
+	UsageNewsImage       = "news_image"
+	UsageDocument        = "document"
+	UsageDocumentPreview = "document_preview"
+	// UsageUnattached - запись в files, на которую никто не ссылается
+	UsageUnattached = "unattached"
+)
+
+var errDiskUsageUnsupported = errors.New("disk usage is not supported on this platform")
+
+// StorageUsage - сколько файлов лежит в хранилище и сколько места они занимают
+type StorageUsage struct {
+	Files int64 `json:"files"`
+	Bytes int64 `json:"bytes"`
+}
+
+// DiskUsage - состояние файловой системы, на которой лежит хранилище
+type DiskUsage struct {
+	TotalBytes     uint64 `json:"total_bytes"`
+	FreeBytes      uint64 `json:"free_bytes"`
+	AvailableBytes uint64 `json:"available_bytes"`
+}
+
+type StorageReport struct {
+	StoragePath string `json:"storage_path"`
+	// Disk - nil, если платформа не умеет сообщать свободное место
+	Disk *DiskUsage `json:"disk"`
+	// OnDisk - фактическое содержимое каталога хранилища
+	OnDisk StorageUsage `json:"on_disk"`
+	// Database - записи таблицы files
+	Database StorageUsage `json:"database"`
+	// ByUsage - записи files по типу использования. Файл, на который ссылаются
+	// несколько сущностей, учитывается в каждом типе
+	ByUsage map[string]StorageUsage `json:"by_usage"`
+	// MissingOnDisk - сколько записей каждого типа ссылаются на отсутствующий файл
+	MissingOnDisk map[string]int64 `json:"missing_on_disk"`
+	// Untracked - файлы в каталоге, о которых нет записи в базе
+	Untracked int64 `json:"untracked"`
+}
+
+// Usage обходит каталог хранилища и суммирует размеры файлов
+func (s *Service) Usage(ctx context.Context) (StorageUsage, error) {
+	var usage StorageUsage
+	err := s.walk(ctx, func(name string, size int64) {
+		usage.Files++
+		usage.Bytes += size
+	})
+	if err != nil {
+		return usage, err
+	}
+	return usage, nil
+}
+
+// ReportUsage пересчитывает занятое место и обновляет метрики хранилища.
+// Предназначен для периодического запуска через lifecycle.Periodic
+func (s *Service) ReportUsage(ctx context.Context) error {
+	usage, err := s.Usage(ctx)
+	if err != nil {
+		return err
+	}
+	metrics.StorageFiles.Set(float64(usage.Files))
+	metrics.StorageBytes.Set(float64(usage.Bytes))
+	return nil
+}
+
+// CheckWritable создаёт и удаляет временный файл в хранилище
+func (s *Service) CheckWritable(ctx context.Context) error {
+	probe, err := os.CreateTemp(s.storagePath, ".probe-*")
+	if err != nil {
+		return fmt.Errorf("storage is not writable: %w", err)
+	}
+	name := probe.Name()
+	_, writeErr := probe.WriteString("ok")
+	closeErr := probe.Close()
+	removeErr := os.Remove(name)
+
+	switch {
+	case writeErr != nil:
+		return fmt.Errorf("storage is not writable: %w", writeErr)
+	case closeErr != nil:
+		return fmt.Errorf("storage is not writable: %w", closeErr)
+	case removeErr != nil:
+		return fmt.Errorf("failed to remove storage probe file: %w", removeErr)
+	}
+	return nil
+}
+
+// StorageReport сверяет содержимое каталога хранилища с записями в базе
+func (s *Service) StorageReport(ctx context.Context) (*StorageReport, error) {
+	report := &StorageReport{
+		StoragePath:   s.storagePath,
+		ByUsage:       map[string]StorageUsage{},
+		MissingOnDisk: map[string]int64{},
+	}
+
+	onDisk := map[string]bool{}
+	err := s.walk(ctx, func(name string, size int64) {
+		onDisk[name] = true
+		report.OnDisk.Files++
+		report.OnDisk.Bytes += size
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	disk, err := diskUsage(s.storagePath)
+	if err == nil {
+		report.Disk = disk
+	} else if !errors.Is(err, errDiskUsageUnsupported) {
+		return nil, fmt.Errorf("failed to read disk usage: %w", err)
+	}
+
+	usages, err := s.fileUsages(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	db := s.db.WithContext(ctx)
+	rows, err := db.Model(&models.File{}).Select("id", "size", "path").Rows()
+	if err != nil {
+		return nil, fmt.Errorf("failed to read files: %w", err)
+	}
+	defer rows.Close()
+
+	tracked := map[string]bool{}
+	for rows.Next() {
+		var file models.File
+		if err := db.ScanRows(rows, &file); err != nil {
+			return nil, fmt.Errorf("failed to read files: %w", err)
+		}
+
+		name := filepath.Base(file.Path)
+		tracked[name] = true
+		report.Database.Files++
+		report.Database.Bytes += file.Size
+
+		types := usages[file.Id]
+		if len(types) == 0 {
+			types = []string{UsageUnattached}
+		}
+		for _, usage := range types {
+			stat := report.ByUsage[usage]
+			stat.Files++
+			stat.Bytes += file.Size
+			report.ByUsage[usage] = stat
+			if !onDisk[name] {
+				report.MissingOnDisk[usage]++
+			}
+		}
+	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("failed to read files: %w", err)
+	}
+
+	for name := range onDisk {
+		if !tracked[name] {
+			report.Untracked++
+		}
+	}
+
+	return report, nil
+}
+
+// fileUsages возвращает для каждого id из files типы сущностей, которые на него ссылаются
+func (s *Service) fileUsages(ctx context.Context) (map[uint][]string, error) {
+	db := s.db.WithContext(ctx)
+	sources := []struct {
+		usage string
+		query func(ids *[]uint) error
+	}{
+		{UsageTeamLogo, func(ids *[]uint) error {
+			return db.Model(&models.Team{}).Where("team_logo_id IS NOT NULL").Pluck("team_logo_id", ids).Error
+		}},
+		{UsageGalleryPreview, func(ids *[]uint) error {
+			return db.Model(&models.GalleryItem{}).Where("preview_id IS NOT NULL").Pluck("preview_id", ids).Error
+		}},
+		{UsageGalleryImage, func(ids *[]uint) error {
+			return db.Table("gallery_item_images").Distinct().Pluck("file_id", ids).Error
+		}},
+		{UsageNewsImage, func(ids *[]uint) error {
+			return db.Table("news_images").Distinct().Pluck("file_id", ids).Error
+		}},
+		// Файлы прежних версий документа тоже используются: их можно скачать и восстановить
+		{UsageDocument, func(ids *[]uint) error {
+			return db.Model(&models.DocumentVersion{}).Distinct().Pluck("file_id", ids).Error
+		}},
+		{UsageDocumentPreview, func(ids *[]uint) error {
+			return db.Model(&models.DocumentContent{}).Where("preview_id IS NOT NULL").Pluck("preview_id", ids).Error
+		}},
+	}
+
+	usages := map[uint][]string{}
+	for _, source := range sources {
+		var ids []uint
+		if err := source.query(&ids); err != nil {
+			return nil, fmt.Errorf("failed to read %s references: %w", source.usage, err)
+		}
+		for _, id := range ids {
+			usages[id] = append(usages[id], source.usage)
+		}
+	}
+	return usages, nil
+}
+
+// walk вызывает fn для каждого обычного файла в хранилище
+func (s *Service) walk(ctx context.Context, fn func(name string, size int64)) error {
+	err := filepath.WalkDir(s.storagePath, func(path string, entry fs.DirEntry, err error) error {
+		if err != nil {
+			return err
+		}
+		if ctx.Err() != nil {
+			return ctx.Err()
+		}
+		// Скрытые файлы и каталоги - служебные (например, проверка записи из
+		// CheckWritable и архивы альбомов)
+		if entry.IsDir() && path != s.storagePath && strings.HasPrefix(entry.Name(), ".") {
+			return fs.SkipDir
+		}
+		if !entry.Type().IsRegular() || strings.HasPrefix(entry.Name(), ".") {
+			return nil
+		}
+		info, err := entry.Info()
+		if err != nil {
+			return err
+		}
+		fn(entry.Name(), info.Size())
+		return nil
+	})
+	if err != nil {
+		return fmt.Errorf("failed to scan storage directory: %w", err)
+	}
+	return nil
+}
@@ -0,0 +1,330 @@
+package gallery_item
+
+import (
+	"archive/zip"
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"federation-backend/app/db/models"
+	"federation-backend/app/logging"
+	"fmt"
+	"io"
+	"io/fs"
+	"log/slog"
+	"os"
+	"path"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"time"
+
+	"gorm.io/gorm"
+)
+
+// Archive - ZIP-архив изображений альбома. Size известен заранее: файлы
+// кладутся в архив без сжатия
+type Archive struct {
+	// Name - имя файла архива для скачивания
+	Name    string
+	Size    int64
+	ModTime time.Time
+	// Path - готовый архив из кэша. Пусто - архив пишется на лету WriteArchive
+	Path string
+
+	itemID  uint
+	entries []archiveEntry
+	// cachePath - куда сохранить архив, пока он пишется клиенту
+	cachePath string
+}
+
+// archiveEntry - изображение в архиве
+type archiveEntry struct {
+	name     string
+	path     string
+	size     int64
+	modified time.Time
+}
+
+// Archive готовит архив изображений альбома id в порядке показа. Имена в
+// архиве - имена загруженных файлов, повторы получают суффикс " (2)", " (3)".
+// Изображения, файла которых нет в хранилище, пропускаются
+func (s *Service) Archive(ctx context.Context, id uint) (*Archive, error) {
+	var item models.GalleryItem
+	if err := withImages(s.db.WithContext(ctx)).First(&item, id).Error; err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, ErrNotFound
+		}
+		return nil, fmt.Errorf("failed to get gallery item: %w", err)
+	}
+
+	archive := &Archive{
+		Name:    archiveName(item),
+		ModTime: item.UpdatedAt,
+		itemID:  item.Id,
+	}
+	names := map[string]bool{}
+	var total int64
+	for _, image := range item.Images {
+		filePath := s.fileService.GetFilePath(filepath.Base(image.File.Path))
+		info, err := os.Stat(filePath)
+		if err != nil {
+			s.logger.WarnContext(ctx, "gallery image file is missing, skipped in archive",
+				logging.Entity("gallery_item", item.Id), slog.Uint64("file_id", uint64(image.FileID)), logging.Error(err))
+			continue
+		}
+		modified := image.File.CreatedAt
+		if image.File.TakenAt != nil {
+			modified = *image.File.TakenAt
+		}
+		archive.entries = append(archive.entries, archiveEntry{
+			name:     uniqueName(names, entryName(image.File.Name, image.FileID)),
+			path:     filePath,
+			size:     info.Size(),
+			modified: modified,
+		})
+		total += info.Size()
+	}
+
+	if s.config.ArchiveCacheMB > 0 && total >= s.config.ArchiveCacheSize() {
+		cachePath := filepath.Join(s.archiveDir, fmt.Sprintf("gallery-%d-%s.zip", item.Id, fingerprint(archive.entries)))
+		if info, err := os.Stat(cachePath); err == nil {
+			archive.Path, archive.Size, archive.ModTime = cachePath, info.Size(), info.ModTime()
+			return archive, nil
+		}
+		archive.cachePath = cachePath
+	}
+
+	archive.Size = archiveSize(archive.entries)
+	return archive, nil
+}
+
+// WriteArchive пишет архив в w, читая файлы по одному. Архив большого
+// альбома одновременно сохраняется в кэш; ошибка записи в кэш отдачу не
+// прерывает
+func (s *Service) WriteArchive(ctx context.Context, archive *Archive, w io.Writer) error {
+	var cache *cacheWriter
+	if archive.cachePath != "" {
+		cache = s.createCache(ctx, archive)
+		if cache != nil {
+			w = io.MultiWriter(w, cache)
+		}
+	}
+
+	err := writeZip(ctx, w, archive.entries, func(entry archiveEntry) (io.ReadCloser, error) {
+		return os.Open(entry.path)
+	})
+	if err != nil {
+		s.logger.WarnContext(ctx, "gallery archive interrupted", logging.Entity("gallery_item", archive.itemID), logging.Error(err))
+	}
+	if cache != nil {
+		s.finishCache(ctx, archive, cache, err)
+	}
+	return err
+}
+
+// writeZip пишет архив из entries без сжатия: фотографии уже сжаты. Размер
+// такого архива зависит только от имён, размеров и времени файлов, его
+// заранее считает archiveSize
+func writeZip(ctx context.Context, w io.Writer, entries []archiveEntry, open func(archiveEntry) (io.ReadCloser, error)) error {
+	archive := zip.NewWriter(w)
+	for _, entry := range entries {
+		if err := ctx.Err(); err != nil {
+			return err
+		}
+		dst, err := archive.CreateHeader(&zip.FileHeader{
+			Name:     entry.name,
+			Method:   zip.Store,
+			Modified: entry.modified,
+		})
+		if err != nil {
+			return err
+		}
+
+		src, err := open(entry)
+		if err != nil {
+			return fmt.Errorf("failed to open %s: %w", entry.name, err)
+		}
+		written, err := io.Copy(dst, src)
+		src.Close()
+		if err != nil {
+			return fmt.Errorf("failed to write %s: %w", entry.name, err)
+		}
+		// Размер уже обещан в Content-Length
+		if written != entry.size {
+			return fmt.Errorf("file %s changed while archiving: %d bytes instead of %d", entry.name, written, entry.size)
+		}
+	}
+	return archive.Close()
+}
+
+// Размеры записей ZIP, которые пишет archive/zip
+const (
+	zipLocalHeaderLen   = 30
+	zipCentralHeaderLen = 46
+	zipEndLen           = 22
+	zipDescriptorLen    = 16
+	zipDescriptor64Len  = 24
+	zip64EndLen         = 56
+	zip64LocatorLen     = 20
+	zipExtTimeLen       = 9
+	zip64ExtraHeaderLen = 4
+	zip64ExtraFieldLen  = 8
+	zipMax32            = 1<<32 - 1
+	zipMax16            = 1<<16 - 1
+)
+
+// archiveSize - размер архива, который writeZip запишет из entries. Считается
+// по формату без чтения файлов и повторяет решения archive/zip о zip64
+func archiveSize(entries []archiveEntry) int64 {
+	var offset, central int64
+	usedZip64 := false
+	for _, entry := range entries {
+		extra := int64(0)
+		if !entry.modified.IsZero() {
+			extra = zipExtTimeLen
+		}
+
+		// Поле zip64 в центральном каталоге: оба размера и/или смещение
+		zip64Fields := int64(0)
+		if entry.size >= zipMax32 {
+			zip64Fields += 2
+		}
+		if offset >= zipMax32 {
+			zip64Fields++
+		}
+		centralExtra := extra
+		if zip64Fields > 0 {
+			usedZip64 = true
+			centralExtra += zip64ExtraHeaderLen + zip64Fields*zip64ExtraFieldLen
+		}
+		central += zipCentralHeaderLen + int64(len(entry.name)) + centralExtra
+
+		// Размеры пишутся в data descriptor после содержимого
+		descriptor := int64(zipDescriptorLen)
+		if entry.size > zipMax32 {
+			descriptor = zipDescriptor64Len
+		}
+		offset += zipLocalHeaderLen + int64(len(entry.name)) + extra + entry.size + descriptor
+	}
+
+	size := offset + central + zipEndLen
+	if usedZip64 || len(entries) >= zipMax16 || central >= zipMax32 || offset >= zipMax32 {
+		size += zip64EndLen + zip64LocatorLen
+	}
+	return size
+}
+
+// cacheWriter пишет копию архива во временный файл кэша. После первой
+// ошибки запись прекращается, но ошибка не возвращается, чтобы не прервать
+// отдачу клиенту
+type cacheWriter struct {
+	file *os.File
+	err  error
+}
+
+func (w *cacheWriter) Write(p []byte) (int, error) {
+	if w.err == nil {
+		_, w.err = w.file.Write(p)
+	}
+	return len(p), nil
+}
+
+func (s *Service) createCache(ctx context.Context, archive *Archive) *cacheWriter {
+	if err := os.MkdirAll(s.archiveDir, 0755); err != nil {
+		s.logger.WarnContext(ctx, "failed to create gallery archive cache", logging.Entity("gallery_item", archive.itemID), logging.Error(err))
+		return nil
+	}
+	file, err := os.CreateTemp(s.archiveDir, ".gallery-*.tmp")
+	if err != nil {
+		s.logger.WarnContext(ctx, "failed to create gallery archive cache", logging.Entity("gallery_item", archive.itemID), logging.Error(err))
+		return nil
+	}
+	return &cacheWriter{file: file}
+}
+
+// finishCache кладёт полностью записанный архив в кэш вместо прежних архивов
+// альбома, недописанный удаляет
+func (s *Service) finishCache(ctx context.Context, archive *Archive, cache *cacheWriter, err error) {
+	tmp := cache.file.Name()
+	if closeErr := cache.file.Close(); cache.err == nil {
+		cache.err = closeErr
+	}
+	if err != nil || cache.err != nil {
+		if cache.err != nil {
+			s.logger.WarnContext(ctx, "failed to cache gallery archive", logging.Entity("gallery_item", archive.itemID), logging.Error(cache.err))
+		}
+		os.Remove(tmp)
+		return
+	}
+
+	s.removeArchives(ctx, archive.itemID)
+	if err := os.Rename(tmp, archive.cachePath); err != nil {
+		s.logger.WarnContext(ctx, "failed to cache gallery archive", logging.Entity("gallery_item", archive.itemID), logging.Error(err))
+		os.Remove(tmp)
+		return
+	}
+	s.logger.InfoContext(ctx, "gallery archive cached", logging.Entity("gallery_item", archive.itemID), slog.Int64("size", archive.Size))
+}
+
+// removeArchives удаляет сохранённые архивы альбома
+func (s *Service) removeArchives(ctx context.Context, itemID uint) {
+	paths, _ := filepath.Glob(filepath.Join(s.archiveDir, fmt.Sprintf("gallery-%d-*.zip", itemID)))
+	for _, archivePath := range paths {
+		if err := os.Remove(archivePath); err != nil && !errors.Is(err, fs.ErrNotExist) {
+			s.logger.WarnContext(ctx, "failed to remove cached gallery archive", logging.Entity("gallery_item", itemID), logging.Error(err))
+		}
+	}
+}
+
+// fingerprint - отпечаток состава архива: новый набор, порядок или имена
+// изображений дают новый файл кэша
+func fingerprint(entries []archiveEntry) string {
+	hash := sha256.New()
+	for _, entry := range entries {
+		fmt.Fprintf(hash, "%s\x00%s\x00%d\x00%d\n", entry.name, filepath.Base(entry.path), entry.size, entry.modified.Unix())
+	}
+	return hex.EncodeToString(hash.Sum(nil))[:16]
+}
+
+// archiveName - имя архива по названию альбома
+func archiveName(item models.GalleryItem) string {
+	name := strings.TrimSpace(strings.Map(safeRune, item.Name))
+	if name == "" {
+		name = "gallery-" + strconv.FormatUint(uint64(item.Id), 10)
+	}
+	return name + ".zip"
+}
+
+// entryName - имя файла в архиве без каталогов, которые мог передать браузер
+func entryName(name string, fileID uint) string {
+	name = path.Base(strings.ReplaceAll(name, `\`, "/"))
+	name = strings.TrimSpace(strings.Map(safeRune, name))
+	if name == "" || name == "." || name == ".." {
+		name = "image-" + strconv.FormatUint(uint64(fileID), 10)
+	}
+	return name
+}
+
+// safeRune убирает управляющие символы и символы, недопустимые в именах
+// файлов Windows
+func safeRune(r rune) rune {
+	if r < 0x20 || r == 0x7f || strings.ContainsRune(`<>:"/\|?*`, r) {
+		return -1
+	}
+	return r
+}
+
+// uniqueName добавляет к повторяющемуся имени " (2)", " (3)" перед
+// расширением. Регистр не учитывается: в Windows и macOS "A.jpg" и "a.jpg" -
+// один файл
+func uniqueName(taken map[string]bool, name string) string {
+	ext := filepath.Ext(name)
+	base := strings.TrimSuffix(name, ext)
+	candidate := name
+	for i := 2; taken[strings.ToLower(candidate)]; i++ {
+		candidate = fmt.Sprintf("%s (%d)%s", base, i, ext)
+	}
+	taken[strings.ToLower(candidate)] = true
+	return candidate
+}
@@ -0,0 +1,200 @@
+package gallery_item
+
+import (
+	"archive/zip"
+	"bytes"
+	"context"
+	"io"
+	"log/slog"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestUniqueName(t *testing.T) {
+	tests := []struct {
+		name  string
+		names []string
+		want  []string
+	}{
+		{
+			name:  "distinct names",
+			names: []string{"a.jpg", "b.jpg"},
+			want:  []string{"a.jpg", "b.jpg"},
+		},
+		{
+			name:  "repeats get a counter before the extension",
+			names: []string{"IMG.jpg", "IMG.jpg", "IMG.jpg"},
+			want:  []string{"IMG.jpg", "IMG (2).jpg", "IMG (3).jpg"},
+		},
+		{
+			name:  "case is ignored",
+			names: []string{"Фото.JPG", "фото.jpg"},
+			want:  []string{"Фото.JPG", "фото (2).jpg"},
+		},
+		{
+			name:  "generated name is taken too",
+			names: []string{"a (2).jpg", "a.jpg", "a.jpg"},
+			want:  []string{"a (2).jpg", "a.jpg", "a (3).jpg"},
+		},
+		{
+			name:  "no extension",
+			names: []string{"scan", "scan"},
+			want:  []string{"scan", "scan (2)"},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			taken := map[string]bool{}
+			for i, name := range tt.names {
+				if got := uniqueName(taken, name); got != tt.want[i] {
+					t.Errorf("name %d: got %q, want %q", i, got, tt.want[i])
+				}
+			}
+		})
+	}
+}
+
+func TestEntryName(t *testing.T) {
+	tests := []struct {
+		name string
+		want string
+	}{
+		{"photo.jpg", "photo.jpg"},
+		{`C:\Users\me\photo.jpg`, "photo.jpg"},
+		{"../../etc/passwd", "passwd"},
+		{"a<b>:c?.png", "abc.png"},
+		{"..", "image-7"},
+		{"", "image-7"},
+	}
+	for _, tt := range tests {
+		if got := entryName(tt.name, 7); got != tt.want {
+			t.Errorf("entryName(%q) = %q, want %q", tt.name, got, tt.want)
+		}
+	}
+}
+
+// Content-Length архива считается заранее и должен совпасть с записанным
+func TestArchiveSizeMatchesWritten(t *testing.T) {
+	modified := time.Date(2023, 5, 6, 7, 8, 9, 0, time.UTC)
+	tests := []struct {
+		name  string
+		files map[string]int
+		// zeroTime - у файлов нет времени изменения
+		zeroTime bool
+	}{
+		{name: "empty album", files: map[string]int{}},
+		{name: "one file", files: map[string]int{"a.jpg": 1000}},
+		{name: "empty file", files: map[string]int{"empty.png": 0}},
+		{name: "cyrillic names", files: map[string]int{"Финал кубка.jpg": 2048, "Награждение (2).jpg": 10}},
+		{name: "large file", files: map[string]int{"big.jpg": 3 << 20, "small.jpg": 1}},
+		{name: "no modification time", files: map[string]int{"a.jpg": 5}, zeroTime: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			modified := modified
+			if tt.zeroTime {
+				modified = time.Time{}
+			}
+			dir := t.TempDir()
+			contents := map[string][]byte{}
+			var entries []archiveEntry
+			for name, size := range tt.files {
+				content := bytes.Repeat([]byte{byte(len(name))}, size)
+				path := filepath.Join(dir, strings.ReplaceAll(name, " ", "_"))
+				if err := os.WriteFile(path, content, 0644); err != nil {
+					t.Fatal(err)
+				}
+				contents[name] = content
+				entries = append(entries, archiveEntry{name: name, path: path, size: int64(size), modified: modified})
+			}
+
+			size := archiveSize(entries)
+			service := &Service{logger: slog.New(slog.NewTextHandler(io.Discard, nil))}
+			var out bytes.Buffer
+			if err := service.WriteArchive(context.Background(), &Archive{entries: entries, Size: size}, &out); err != nil {
+				t.Fatal(err)
+			}
+			if int64(out.Len()) != size {
+				t.Fatalf("archiveSize = %d, written %d bytes", size, out.Len())
+			}
+
+			reader, err := zip.NewReader(bytes.NewReader(out.Bytes()), int64(out.Len()))
+			if err != nil {
+				t.Fatalf("written archive does not open: %v", err)
+			}
+			if len(reader.File) != len(tt.files) {
+				t.Fatalf("archive has %d files, want %d", len(reader.File), len(tt.files))
+			}
+			for _, file := range reader.File {
+				if file.Method != zip.Store {
+					t.Errorf("%s is compressed", file.Name)
+				}
+				src, err := file.Open()
+				if err != nil {
+					t.Fatal(err)
+				}
+				got, err := io.ReadAll(src)
+				src.Close()
+				if err != nil || !bytes.Equal(got, contents[file.Name]) {
+					t.Errorf("%s: content differs (%v)", file.Name, err)
+				}
+			}
+		})
+	}
+}
+
+// Записи от 4 ГиБ и смещения за 4 ГиБ требуют полей zip64. Содержимое -
+// нули, записанное только считается
+func TestArchiveSizeZip64(t *testing.T) {
+	if testing.Short() {
+		t.Skip("writes more than 4 GiB")
+	}
+	entries := []archiveEntry{
+		{name: "big.jpg", size: 1 << 32, modified: time.Date(2023, 5, 6, 7, 8, 9, 0, time.UTC)},
+		{name: "after.jpg", size: 10},
+		{name: "exact.jpg", size: 1<<32 - 1, modified: time.Date(2023, 5, 6, 7, 8, 9, 0, time.UTC)},
+	}
+	var counter countWriter
+	err := writeZip(context.Background(), &counter, entries, func(entry archiveEntry) (io.ReadCloser, error) {
+		return io.NopCloser(io.LimitReader(zeros{}, entry.size)), nil
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if size := archiveSize(entries); size != counter.n {
+		t.Fatalf("archiveSize = %d, written %d bytes", size, counter.n)
+	}
+}
+
+type zeros struct{}
+
+func (zeros) Read(p []byte) (int, error) {
+	clear(p)
+	return len(p), nil
+}
+
+type countWriter struct {
+	n int64
+}
+
+func (w *countWriter) Write(p []byte) (int, error) {
+	w.n += int64(len(p))
+	return len(p), nil
+}
+
+// Файл, изменившийся после подсчёта размера, обрывает архив
+func TestWriteArchiveFileChanged(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "a.jpg")
+	if err := os.WriteFile(path, []byte("12345"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	entries := []archiveEntry{{name: "a.jpg", path: path, size: 4, modified: time.Now()}}
+	service := &Service{logger: slog.New(slog.NewTextHandler(io.Discard, nil))}
+	if err := service.WriteArchive(context.Background(), &Archive{entries: entries}, io.Discard); err == nil {
+		t.Fatal("want error for a file that changed size")
+	}
+}
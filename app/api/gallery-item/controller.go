@@ -1,10 +1,17 @@
 package gallery_item
 
 import (
+	"errors"
 	"federation-backend/app/api/shared"
-	"log"
+	"federation-backend/app/api/shared/photo"
+	"federation-backend/app/config"
+	"federation-backend/app/tracing"
+	"log/slog"
+	"mime"
 	"net/http"
+	"os"
 	"strconv"
+	"time"
 
 	"gorm.io/gorm"
 
@@ -15,15 +22,27 @@ type Controller struct {
 	service *Service
 }
 
+// RegisterExtraRoutes - подписи и авторы отдельных изображений альбома и
+// скачивание альбома архивом
+func (c *Controller) RegisterExtraRoutes(router *gin.RouterGroup) {
+	router.PUT("/:id/images/:file_id", c.UpdateImage)
+	router.GET("/:id/archive", c.Archive)
+	router.HEAD("/:id/archive", c.Archive)
+}
+
 func (c *Controller) Create(ctx *gin.Context) {
 	var dto CreateGalleryItemDTO
-	if err := ctx.ShouldBind(&dto); err != nil {
+	// Разбор multipart-формы со всеми файлами - отдельный спан, чтобы отличать его от сохранения
+	_, span := tracing.Start(ctx.Request.Context(), "gallery.bind_form")
+	err := ctx.ShouldBind(&dto)
+	tracing.End(span, err)
+	if err != nil {
 		ctx.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
 		return
 	}
 
-	if err := c.service.Create(&dto); err != nil {
-		ctx.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+	if err := c.service.Create(ctx.Request.Context(), &dto); err != nil {
+		respondError(ctx, err)
 		return
 	}
 
@@ -37,9 +56,9 @@ func (c *Controller) Get(ctx *gin.Context) {
 		return
 	}
 
-	item, err := c.service.Get(uint(id))
+	item, err := c.service.Get(ctx.Request.Context(), uint(id))
 	if err != nil {
-		ctx.JSON(http.StatusNotFound, gin.H{"error": err.Error()})
+		respondError(ctx, err)
 		return
 	}
 
@@ -54,13 +73,16 @@ func (c *Controller) Update(ctx *gin.Context) {
 	}
 
 	var dto UpdateGalleryItemDTO
-	if err := ctx.ShouldBind(&dto); err != nil {
+	_, span := tracing.Start(ctx.Request.Context(), "gallery.bind_form")
+	err = ctx.ShouldBind(&dto)
+	tracing.End(span, err)
+	if err != nil {
 		ctx.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
 		return
 	}
 
-	if err := c.service.Update(uint(id), &dto); err != nil {
-		ctx.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+	if err := c.service.Update(ctx.Request.Context(), uint(id), &dto); err != nil {
+		respondError(ctx, err)
 		return
 	}
 
@@ -74,8 +96,8 @@ func (c *Controller) Delete(ctx *gin.Context) {
 		return
 	}
 
-	if err := c.service.Delete(uint(id)); err != nil {
-		ctx.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+	if err := c.service.Delete(ctx.Request.Context(), uint(id)); err != nil {
+		respondError(ctx, err)
 		return
 	}
 
@@ -83,15 +105,100 @@ func (c *Controller) Delete(ctx *gin.Context) {
 }
 
 func (c *Controller) GetAll(ctx *gin.Context) {
-	items, err := c.service.GetAll()
+	items, err := c.service.GetAll(ctx.Request.Context())
 	if err != nil {
 		ctx.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
 	}
 	ctx.JSON(http.StatusOK, items)
 }
 
-func NewController(db *gorm.DB, service shared.FileProcessor, logger *log.Logger) *Controller {
+// UpdateImage меняет подпись и автора изображения альбома
+func (c *Controller) UpdateImage(ctx *gin.Context) {
+	id, err := strconv.ParseUint(ctx.Param("id"), 10, 32)
+	if err != nil {
+		ctx.JSON(http.StatusBadRequest, gin.H{"error": "invalid id"})
+		return
+	}
+	fileID, err := strconv.ParseUint(ctx.Param("file_id"), 10, 32)
+	if err != nil {
+		ctx.JSON(http.StatusBadRequest, gin.H{"error": "invalid file_id"})
+		return
+	}
+
+	var dto UpdateImageDTO
+	if err := ctx.ShouldBindJSON(&dto); err != nil {
+		ctx.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	image, err := c.service.UpdateImage(ctx.Request.Context(), uint(id), uint(fileID), &dto)
+	if err != nil {
+		respondError(ctx, err)
+		return
+	}
+	ctx.JSON(http.StatusOK, image)
+}
+
+// Archive отдаёт изображения альбома ZIP-архивом. Архив большого альбома
+// отдаётся из кэша с поддержкой Range, остальные пишутся на лету
+func (c *Controller) Archive(ctx *gin.Context) {
+	id, err := strconv.ParseUint(ctx.Param("id"), 10, 32)
+	if err != nil {
+		ctx.JSON(http.StatusBadRequest, gin.H{"error": "invalid id"})
+		return
+	}
+
+	archive, err := c.service.Archive(ctx.Request.Context(), uint(id))
+	if err != nil {
+		respondError(ctx, err)
+		return
+	}
+
+	ctx.Header("Content-Disposition", mime.FormatMediaType("attachment", map[string]string{"filename": archive.Name}))
+	ctx.Header("Content-Type", "application/zip")
+	// Архив большого альбома отдаётся дольше server.write_timeout: снимаем
+	// срок записи для этого ответа. Без поддержки у ResponseWriter остаётся общий
+	_ = http.NewResponseController(ctx.Writer).SetWriteDeadline(time.Time{})
+	if archive.Path != "" {
+		file, err := os.Open(archive.Path)
+		if err != nil {
+			ctx.Writer.Header().Del("Content-Disposition")
+			ctx.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+			return
+		}
+		defer file.Close()
+		http.ServeContent(ctx.Writer, ctx.Request, archive.Name, archive.ModTime, file)
+		return
+	}
+
+	ctx.Header("Content-Length", strconv.FormatInt(archive.Size, 10))
+	ctx.Header("Last-Modified", archive.ModTime.UTC().Format(http.TimeFormat))
+	ctx.Status(http.StatusOK)
+	if ctx.Request.Method == http.MethodHead {
+		return
+	}
+	if err := c.service.WriteArchive(ctx.Request.Context(), archive, ctx.Writer); err != nil {
+		// Заголовки уже ушли: обрываем соединение, чтобы клиент не принял
+		// обрезанный архив за целый
+		panic(http.ErrAbortHandler)
+	}
+}
+
+func respondError(ctx *gin.Context, err error) {
+	switch {
+	case errors.Is(err, ErrNotFound), errors.Is(err, ErrImageNotFound):
+		ctx.JSON(http.StatusNotFound, gin.H{"error": err.Error()})
+	case shared.IsChapterError(err), errors.Is(err, ErrInvalidOrder), errors.Is(err, ErrInvalidCover),
+		errors.Is(err, ErrCoverConflict), errors.Is(err, ErrTooManyLabels), errors.Is(err, ErrDateRequired),
+		errors.Is(err, photo.ErrInvalidImage):
+		ctx.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+	default:
+		ctx.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+	}
+}
+
+func NewController(db *gorm.DB, service shared.FileProcessor, config config.GalleryConfig, logger *slog.Logger) *Controller {
 	return &Controller{
-		service: NewService(db, service, logger),
+		service: NewService(db, service, config, logger),
 	}
 }
@@ -0,0 +1,352 @@
+package gallery_item
+
+import (
+	"context"
+	"errors"
+	"federation-backend/app/api/shared/photo"
+	"federation-backend/app/db/models"
+	"federation-backend/app/logging"
+	"fmt"
+	"log/slog"
+	"mime/multipart"
+	"path/filepath"
+	"time"
+
+	"gorm.io/gorm"
+)
+
+// UpdateImage меняет подпись и автора изображения fileID альбома id
+func (s *Service) UpdateImage(ctx context.Context, id, fileID uint, dto *UpdateImageDTO) (models.GalleryImage, error) {
+	var image models.GalleryImage
+	err := s.db.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		var count int64
+		if err := tx.Model(&models.GalleryItem{}).Where("id = ?", id).Count(&count).Error; err != nil {
+			return fmt.Errorf("failed to get gallery item: %w", err)
+		}
+		if count == 0 {
+			return ErrNotFound
+		}
+
+		err := tx.Preload("File").Where("gallery_item_id = ? AND file_id = ?", id, fileID).First(&image).Error
+		if err != nil {
+			if errors.Is(err, gorm.ErrRecordNotFound) {
+				return ErrImageNotFound
+			}
+			return fmt.Errorf("failed to get gallery image: %w", err)
+		}
+
+		updates := map[string]any{}
+		if dto.Caption != nil {
+			image.Caption = *dto.Caption
+			updates["caption"] = image.Caption
+		}
+		if dto.Photographer != nil {
+			image.Photographer = *dto.Photographer
+			updates["photographer"] = image.Photographer
+		}
+		if len(updates) == 0 {
+			return nil
+		}
+		err = tx.Model(&models.GalleryImage{}).Where("gallery_item_id = ? AND file_id = ?", id, fileID).Updates(updates).Error
+		if err != nil {
+			return fmt.Errorf("failed to update gallery image: %w", err)
+		}
+		return nil
+	})
+	return image, err
+}
+
+// updateImages обрабатывает обновление изображений
+// Файлы удалённых изображений добавляются в obsolete
+func (s *Service) updateImages(ctx context.Context, tx *gorm.DB, item *models.GalleryItem, dto *UpdateGalleryItemDTO, obsolete *[]models.File) error {
+	// Удаляем помеченные изображения
+	if err := s.deleteMarkedImages(tx, item, dto.DeletedImages, obsolete); err != nil {
+		return err
+	}
+
+	// Синхронизируем старые изображения
+	if err := s.syncOldImages(ctx, tx, item, dto.OldImages, dto.DeletedImages); err != nil {
+		return err
+	}
+
+	// Расставляем оставшиеся изображения, новые встанут после них
+	if err := s.reorderImages(tx, item.Id, dto.Order); err != nil {
+		return err
+	}
+
+	// Добавляем новые изображения
+	if _, err := s.addImages(ctx, tx, item.Id, dto.NewImages, dto.NewCaptions, dto.NewPhotographers); err != nil {
+		return err
+	}
+
+	item.Images = nil
+	if err := imagesOf(tx, item.Id, &item.Images); err != nil {
+		return err
+	}
+	return nil
+}
+
+// imagesOf загружает изображения альбома в порядке показа
+func imagesOf(tx *gorm.DB, itemID uint, images *[]models.GalleryImage) error {
+	err := tx.Preload("File").Where("gallery_item_id = ?", itemID).
+		Order("position, file_id").Find(images).Error
+	if err != nil {
+		return fmt.Errorf("failed to get gallery images: %w", err)
+	}
+	return nil
+}
+
+// deleteMarkedImages убирает из альбома изображения, помеченные для
+// удаления, их файлы добавляются в obsolete
+func (s *Service) deleteMarkedImages(tx *gorm.DB, item *models.GalleryItem, deletedImages []int, obsolete *[]models.File) error {
+	if len(deletedImages) == 0 {
+		return nil
+	}
+
+	// Находим изображения для удаления только среди изображений альбома
+	var imagesToDelete []models.GalleryImage
+	if err := tx.Preload("File").Where("gallery_item_id = ? AND file_id IN ?", item.Id, deletedImages).
+		Find(&imagesToDelete).Error; err != nil {
+		return fmt.Errorf("failed to find images to delete: %w", err)
+	}
+
+	for _, image := range imagesToDelete {
+		if err := s.removeImage(tx, image); err != nil {
+			return err
+		}
+		*obsolete = append(*obsolete, image.File)
+	}
+
+	return nil
+}
+
+// syncOldImages убирает из альбома изображения, которых нет в oldImages.
+// Файлы при этом остаются
+func (s *Service) syncOldImages(ctx context.Context, tx *gorm.DB, item *models.GalleryItem, oldImages []int, deletedImages []int) error {
+	if oldImages == nil {
+		return nil // Не обновляем старые изображения, если не указаны
+	}
+
+	var currentImages []models.GalleryImage
+	if err := tx.Where("gallery_item_id = ?", item.Id).Find(&currentImages).Error; err != nil {
+		return fmt.Errorf("failed to get current images: %w", err)
+	}
+
+	for _, currentImage := range currentImages {
+		// Пропускаем если уже удалено
+		if sliceContains(deletedImages, int(currentImage.FileID)) {
+			continue
+		}
+
+		if !sliceContains(oldImages, int(currentImage.FileID)) {
+			if err := s.removeImage(tx, currentImage); err != nil {
+				return err
+			}
+		}
+	}
+
+	return nil
+}
+
+// removeImage убирает изображение из альбома (но не из базы файлов)
+func (s *Service) removeImage(tx *gorm.DB, image models.GalleryImage) error {
+	err := tx.Where("gallery_item_id = ? AND file_id = ?", image.GalleryItemID, image.FileID).
+		Delete(&models.GalleryImage{}).Error
+	if err != nil {
+		return fmt.Errorf("failed to remove image %d from gallery item: %w", image.FileID, err)
+	}
+	return nil
+}
+
+// reorderImages нумерует изображения альбома с 1 без пропусков: в порядке
+// order, если он задан, иначе в прежнем
+func (s *Service) reorderImages(tx *gorm.DB, itemID uint, order []uint) error {
+	var images []models.GalleryImage
+	if err := tx.Where("gallery_item_id = ?", itemID).Order("position, file_id").Find(&images).Error; err != nil {
+		return fmt.Errorf("failed to get gallery images: %w", err)
+	}
+
+	if order != nil {
+		if len(order) != len(images) {
+			return ErrInvalidOrder
+		}
+		byFile := make(map[uint]models.GalleryImage, len(images))
+		for _, image := range images {
+			byFile[image.FileID] = image
+		}
+		ordered := make([]models.GalleryImage, 0, len(order))
+		for _, fileID := range order {
+			image, ok := byFile[fileID]
+			if !ok {
+				return ErrInvalidOrder
+			}
+			delete(byFile, fileID)
+			ordered = append(ordered, image)
+		}
+		images = ordered
+	}
+
+	for i, image := range images {
+		position := uint(i + 1)
+		if image.Position == position {
+			continue
+		}
+		err := tx.Model(&models.GalleryImage{}).Where("gallery_item_id = ? AND file_id = ?", itemID, image.FileID).
+			Update("position", position).Error
+		if err != nil {
+			return fmt.Errorf("failed to reorder gallery images: %w", err)
+		}
+	}
+	return nil
+}
+
+// addImages сохраняет файлы параллельно и добавляет их в конец альбома с
+// подписями captions и авторами photographers по порядку
+func (s *Service) addImages(ctx context.Context, tx *gorm.DB, itemID uint, headers []*multipart.FileHeader, captions, photographers []string) ([]models.GalleryImage, error) {
+	if len(headers) == 0 {
+		return nil, nil
+	}
+
+	// Сохраняем файлы параллельно
+	files, errs := s.fileService.SaveFilesParallel(ctx, headers)
+
+	// Проверяем ошибки
+	var saveErrors []error
+	for i, err := range errs {
+		if err != nil {
+			saveErrors = append(saveErrors, fmt.Errorf("image %d: %w", i, err))
+		}
+	}
+
+	if len(saveErrors) > 0 {
+		// Удаляем успешно сохраненные файлы при наличии ошибок
+		for i, file := range files {
+			if file != nil && errs[i] == nil {
+				filename := filepath.Base(file.Path)
+				s.fileService.DeleteFile(ctx, filename)
+			}
+		}
+		return nil, fmt.Errorf("failed to save some images: %w", errors.Join(saveErrors...))
+	}
+
+	var last uint
+	err := tx.Model(&models.GalleryImage{}).Where("gallery_item_id = ?", itemID).
+		Select("COALESCE(MAX(position), 0)").Scan(&last).Error
+	if err != nil {
+		return nil, fmt.Errorf("failed to get last image position: %w", err)
+	}
+
+	images := make([]models.GalleryImage, 0, len(files))
+	for i, file := range files {
+		image := models.GalleryImage{
+			GalleryItemID: itemID,
+			FileID:        file.Id,
+			Position:      last + uint(i) + 1,
+		}
+		if i < len(captions) {
+			image.Caption = captions[i]
+		}
+		if i < len(photographers) {
+			image.Photographer = photographers[i]
+		}
+		if err := tx.Omit("File").Create(&image).Error; err != nil {
+			return nil, fmt.Errorf("failed to associate image: %w", err)
+		}
+		image.File = *file
+		images = append(images, image)
+	}
+
+	return images, nil
+}
+
+// updateCover меняет обложку на новый файл preview или на изображение
+// cover_id. Если обложкой было изображение, которое убрали из альбома, ею
+// становится первое оставшееся. Отдельно загруженная прежняя обложка
+// добавляется в obsolete
+func (s *Service) updateCover(ctx context.Context, item *models.GalleryItem, dto *UpdateGalleryItemDTO, coverWasImage bool, obsolete *[]models.File) error {
+	previous := item.Preview
+	switch {
+	case dto.Preview != nil:
+		file, err := s.fileService.SaveFile(ctx, dto.Preview)
+		if err != nil {
+			return fmt.Errorf("failed to save preview: %w", err)
+		}
+		item.PreviewID, item.Preview = &file.Id, file
+	case dto.CoverID != nil:
+		image := findImage(item.Images, *dto.CoverID)
+		if image == nil {
+			return ErrInvalidCover
+		}
+		item.PreviewID, item.Preview = &image.FileID, &image.File
+	case coverWasImage && !isImageCover(item):
+		item.PreviewID, item.Preview = nil, nil
+		if len(item.Images) > 0 {
+			item.PreviewID, item.Preview = &item.Images[0].FileID, &item.Images[0].File
+		}
+	default:
+		return nil
+	}
+
+	if !coverWasImage && previous != nil && previous.Id != *item.PreviewID {
+		*obsolete = append(*obsolete, *previous)
+	}
+	return nil
+}
+
+// isImageCover - обложка альбома одно из его изображений
+func isImageCover(item *models.GalleryItem) bool {
+	return item.PreviewID != nil && findImage(item.Images, *item.PreviewID) != nil
+}
+
+// earliestTakenAt - самое раннее время съёмки среди загружаемых фотографий
+// по их EXIF. Файлы читаются только до данных изображения
+func earliestTakenAt(headers []*multipart.FileHeader) (*time.Time, error) {
+	var earliest *time.Time
+	for _, header := range headers {
+		if !photo.Supported(header.Filename) {
+			continue
+		}
+		file, err := header.Open()
+		if err != nil {
+			return nil, fmt.Errorf("failed to open uploaded file: %w", err)
+		}
+		taken := photo.TakenAt(file, header.Filename)
+		file.Close()
+		if taken != nil && (earliest == nil || taken.Before(*earliest)) {
+			earliest = taken
+		}
+	}
+	return earliest, nil
+}
+
+// findImage ищет изображение альбома по id файла
+func findImage(images []models.GalleryImage, fileID uint) *models.GalleryImage {
+	for i := range images {
+		if images[i].FileID == fileID {
+			return &images[i]
+		}
+	}
+	return nil
+}
+
+// deleteFile удаляет файл альбома с диска и из базы. Ошибки только пишутся
+// в лог, чтобы не откатывать изменение альбома из-за оставшегося файла
+func (s *Service) deleteFile(ctx context.Context, tx *gorm.DB, itemID uint, file models.File) {
+	filename := filepath.Base(file.Path)
+	if err := s.fileService.DeleteFile(ctx, filename); err != nil {
+		s.logger.WarnContext(ctx, "failed to delete image file", logging.Entity("gallery_item", itemID), slog.String("file", filename), logging.Error(err))
+	}
+	if err := tx.Delete(&models.File{}, file.Id).Error; err != nil {
+		s.logger.WarnContext(ctx, "failed to delete file record", logging.Entity("gallery_item", itemID), slog.Uint64("file_id", uint64(file.Id)), logging.Error(err))
+	}
+}
+
+// sliceContains проверяет наличие элемента в срезе
+func sliceContains(slice []int, val int) bool {
+	for _, item := range slice {
+		if item == val {
+			return true
+		}
+	}
+	return false
+}
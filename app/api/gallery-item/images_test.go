@@ -0,0 +1,125 @@
+package gallery_item
+
+import (
+	"errors"
+	"path/filepath"
+	"slices"
+	"testing"
+
+	"federation-backend/app/db/models"
+
+	"gorm.io/driver/sqlite"
+	"gorm.io/gorm"
+	"gorm.io/gorm/logger"
+)
+
+func TestReorderImages(t *testing.T) {
+	const itemID = 1
+	tests := []struct {
+		name string
+		// positions - file_id -> position до перестановки
+		positions map[uint]uint
+		order     []uint
+		// want - file_id по возрастанию position после перестановки
+		want    []uint
+		wantErr error
+	}{
+		{
+			name:      "gaps are closed in the current order",
+			positions: map[uint]uint{10: 2, 11: 5, 12: 9},
+			want:      []uint{10, 11, 12},
+		},
+		{
+			name:      "equal positions are ordered by file id",
+			positions: map[uint]uint{12: 0, 10: 0, 11: 1},
+			want:      []uint{10, 12, 11},
+		},
+		{
+			name:      "explicit order",
+			positions: map[uint]uint{10: 1, 11: 2, 12: 3},
+			order:     []uint{12, 10, 11},
+			want:      []uint{12, 10, 11},
+		},
+		{
+			name:      "order misses an image",
+			positions: map[uint]uint{10: 1, 11: 2, 12: 3},
+			order:     []uint{12, 10},
+			wantErr:   ErrInvalidOrder,
+		},
+		{
+			name:      "order has a foreign image",
+			positions: map[uint]uint{10: 1, 11: 2},
+			order:     []uint{10, 99},
+			wantErr:   ErrInvalidOrder,
+		},
+		{
+			name:      "order repeats an image",
+			positions: map[uint]uint{10: 1, 11: 2},
+			order:     []uint{10, 10},
+			wantErr:   ErrInvalidOrder,
+		},
+		{
+			name:      "empty order for an empty album",
+			positions: map[uint]uint{},
+			order:     []uint{},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			db := openGalleryDB(t)
+			for fileID, position := range tt.positions {
+				if err := db.Create(&models.GalleryImage{GalleryItemID: itemID, FileID: fileID, Position: position}).Error; err != nil {
+					t.Fatal(err)
+				}
+			}
+			// Изображения другого альбома не трогаются
+			if err := db.Create(&models.GalleryImage{GalleryItemID: itemID + 1, FileID: 10, Position: 7}).Error; err != nil {
+				t.Fatal(err)
+			}
+
+			err := (&Service{}).reorderImages(db, itemID, tt.order)
+			if !errors.Is(err, tt.wantErr) {
+				t.Fatalf("error = %v, want %v", err, tt.wantErr)
+			}
+			if tt.wantErr != nil {
+				return
+			}
+
+			var images []models.GalleryImage
+			if err := db.Where("gallery_item_id = ?", itemID).Order("position").Find(&images).Error; err != nil {
+				t.Fatal(err)
+			}
+			var got []uint
+			for i, image := range images {
+				if image.Position != uint(i+1) {
+					t.Errorf("file %d at position %d, want %d", image.FileID, image.Position, i+1)
+				}
+				got = append(got, image.FileID)
+			}
+			if !slices.Equal(got, tt.want) {
+				t.Errorf("order = %v, want %v", got, tt.want)
+			}
+
+			var other models.GalleryImage
+			if err := db.Where("gallery_item_id = ?", itemID+1).First(&other).Error; err != nil || other.Position != 7 {
+				t.Errorf("other album image changed: %+v, %v", other, err)
+			}
+		})
+	}
+}
+
+func openGalleryDB(t *testing.T) *gorm.DB {
+	t.Helper()
+	db, err := gorm.Open(sqlite.Open(filepath.Join(t.TempDir(), "gallery.db")), &gorm.Config{Logger: logger.Discard})
+	if err != nil {
+		t.Fatalf("open sqlite: %v", err)
+	}
+	sqlDB, _ := db.DB()
+	sqlDB.SetMaxOpenConns(1)
+	t.Cleanup(func() { sqlDB.Close() })
+	if err := db.AutoMigrate(&models.GalleryImage{}); err != nil {
+		t.Fatalf("migrate: %v", err)
+	}
+	return db
+}
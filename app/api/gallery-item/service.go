@@ -1,46 +1,83 @@
 package gallery_item
 
 import (
+	"context"
 	"errors"
 	"federation-backend/app/api/shared"
+	"federation-backend/app/config"
+	database "federation-backend/app/db"
 	"federation-backend/app/db/models"
+	"federation-backend/app/db/models/enums"
 	"fmt"
-	"log"
+	"log/slog"
 	"mime/multipart"
-	"path/filepath"
 	"strconv"
 	"time"
 
 	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+var (
+	ErrNotFound      = errors.New("gallery item not found")
+	ErrImageNotFound = errors.New("image not found in gallery item")
+	ErrInvalidOrder  = errors.New("order must list every remaining image of the gallery item exactly once")
+	ErrInvalidCover  = errors.New("cover must be one of the gallery item images")
+	ErrCoverConflict = errors.New("preview and cover are mutually exclusive")
+	ErrTooManyLabels = errors.New("more captions or photographers than images")
+	ErrDateRequired  = errors.New("date is required: images have no EXIF capture time")
 )
 
 type CreateGalleryItemDTO struct {
-	Name      string                  `form:"name"`
-	ChapterID uint                    `form:"chapter_id" binding:"required"`
-	Date      string                  `form:"date" binding:"required"`
-	Images    []*multipart.FileHeader `form:"images" binding:"required,min=1"`
-	Preview   *multipart.FileHeader   `form:"preview" binding:"required"`
+	Name      string `form:"name"`
+	ChapterID uint   `form:"chapter_id" binding:"required"`
+	// Date - unix-время; без него - самое раннее время съёмки из EXIF изображений
+	Date   string                  `form:"date"`
+	Images []*multipart.FileHeader `form:"images" binding:"required,min=1"`
+	// Captions и Photographers - подписи и авторы изображений Images по порядку
+	Captions      []string `form:"captions" binding:"dive,max=500"`
+	Photographers []string `form:"photographers" binding:"dive,max=255"`
+	// Preview - отдельно загруженная обложка. Без неё обложкой становится
+	// изображение номер Cover (с 0) из Images, по умолчанию первое
+	Preview *multipart.FileHeader `form:"preview"`
+	Cover   *int                  `form:"cover" binding:"omitempty,min=0"`
 }
 
 type UpdateGalleryItemDTO struct {
-	ChapterID     *uint                   `form:"chapter_id"`
-	Name          *string                 `form:"name"`
-	Date          *string                 `form:"date"`
-	NewImages     []*multipart.FileHeader `form:"new_images"`
-	OldImages     []int                   `form:"old_images"`
-	DeletedImages []int                   `form:"deleted_images"`
-	Preview       *multipart.FileHeader   `form:"preview"`
+	ChapterID        *uint                   `form:"chapter_id"`
+	Name             *string                 `form:"name"`
+	Date             *string                 `form:"date"`
+	NewImages        []*multipart.FileHeader `form:"new_images"`
+	NewCaptions      []string                `form:"new_captions" binding:"dive,max=500"`
+	NewPhotographers []string                `form:"new_photographers" binding:"dive,max=255"`
+	OldImages        []int                   `form:"old_images"`
+	DeletedImages    []int                   `form:"deleted_images"`
+	// Order - id файлов оставшихся изображений в новом порядке, каждое по
+	// разу. Новые изображения добавляются после них
+	Order   []uint                `form:"order"`
+	Preview *multipart.FileHeader `form:"preview"`
+	// CoverID - id файла изображения альбома, которое станет обложкой
+	CoverID *uint `form:"cover_id"`
+}
+
+// UpdateImageDTO - подпись и автор одного изображения альбома
+type UpdateImageDTO struct {
+	Caption      *string `json:"caption" binding:"omitempty,max=500"`
+	Photographer *string `json:"photographer" binding:"omitempty,max=255"`
 }
 
 type Service struct {
 	db          *gorm.DB
 	fileService shared.FileProcessor
-	logger      *log.Logger
+	config      config.GalleryConfig
+	// archiveDir - каталог сохранённых архивов альбомов
+	archiveDir string
+	logger     *slog.Logger
 }
 
 type FileService interface {
-	SaveFile(fileHeader *multipart.FileHeader) (*models.File, error)
-	DeleteFile(filename string) error
+	SaveFile(ctx context.Context, fileHeader *multipart.FileHeader) (*models.File, error)
+	DeleteFile(ctx context.Context, filename string) error
 }
 
 func (s *Service) parseDate(date string, dst *time.Time) error {
@@ -53,40 +90,78 @@ func (s *Service) parseDate(date string, dst *time.Time) error {
 	return nil
 }
 
-func (s *Service) Create(createDTO *CreateGalleryItemDTO) error {
+// withImages подгружает изображения альбома в порядке показа
+func withImages(db *gorm.DB) *gorm.DB {
+	return db.Preload("Images", func(db *gorm.DB) *gorm.DB {
+		return db.Order("position, file_id")
+	}).Preload("Images.File")
+}
+
+func (s *Service) Create(ctx context.Context, createDTO *CreateGalleryItemDTO) error {
 	var date time.Time
-	if err := s.parseDate(createDTO.Date, &date); err != nil {
-		return err
+	if createDTO.Date != "" {
+		if err := s.parseDate(createDTO.Date, &date); err != nil {
+			return err
+		}
 	}
-
-	return s.db.Transaction(func(tx *gorm.DB) error {
-		preview, err := s.fileService.SaveFile(createDTO.Preview)
+	if len(createDTO.Captions) > len(createDTO.Images) || len(createDTO.Photographers) > len(createDTO.Images) {
+		return ErrTooManyLabels
+	}
+	cover := 0
+	if createDTO.Cover != nil {
+		if createDTO.Preview != nil {
+			return ErrCoverConflict
+		}
+		if *createDTO.Cover >= len(createDTO.Images) {
+			return ErrInvalidCover
+		}
+		cover = *createDTO.Cover
+	}
+	// Дата альбома без date известна до сохранения, поэтому альбом без неё
+	// отклоняется раньше, чем что-либо записано
+	if createDTO.Date == "" {
+		taken, err := earliestTakenAt(createDTO.Images)
 		if err != nil {
-			return fmt.Errorf("failed to save image: %w", err)
+			return err
+		}
+		if taken == nil {
+			return ErrDateRequired
+		}
+		date = *taken
+	}
+
+	return s.db.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		ctx := database.WithTx(ctx, tx)
+		if err := shared.CheckChapter(tx, createDTO.ChapterID, enums.GalleryPage); err != nil {
+			return err
 		}
 
 		galleryItem := models.GalleryItem{
 			ChapterID: createDTO.ChapterID,
 			Name:      createDTO.Name,
 			Date:      date,
-			PreviewID: preview.Id,
-			Preview:   *preview,
+		}
+		if createDTO.Preview != nil {
+			preview, err := s.fileService.SaveFile(ctx, createDTO.Preview)
+			if err != nil {
+				return fmt.Errorf("failed to save image: %w", err)
+			}
+			galleryItem.PreviewID = &preview.Id
 		}
 
-		if err := tx.Create(&galleryItem).Error; err != nil {
+		if err := tx.Omit(clause.Associations).Create(&galleryItem).Error; err != nil {
 			return fmt.Errorf("failed to create gallery item: %w", err)
 		}
 
-		// Save and associate images
-		for _, fileHeader := range createDTO.Images {
-			file, err := s.fileService.SaveFile(fileHeader)
-			if err != nil {
-				return fmt.Errorf("failed to save image: %w", err)
-			}
+		images, err := s.addImages(ctx, tx, galleryItem.Id, createDTO.Images, createDTO.Captions, createDTO.Photographers)
+		if err != nil {
+			return err
+		}
 
-			// Associate the file with gallery item using the many-to-many relationship
-			if err := tx.Model(&galleryItem).Association("Images").Append(file); err != nil {
-				return fmt.Errorf("failed to associate image: %w", err)
+		if createDTO.Preview == nil {
+			err := tx.Model(&galleryItem).Update("preview_id", images[cover].FileID).Error
+			if err != nil {
+				return fmt.Errorf("failed to set gallery item cover: %w", err)
 			}
 		}
 
@@ -94,28 +169,26 @@ func (s *Service) Create(createDTO *CreateGalleryItemDTO) error {
 	})
 }
 
-func (s *Service) Get(id uint) (models.GalleryItem, error) {
+func (s *Service) Get(ctx context.Context, id uint) (models.GalleryItem, error) {
 	var item models.GalleryItem
-	err := s.db.
+	err := withImages(s.db.WithContext(ctx)).
 		Preload("Preview").
-		Preload("Images").
 		Preload("Chapter").
 		First(&item, id).Error
 
 	if err != nil {
 		if errors.Is(err, gorm.ErrRecordNotFound) {
-			return models.GalleryItem{}, errors.New("gallery item not found")
+			return models.GalleryItem{}, ErrNotFound
 		}
 		return models.GalleryItem{}, fmt.Errorf("failed to get gallery item: %w", err)
 	}
 	return item, nil
 }
 
-func (s *Service) GetAll() ([]models.GalleryItem, error) {
+func (s *Service) GetAll(ctx context.Context) ([]models.GalleryItem, error) {
 	var items []models.GalleryItem
-	err := s.db.
+	err := withImages(s.db.WithContext(ctx)).
 		Preload("Preview").
-		Preload("Images").
 		Preload("Chapter").
 		Find(&items).Error
 
@@ -124,54 +197,73 @@ func (s *Service) GetAll() ([]models.GalleryItem, error) {
 	}
 	return items, nil
 }
-func (s *Service) Update(id uint, updateDTO *UpdateGalleryItemDTO) error {
-	return s.db.Transaction(func(tx *gorm.DB) error {
+
+func (s *Service) Update(ctx context.Context, id uint, updateDTO *UpdateGalleryItemDTO) error {
+	if updateDTO.Preview != nil && updateDTO.CoverID != nil {
+		return ErrCoverConflict
+	}
+	if len(updateDTO.NewCaptions) > len(updateDTO.NewImages) || len(updateDTO.NewPhotographers) > len(updateDTO.NewImages) {
+		return ErrTooManyLabels
+	}
+
+	return s.db.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		ctx := database.WithTx(ctx, tx)
 		// Загружаем сущность
-		item, err := s.loadGalleryItemWithAssociations(tx, id)
+		item, err := s.loadGalleryItemWithAssociations(ctx, tx, id)
 		if err != nil {
 			return err
 		}
+		coverWasImage := isImageCover(item)
+		// Файлы удаляются после сохранения альбома, когда он на них уже не ссылается
+		var obsolete []models.File
 
 		// Обновляем основные поля
-		if err := s.updateBasicFields(tx, item, updateDTO); err != nil {
+		if err := s.updateBasicFields(ctx, tx, item, updateDTO); err != nil {
 			return err
 		}
 
 		// Обновляем изображения
-		if err := s.updateImages(tx, item, updateDTO); err != nil {
+		if err := s.updateImages(ctx, tx, item, updateDTO, &obsolete); err != nil {
+			return err
+		}
+
+		// Обложку проверяем уже по новому составу альбома
+		if err := s.updateCover(ctx, item, updateDTO, coverWasImage, &obsolete); err != nil {
 			return err
 		}
 
 		// Сохраняем изменения
-		if err := tx.Save(item).Error; err != nil {
+		if err := tx.Omit(clause.Associations).Save(item).Error; err != nil {
 			return fmt.Errorf("failed to update gallery item: %w", err)
 		}
 
+		for _, file := range obsolete {
+			s.deleteFile(ctx, tx, item.Id, file)
+		}
 		return nil
 	})
 }
 
 // loadGalleryItemWithAssociations загружает галерею со всеми ассоциациями
-func (s *Service) loadGalleryItemWithAssociations(tx *gorm.DB, id uint) (*models.GalleryItem, error) {
+func (s *Service) loadGalleryItemWithAssociations(ctx context.Context, tx *gorm.DB, id uint) (*models.GalleryItem, error) {
 	var item models.GalleryItem
-	if err := tx.Preload("Images").Preload("Preview").Preload("Chapter").
+	if err := withImages(tx).Preload("Preview").Preload("Chapter").
 		First(&item, id).Error; err != nil {
-		return nil, fmt.Errorf("gallery item not found: %w", err)
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, ErrNotFound
+		}
+		return nil, fmt.Errorf("failed to get gallery item: %w", err)
 	}
 	return &item, nil
 }
 
 // updateBasicFields обновляет основные поля галереи
-func (s *Service) updateBasicFields(tx *gorm.DB, item *models.GalleryItem, dto *UpdateGalleryItemDTO) error {
-	// Обновляем превью если предоставлено
-	if dto.Preview != nil {
-		if err := s.updatePreview(tx, item, dto.Preview); err != nil {
-			return err
-		}
-	}
-
+func (s *Service) updateBasicFields(ctx context.Context, tx *gorm.DB, item *models.GalleryItem, dto *UpdateGalleryItemDTO) error {
 	// Обновляем основные поля
 	if dto.ChapterID != nil {
+		if err := shared.CheckChapter(tx, *dto.ChapterID, enums.GalleryPage); err != nil {
+			return err
+		}
 		item.ChapterID = *dto.ChapterID
 
 		// Загружаем новый Chapter для обновления ассоциации
@@ -199,24 +291,6 @@ func (s *Service) updateBasicFields(tx *gorm.DB, item *models.GalleryItem, dto *
 	return nil
 }
 
-// updatePreview обновляет превью галереи
-func (s *Service) updatePreview(tx *gorm.DB, item *models.GalleryItem, preview *multipart.FileHeader) error {
-	if preview == nil {
-		return nil
-	}
-
-	file, err := s.fileService.SaveFile(preview)
-	if err != nil {
-		return fmt.Errorf("failed to save preview: %w", err)
-	}
-
-	if err := tx.Model(item).Association("Preview").Replace(file); err != nil {
-		return fmt.Errorf("failed to replace preview: %w", err)
-	}
-
-	return nil
-}
-
 // updateDate обновляет дату галереи
 func (s *Service) updateDate(item *models.GalleryItem, dateStr string) error {
 	var date time.Time
@@ -227,213 +301,46 @@ func (s *Service) updateDate(item *models.GalleryItem, dateStr string) error {
 	return nil
 }
 
-// updateImages обрабатывает обновление изображений
-func (s *Service) updateImages(tx *gorm.DB, item *models.GalleryItem, dto *UpdateGalleryItemDTO) error {
-	// Удаляем помеченные изображения
-	if err := s.deleteMarkedImages(tx, item, dto.DeletedImages); err != nil {
-		return err
-	}
-
-	// Синхронизируем старые изображения
-	if err := s.syncOldImages(tx, item, dto.OldImages, dto.DeletedImages); err != nil {
-		return err
-	}
-
-	// Добавляем новые изображения
-	if err := s.addNewImages(tx, item, dto.NewImages); err != nil {
-		return err
-	}
-
-	return nil
-}
-
-// deleteMarkedImages удаляет изображения, помеченные для удаления
-func (s *Service) deleteMarkedImages(tx *gorm.DB, item *models.GalleryItem, deletedImages []int) error {
-	if len(deletedImages) == 0 {
-		return nil
-	}
-
-	// Находим изображения для удаления
-	var imagesToDelete []models.File
-	if err := tx.Where("id IN ?", deletedImages).Find(&imagesToDelete).Error; err != nil {
-		return fmt.Errorf("failed to find images to delete: %w", err)
-	}
-
-	// Удаляем каждое изображение
-	for _, image := range imagesToDelete {
-		if err := s.deleteSingleImage(tx, item, &image); err != nil {
-			// Логируем ошибку, но продолжаем удаление остальных
-			fmt.Printf("Warning: %v\n", err)
-		}
-	}
-
-	return nil
-}
-
-// deleteSingleImage удаляет одно изображение
-func (s *Service) deleteSingleImage(tx *gorm.DB, item *models.GalleryItem, image *models.File) error {
-	// Удаляем из файловой системы
-	filename := filepath.Base(image.Path)
-	if err := s.fileService.DeleteFile(filename); err != nil {
-		return fmt.Errorf("failed to delete image file %s: %w", filename, err)
-	}
-
-	// Удаляем из ассоциации
-	if err := tx.Model(item).Association("Images").Delete(image); err != nil {
-		return fmt.Errorf("failed to remove image %d from association: %w", image.Id, err)
-	}
-
-	// Удаляем запись из базы
-	if err := tx.Delete(image).Error; err != nil {
-		return fmt.Errorf("failed to delete file record %d: %w", image.Id, err)
-	}
-
-	return nil
-}
-
-// syncOldImages синхронизирует старые изображения
-func (s *Service) syncOldImages(tx *gorm.DB, item *models.GalleryItem, oldImages []int, deletedImages []int) error {
-	if oldImages == nil {
-		return nil // Не обновляем старые изображения, если не указаны
-	}
-
-	// Получаем текущие изображения
-	var currentImages []models.File
-	if err := tx.Model(item).Association("Images").Find(&currentImages); err != nil {
-		return fmt.Errorf("failed to get current images: %w", err)
-	}
-
-	// Идентифицируем изображения для удаления
-	for _, currentImage := range currentImages {
-		// Пропускаем если уже удалено
-		if sliceContains(deletedImages, int(currentImage.Id)) {
-			continue
-		}
-
-		// Проверяем нужно ли оставить изображение
-		if !shouldKeepImage(&currentImage, oldImages) {
-			if err := s.removeImageFromAssociation(tx, item, &currentImage); err != nil {
-				return err
-			}
-		}
-	}
-
-	return nil
-}
-
-// shouldKeepImage определяет нужно ли оставлять изображение
-func shouldKeepImage(image *models.File, oldImages []int) bool {
-	for _, oldImageID := range oldImages {
-		if int(image.Id) == oldImageID {
-			return true
-		}
-	}
-	return false
-}
-
-// removeImageFromAssociation удаляет изображение из ассоциации (но не из базы)
-func (s *Service) removeImageFromAssociation(tx *gorm.DB, item *models.GalleryItem, image *models.File) error {
-	if err := tx.Model(item).Association("Images").Delete(image); err != nil {
-		return fmt.Errorf("failed to remove image %d from association: %w", image.Id, err)
-	}
-	return nil
-}
-
-// Обновляем метод addNewImages для параллельного сохранения
-func (s *Service) addNewImages(tx *gorm.DB, item *models.GalleryItem, newImages []*multipart.FileHeader) error {
-	if len(newImages) == 0 {
-		return nil
-	}
-
-	// Сохраняем файлы параллельно
-	files, errors := s.fileService.SaveFilesParallel(newImages)
-
-	// Проверяем ошибки
-	var saveErrors []error
-	for i, err := range errors {
+func (s *Service) Delete(ctx context.Context, id uint) error {
+	return s.db.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		ctx := database.WithTx(ctx, tx)
+		item, err := s.loadGalleryItemWithAssociations(ctx, tx, id)
 		if err != nil {
-			saveErrors = append(saveErrors, fmt.Errorf("image %d: %w", i, err))
-		}
-	}
-
-	if len(saveErrors) > 0 {
-		// Удаляем успешно сохраненные файлы при наличии ошибок
-		for i, file := range files {
-			if file != nil && errors[i] == nil {
-				filename := filepath.Base(file.Path)
-				s.fileService.DeleteFile(filename)
-			}
+			return err
 		}
-		return fmt.Errorf("failed to save some images: %v", saveErrors)
-	}
 
-	// Ассоциируем успешно сохраненные файлы
-	for _, file := range files {
-		if file != nil {
-			if err := tx.Model(item).Association("Images").Append(file); err != nil {
-				return fmt.Errorf("failed to associate image: %w", err)
-			}
+		// Сначала записи альбома, потом файлы, на которые они ссылаются
+		if err := tx.Where("gallery_item_id = ?", item.Id).Delete(&models.GalleryImage{}).Error; err != nil {
+			return fmt.Errorf("failed to delete gallery item images: %w", err)
 		}
-	}
-
-	return nil
-}
-
-// sliceContains проверяет наличие элемента в срезе
-func sliceContains(slice []int, val int) bool {
-	for _, item := range slice {
-		if item == val {
-			return true
-		}
-	}
-	return false
-}
-
-func (s *Service) Delete(id uint) error {
-	return s.db.Transaction(func(tx *gorm.DB) error {
-		var item models.GalleryItem
-		if err := tx.Preload("Images").Preload("Preview").First(&item, id).Error; err != nil {
-			return fmt.Errorf("gallery item not found: %w", err)
+		if err := tx.Omit(clause.Associations).Delete(item).Error; err != nil {
+			return fmt.Errorf("failed to delete gallery item: %w", err)
 		}
 
-		filename := filepath.Base(item.Preview.Path)
-		if err := s.fileService.DeleteFile(filename); err != nil {
-			fmt.Printf("Warning: failed to delete image file %s: %v\n", filename, err)
+		// Обложка из изображений альбома удаляется вместе с ними
+		if item.Preview != nil && !isImageCover(item) {
+			s.deleteFile(ctx, tx, item.Id, *item.Preview)
 		}
-
-		// Delete associated files
 		for _, image := range item.Images {
-			// Extract just the filename from the path
-			filename := filepath.Base(image.Path)
-			if err := s.fileService.DeleteFile(filename); err != nil {
-				// Log but continue with other deletions
-				fmt.Printf("Warning: failed to delete image file %s: %v\n", filename, err)
-			}
-
-			// Delete the file record from database
-			if err := tx.Delete(&image).Error; err != nil {
-				fmt.Printf("Warning: failed to delete file record %d: %v\n", image.Id, err)
-			}
-		}
-
-		// Clear the association first (good practice)
-		if err := tx.Model(&item).Association("Images").Clear(); err != nil {
-			return fmt.Errorf("failed to clear image associations: %w", err)
-		}
-
-		// Delete the gallery item
-		if err := tx.Delete(&item).Error; err != nil {
-			return fmt.Errorf("failed to delete gallery item: %w", err)
+			s.deleteFile(ctx, tx, item.Id, image.File)
 		}
+		s.removeArchives(ctx, item.Id)
 
 		return nil
 	})
 }
 
-func NewService(db *gorm.DB, fileProcessor shared.FileProcessor, logger *log.Logger) *Service {
+func NewService(db *gorm.DB, fileProcessor shared.FileProcessor, config config.GalleryConfig, logger *slog.Logger) *Service {
+	archiveDir := config.ArchiveCacheDir
+	if archiveDir == "" {
+		// Скрытый каталог в хранилище: отчёт о хранилище его не учитывает
+		archiveDir = fileProcessor.GetFilePath(".archives")
+	}
 	return &Service{
 		db:          db,
 		fileService: fileProcessor,
+		config:      config,
+		archiveDir:  archiveDir,
 		logger:      logger,
 	}
 }
@@ -0,0 +1,96 @@
+package health
+
+import (
+	"context"
+	"federation-backend/app/logging"
+	"log/slog"
+	"net/http"
+	"sync"
+	"time"
+
+	"github.com/gin-gonic/gin"
+)
+
+// checkTimeout ограничивает одну проверку, чтобы зависшая база
+// не держала запрос оркестратора дольше его собственного таймаута
+const checkTimeout = 3 * time.Second
+
+const (
+	StatusOK          = "ok"
+	StatusFail        = "fail"
+	StatusUnavailable = "unavailable"
+)
+
+// Check - одна проверка готовности. Run возвращает ошибку, если зависимость недоступна
+type Check struct {
+	Name string
+	Run  func(ctx context.Context) error
+}
+
+type CheckResult struct {
+	Status   string `json:"status"`
+	Error    string `json:"error,omitempty"`
+	Duration string `json:"duration"`
+}
+
+type Controller struct {
+	checks []Check
+	logger *slog.Logger
+}
+
+// Liveness отвечает, пока процесс способен обрабатывать запросы, и ничего не проверяет:
+// перезапуск контейнера из-за недоступной базы не поможет
+func (c *Controller) Liveness(ctx *gin.Context) {
+	ctx.JSON(http.StatusOK, gin.H{"status": StatusOK})
+}
+
+// Readiness выполняет все проверки параллельно и отвечает 503, если хотя бы одна не прошла
+func (c *Controller) Readiness(ctx *gin.Context) {
+	results := make(map[string]CheckResult, len(c.checks))
+	var mu sync.Mutex
+	var wg sync.WaitGroup
+
+	for _, check := range c.checks {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			result := c.run(ctx.Request.Context(), check)
+			mu.Lock()
+			results[check.Name] = result
+			mu.Unlock()
+		}()
+	}
+	wg.Wait()
+
+	status, code := StatusOK, http.StatusOK
+	for _, result := range results {
+		if result.Status != StatusOK {
+			status, code = StatusUnavailable, http.StatusServiceUnavailable
+			break
+		}
+	}
+
+	ctx.JSON(code, gin.H{"status": status, "checks": results})
+}
+
+func (c *Controller) run(ctx context.Context, check Check) CheckResult {
+	ctx, cancel := context.WithTimeout(ctx, checkTimeout)
+	defer cancel()
+
+	start := time.Now()
+	err := check.Run(ctx)
+	result := CheckResult{Status: StatusOK, Duration: time.Since(start).String()}
+	if err != nil {
+		result.Status = StatusFail
+		result.Error = err.Error()
+		c.logger.WarnContext(ctx, "readiness check failed", slog.String("check", check.Name), logging.Error(err))
+	}
+	return result
+}
+
+func NewController(logger *slog.Logger, checks ...Check) *Controller {
+	return &Controller{
+		checks: checks,
+		logger: logger,
+	}
+}
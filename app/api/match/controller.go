@@ -1,13 +1,19 @@
 package match
 
 import (
+	"context"
+	"federation-backend/app/api/shared"
 	"federation-backend/app/api/shared/crud"
+	"federation-backend/app/api/shared/export"
 	"federation-backend/app/db/models"
 	"federation-backend/app/db/models/enums"
+	"federation-backend/app/logging"
 	"fmt"
-	"log"
+	"log/slog"
 	"net/http"
+	"net/url"
 	"strconv"
+	"strings"
 	"time"
 
 	"github.com/gin-gonic/gin"
@@ -15,9 +21,10 @@ import (
 )
 
 type Controller struct {
-	db    *gorm.DB
-	match *crud.Service[models.Match]
-	teams *crud.Service[models.Team]
+	db     *gorm.DB
+	match  *crud.Service[models.Match]
+	teams  *crud.Service[models.Team]
+	logger *slog.Logger
 }
 
 func (c Controller) Delete(ctx *gin.Context) {
@@ -53,9 +60,85 @@ func (c Controller) Get(ctx *gin.Context) {
 	ctx.JSON(http.StatusOK, match)
 }
 
+// ListQuery - фильтры списка матчей
+type ListQuery struct {
+	Sex    enums.Sex
+	League string
+	City   string
+	TeamID *uint
+	// From, To - интервал дат [From, To), нулевое значение - без границы
+	From time.Time
+	To   time.Time
+}
+
+// parseListQuery читает фильтры sex, league, city, team_id, from и to;
+// даты - в форматах shared.ParseDate
+func parseListQuery(values url.Values) (ListQuery, error) {
+	query := ListQuery{
+		Sex:    enums.Sex(values.Get("sex")),
+		League: values.Get("league"),
+		City:   values.Get("city"),
+	}
+	if query.Sex != "" && !query.Sex.Valid() {
+		return ListQuery{}, fmt.Errorf("unknown sex %q", query.Sex)
+	}
+	if raw := values.Get("team_id"); raw != "" {
+		id, err := strconv.ParseUint(raw, 10, 32)
+		if err != nil {
+			return ListQuery{}, fmt.Errorf("invalid team_id %q", raw)
+		}
+		teamID := uint(id)
+		query.TeamID = &teamID
+	}
+
+	var err error
+	if raw := values.Get("from"); raw != "" {
+		if query.From, err = shared.ParseDate(raw); err != nil {
+			return ListQuery{}, fmt.Errorf("invalid from: %w", err)
+		}
+	}
+	if raw := values.Get("to"); raw != "" {
+		if query.To, err = shared.ParseDate(raw); err != nil {
+			return ListQuery{}, fmt.Errorf("invalid to: %w", err)
+		}
+	}
+	return query, nil
+}
+
+// filtered - матчи под фильтрами query, без сортировки
+func (c Controller) filtered(ctx context.Context, query ListQuery) *gorm.DB {
+	db := c.db.WithContext(ctx).Model(&models.Match{})
+	if query.Sex != "" {
+		db = db.Where("sex = ?", query.Sex)
+	}
+	if query.League != "" {
+		db = db.Where("league = ?", query.League)
+	}
+	if query.City != "" {
+		db = db.Where("city = ?", query.City)
+	}
+	if query.TeamID != nil {
+		db = db.Where("id IN (?)", c.db.WithContext(ctx).Table("match_teams").Select("match_id").Where("team_id = ?", *query.TeamID))
+	}
+	if !query.From.IsZero() {
+		db = db.Where("date >= ?", query.From)
+	}
+	if !query.To.IsZero() {
+		db = db.Where("date < ?", query.To)
+	}
+	return db
+}
+
+// GetAll - список матчей с фильтрами parseListQuery
 func (c Controller) GetAll(ctx *gin.Context) {
+	query, err := parseListQuery(ctx.Request.URL.Query())
+	if err != nil {
+		ctx.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
 	var matches []*models.Match
-	result := c.db.Preload("Teams").Preload("Teams.TeamLogo").Find(&matches)
+	result := c.filtered(ctx.Request.Context(), query).Preload("Teams").Preload("Teams.TeamLogo").Find(&matches)
 	if result.Error != nil {
 		ctx.JSON(http.StatusInternalServerError, gin.H{"error": result.Error.Error()})
 		return // ← Added return
@@ -69,6 +152,54 @@ func (c Controller) GetAll(ctx *gin.Context) {
 	ctx.JSON(http.StatusOK, matches)
 }
 
+func (c Controller) RegisterExtraRoutes(router *gin.RouterGroup) {
+	router.GET("/export", c.Export)
+}
+
+// exportHeader - столбцы выгрузки матчей, teams - названия команд через " - "
+var exportHeader = []string{"id", "date", "league", "city", "sex", "teams"}
+
+// Export выгружает расписание в csv или xlsx (format) с фильтрами GetAll
+func (c Controller) Export(ctx *gin.Context) {
+	format, err := export.ParseFormat(ctx.Query("format"))
+	if err != nil {
+		ctx.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	query, err := parseListQuery(ctx.Request.URL.Query())
+	if err != nil {
+		ctx.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	// Страницам выгрузки нужна однозначная сортировка
+	db := c.filtered(ctx.Request.Context(), query).Order("id").Preload("Teams", func(db *gorm.DB) *gorm.DB {
+		return db.Order("team_name")
+	})
+	export.Stream(ctx, c.logger, format, "matches", exportHeader, func(write func(row []string) error) error {
+		return export.Batches(db, export.BatchSize, func(batch []models.Match) error {
+			for _, match := range batch {
+				names := make([]string, 0, len(match.Teams))
+				for _, team := range match.Teams {
+					names = append(names, team.TeamName)
+				}
+				err := write([]string{
+					strconv.FormatUint(uint64(match.Id), 10),
+					export.Time(match.Date),
+					match.League,
+					match.City,
+					string(match.Sex),
+					strings.Join(names, " - "),
+				})
+				if err != nil {
+					return err
+				}
+			}
+			return nil
+		})
+	})
+}
+
 type CreateMatchDTO struct {
 	League  string    `json:"league" binding:"required"`
 	Date    string    `json:"date" binding:"required"`
@@ -92,36 +223,36 @@ func (c Controller) Create(ctx *gin.Context) {
 	var item models.Match
 	var date time.Time
 	var err error
-	if date, err = c.parseDate(dto.Date); err != nil {
+	if date, err = shared.ParseDate(dto.Date); err != nil {
 		ctx.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
 	}
 
 	item.Date = date
 	item.League = dto.League
 	item.Sex = dto.Sex
 	item.City = dto.City
-	log.Println(dto.TeamIDs)
 
 	// Create match first
-	if err := c.match.Create(ctx, &item); err != nil {
+	if err := c.match.Create(ctx.Request.Context(), &item); err != nil {
 		ctx.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
 		return // ← Added return
 	}
 
 	// More efficient team association using WHERE IN
 	var teams []*models.Team
-	if err := c.teams.Db.Where("id IN ?", dto.TeamIDs).Find(&teams).Error; err != nil {
+	if err := c.teams.Db.WithContext(ctx.Request.Context()).Where("id IN ?", dto.TeamIDs).Find(&teams).Error; err != nil {
 		ctx.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
 		return // ← Added return
 	}
 
 	// Associate teams with match
-	if err := c.match.Db.Model(&item).Association("Teams").Append(teams); err != nil {
+	if err := c.match.Db.WithContext(ctx.Request.Context()).Model(&item).Association("Teams").Append(teams); err != nil {
 		ctx.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
 		return // ← Added return
 	}
 
-	log.Println(teams)
+	c.logger.InfoContext(ctx.Request.Context(), "match created", logging.Entity("match", item.Id), slog.Any("team_ids", dto.TeamIDs))
 
 	ctx.JSON(http.StatusCreated, gin.H{"message": "match created", "id": item.Id})
 }
@@ -160,8 +291,9 @@ func (c Controller) Update(ctx *gin.Context) {
 
 	if dto.Date != nil {
 		var date time.Time
-		if date, err = c.parseDate(*dto.Date); err != nil {
+		if date, err = shared.ParseDate(*dto.Date); err != nil {
 			ctx.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			return
 		}
 		item.Date = date
 	}
@@ -174,18 +306,18 @@ func (c Controller) Update(ctx *gin.Context) {
 		item.City = *dto.City
 	}
 
-	if err := c.match.Db.Save(item).Error; err != nil {
+	if err := c.match.Db.WithContext(ctx.Request.Context()).Save(item).Error; err != nil {
 		ctx.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
 		return // ← Added return
 	}
 
 	if dto.TeamIDs != nil {
 		var teams []*models.Team
-		if err := c.teams.Db.Where("id IN ?", dto.TeamIDs).Find(&teams).Error; err != nil {
+		if err := c.teams.Db.WithContext(ctx.Request.Context()).Where("id IN ?", dto.TeamIDs).Find(&teams).Error; err != nil {
 			ctx.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
 			return // ← Added return
 		}
-		if err := c.match.Db.Model(item).Association("Teams").Replace(teams); err != nil {
+		if err := c.match.Db.WithContext(ctx.Request.Context()).Model(item).Association("Teams").Replace(teams); err != nil {
 			ctx.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
 			return // ← Added return
 		}
@@ -194,32 +326,11 @@ func (c Controller) Update(ctx *gin.Context) {
 	ctx.JSON(http.StatusOK, gin.H{"message": "match updated"})
 }
 
-func NewController(db *gorm.DB, logger *log.Logger) *Controller {
+func NewController(db *gorm.DB, logger *slog.Logger) *Controller {
 	return &Controller{
-		db:    db,
-		match: crud.NewCrudService[models.Match](db, logger),
-		teams: crud.NewCrudService[models.Team](db, logger),
-	}
-}
-
-func (c *Controller) parseDate(date string) (time.Time, error) {
-	// Try parsing as timestamp first
-	if timestamp, err := strconv.ParseInt(date, 10, 64); err == nil {
-		return time.Unix(timestamp, 0), nil
+		db:     db,
+		logger: logger,
+		match:  crud.NewCrudService[models.Match](db, logger),
+		teams:  crud.NewCrudService[models.Team](db, logger),
 	}
-
-	// Try parsing as RFC3339 or other date formats
-	formats := []string{
-		time.RFC3339,
-		"2006-01-02",
-		"2006-01-02 15:04:05",
-	}
-
-	for _, format := range formats {
-		if parsed, err := time.Parse(format, date); err == nil {
-			return parsed, nil
-		}
-	}
-
-	return time.Time{}, fmt.Errorf("invalid date format: %s", date)
 }
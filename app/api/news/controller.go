@@ -2,6 +2,8 @@ package news
 
 import (
 	"federation-backend/app/api/shared"
+	"federation-backend/app/tracing"
+	"log/slog"
 	"net/http"
 	"strconv"
 
@@ -15,13 +17,21 @@ type Controller struct {
 
 func (c *Controller) Create(ctx *gin.Context) {
 	var dto CreateNewsDTO
-	if err := ctx.ShouldBind(&dto); err != nil {
+	// Разбор multipart-формы со всеми файлами - отдельный спан, чтобы отличать его от сохранения
+	_, span := tracing.Start(ctx.Request.Context(), "news.bind_form")
+	err := ctx.ShouldBind(&dto)
+	tracing.End(span, err)
+	if err != nil {
 		ctx.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
 		return
 	}
 
-	if err := c.service.Create(&dto); err != nil {
-		ctx.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+	if err := c.service.Create(ctx.Request.Context(), &dto); err != nil {
+		status := http.StatusInternalServerError
+		if shared.IsChapterError(err) {
+			status = http.StatusBadRequest
+		}
+		ctx.JSON(status, gin.H{"error": err.Error()})
 		return
 	}
 
@@ -35,7 +45,7 @@ func (c *Controller) Get(ctx *gin.Context) {
 		return
 	}
 
-	news, err := c.service.Get(uint(id))
+	news, err := c.service.Get(ctx.Request.Context(), uint(id))
 	if err != nil {
 		ctx.JSON(http.StatusNotFound, gin.H{"error": err.Error()})
 		return
@@ -52,13 +62,20 @@ func (c *Controller) Update(ctx *gin.Context) {
 	}
 
 	var dto UpdateNewsDTO
-	if err := ctx.ShouldBind(&dto); err != nil {
+	_, span := tracing.Start(ctx.Request.Context(), "news.bind_form")
+	err = ctx.ShouldBind(&dto)
+	tracing.End(span, err)
+	if err != nil {
 		ctx.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
 		return
 	}
 
-	if err := c.service.Update(uint(id), &dto); err != nil {
-		ctx.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+	if err := c.service.Update(ctx.Request.Context(), uint(id), &dto); err != nil {
+		status := http.StatusInternalServerError
+		if shared.IsChapterError(err) {
+			status = http.StatusBadRequest
+		}
+		ctx.JSON(status, gin.H{"error": err.Error()})
 		return
 	}
 
@@ -72,7 +89,7 @@ func (c *Controller) Delete(ctx *gin.Context) {
 		return
 	}
 
-	if err := c.service.Delete(uint(id)); err != nil {
+	if err := c.service.Delete(ctx.Request.Context(), uint(id)); err != nil {
 		ctx.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
 		return
 	}
@@ -81,7 +98,7 @@ func (c *Controller) Delete(ctx *gin.Context) {
 }
 
 func (c *Controller) GetAll(ctx *gin.Context) {
-	news, err := c.service.GetAll()
+	news, err := c.service.GetAll(ctx.Request.Context())
 	if err != nil {
 		ctx.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
 		return
@@ -90,8 +107,8 @@ func (c *Controller) GetAll(ctx *gin.Context) {
 	ctx.JSON(http.StatusOK, news)
 }
 
-func NewController(db *gorm.DB, fs *shared.ConcurrentFileProcessor) *Controller {
+func NewController(db *gorm.DB, fs *shared.ConcurrentFileProcessor, logger *slog.Logger) *Controller {
 	return &Controller{
-		service: NewService(db, fs),
+		service: NewService(db, fs, logger),
 	}
 }
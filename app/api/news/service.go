@@ -1,10 +1,15 @@
 package news
 
 import (
+	"context"
 	"errors"
 	"federation-backend/app/api/shared"
+	database "federation-backend/app/db"
 	"federation-backend/app/db/models"
+	"federation-backend/app/db/models/enums"
+	"federation-backend/app/logging"
 	"fmt"
+	"log/slog"
 	"mime/multipart"
 	"path/filepath"
 	"strconv"
@@ -35,11 +40,12 @@ type UpdateNewsDTO struct {
 type Service struct {
 	db          *gorm.DB
 	fileService shared.FileProcessor
+	logger      *slog.Logger
 }
 
 type FileService interface {
-	SaveFile(fileHeader *multipart.FileHeader) (*models.File, error)
-	DeleteFile(filename string) error
+	SaveFile(ctx context.Context, fileHeader *multipart.FileHeader) (*models.File, error)
+	DeleteFile(ctx context.Context, filename string) error
 }
 
 func (s *Service) parseDate(date string) (time.Time, error) {
@@ -64,7 +70,7 @@ func (s *Service) parseDate(date string) (time.Time, error) {
 	return time.Time{}, fmt.Errorf("invalid date format: %s", date)
 }
 
-func (s *Service) Create(dto interface{}) error {
+func (s *Service) Create(ctx context.Context, dto interface{}) error {
 	createDTO, ok := dto.(*CreateNewsDTO)
 	if !ok {
 		return errors.New("invalid DTO type")
@@ -75,7 +81,12 @@ func (s *Service) Create(dto interface{}) error {
 		return fmt.Errorf("failed to parse date: %w", err)
 	}
 
-	return s.db.Transaction(func(tx *gorm.DB) error {
+	return s.db.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		ctx := database.WithTx(ctx, tx)
+		if err := shared.CheckChapter(tx, createDTO.ChapterID, enums.NewsPage); err != nil {
+			return err
+		}
+
 		news := models.News{
 			BaseNewsData: models.BaseNewsData{
 				Heading:     createDTO.Heading,
@@ -92,7 +103,7 @@ func (s *Service) Create(dto interface{}) error {
 
 		// Save and associate images
 		for _, fileHeader := range createDTO.Images {
-			file, err := s.fileService.SaveFile(fileHeader)
+			file, err := s.fileService.SaveFile(ctx, fileHeader)
 			if err != nil {
 				return fmt.Errorf("failed to save image: %w", err)
 			}
@@ -107,9 +118,9 @@ func (s *Service) Create(dto interface{}) error {
 	})
 }
 
-func (s *Service) Get(id uint) (models.News, error) {
+func (s *Service) Get(ctx context.Context, id uint) (models.News, error) {
 	var news models.News
-	err := s.db.
+	err := s.db.WithContext(ctx).
 		Preload("Images").
 		Preload("Chapter").
 		First(&news, id).Error
@@ -122,13 +133,14 @@ func (s *Service) Get(id uint) (models.News, error) {
 	}
 	return news, nil
 }
-func (s *Service) Update(id uint, dto interface{}) error {
+func (s *Service) Update(ctx context.Context, id uint, dto interface{}) error {
 	updateDTO, ok := dto.(*UpdateNewsDTO)
 	if !ok {
 		return errors.New("invalid DTO type")
 	}
 
-	return s.db.Transaction(func(tx *gorm.DB) error {
+	return s.db.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		ctx := database.WithTx(ctx, tx)
 		var news models.News
 		if err := tx.Preload("Images").First(&news, id).Error; err != nil {
 			return fmt.Errorf("news not found: %w", err)
@@ -153,6 +165,9 @@ func (s *Service) Update(id uint, dto interface{}) error {
 		}
 
 		if updateDTO.ChapterID != nil {
+			if err := shared.CheckChapter(tx, *updateDTO.ChapterID, enums.NewsPage); err != nil {
+				return err
+			}
 			news.ChapterID = *updateDTO.ChapterID
 
 			// Загружаем новый Chapter для обновления ассоциации
@@ -168,13 +183,13 @@ func (s *Service) Update(id uint, dto interface{}) error {
 		}
 
 		// Handle image deletion
-		if err := s.deleteImages(tx, &news, updateDTO.DeletedImages); err != nil {
+		if err := s.deleteImages(ctx, tx, &news, updateDTO.DeletedImages); err != nil {
 			return err
 		}
 
 		// Handle new image addition (parallel)
 		if len(updateDTO.NewImages) > 0 {
-			if err := s.addNewImages(tx, &news, updateDTO.NewImages); err != nil {
+			if err := s.addNewImages(ctx, tx, &news, updateDTO.NewImages); err != nil {
 				return err
 			}
 		}
@@ -189,7 +204,7 @@ func (s *Service) Update(id uint, dto interface{}) error {
 }
 
 // deleteImages удаляет указанные изображения
-func (s *Service) deleteImages(tx *gorm.DB, news *models.News, deletedImageIDs []uint) error {
+func (s *Service) deleteImages(ctx context.Context, tx *gorm.DB, news *models.News, deletedImageIDs []uint) error {
 	if len(deletedImageIDs) == 0 {
 		return nil
 	}
@@ -202,9 +217,9 @@ func (s *Service) deleteImages(tx *gorm.DB, news *models.News, deletedImageIDs [
 
 	// Удаляем каждое изображение
 	for _, image := range imagesToDelete {
-		if err := s.deleteSingleImage(tx, news, &image); err != nil {
+		if err := s.deleteSingleImage(ctx, tx, news, &image); err != nil {
 			// Логируем ошибку, но продолжаем удаление остальных
-			fmt.Printf("Warning: %v\n", err)
+			s.logger.WarnContext(ctx, "failed to delete image", logging.Entity("news", news.Id), logging.Error(err))
 		}
 	}
 
@@ -212,10 +227,10 @@ func (s *Service) deleteImages(tx *gorm.DB, news *models.News, deletedImageIDs [
 }
 
 // deleteSingleImage удаляет одно изображение
-func (s *Service) deleteSingleImage(tx *gorm.DB, news *models.News, image *models.File) error {
+func (s *Service) deleteSingleImage(ctx context.Context, tx *gorm.DB, news *models.News, image *models.File) error {
 	// Удаляем из файловой системы
 	filename := filepath.Base(image.Path)
-	if err := s.fileService.DeleteFile(filename); err != nil {
+	if err := s.fileService.DeleteFile(ctx, filename); err != nil {
 		return fmt.Errorf("failed to delete image file %s: %w", filename, err)
 	}
 
@@ -233,9 +248,9 @@ func (s *Service) deleteSingleImage(tx *gorm.DB, news *models.News, image *model
 }
 
 // addNewImages добавляет новые изображения параллельно
-func (s *Service) addNewImages(tx *gorm.DB, news *models.News, newImages []*multipart.FileHeader) error {
+func (s *Service) addNewImages(ctx context.Context, tx *gorm.DB, news *models.News, newImages []*multipart.FileHeader) error {
 	// Сохраняем файлы параллельно
-	files, errors := s.fileService.SaveFilesParallel(newImages)
+	files, errors := s.fileService.SaveFilesParallel(ctx, newImages)
 
 	// Проверяем ошибки
 	var saveErrors []error
@@ -250,7 +265,7 @@ func (s *Service) addNewImages(tx *gorm.DB, news *models.News, newImages []*mult
 		for i, file := range files {
 			if file != nil && errors[i] == nil {
 				filename := filepath.Base(file.Path)
-				s.fileService.DeleteFile(filename)
+				s.fileService.DeleteFile(ctx, filename)
 			}
 		}
 		return fmt.Errorf("failed to save some images: %v", saveErrors)
@@ -268,8 +283,9 @@ func (s *Service) addNewImages(tx *gorm.DB, news *models.News, newImages []*mult
 	return nil
 }
 
-func (s *Service) Delete(id uint) error {
-	return s.db.Transaction(func(tx *gorm.DB) error {
+func (s *Service) Delete(ctx context.Context, id uint) error {
+	return s.db.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		ctx := database.WithTx(ctx, tx)
 		var news models.News
 		if err := tx.Preload("Images").First(&news, id).Error; err != nil {
 			return fmt.Errorf("news not found: %w", err)
@@ -279,14 +295,14 @@ func (s *Service) Delete(id uint) error {
 		for _, image := range news.Images {
 			// Extract just the filename from the path
 			filename := filepath.Base(image.Path)
-			if err := s.fileService.DeleteFile(filename); err != nil {
+			if err := s.fileService.DeleteFile(ctx, filename); err != nil {
 				// Log but continue with other deletions
-				fmt.Printf("Warning: failed to delete image file %s: %v\n", filename, err)
+				s.logger.WarnContext(ctx, "failed to delete image file", logging.Entity("news", news.Id), slog.String("file", filename), logging.Error(err))
 			}
 
 			// Delete the file record from database
 			if err := tx.Delete(&image).Error; err != nil {
-				fmt.Printf("Warning: failed to delete file record %d: %v\n", image.Id, err)
+				s.logger.WarnContext(ctx, "failed to delete file record", logging.Entity("news", news.Id), slog.Uint64("file_id", uint64(image.Id)), logging.Error(err))
 			}
 		}
 
@@ -304,9 +320,9 @@ func (s *Service) Delete(id uint) error {
 	})
 }
 
-func (s *Service) GetAll() ([]models.News, error) {
+func (s *Service) GetAll(ctx context.Context) ([]models.News, error) {
 	var news []models.News
-	err := s.db.
+	err := s.db.WithContext(ctx).
 		Preload("Images").
 		Preload("Chapter").
 		Find(&news).Error
@@ -317,9 +333,10 @@ func (s *Service) GetAll() ([]models.News, error) {
 	return news, nil
 }
 
-func NewService(db *gorm.DB, fileProcessor shared.FileProcessor) *Service {
+func NewService(db *gorm.DB, fileProcessor shared.FileProcessor, logger *slog.Logger) *Service {
 	return &Service{
 		db:          db,
 		fileService: fileProcessor,
+		logger:      logger,
 	}
 }
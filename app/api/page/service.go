@@ -0,0 +1,185 @@
+package page
+
+import (
+	"context"
+	"errors"
+	"federation-backend/app/db/models"
+	"federation-backend/app/db/models/enums"
+	"federation-backend/app/logging"
+	"fmt"
+	"log/slog"
+
+	"gorm.io/gorm"
+)
+
+var (
+	ErrNotFound  = errors.New("page not found")
+	ErrSlugTaken = errors.New("page slug is already taken")
+	// ErrHasChapters - у страницы есть разделы, удалить её нельзя
+	ErrHasChapters = errors.New("page has chapters")
+	// ErrKindInUse - в разделах страницы есть записи прежнего вида
+	ErrKindInUse = errors.New("page chapters have news, gallery items or documents, kind cannot change")
+)
+
+type CreatePageDTO struct {
+	Name string         `form:"name" json:"name" binding:"required,max=100"`
+	Slug enums.Page     `form:"slug" json:"slug" binding:"required"`
+	Kind enums.PageKind `form:"kind" json:"kind" binding:"required"`
+}
+
+// UpdatePageDTO: смена slug переносит на новый slug и разделы страницы
+type UpdatePageDTO struct {
+	Name *string         `form:"name" json:"name" binding:"omitempty,max=100"`
+	Slug *enums.Page     `form:"slug" json:"slug"`
+	Kind *enums.PageKind `form:"kind" json:"kind"`
+}
+
+type Service struct {
+	db     *gorm.DB
+	logger *slog.Logger
+}
+
+func (s *Service) Create(ctx context.Context, dto *CreatePageDTO) (*models.Page, error) {
+	page := models.Page{Name: dto.Name, Slug: dto.Slug, Kind: dto.Kind}
+	err := s.db.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		if err := slugFree(tx, dto.Slug, 0); err != nil {
+			return err
+		}
+		if err := tx.Create(&page).Error; err != nil {
+			return fmt.Errorf("failed to create page: %w", err)
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	return &page, nil
+}
+
+func (s *Service) Get(ctx context.Context, id uint) (*models.Page, error) {
+	var page models.Page
+	if err := find(s.db.WithContext(ctx), id, &page); err != nil {
+		return nil, err
+	}
+	return &page, nil
+}
+
+func (s *Service) GetAll(ctx context.Context) ([]models.Page, error) {
+	pages := []models.Page{}
+	if err := s.db.WithContext(ctx).Order("id").Find(&pages).Error; err != nil {
+		return nil, fmt.Errorf("failed to get pages: %w", err)
+	}
+	return pages, nil
+}
+
+func (s *Service) Update(ctx context.Context, id uint, dto *UpdatePageDTO) (*models.Page, error) {
+	var page models.Page
+	err := s.db.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		if err := find(tx, id, &page); err != nil {
+			return err
+		}
+
+		if dto.Kind != nil && *dto.Kind != page.Kind {
+			used, err := contentCount(tx, page.Slug)
+			if err != nil {
+				return err
+			}
+			if used > 0 {
+				return ErrKindInUse
+			}
+			page.Kind = *dto.Kind
+		}
+
+		if dto.Slug != nil && *dto.Slug != page.Slug {
+			if err := slugFree(tx, *dto.Slug, page.Id); err != nil {
+				return err
+			}
+			if err := tx.Model(&models.Chapter{}).Where("page = ?", page.Slug).
+				Update("page", *dto.Slug).Error; err != nil {
+				return fmt.Errorf("failed to move chapters to new slug: %w", err)
+			}
+			s.logger.InfoContext(ctx, "page slug changed", logging.Entity("page", page.Id),
+				slog.String("from", string(page.Slug)), slog.String("to", string(*dto.Slug)))
+			page.Slug = *dto.Slug
+		}
+
+		if dto.Name != nil {
+			page.Name = *dto.Name
+		}
+
+		if err := tx.Save(&page).Error; err != nil {
+			return fmt.Errorf("failed to update page: %w", err)
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	return &page, nil
+}
+
+// Delete удаляет страницу без разделов
+func (s *Service) Delete(ctx context.Context, id uint) error {
+	return s.db.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		var page models.Page
+		if err := find(tx, id, &page); err != nil {
+			return err
+		}
+
+		var chapters int64
+		if err := tx.Model(&models.Chapter{}).Where("page = ?", page.Slug).Count(&chapters).Error; err != nil {
+			return fmt.Errorf("failed to count page chapters: %w", err)
+		}
+		if chapters > 0 {
+			return fmt.Errorf("%w: %d", ErrHasChapters, chapters)
+		}
+
+		if err := tx.Delete(&page).Error; err != nil {
+			return fmt.Errorf("failed to delete page: %w", err)
+		}
+		return nil
+	})
+}
+
+func find(db *gorm.DB, id uint, page *models.Page) error {
+	if err := db.First(page, id).Error; err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return ErrNotFound
+		}
+		return fmt.Errorf("failed to get page: %w", err)
+	}
+	return nil
+}
+
+// slugFree проверяет, что slug не занят другой страницей, кроме exceptID
+func slugFree(db *gorm.DB, slug enums.Page, exceptID uint) error {
+	var count int64
+	if err := db.Model(&models.Page{}).Where("slug = ? AND id <> ?", slug, exceptID).Count(&count).Error; err != nil {
+		return fmt.Errorf("failed to check page slug: %w", err)
+	}
+	if count > 0 {
+		return ErrSlugTaken
+	}
+	return nil
+}
+
+// contentCount - сколько новостей, альбомов и документов в разделах страницы
+func contentCount(db *gorm.DB, slug enums.Page) (int64, error) {
+	chapters := db.Model(&models.Chapter{}).Select("id").Where("page = ?", slug)
+	var total int64
+	for _, model := range []any{&models.News{}, &models.GalleryItem{}, &models.Document{}} {
+		var count int64
+		if err := db.Model(model).Where("chapter_id IN (?)", chapters).Count(&count).Error; err != nil {
+			return 0, fmt.Errorf("failed to count page content: %w", err)
+		}
+		total += count
+	}
+	return total, nil
+}
+
+func NewService(db *gorm.DB, logger *slog.Logger) *Service {
+	return &Service{
+		db:     db,
+		logger: logger,
+	}
+}
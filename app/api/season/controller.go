@@ -0,0 +1,96 @@
+package season
+
+import (
+	"errors"
+	"log/slog"
+	"mime/multipart"
+	"net/http"
+	"strconv"
+
+	"federation-backend/app/api/shared/spreadsheet"
+
+	"github.com/gin-gonic/gin"
+	"gorm.io/gorm"
+)
+
+type Controller struct {
+	service *Service
+}
+
+func NewController(db *gorm.DB, logger *slog.Logger) *Controller {
+	return &Controller{service: NewService(db, logger)}
+}
+
+// Import принимает multipart-форму с файлами teams и/или matches (csv или xlsx).
+// Без confirm=true только проверяет строки: 200 с отчётом или 422 с ошибками.
+// С confirm=true при отсутствии ошибок применяет импорт и отвечает 201
+func (c *Controller) Import(ctx *gin.Context) {
+	confirm := false
+	if raw := ctx.Query("confirm"); raw != "" {
+		var err error
+		if confirm, err = strconv.ParseBool(raw); err != nil {
+			ctx.JSON(http.StatusBadRequest, gin.H{"error": "invalid confirm"})
+			return
+		}
+	}
+
+	var input Input
+	var err error
+	if input.Teams, err = readSheet(ctx, SheetTeams); err != nil {
+		ctx.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	if input.Matches, err = readSheet(ctx, SheetMatches); err != nil {
+		ctx.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	if input.Teams == nil && input.Matches == nil {
+		ctx.JSON(http.StatusBadRequest, gin.H{"error": "upload a teams or matches file"})
+		return
+	}
+
+	report, err := c.service.Import(ctx.Request.Context(), input, !confirm)
+	if err != nil {
+		ctx.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+
+	switch {
+	case len(report.Errors) > 0:
+		ctx.JSON(http.StatusUnprocessableEntity, report)
+	case report.Applied:
+		ctx.JSON(http.StatusCreated, report)
+	default:
+		ctx.JSON(http.StatusOK, report)
+	}
+}
+
+// readSheet читает файл из поля field. nil без ошибки - поле не передано
+func readSheet(ctx *gin.Context, field string) ([]spreadsheet.Row, error) {
+	header, err := ctx.FormFile(field)
+	if errors.Is(err, http.ErrMissingFile) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	return openSheet(header)
+}
+
+func openSheet(header *multipart.FileHeader) ([]spreadsheet.Row, error) {
+	file, err := header.Open()
+	if err != nil {
+		return nil, err
+	}
+	defer file.Close()
+
+	rows, err := spreadsheet.Read(file, header.Size, header.Filename)
+	if err != nil {
+		return nil, err
+	}
+	// Пустой файл - не то же самое, что отсутствующий
+	if rows == nil {
+		rows = []spreadsheet.Row{}
+	}
+	return rows, nil
+}
@@ -0,0 +1,458 @@
+package season
+
+import (
+	"context"
+	"fmt"
+	"log/slog"
+	"slices"
+	"strconv"
+	"strings"
+	"time"
+
+	"federation-backend/app/api/shared"
+	"federation-backend/app/api/shared/spreadsheet"
+	"federation-backend/app/db/models"
+	"federation-backend/app/db/models/enums"
+
+	"gorm.io/gorm"
+)
+
+// Имена листов в отчёте
+const (
+	SheetTeams   = "teams"
+	SheetMatches = "matches"
+)
+
+// TeamSeparator разделяет названия команд в столбце teams, как в выгрузке матчей
+const TeamSeparator = " - "
+
+// Столбцы файлов импорта, те же, что в выгрузке. Остальные столбцы (id,
+// created_at) игнорируются
+var (
+	teamColumns  = []string{"team_name", "sex"}
+	matchColumns = []string{"date", "league", "city", "sex", "teams"}
+)
+
+// RowError - ошибка в строке файла. Row - номер строки как в табличном редакторе
+type RowError struct {
+	Sheet  string `json:"sheet"`
+	Row    int    `json:"row"`
+	Column string `json:"column,omitempty"`
+	Error  string `json:"error"`
+}
+
+// SheetSummary - сколько строк прочитано и сколько записей будет (или было)
+// создано. Existing - команды, которые уже есть в базе и не создаются
+type SheetSummary struct {
+	Rows     int `json:"rows"`
+	New      int `json:"new"`
+	Existing int `json:"existing,omitempty"`
+}
+
+type Report struct {
+	DryRun  bool         `json:"dry_run"`
+	Applied bool         `json:"applied"`
+	Teams   SheetSummary `json:"teams"`
+	Matches SheetSummary `json:"matches"`
+	Errors  []RowError   `json:"errors"`
+}
+
+// Input - прочитанные файлы, любой из листов может отсутствовать
+type Input struct {
+	Teams   []spreadsheet.Row
+	Matches []spreadsheet.Row
+}
+
+type Service struct {
+	db     *gorm.DB
+	logger *slog.Logger
+}
+
+func NewService(db *gorm.DB, logger *slog.Logger) *Service {
+	return &Service{db: db, logger: logger}
+}
+
+// Import проверяет все строки и, если ошибок нет и это не dryRun, создаёт
+// команды и матчи в одной транзакции. Ошибки строк возвращаются в отчёте,
+// error - только сбой базы
+func (s *Service) Import(ctx context.Context, input Input, dryRun bool) (*Report, error) {
+	var report *Report
+	err := s.db.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		plan, err := newPlan(tx)
+		if err != nil {
+			return err
+		}
+		plan.addTeams(input.Teams)
+		if err := plan.addMatches(tx, input.Matches); err != nil {
+			return err
+		}
+
+		report = plan.report(dryRun)
+		if dryRun || len(report.Errors) > 0 {
+			return nil
+		}
+		if err := plan.apply(tx); err != nil {
+			return err
+		}
+		report.Applied = true
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	if report.Applied {
+		s.logger.InfoContext(ctx, "season import applied",
+			slog.Int("teams", report.Teams.New),
+			slog.Int("matches", report.Matches.New),
+		)
+	}
+	return report, nil
+}
+
+// teamKey - команда определяется названием без учёта регистра и полом
+type teamKey struct {
+	name string
+	sex  enums.Sex
+}
+
+func newTeamKey(name string, sex enums.Sex) teamKey {
+	return teamKey{name: strings.ToLower(strings.TrimSpace(name)), sex: sex}
+}
+
+type plannedTeam struct {
+	row  int
+	team models.Team
+}
+
+type plannedMatch struct {
+	row   int
+	match models.Match
+	teams []teamKey
+}
+
+// plan - проверенные строки и то, что из них будет создано
+type plan struct {
+	existing map[teamKey]uint
+	// teamRows - строка файла команд, в которой встретилась команда
+	teamRows map[teamKey]int
+	teams    []plannedTeam
+	matches  []plannedMatch
+
+	teamRowCount, matchRowCount, existingCount int
+	errors                                     []RowError
+}
+
+func newPlan(tx *gorm.DB) (*plan, error) {
+	var rows []struct {
+		Id       uint
+		TeamName string
+		Sex      string
+	}
+	if err := tx.Model(&models.Team{}).Select("id, team_name, sex").Scan(&rows).Error; err != nil {
+		return nil, fmt.Errorf("failed to load teams: %w", err)
+	}
+
+	p := &plan{existing: make(map[teamKey]uint, len(rows)), teamRows: map[teamKey]int{}}
+	for _, row := range rows {
+		p.existing[newTeamKey(row.TeamName, enums.Sex(row.Sex))] = row.Id
+	}
+	return p, nil
+}
+
+func (p *plan) fail(sheet string, row int, column, message string) {
+	p.errors = append(p.errors, RowError{Sheet: sheet, Row: row, Column: column, Error: message})
+}
+
+// columns находит нужные столбцы в заголовке. Возвращает false, если какого-то нет
+func (p *plan) columns(sheet string, header spreadsheet.Row, required []string) (map[string]int, bool) {
+	index := map[string]int{}
+	for i := range header.Cells {
+		index[strings.ToLower(header.Cell(i))] = i
+	}
+	ok := true
+	for _, column := range required {
+		if _, found := index[column]; !found {
+			p.fail(sheet, header.Number, column, "column is missing")
+			ok = false
+		}
+	}
+	return index, ok
+}
+
+func (p *plan) addTeams(rows []spreadsheet.Row) {
+	if rows == nil {
+		return
+	}
+	if len(rows) == 0 {
+		p.fail(SheetTeams, 1, "", "file is empty")
+		return
+	}
+	columns, ok := p.columns(SheetTeams, rows[0], teamColumns)
+	if !ok {
+		return
+	}
+
+	for _, row := range rows[1:] {
+		p.teamRowCount++
+		name := row.Cell(columns["team_name"])
+		sex, sexOK := parseSex(row.Cell(columns["sex"]))
+
+		valid := true
+		if name == "" {
+			p.fail(SheetTeams, row.Number, "team_name", "team name is required")
+			valid = false
+		} else if len([]rune(name)) > 255 {
+			p.fail(SheetTeams, row.Number, "team_name", "team name is longer than 255 characters")
+			valid = false
+		}
+		if !sexOK {
+			p.fail(SheetTeams, row.Number, "sex", fmt.Sprintf("unknown sex %q, expected male or female", row.Cell(columns["sex"])))
+			valid = false
+		}
+		if !valid {
+			continue
+		}
+
+		key := newTeamKey(name, sex)
+		if first, seen := p.teamRows[key]; seen {
+			p.fail(SheetTeams, row.Number, "team_name", fmt.Sprintf("duplicate of row %d", first))
+			continue
+		}
+		p.teamRows[key] = row.Number
+		if _, exists := p.existing[key]; exists {
+			p.existingCount++
+			continue
+		}
+		p.teams = append(p.teams, plannedTeam{row: row.Number, team: models.Team{TeamName: name, Sex: sex}})
+	}
+}
+
+func (p *plan) addMatches(tx *gorm.DB, rows []spreadsheet.Row) error {
+	if rows == nil {
+		return nil
+	}
+	if len(rows) == 0 {
+		p.fail(SheetMatches, 1, "", "file is empty")
+		return nil
+	}
+	columns, ok := p.columns(SheetMatches, rows[0], matchColumns)
+	if !ok {
+		return nil
+	}
+
+	// fixtures - ключ матча -> строка файла, где он встретился впервые
+	fixtures := map[string]int{}
+	var from, to time.Time
+	for _, row := range rows[1:] {
+		p.matchRowCount++
+		planned, ok := p.parseMatch(row, columns)
+		if !ok {
+			continue
+		}
+
+		key := fixtureKey(planned.match.Date, planned.match.Sex, planned.teams)
+		if first, seen := fixtures[key]; seen {
+			p.fail(SheetMatches, row.Number, "", fmt.Sprintf("duplicate of row %d", first))
+			continue
+		}
+		fixtures[key] = row.Number
+		p.matches = append(p.matches, planned)
+
+		if from.IsZero() || planned.match.Date.Before(from) {
+			from = planned.match.Date
+		}
+		if to.IsZero() || planned.match.Date.After(to) {
+			to = planned.match.Date
+		}
+	}
+	if len(p.matches) == 0 {
+		return nil
+	}
+	return p.skipExistingMatches(tx, from, to)
+}
+
+func (p *plan) parseMatch(row spreadsheet.Row, columns map[string]int) (plannedMatch, bool) {
+	valid := true
+	planned := plannedMatch{row: row.Number}
+
+	rawDate := row.Cell(columns["date"])
+	if rawDate == "" {
+		p.fail(SheetMatches, row.Number, "date", "date is required")
+		valid = false
+	} else if date, err := shared.ParseDate(rawDate); err != nil {
+		p.fail(SheetMatches, row.Number, "date", err.Error())
+		valid = false
+	} else {
+		planned.match.Date = date
+	}
+
+	planned.match.League = row.Cell(columns["league"])
+	if planned.match.League == "" {
+		p.fail(SheetMatches, row.Number, "league", "league is required")
+		valid = false
+	} else if len([]rune(planned.match.League)) > 100 {
+		p.fail(SheetMatches, row.Number, "league", "league is longer than 100 characters")
+		valid = false
+	}
+
+	planned.match.City = row.Cell(columns["city"])
+	if planned.match.City == "" {
+		p.fail(SheetMatches, row.Number, "city", "city is required")
+		valid = false
+	}
+
+	sex, ok := parseSex(row.Cell(columns["sex"]))
+	if !ok {
+		p.fail(SheetMatches, row.Number, "sex", fmt.Sprintf("unknown sex %q, expected male or female", row.Cell(columns["sex"])))
+		return planned, false
+	}
+	planned.match.Sex = sex
+
+	var names []string
+	for _, name := range strings.Split(row.Cell(columns["teams"]), TeamSeparator) {
+		if name = strings.TrimSpace(name); name != "" {
+			names = append(names, name)
+		}
+	}
+	if len(names) < 2 {
+		p.fail(SheetMatches, row.Number, "teams", fmt.Sprintf("at least two teams separated by %q are required", TeamSeparator))
+		return planned, false
+	}
+	for _, name := range names {
+		key := newTeamKey(name, sex)
+		if slices.Contains(planned.teams, key) {
+			p.fail(SheetMatches, row.Number, "teams", fmt.Sprintf("team %q is listed twice", name))
+			valid = false
+			continue
+		}
+		if !p.knows(key) {
+			p.fail(SheetMatches, row.Number, "teams", fmt.Sprintf("unknown %s team %q", sex, name))
+			valid = false
+			continue
+		}
+		planned.teams = append(planned.teams, key)
+	}
+	return planned, valid
+}
+
+// knows - команда есть в базе или создаётся этим же импортом
+func (p *plan) knows(key teamKey) bool {
+	if _, ok := p.existing[key]; ok {
+		return true
+	}
+	_, ok := p.teamRows[key]
+	return ok
+}
+
+// skipExistingMatches отмечает ошибкой матчи, которые уже есть в базе:
+// та же дата, пол и состав команд
+func (p *plan) skipExistingMatches(tx *gorm.DB, from, to time.Time) error {
+	var rows []struct {
+		Id     uint
+		Date   time.Time
+		Sex    string
+		TeamID uint
+	}
+	err := tx.Table("matches").
+		Select("matches.id, matches.date, matches.sex, match_teams.team_id").
+		Joins("JOIN match_teams ON match_teams.match_id = matches.id").
+		Where("matches.date BETWEEN ? AND ?", from, to).
+		Scan(&rows).Error
+	if err != nil {
+		return fmt.Errorf("failed to load matches: %w", err)
+	}
+
+	names := make(map[uint]teamKey, len(p.existing))
+	for key, id := range p.existing {
+		names[id] = key
+	}
+	type existingMatch struct {
+		date  time.Time
+		sex   enums.Sex
+		teams []teamKey
+	}
+	byID := map[uint]*existingMatch{}
+	for _, row := range rows {
+		match, ok := byID[row.Id]
+		if !ok {
+			match = &existingMatch{date: row.Date, sex: enums.Sex(row.Sex)}
+			byID[row.Id] = match
+		}
+		match.teams = append(match.teams, names[row.TeamID])
+	}
+	existing := make(map[string]uint, len(byID))
+	for id, match := range byID {
+		existing[fixtureKey(match.date, match.sex, match.teams)] = id
+	}
+
+	kept := p.matches[:0]
+	for _, planned := range p.matches {
+		key := fixtureKey(planned.match.Date, planned.match.Sex, planned.teams)
+		if id, ok := existing[key]; ok {
+			p.fail(SheetMatches, planned.row, "", fmt.Sprintf("match already exists (id %d)", id))
+			continue
+		}
+		kept = append(kept, planned)
+	}
+	p.matches = kept
+	return nil
+}
+
+// fixtureKey - матч определяется временем начала, полом и составом команд
+func fixtureKey(date time.Time, sex enums.Sex, teams []teamKey) string {
+	names := make([]string, len(teams))
+	for i, team := range teams {
+		names[i] = team.name
+	}
+	slices.Sort(names)
+	return strconv.FormatInt(date.Unix(), 10) + "|" + string(sex) + "|" + strings.Join(names, "\x00")
+}
+
+func (p *plan) report(dryRun bool) *Report {
+	slices.SortStableFunc(p.errors, func(a, b RowError) int {
+		if a.Sheet != b.Sheet {
+			// Сначала команды: ошибки в них объясняют неизвестные команды матчей
+			return strings.Compare(b.Sheet, a.Sheet)
+		}
+		return a.Row - b.Row
+	})
+	return &Report{
+		DryRun:  dryRun,
+		Teams:   SheetSummary{Rows: p.teamRowCount, New: len(p.teams), Existing: p.existingCount},
+		Matches: SheetSummary{Rows: p.matchRowCount, New: len(p.matches)},
+		Errors:  append([]RowError{}, p.errors...),
+	}
+}
+
+// apply создаёт команды, затем матчи со ссылками на команды. Вызывается в транзакции
+func (p *plan) apply(tx *gorm.DB) error {
+	ids := make(map[teamKey]uint, len(p.existing)+len(p.teams))
+	for key, id := range p.existing {
+		ids[key] = id
+	}
+	for i := range p.teams {
+		team := &p.teams[i].team
+		if err := tx.Create(team).Error; err != nil {
+			return fmt.Errorf("failed to create team from row %d: %w", p.teams[i].row, err)
+		}
+		ids[newTeamKey(team.TeamName, team.Sex)] = team.Id
+	}
+
+	for i := range p.matches {
+		planned := &p.matches[i]
+		for _, key := range planned.teams {
+			planned.match.Teams = append(planned.match.Teams, &models.Team{Model: models.Model{Id: ids[key]}})
+		}
+		// Omit("Teams.*"): команды уже в базе, создаются только связи match_teams
+		if err := tx.Omit("Teams.*").Create(&planned.match).Error; err != nil {
+			return fmt.Errorf("failed to create match from row %d: %w", planned.row, err)
+		}
+	}
+	return nil
+}
+
+func parseSex(raw string) (enums.Sex, bool) {
+	sex := enums.Sex(strings.ToLower(raw))
+	return sex, sex.Valid()
+}
@@ -0,0 +1,43 @@
+package shared
+
+import (
+	"errors"
+	"federation-backend/app/db/models"
+	"federation-backend/app/db/models/enums"
+	"fmt"
+
+	"gorm.io/gorm"
+)
+
+var (
+	ErrChapterNotFound = errors.New("chapter not found")
+	// ErrChapterKind - страница раздела не принимает такие записи
+	ErrChapterKind = errors.New("chapter page does not accept this kind of content")
+)
+
+// CheckChapter проверяет, что в раздел chapterID можно поместить запись
+// вида kind: раздел есть, и его страница этого вида
+func CheckChapter(db *gorm.DB, chapterID uint, kind enums.PageKind) error {
+	var chapter models.Chapter
+	if err := db.Select("id", "page").First(&chapter, chapterID).Error; err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return ErrChapterNotFound
+		}
+		return fmt.Errorf("failed to get chapter: %w", err)
+	}
+
+	var count int64
+	err := db.Model(&models.Page{}).Where("slug = ? AND kind = ?", chapter.Page, kind).Count(&count).Error
+	if err != nil {
+		return fmt.Errorf("failed to check chapter page: %w", err)
+	}
+	if count == 0 {
+		return fmt.Errorf("%w: %s expected", ErrChapterKind, kind)
+	}
+	return nil
+}
+
+// IsChapterError - ошибка CheckChapter, то есть неверный chapter_id в запросе
+func IsChapterError(err error) bool {
+	return errors.Is(err, ErrChapterNotFound) || errors.Is(err, ErrChapterKind)
+}
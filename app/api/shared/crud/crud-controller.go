@@ -2,8 +2,10 @@
 package crud
 
 import (
-	"log"
+	"federation-backend/app/logging"
+	"log/slog"
 	"net/http"
+	"reflect"
 	"strconv"
 	"strings"
 
@@ -13,14 +15,16 @@ import (
 
 type Controller[T any] struct {
 	service *Service[T]
-	logger  *log.Logger
+	logger  *slog.Logger
+	entity  string
 }
 
 // NewCrudController создает новый экземпляр CrudController
-func NewCrudController[T any](db *gorm.DB, logger *log.Logger) *Controller[T] {
+func NewCrudController[T any](db *gorm.DB, logger *slog.Logger) *Controller[T] {
 	return &Controller[T]{
 		service: NewCrudService[T](db, logger),
 		logger:  logger,
+		entity:  strings.ToLower(reflect.TypeFor[T]().Name()),
 	}
 }
 
@@ -33,6 +37,7 @@ func (c *Controller[T]) Create(ctx *gin.Context) {
 	}
 
 	if err := c.service.Create(ctx.Request.Context(), &dto); err != nil {
+		c.logger.ErrorContext(ctx.Request.Context(), "failed to create entity", slog.String(logging.KeyEntity, c.entity), logging.Error(err))
 		ctx.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
 		return
 	}
@@ -63,7 +68,7 @@ func (c *Controller[T]) Get(ctx *gin.Context) {
 
 // GetAll обрабатывает GET запросы для получения всех сущностей
 func (c *Controller[T]) GetAll(ctx *gin.Context) {
-	entities, err := c.service.GetAll()
+	entities, err := c.service.GetAll(ctx.Request.Context())
 	if err != nil {
 		ctx.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
 		return
@@ -111,10 +116,13 @@ func (c *Controller[T]) Delete(ctx *gin.Context) {
 			ctx.JSON(http.StatusNotFound, gin.H{"error": "Entity not found"})
 			return
 		}
+		c.logger.ErrorContext(ctx.Request.Context(), "failed to delete entity", logging.Entity(c.entity, uint(id)), logging.Error(err))
 		ctx.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
 		return
 	}
 
+	c.logger.InfoContext(ctx.Request.Context(), "entity deleted", logging.Entity(c.entity, uint(id)))
+
 	ctx.JSON(http.StatusOK, gin.H{"message": "Entity deleted successfully"})
 }
 
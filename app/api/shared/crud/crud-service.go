@@ -4,17 +4,17 @@ package crud
 import (
 	"context"
 	"errors"
-	"log"
+	"log/slog"
 
 	"gorm.io/gorm"
 )
 
 type Service[T any] struct {
 	Db     *gorm.DB
-	logger *log.Logger
+	logger *slog.Logger
 }
 
-func NewCrudService[T any](db *gorm.DB, logger *log.Logger) *Service[T] {
+func NewCrudService[T any](db *gorm.DB, logger *slog.Logger) *Service[T] {
 	return &Service[T]{Db: db, logger: logger}
 }
 
@@ -124,11 +124,9 @@ func (c *Service[T]) GetWithConditions(
 }
 
 // GetAll возвращает все записи с возможностью предзагрузки связей
-func (c *Service[T]) GetAll() ([]T, error) {
+func (c *Service[T]) GetAll(ctx context.Context) ([]T, error) {
 	var models []T
-	defer c.logger.Printf("Exiting GetAll()")
-	c.logger.Printf("GetAll() invoked for %+v\n", *c)
-	result := c.Db.Find(&models)
+	result := c.Db.WithContext(ctx).Find(&models)
 	if result.Error != nil {
 		return nil, result.Error
 	}
@@ -141,7 +139,7 @@ func (c *Service[T]) GetAll() ([]T, error) {
 // UpdateWithAssociations обновляет запись со связями
 func (c *Service[T]) UpdateWithAssociations(ctx context.Context, id uint, dto *T) error {
 	var model T
-	result := c.Db.First(&model, id)
+	result := c.Db.WithContext(ctx).First(&model, id)
 	if result.Error != nil {
 		return result.Error
 	}
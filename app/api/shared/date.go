@@ -0,0 +1,31 @@
+package shared
+
+import (
+	"fmt"
+	"strconv"
+	"time"
+)
+
+// dateFormats - форматы дат, которые принимает API, кроме unix timestamp
+var dateFormats = []string{
+	time.RFC3339,
+	"2006-01-02",
+	"2006-01-02 15:04:05",
+}
+
+// ParseDate разбирает дату из запроса или файла импорта: unix timestamp в
+// секундах, RFC3339, YYYY-MM-DD или YYYY-MM-DD HH:MM:SS
+func ParseDate(date string) (time.Time, error) {
+	// Try parsing as timestamp first
+	if timestamp, err := strconv.ParseInt(date, 10, 64); err == nil {
+		return time.Unix(timestamp, 0), nil
+	}
+
+	for _, format := range dateFormats {
+		if parsed, err := time.Parse(format, date); err == nil {
+			return parsed, nil
+		}
+	}
+
+	return time.Time{}, fmt.Errorf("invalid date format: %s", date)
+}
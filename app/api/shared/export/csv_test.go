@@ -0,0 +1,60 @@
+package export
+
+import (
+	"bytes"
+	"testing"
+)
+
+func TestEscapeFormula(t *testing.T) {
+	tests := []struct {
+		cell string
+		want string
+	}{
+		{"", ""},
+		{"Иван", "Иван"},
+		{"=HYPERLINK(\"http://evil\")", "'=HYPERLINK(\"http://evil\")"},
+		{"@SUM(A1)", "'@SUM(A1)"},
+		{"\t=1+1", "'\t=1+1"},
+		{"\r=1+1", "'\r=1+1"},
+		{"+79123456789", "+79123456789"},
+		{"-5", "-5"},
+		{"-2.5", "-2.5"},
+		{"-", "-"},
+		{"+1+cmd|' /C calc'!A0", "'+1+cmd|' /C calc'!A0"},
+		{"-2+3", "'-2+3"},
+		{"a=b", "a=b"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.cell, func(t *testing.T) {
+			if got := escapeFormula(tt.cell); got != tt.want {
+				t.Errorf("escapeFormula(%q) = %q, want %q", tt.cell, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestCSVWriter(t *testing.T) {
+	var out bytes.Buffer
+	writer, err := NewWriter(&out, CSV, "callbacks")
+	if err != nil {
+		t.Fatal(err)
+	}
+	rows := [][]string{
+		{"name", "phone"},
+		{"=cmd", "+79123456789"},
+		{"Иван, \"Торпедо\"", "-1"},
+	}
+	for _, row := range rows {
+		if err := writer.Write(row); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := writer.Close(); err != nil {
+		t.Fatal(err)
+	}
+
+	want := utf8BOM + "name,phone\n'=cmd,+79123456789\n\"Иван, \"\"Торпедо\"\"\",-1\n"
+	if got := out.String(); got != want {
+		t.Errorf("csv = %q, want %q", got, want)
+	}
+}
@@ -0,0 +1,151 @@
+package export
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"log/slog"
+	"net/http"
+	"time"
+
+	"federation-backend/app/logging"
+
+	"github.com/gin-gonic/gin"
+	"gorm.io/gorm"
+)
+
+type Format string
+
+const (
+	CSV  Format = "csv"
+	XLSX Format = "xlsx"
+)
+
+// BatchSize - сколько строк выгрузка читает из базы за один запрос
+const BatchSize = 500
+
+var contentTypes = map[Format]string{
+	CSV:  "text/csv; charset=utf-8",
+	XLSX: "application/vnd.openxmlformats-officedocument.spreadsheetml.sheet",
+}
+
+// ParseFormat читает параметр format, по умолчанию csv
+func ParseFormat(raw string) (Format, error) {
+	if raw == "" {
+		return CSV, nil
+	}
+	format := Format(raw)
+	if _, ok := contentTypes[format]; !ok {
+		return "", fmt.Errorf("unknown export format %q, expected csv or xlsx", raw)
+	}
+	return format, nil
+}
+
+// Writer пишет таблицу построчно, ничего не накапливая в памяти
+type Writer interface {
+	Write(row []string) error
+	// Close дописывает файл (для xlsx - оглавление архива)
+	Close() error
+}
+
+func NewWriter(w io.Writer, format Format, sheet string) (Writer, error) {
+	switch format {
+	case CSV:
+		return newCSVWriter(w)
+	case XLSX:
+		return newXLSXWriter(w, sheet)
+	}
+	return nil, fmt.Errorf("unknown export format %q", format)
+}
+
+// Stream отдаёт таблицу файлом name-<дата>.<format>: пишет заголовок header,
+// затем строки, которые fill передаёт в write. Пока ответ не начал уходить
+// клиенту, ошибка fill возвращается как 500, после - соединение обрывается
+// и ошибка только логируется
+func Stream(ctx *gin.Context, logger *slog.Logger, format Format, name string, header []string, fill func(write func(row []string) error) error) {
+	filename := fmt.Sprintf("%s-%s.%s", name, time.Now().Format("20060102"), format)
+	ctx.Header("Content-Type", contentTypes[format])
+	ctx.Header("Content-Disposition", fmt.Sprintf(`attachment; filename="%s"`, filename))
+	ctx.Status(http.StatusOK)
+
+	buffered := bufio.NewWriterSize(ctx.Writer, 32<<10)
+	rows := 0
+	err := func() error {
+		writer, err := NewWriter(buffered, format, name)
+		if err != nil {
+			return err
+		}
+		if err := writer.Write(header); err != nil {
+			return err
+		}
+		err = fill(func(row []string) error {
+			rows++
+			return writer.Write(row)
+		})
+		if err != nil {
+			return err
+		}
+		if err := writer.Close(); err != nil {
+			return err
+		}
+		return buffered.Flush()
+	}()
+
+	if err != nil {
+		if !ctx.Writer.Written() {
+			ctx.Writer.Header().Del("Content-Type")
+			ctx.Writer.Header().Del("Content-Disposition")
+			ctx.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+			return
+		}
+		logger.ErrorContext(ctx.Request.Context(), "export interrupted",
+			slog.String("export", name),
+			slog.Int("rows", rows),
+			logging.Error(err),
+		)
+		// Обрыв соединения вместо штатного завершения: клиент не примет
+		// обрезанный файл за целый
+		panic(http.ErrAbortHandler)
+	}
+
+	logger.InfoContext(ctx.Request.Context(), "export completed",
+		slog.String("export", name),
+		slog.String("format", string(format)),
+		slog.Int("rows", rows),
+	)
+}
+
+// Batches читает выборку db страницами по size строк и передаёт каждую в fn.
+// У db должна быть однозначная сортировка, иначе страницы могут пересекаться
+func Batches[T any](db *gorm.DB, size int, fn func(batch []T) error) error {
+	for offset := 0; ; offset += size {
+		var batch []T
+		if err := db.Session(&gorm.Session{}).Limit(size).Offset(offset).Find(&batch).Error; err != nil {
+			return err
+		}
+		if len(batch) > 0 {
+			if err := fn(batch); err != nil {
+				return err
+			}
+		}
+		if len(batch) < size {
+			return nil
+		}
+	}
+}
+
+// Time - время в выгрузке, пустая строка для нулевого значения
+func Time(t time.Time) string {
+	if t.IsZero() {
+		return ""
+	}
+	return t.Format("2006-01-02 15:04:05")
+}
+
+// Optional - значение указателя или пустая строка
+func Optional[T any](value *T) string {
+	if value == nil {
+		return ""
+	}
+	return fmt.Sprint(*value)
+}
@@ -0,0 +1,117 @@
+package extract
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"path/filepath"
+	"strings"
+	"unicode"
+	"unicode/utf8"
+)
+
+// MaxText ограничивает извлечённый текст: для поиска хватает начала
+// документа, а колонка с текстом не должна расти без предела
+const MaxText = 1 << 20
+
+var ErrUnsupportedFormat = errors.New("unsupported document format, expected .pdf, .docx or .xlsx")
+
+// Result - сведения, извлечённые из файла документа
+type Result struct {
+	// PageCount - число страниц, у XLSX - число листов
+	PageCount int
+	Title     string
+	Author    string
+	// Text - текст документа, пробелы и переводы строк схлопнуты в один пробел
+	Text string
+	// Thumbnail - миниатюра, которую офисный редактор сохранил в самом файле
+	// (docProps/thumbnail), и её расширение
+	Thumbnail    []byte
+	ThumbnailExt string
+}
+
+// Read разбирает файл документа. Формат определяется по расширению name
+func Read(file io.ReaderAt, size int64, name string) (*Result, error) {
+	var result *Result
+	var err error
+	switch strings.ToLower(filepath.Ext(name)) {
+	case ".pdf":
+		result, err = readPDF(file, size)
+	case ".docx":
+		result, err = readDOCX(file, size)
+	case ".xlsx":
+		result, err = readXLSX(file, size)
+	default:
+		return nil, ErrUnsupportedFormat
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to read %s: %w", name, err)
+	}
+	result.Title = Normalize(result.Title)
+	result.Author = Normalize(result.Author)
+	return result, nil
+}
+
+// Supported - формат файла с именем name умеет разбирать Read
+func Supported(name string) bool {
+	switch strings.ToLower(filepath.Ext(name)) {
+	case ".pdf", ".docx", ".xlsx":
+		return true
+	}
+	return false
+}
+
+// IsPDF - файл с именем name разбирается как PDF
+func IsPDF(name string) bool {
+	return strings.EqualFold(filepath.Ext(name), ".pdf")
+}
+
+// Normalize схлопывает пробельные символы в один пробел и убирает
+// управляющие: так хранится текст документа и так же сравнивается запрос поиска
+func Normalize(text string) string {
+	var builder textBuilder
+	builder.write(text)
+	return builder.String()
+}
+
+// textBuilder собирает текст не длиннее MaxText байт
+type textBuilder struct {
+	strings.Builder
+	space bool
+	full  bool
+}
+
+func (b *textBuilder) write(text string) {
+	for _, r := range text {
+		if b.full {
+			return
+		}
+		switch {
+		case unicode.IsSpace(r):
+			b.space = b.Len() > 0
+			continue
+		case r == utf8.RuneError, unicode.IsControl(r):
+			// NUL и прочие управляющие символы не примет Postgres
+			continue
+		}
+
+		size := utf8.RuneLen(r)
+		if b.space {
+			size++
+		}
+		if b.Len()+size > MaxText {
+			b.full = true
+			return
+		}
+		if b.space {
+			b.WriteByte(' ')
+			b.space = false
+		}
+		b.WriteRune(r)
+	}
+}
+
+// separate отделяет следующий фрагмент пробелом: конец абзаца, ячейки, страницы
+func (b *textBuilder) separate() {
+	b.space = b.Len() > 0
+}
@@ -0,0 +1,205 @@
+package extract
+
+import (
+	"archive/zip"
+	"encoding/xml"
+	"errors"
+	"fmt"
+	"io"
+	"path"
+	"strconv"
+	"strings"
+)
+
+// maxThumbnail - миниатюры больше этого размера не используются
+const maxThumbnail = 2 << 20
+
+// thumbnailTypes - форматы миниатюр, которые можно показать в браузере
+// (Word и Excel под Windows сохраняют ещё WMF и EMF)
+var thumbnailTypes = map[string]string{
+	".jpeg": ".jpg",
+	".jpg":  ".jpg",
+	".png":  ".png",
+}
+
+// ooxml - части zip-архива DOCX или XLSX по именам
+type ooxml map[string]*zip.File
+
+func openOOXML(file io.ReaderAt, size int64) (ooxml, error) {
+	archive, err := zip.NewReader(file, size)
+	if err != nil {
+		return nil, fmt.Errorf("not an office document: %w", err)
+	}
+	parts := make(ooxml, len(archive.File))
+	for _, part := range archive.File {
+		parts[part.Name] = part
+	}
+	return parts, nil
+}
+
+// properties заполняет заголовок и автора из docProps/core.xml и миниатюру
+func (o ooxml) properties(result *Result) error {
+	if core := o["docProps/core.xml"]; core != nil {
+		var properties struct {
+			Title   string `xml:"title"`
+			Creator string `xml:"creator"`
+		}
+		if err := o.decode(core, &properties); err != nil {
+			return err
+		}
+		result.Title = properties.Title
+		result.Author = properties.Creator
+	}
+
+	for name, part := range o {
+		if path.Dir(name) != "docProps" || !strings.HasPrefix(path.Base(name), "thumbnail.") {
+			continue
+		}
+		ext, ok := thumbnailTypes[strings.ToLower(path.Ext(name))]
+		if !ok || part.UncompressedSize64 > maxThumbnail {
+			continue
+		}
+		reader, err := part.Open()
+		if err != nil {
+			return err
+		}
+		data, err := io.ReadAll(io.LimitReader(reader, maxThumbnail))
+		reader.Close()
+		if err != nil {
+			return fmt.Errorf("invalid %s: %w", name, err)
+		}
+		result.Thumbnail, result.ThumbnailExt = data, ext
+		break
+	}
+	return nil
+}
+
+func (o ooxml) decode(part *zip.File, into any) error {
+	reader, err := part.Open()
+	if err != nil {
+		return err
+	}
+	defer reader.Close()
+	if err := xml.NewDecoder(reader).Decode(into); err != nil {
+		return fmt.Errorf("invalid %s: %w", part.Name, err)
+	}
+	return nil
+}
+
+// text собирает в builder содержимое элементов textElement части name.
+// После каждого элемента из breaks текст разделяется пробелом
+func (o ooxml) text(builder *textBuilder, name, textElement string, breaks ...string) error {
+	part := o[name]
+	if part == nil {
+		return nil
+	}
+	reader, err := part.Open()
+	if err != nil {
+		return err
+	}
+	defer reader.Close()
+
+	inText := false
+	decoder := xml.NewDecoder(reader)
+	for !builder.full {
+		token, err := decoder.Token()
+		if errors.Is(err, io.EOF) {
+			return nil
+		}
+		if err != nil {
+			return fmt.Errorf("invalid %s: %w", name, err)
+		}
+		switch element := token.(type) {
+		case xml.StartElement:
+			switch element.Name.Local {
+			case textElement:
+				inText = true
+			case "tab", "br":
+				builder.separate()
+			case "rPh":
+				// Фонетические подсказки к строкам XLSX - не текст ячейки
+				if err := decoder.Skip(); err != nil {
+					return fmt.Errorf("invalid %s: %w", name, err)
+				}
+			}
+		case xml.CharData:
+			if inText {
+				builder.write(string(element))
+			}
+		case xml.EndElement:
+			if element.Name.Local == textElement {
+				inText = false
+			}
+			for _, local := range breaks {
+				if element.Name.Local == local {
+					builder.separate()
+				}
+			}
+		}
+	}
+	return nil
+}
+
+func readDOCX(file io.ReaderAt, size int64) (*Result, error) {
+	parts, err := openOOXML(file, size)
+	if err != nil {
+		return nil, err
+	}
+	if parts["word/document.xml"] == nil {
+		return nil, errors.New("word/document.xml is missing")
+	}
+
+	result := &Result{}
+	if err := parts.properties(result); err != nil {
+		return nil, err
+	}
+	// Число страниц считает Word при сохранении, сам файл разбит только на абзацы
+	if app := parts["docProps/app.xml"]; app != nil {
+		var properties struct {
+			Pages string `xml:"Pages"`
+		}
+		if err := parts.decode(app, &properties); err != nil {
+			return nil, err
+		}
+		result.PageCount, _ = strconv.Atoi(strings.TrimSpace(properties.Pages))
+	}
+
+	var text textBuilder
+	if err := parts.text(&text, "word/document.xml", "t", "p"); err != nil {
+		return nil, err
+	}
+	result.Text = text.String()
+	return result, nil
+}
+
+// readXLSX берёт текст из общей таблицы строк: там лежат все текстовые ячейки
+// книги, числа и даты для поиска не нужны
+func readXLSX(file io.ReaderAt, size int64) (*Result, error) {
+	parts, err := openOOXML(file, size)
+	if err != nil {
+		return nil, err
+	}
+	workbook := parts["xl/workbook.xml"]
+	if workbook == nil {
+		return nil, errors.New("xl/workbook.xml is missing")
+	}
+
+	result := &Result{}
+	if err := parts.properties(result); err != nil {
+		return nil, err
+	}
+	var book struct {
+		Sheets []struct{} `xml:"sheets>sheet"`
+	}
+	if err := parts.decode(workbook, &book); err != nil {
+		return nil, err
+	}
+	result.PageCount = len(book.Sheets)
+
+	var text textBuilder
+	if err := parts.text(&text, "xl/sharedStrings.xml", "t", "si"); err != nil {
+		return nil, err
+	}
+	result.Text = text.String()
+	return result, nil
+}
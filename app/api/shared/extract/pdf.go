@@ -0,0 +1,46 @@
+package extract
+
+import (
+	"fmt"
+	"io"
+
+	"github.com/ledongthuc/pdf"
+)
+
+func readPDF(file io.ReaderAt, size int64) (result *Result, err error) {
+	// Разбор повреждённых файлов в библиотеке может закончиться паникой
+	defer func() {
+		if r := recover(); r != nil {
+			result, err = nil, fmt.Errorf("invalid pdf: %v", r)
+		}
+	}()
+
+	reader, err := pdf.NewReader(file, size)
+	if err != nil {
+		return nil, fmt.Errorf("invalid pdf: %w", err)
+	}
+
+	info := reader.Trailer().Key("Info")
+	result = &Result{
+		PageCount: reader.NumPage(),
+		Title:     info.Key("Title").Text(),
+		Author:    info.Key("Author").Text(),
+	}
+
+	var text textBuilder
+	for i := 1; i <= result.PageCount && !text.full; i++ {
+		page := reader.Page(i)
+		if page.V.IsNull() {
+			continue
+		}
+		// Страница, текст которой не разобрать, не мешает остальным
+		pageText, err := page.GetPlainText(nil)
+		if err != nil {
+			continue
+		}
+		text.write(pageText)
+		text.separate()
+	}
+	result.Text = text.String()
+	return result, nil
+}
@@ -0,0 +1,35 @@
+package extract
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strconv"
+	"strings"
+)
+
+// Preview рисует первую страницу PDF из файла path в PNG шириной width.
+// command - pdftoppm из poppler-utils или совместимая с ним программа
+func Preview(ctx context.Context, command, path string, width int) ([]byte, error) {
+	dir, err := os.MkdirTemp("", "document-preview-")
+	if err != nil {
+		return nil, err
+	}
+	defer os.RemoveAll(dir)
+
+	output := filepath.Join(dir, "page")
+	cmd := exec.CommandContext(ctx, command,
+		"-png", "-f", "1", "-l", "1", "-singlefile",
+		"-scale-to-x", strconv.Itoa(width), "-scale-to-y", "-1",
+		path, output,
+	)
+	var stderr bytes.Buffer
+	cmd.Stderr = &stderr
+	if err := cmd.Run(); err != nil {
+		return nil, fmt.Errorf("%s: %w: %s", command, err, strings.TrimSpace(stderr.String()))
+	}
+	return os.ReadFile(output + ".png")
+}
@@ -1,62 +1,85 @@
 package shared
 
 import (
-	"log"
+	"context"
+	"fmt"
+	"log/slog"
 	"mime/multipart"
 	"sync"
 
 	files "federation-backend/app/api/file"
 	"federation-backend/app/db/models"
+	"federation-backend/app/logging"
+	"federation-backend/app/tracing"
+
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/codes"
 )
 
 type FileProcessor interface {
-	SaveFile(fileHeader *multipart.FileHeader) (*models.File, error)
-	DeleteFile(filename string) error
-	SaveFilesParallel(files []*multipart.FileHeader) ([]*models.File, []error)
+	SaveFile(ctx context.Context, fileHeader *multipart.FileHeader) (*models.File, error)
+	DeleteFile(ctx context.Context, filename string) error
+	SaveFilesParallel(ctx context.Context, files []*multipart.FileHeader) ([]*models.File, []error)
+	GetFilePath(filename string) string
 }
 
 type ConcurrentFileProcessor struct {
 	fileService *files.Service
-	logger      *log.Logger
+	logger      *slog.Logger
 }
 
-func NewConcurrentFileProcessor(fileService *files.Service, logger *log.Logger) *ConcurrentFileProcessor {
+func NewConcurrentFileProcessor(fileService *files.Service, logger *slog.Logger) *ConcurrentFileProcessor {
 	return &ConcurrentFileProcessor{
 		fileService: fileService,
 		logger:      logger,
 	}
 }
 
-func (p *ConcurrentFileProcessor) SaveFilesParallel(files []*multipart.FileHeader) ([]*models.File, []error) {
+func (p *ConcurrentFileProcessor) SaveFilesParallel(ctx context.Context, files []*multipart.FileHeader) ([]*models.File, []error) {
+	ctx, span := tracing.Start(ctx, "files.SaveFilesParallel", attribute.Int("files.count", len(files)))
+	defer span.End()
+
 	var wg sync.WaitGroup
 	results := make([]*models.File, len(files))
 	errors := make([]error, len(files))
 
 	for i, file := range files {
-		p.logger.Printf("[%d] Saving image...\n", i)
+		p.logger.DebugContext(ctx, "saving file", slog.Int("index", i), slog.String("name", file.Filename))
 		wg.Add(1)
 		go func(idx int, f *multipart.FileHeader) {
 			defer wg.Done()
-			file, err := p.fileService.SaveFile(f)
+			file, err := p.fileService.SaveFile(ctx, f)
 			results[idx] = file
 			errors[idx] = err
 			if err != nil {
-				p.logger.Printf("[%d] Image wasn't saved!\n", idx)
-			} else {
-				p.logger.Printf("[%d] Image was saved!\n", idx)
+				p.logger.WarnContext(ctx, "file was not saved", slog.Int("index", idx), slog.String("name", f.Filename), logging.Error(err))
 			}
 		}(i, file)
 	}
 
 	wg.Wait()
+	failed := 0
+	for _, err := range errors {
+		if err != nil {
+			failed++
+		}
+	}
+	span.SetAttributes(attribute.Int("files.failed", failed))
+	if failed > 0 {
+		span.SetStatus(codes.Error, fmt.Sprintf("%d of %d files were not saved", failed, len(files)))
+	}
 	return results, errors
 }
 
 // Реализуем остальные методы интерфейса
-func (p *ConcurrentFileProcessor) SaveFile(fileHeader *multipart.FileHeader) (*models.File, error) {
-	return p.fileService.SaveFile(fileHeader)
+func (p *ConcurrentFileProcessor) SaveFile(ctx context.Context, fileHeader *multipart.FileHeader) (*models.File, error) {
+	return p.fileService.SaveFile(ctx, fileHeader)
+}
+
+func (p *ConcurrentFileProcessor) DeleteFile(ctx context.Context, filename string) error {
+	return p.fileService.DeleteFile(ctx, filename)
 }
 
-func (p *ConcurrentFileProcessor) DeleteFile(filename string) error {
-	return p.fileService.DeleteFile(filename)
+func (p *ConcurrentFileProcessor) GetFilePath(filename string) string {
+	return p.fileService.GetFilePath(filename)
 }
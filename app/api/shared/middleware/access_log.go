@@ -0,0 +1,59 @@
+package middleware
+
+import (
+	"federation-backend/app/logging"
+	"log/slog"
+	"net/http"
+	"slices"
+	"time"
+
+	"github.com/gin-gonic/gin"
+)
+
+// AccessLog пишет одну запись на каждый обработанный запрос.
+// Успешные запросы к quiet (пробы оркестратора, сбор метрик) пишутся на уровне debug
+func AccessLog(logger *slog.Logger, quiet ...string) gin.HandlerFunc {
+	return func(ctx *gin.Context) {
+		start := time.Now()
+		ctx.Next()
+
+		status := ctx.Writer.Status()
+		level := slog.LevelInfo
+		switch {
+		case status >= http.StatusInternalServerError:
+			level = slog.LevelError
+		case status >= http.StatusBadRequest:
+			level = slog.LevelWarn
+		case slices.Contains(quiet, ctx.Request.URL.Path):
+			level = slog.LevelDebug
+		}
+
+		attrs := []slog.Attr{
+			slog.String("method", ctx.Request.Method),
+			slog.String("path", ctx.Request.URL.Path),
+			slog.String("route", ctx.FullPath()),
+			slog.Int("status", status),
+			slog.Duration("duration", time.Since(start)),
+			slog.Int("bytes", ctx.Writer.Size()),
+			slog.String("client_ip", ctx.ClientIP()),
+		}
+		if len(ctx.Errors) > 0 {
+			attrs = append(attrs, slog.String(logging.KeyError, ctx.Errors.String()))
+		}
+
+		logger.LogAttrs(ctx.Request.Context(), level, "request handled", attrs...)
+	}
+}
+
+// Recovery отвечает 500 на панику в обработчике и логирует её вместе с ID запроса.
+// http.ErrAbortHandler пропускается дальше: так обработчик обрывает соединение,
+// если ответ уже начал уходить клиенту
+func Recovery(logger *slog.Logger) gin.HandlerFunc {
+	return gin.CustomRecoveryWithWriter(nil, func(ctx *gin.Context, recovered any) {
+		if recovered == http.ErrAbortHandler {
+			panic(recovered)
+		}
+		logger.ErrorContext(ctx.Request.Context(), "panic recovered", slog.Any("panic", recovered))
+		ctx.AbortWithStatusJSON(http.StatusInternalServerError, gin.H{"error": "internal server error"})
+	})
+}
@@ -0,0 +1,47 @@
+package middleware
+
+import (
+	"crypto/subtle"
+	"net/http"
+	"strings"
+
+	"github.com/gin-gonic/gin"
+)
+
+// Authenticate кладёт в контекст идентификатор admin, если запрос несёт верный
+// Authorization: Bearer <token>. Запросы без токена не отклоняет: стоит перед
+// лимитами, чтобы сотрудник считался по своему ведру, а не по IP
+func Authenticate(token string) gin.HandlerFunc {
+	return func(ctx *gin.Context) {
+		if token != "" && hasToken(ctx, token) {
+			ctx.Set(ContextIdentity, "admin")
+		}
+		ctx.Next()
+	}
+}
+
+// AdminOnly пропускает только запросы с заголовком Authorization: Bearer <token>.
+// Пустой token означает, что служебные эндпоинты выключены
+func AdminOnly(token string) gin.HandlerFunc {
+	return func(ctx *gin.Context) {
+		if token == "" {
+			ctx.AbortWithStatusJSON(http.StatusForbidden, gin.H{"error": "admin endpoints are disabled, set auth.admin_token"})
+			return
+		}
+
+		if !hasToken(ctx, token) {
+			ctx.Header("WWW-Authenticate", `Bearer realm="admin"`)
+			ctx.AbortWithStatusJSON(http.StatusUnauthorized, gin.H{"error": "invalid or missing admin token"})
+			return
+		}
+
+		ctx.Set(ContextIdentity, "admin")
+		ctx.Next()
+	}
+}
+
+// hasToken - запрос несёт Authorization: Bearer <token>
+func hasToken(ctx *gin.Context, token string) bool {
+	provided, ok := strings.CutPrefix(ctx.GetHeader("Authorization"), "Bearer ")
+	return ok && subtle.ConstantTimeCompare([]byte(provided), []byte(token)) == 1
+}
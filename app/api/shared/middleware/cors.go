@@ -0,0 +1,53 @@
+package middleware
+
+import (
+	"federation-backend/app/config"
+	"federation-backend/app/interfaces"
+	"net/http"
+
+	cors "github.com/rs/cors/wrapper/gin"
+
+	"github.com/gin-gonic/gin"
+)
+
+// Заголовки, через которые списки отдают данные пагинации
+const (
+	HeaderTotalCount = "X-Total-Count"
+	HeaderLink       = "Link"
+)
+
+var allowedHeaders = []string{
+	"Accept",
+	"Authorization",
+	"Cache-Control",
+	"Content-Type",
+	"Origin",
+	"X-CSRF-Token",
+	"X-Requested-With",
+	HeaderRequestID,
+}
+
+var exposedHeaders = []string{
+	HeaderTotalCount,
+	HeaderLink,
+	"Content-Disposition",
+	HeaderRequestID,
+	HeaderRateLimitLimit,
+	HeaderRateLimitRemaining,
+	HeaderRateLimitReset,
+	HeaderRateLimitPolicy,
+	HeaderRetryAfter,
+}
+
+// CORS настраивает кросс-доменные запросы по списку origin для окружения env.
+// Разрешены все методы, которые регистрирует interfaces.RegisterRoutes
+func CORS(cfg *config.CORSConfig, env string) gin.HandlerFunc {
+	return cors.New(cors.Options{
+		AllowedOrigins:   cfg.Origins(env),
+		AllowedMethods:   append([]string{http.MethodHead, http.MethodOptions}, interfaces.Methods...),
+		AllowedHeaders:   allowedHeaders,
+		ExposedHeaders:   exposedHeaders,
+		AllowCredentials: cfg.AllowCredentials,
+		MaxAge:           int(cfg.MaxAge.Seconds()),
+	})
+}
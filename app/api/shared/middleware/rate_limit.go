@@ -0,0 +1,82 @@
+package middleware
+
+import (
+	"federation-backend/app/config"
+	"federation-backend/app/logging"
+	"federation-backend/app/metrics"
+	"federation-backend/app/ratelimit"
+	"fmt"
+	"log/slog"
+	"math"
+	"net/http"
+	"slices"
+	"strconv"
+	"time"
+
+	"github.com/gin-gonic/gin"
+)
+
+const (
+	HeaderRateLimitLimit     = "RateLimit-Limit"
+	HeaderRateLimitRemaining = "RateLimit-Remaining"
+	HeaderRateLimitReset     = "RateLimit-Reset"
+	HeaderRateLimitPolicy    = "RateLimit-Policy"
+	HeaderRetryAfter         = "Retry-After"
+)
+
+// ContextIdentity - ключ gin.Context, под которым middleware аутентификации
+// оставляет идентификатор клиента. Без него лимит считается по IP
+const ContextIdentity = "identity"
+
+// RateLimit ограничивает запросы к группе маршрутов политикой name: у каждого
+// клиента своё ведро. Ответ несёт заголовки RateLimit-*, отказ - 429 и Retry-After.
+// Если хранилище лимитов недоступно, запрос пропускается
+func RateLimit(store ratelimit.Store, name string, policy config.RateLimitPolicy, logger *slog.Logger) gin.HandlerFunc {
+	bucket := ratelimit.Policy{Limit: policy.Limit, Period: policy.Period, Burst: policy.Burst}
+	header := fmt.Sprintf("%d;w=%d", policy.Limit, seconds(policy.Period))
+	if policy.Burst > 0 {
+		header += fmt.Sprintf(";burst=%d", policy.Burst)
+	}
+
+	return func(ctx *gin.Context) {
+		method := ctx.Request.Method
+		if method == http.MethodOptions || (len(policy.Methods) > 0 && !slices.Contains(policy.Methods, method)) {
+			ctx.Next()
+			return
+		}
+
+		decision, err := store.Take(ctx.Request.Context(), "route:"+name+":"+Identity(ctx), bucket)
+		if err != nil {
+			logger.WarnContext(ctx.Request.Context(), "rate limit check failed, request allowed", slog.String("policy", name), logging.Error(err))
+			ctx.Next()
+			return
+		}
+
+		ctx.Header(HeaderRateLimitLimit, strconv.Itoa(decision.Limit))
+		ctx.Header(HeaderRateLimitRemaining, strconv.Itoa(decision.Remaining))
+		ctx.Header(HeaderRateLimitReset, strconv.Itoa(seconds(decision.Reset)))
+		ctx.Header(HeaderRateLimitPolicy, header)
+
+		if !decision.Allowed {
+			metrics.HTTPRateLimited.WithLabelValues(name).Inc()
+			ctx.Header(HeaderRetryAfter, strconv.Itoa(seconds(decision.RetryAfter)))
+			ctx.AbortWithStatusJSON(http.StatusTooManyRequests, gin.H{"error": "too many requests, try again later"})
+			return
+		}
+
+		ctx.Next()
+	}
+}
+
+// Identity - ключ клиента для лимитов: аутентифицированный пользователь или IP
+func Identity(ctx *gin.Context) string {
+	if identity := ctx.GetString(ContextIdentity); identity != "" {
+		return "user:" + identity
+	}
+	return "ip:" + ctx.ClientIP()
+}
+
+// seconds округляет вверх, чтобы клиент не повторил запрос раньше времени
+func seconds(d time.Duration) int {
+	return int(math.Ceil(d.Seconds()))
+}
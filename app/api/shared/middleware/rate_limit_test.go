@@ -0,0 +1,106 @@
+package middleware
+
+import (
+	"federation-backend/app/config"
+	"federation-backend/app/ratelimit"
+	"io"
+	"log/slog"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/gin-gonic/gin"
+)
+
+const testToken = "secretsecretsecret1"
+
+func newRateLimitedRouter(policy config.RateLimitPolicy) *gin.Engine {
+	gin.SetMode(gin.TestMode)
+	logger := slog.New(slog.NewTextHandler(io.Discard, nil))
+	router := gin.New()
+	api := router.Group("/api", Authenticate(testToken), RateLimit(ratelimit.NewMemory(), "default", policy, logger))
+	api.GET("/ping", func(ctx *gin.Context) { ctx.Status(http.StatusOK) })
+	api.POST("/ping", func(ctx *gin.Context) { ctx.Status(http.StatusOK) })
+	api.GET("/admin", AdminOnly(testToken), func(ctx *gin.Context) { ctx.Status(http.StatusOK) })
+	return router
+}
+
+func TestRateLimit(t *testing.T) {
+	type request struct {
+		method string
+		path   string
+		token  string
+		// wantStatus, wantRemaining и wantRetryAfter - ожидаемый ответ, "" - заголовка нет
+		wantStatus     int
+		wantRemaining  string
+		wantRetryAfter string
+	}
+	tests := []struct {
+		name     string
+		policy   config.RateLimitPolicy
+		requests []request
+	}{
+		{
+			name:   "refuse with retry-after",
+			policy: config.RateLimitPolicy{Limit: 2, Period: time.Minute},
+			requests: []request{
+				{method: http.MethodGet, path: "/api/ping", wantStatus: http.StatusOK, wantRemaining: "1"},
+				{method: http.MethodGet, path: "/api/ping", wantStatus: http.StatusOK, wantRemaining: "0"},
+				{method: http.MethodGet, path: "/api/ping", wantStatus: http.StatusTooManyRequests, wantRemaining: "0", wantRetryAfter: "30"},
+			},
+		},
+		{
+			name:   "admin token has its own bucket",
+			policy: config.RateLimitPolicy{Limit: 1, Period: time.Minute},
+			requests: []request{
+				{method: http.MethodGet, path: "/api/ping", wantStatus: http.StatusOK, wantRemaining: "0"},
+				{method: http.MethodGet, path: "/api/ping", wantStatus: http.StatusTooManyRequests, wantRetryAfter: "60", wantRemaining: "0"},
+				{method: http.MethodGet, path: "/api/admin", token: testToken, wantStatus: http.StatusOK, wantRemaining: "0"},
+				{method: http.MethodGet, path: "/api/ping", token: testToken, wantStatus: http.StatusTooManyRequests, wantRetryAfter: "60", wantRemaining: "0"},
+			},
+		},
+		{
+			name:   "wrong token counts by ip",
+			policy: config.RateLimitPolicy{Limit: 1, Period: time.Minute},
+			requests: []request{
+				{method: http.MethodGet, path: "/api/ping", wantStatus: http.StatusOK, wantRemaining: "0"},
+				{method: http.MethodGet, path: "/api/admin", token: "wrong-token-wrong", wantStatus: http.StatusTooManyRequests, wantRetryAfter: "60", wantRemaining: "0"},
+			},
+		},
+		{
+			name:   "methods limit the policy",
+			policy: config.RateLimitPolicy{Limit: 1, Period: time.Minute, Methods: []string{http.MethodPost}},
+			requests: []request{
+				{method: http.MethodGet, path: "/api/ping", wantStatus: http.StatusOK},
+				{method: http.MethodGet, path: "/api/ping", wantStatus: http.StatusOK},
+				{method: http.MethodPost, path: "/api/ping", wantStatus: http.StatusOK, wantRemaining: "0"},
+				{method: http.MethodPost, path: "/api/ping", wantStatus: http.StatusTooManyRequests, wantRetryAfter: "60", wantRemaining: "0"},
+			},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			router := newRateLimitedRouter(tt.policy)
+			for i, r := range tt.requests {
+				req := httptest.NewRequest(r.method, r.path, nil)
+				if r.token != "" {
+					req.Header.Set("Authorization", "Bearer "+r.token)
+				}
+				rec := httptest.NewRecorder()
+				router.ServeHTTP(rec, req)
+
+				if rec.Code != r.wantStatus {
+					t.Fatalf("request %d: status %d, want %d", i, rec.Code, r.wantStatus)
+				}
+				if got := rec.Header().Get(HeaderRateLimitRemaining); got != r.wantRemaining {
+					t.Errorf("request %d: %s = %q, want %q", i, HeaderRateLimitRemaining, got, r.wantRemaining)
+				}
+				if got := rec.Header().Get(HeaderRetryAfter); got != r.wantRetryAfter {
+					t.Errorf("request %d: %s = %q, want %q", i, HeaderRetryAfter, got, r.wantRetryAfter)
+				}
+			}
+		})
+	}
+}
@@ -0,0 +1,30 @@
+package middleware
+
+import (
+	"federation-backend/app/logging"
+	"regexp"
+
+	"github.com/gin-gonic/gin"
+	"github.com/google/uuid"
+)
+
+const HeaderRequestID = "X-Request-ID"
+
+// Входящий ID принимается только если он похож на идентификатор,
+// чтобы клиент не мог записать в логи произвольный текст
+var validRequestID = regexp.MustCompile(`^[A-Za-z0-9._-]{1,64}$`)
+
+// RequestID присваивает запросу ID (из заголовка X-Request-ID или новый),
+// возвращает его клиенту и кладёт в контекст запроса для логов сервисов
+func RequestID() gin.HandlerFunc {
+	return func(ctx *gin.Context) {
+		id := ctx.GetHeader(HeaderRequestID)
+		if !validRequestID.MatchString(id) {
+			id = uuid.NewString()
+		}
+
+		ctx.Header(HeaderRequestID, id)
+		ctx.Request = ctx.Request.WithContext(logging.WithRequestID(ctx.Request.Context(), id))
+		ctx.Next()
+	}
+}
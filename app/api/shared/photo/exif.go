@@ -0,0 +1,173 @@
+package photo
+
+import (
+	"encoding/binary"
+	"errors"
+	"strings"
+	"time"
+)
+
+// Теги EXIF, которые читает parseEXIF
+const (
+	tagMake               = 0x010F
+	tagModel              = 0x0110
+	tagOrientation        = 0x0112
+	tagDateTime           = 0x0132
+	tagExifIFD            = 0x8769
+	tagDateTimeOriginal   = 0x9003
+	tagOffsetTimeOriginal = 0x9011
+)
+
+const (
+	typeASCII = 2
+	typeShort = 3
+	typeLong  = 4
+)
+
+// MaxCamera - длина колонки files.camera
+const MaxCamera = 255
+
+// exifLayout - формат даты в EXIF, без часового пояса
+const exifLayout = "2006:01:02 15:04:05"
+
+var errBadEXIF = errors.New("malformed EXIF")
+
+// exifTags - теги EXIF, нужные при загрузке
+type exifTags struct {
+	orientation int
+	takenAt     *time.Time
+	camera      string
+}
+
+// exifReader читает записи IFD из блока TIFF
+type exifReader struct {
+	data  []byte
+	order binary.ByteOrder
+}
+
+// parseEXIF разбирает блок TIFF из EXIF: IFD0 и вложенный Exif IFD. Время
+// съёмки - DateTimeOriginal со смещением OffsetTimeOriginal, без смещения -
+// в часовом поясе сервера; если его нет - DateTime
+func parseEXIF(data []byte) (exifTags, error) {
+	var tags exifTags
+	if len(data) < 8 {
+		return tags, errBadEXIF
+	}
+	reader := exifReader{data: data}
+	switch string(data[:2]) {
+	case "II":
+		reader.order = binary.LittleEndian
+	case "MM":
+		reader.order = binary.BigEndian
+	default:
+		return tags, errBadEXIF
+	}
+	if reader.order.Uint16(data[2:]) != 42 {
+		return tags, errBadEXIF
+	}
+
+	ifd0, err := reader.ifd(reader.order.Uint32(data[4:]))
+	if err != nil {
+		return tags, err
+	}
+	if value, ok := ifd0[tagOrientation]; ok {
+		tags.orientation = int(reader.integer(value))
+	}
+	maker, model := reader.ascii(ifd0[tagMake]), reader.ascii(ifd0[tagModel])
+	// Model обычно уже начинается с производителя: "Canon EOS 80D"
+	if maker != "" && !strings.HasPrefix(strings.ToLower(model), strings.ToLower(maker)) {
+		model = strings.TrimSpace(maker + " " + model)
+	}
+	if runes := []rune(model); len(runes) > MaxCamera {
+		model = string(runes[:MaxCamera])
+	}
+	tags.camera = model
+
+	taken, offset := reader.ascii(ifd0[tagDateTime]), ""
+	if value, ok := ifd0[tagExifIFD]; ok {
+		exif, err := reader.ifd(reader.integer(value))
+		if err == nil {
+			if original := reader.ascii(exif[tagDateTimeOriginal]); original != "" {
+				taken, offset = original, reader.ascii(exif[tagOffsetTimeOriginal])
+			}
+		}
+	}
+	tags.takenAt = parseEXIFTime(taken, offset)
+	return tags, nil
+}
+
+func parseEXIFTime(value, offset string) *time.Time {
+	location := time.Local
+	if offset != "" {
+		if zone, err := time.Parse("-07:00", offset); err == nil {
+			location = zone.Location()
+		}
+	}
+	taken, err := time.ParseInLocation(exifLayout, value, location)
+	// Камеры без часов пишут нули или пробелы
+	if err != nil || taken.Year() < 1900 {
+		return nil
+	}
+	return &taken
+}
+
+// ifdEntry - запись IFD: тип, число значений и сами значения или смещение до них
+type ifdEntry struct {
+	kind  uint16
+	count uint32
+	value []byte
+}
+
+// ifd читает записи каталога по смещению offset
+func (r exifReader) ifd(offset uint32) (map[uint16]ifdEntry, error) {
+	if uint64(offset)+2 > uint64(len(r.data)) {
+		return nil, errBadEXIF
+	}
+	count := int(r.order.Uint16(r.data[offset:]))
+	start := int(offset) + 2
+	if start+count*12 > len(r.data) {
+		return nil, errBadEXIF
+	}
+	entries := make(map[uint16]ifdEntry, count)
+	for i := 0; i < count; i++ {
+		raw := r.data[start+i*12 : start+i*12+12]
+		entry := ifdEntry{
+			kind:  r.order.Uint16(raw[2:]),
+			count: r.order.Uint32(raw[4:]),
+			value: raw[8:12],
+		}
+		entries[r.order.Uint16(raw)] = entry
+	}
+	return entries, nil
+}
+
+// integer - первое значение SHORT или LONG
+func (r exifReader) integer(entry ifdEntry) uint32 {
+	switch entry.kind {
+	case typeShort:
+		return uint32(r.order.Uint16(entry.value))
+	case typeLong:
+		return r.order.Uint32(entry.value)
+	}
+	return 0
+}
+
+// ascii - строка ASCII без завершающих нулей и пробелов. До четырёх байт
+// хранятся в самой записи, длиннее - по смещению
+func (r exifReader) ascii(entry ifdEntry) string {
+	if entry.kind != typeASCII || entry.count == 0 {
+		return ""
+	}
+	value := entry.value
+	if entry.count > 4 {
+		offset := uint64(r.order.Uint32(entry.value))
+		if offset+uint64(entry.count) > uint64(len(r.data)) {
+			return ""
+		}
+		value = r.data[offset : offset+uint64(entry.count)]
+	} else {
+		value = value[:entry.count]
+	}
+	text := strings.ToValidUTF8(strings.TrimRight(string(value), "\x00"), "")
+	return strings.TrimSpace(text)
+}
@@ -0,0 +1,52 @@
+package photo
+
+import (
+	"image"
+	"image/draw"
+)
+
+// orient поворачивает и отражает src так, как предписывает EXIF Orientation:
+// 2 - отражение по горизонтали, 3 - поворот на 180°, 4 - отражение по
+// вертикали, 5 - транспонирование, 6 - поворот на 90° по часовой, 7 -
+// поперечное отражение, 8 - поворот на 90° против часовой
+func orient(src image.Image, orientation int) image.Image {
+	bounds := src.Bounds()
+	rgba, ok := src.(*image.RGBA)
+	if !ok || bounds.Min != (image.Point{}) {
+		rgba = image.NewRGBA(image.Rect(0, 0, bounds.Dx(), bounds.Dy()))
+		draw.Draw(rgba, rgba.Bounds(), src, bounds.Min, draw.Src)
+	}
+
+	w, h := bounds.Dx(), bounds.Dy()
+	dw, dh := w, h
+	if orientation >= 5 {
+		dw, dh = h, w
+	}
+	dst := image.NewRGBA(image.Rect(0, 0, dw, dh))
+	for y := 0; y < dh; y++ {
+		for x := 0; x < dw; x++ {
+			var sx, sy int
+			switch orientation {
+			case 2:
+				sx, sy = w-1-x, y
+			case 3:
+				sx, sy = w-1-x, h-1-y
+			case 4:
+				sx, sy = x, h-1-y
+			case 5:
+				sx, sy = y, x
+			case 6:
+				sx, sy = y, h-1-x
+			case 7:
+				sx, sy = w-1-y, h-1-x
+			case 8:
+				sx, sy = w-1-y, x
+			default:
+				sx, sy = x, y
+			}
+			si := rgba.PixOffset(sx, sy)
+			copy(dst.Pix[dst.PixOffset(x, y):dst.PixOffset(x, y)+4], rgba.Pix[si:si+4])
+		}
+	}
+	return dst
+}
@@ -0,0 +1,83 @@
+package photo
+
+import (
+	"image"
+	"image/color"
+	"strings"
+	"testing"
+)
+
+// labelled - изображение из строк rows, каждый пиксель окрашен по своей букве
+func labelled(rows ...string) *image.RGBA {
+	img := image.NewRGBA(image.Rect(0, 0, len(rows[0]), len(rows)))
+	for y, row := range rows {
+		for x, label := range row {
+			img.Set(x, y, color.RGBA{R: uint8(label), A: 255})
+		}
+	}
+	return img
+}
+
+// labels - обратное к labelled
+func labels(img image.Image) []string {
+	bounds := img.Bounds()
+	rows := make([]string, 0, bounds.Dy())
+	for y := bounds.Min.Y; y < bounds.Max.Y; y++ {
+		var row strings.Builder
+		for x := bounds.Min.X; x < bounds.Max.X; x++ {
+			r, _, _, _ := img.At(x, y).RGBA()
+			row.WriteByte(byte(r >> 8))
+		}
+		rows = append(rows, row.String())
+	}
+	return rows
+}
+
+func TestOrient(t *testing.T) {
+	src := []string{
+		"abc",
+		"def",
+	}
+	tests := []struct {
+		orientation int
+		want        []string
+	}{
+		{1, []string{"abc", "def"}},
+		{2, []string{"cba", "fed"}},
+		{3, []string{"fed", "cba"}},
+		{4, []string{"def", "abc"}},
+		{5, []string{"ad", "be", "cf"}},
+		{6, []string{"da", "eb", "fc"}},
+		{7, []string{"fc", "eb", "da"}},
+		{8, []string{"cf", "be", "ad"}},
+	}
+	for _, tt := range tests {
+		got := labels(orient(labelled(src...), tt.orientation))
+		if strings.Join(got, "/") != strings.Join(tt.want, "/") {
+			t.Errorf("orientation %d: got %v, want %v", tt.orientation, got, tt.want)
+		}
+	}
+}
+
+// Часть изображения со смещённым началом и не RGBA поворачивается так же
+func TestOrientSubImage(t *testing.T) {
+	full := labelled(
+		"xxxx",
+		"xabc",
+		"xdef",
+	)
+	sub := full.SubImage(image.Rect(1, 1, 4, 3))
+	converted := image.NewNRGBA(sub.Bounds())
+	for y := sub.Bounds().Min.Y; y < sub.Bounds().Max.Y; y++ {
+		for x := sub.Bounds().Min.X; x < sub.Bounds().Max.X; x++ {
+			converted.Set(x, y, sub.At(x, y))
+		}
+	}
+
+	for name, src := range map[string]image.Image{"rgba": sub, "nrgba": converted} {
+		got := labels(orient(src, 6))
+		if want := []string{"da", "eb", "fc"}; strings.Join(got, "/") != strings.Join(want, "/") {
+			t.Errorf("%s: got %v, want %v", name, got, want)
+		}
+	}
+}
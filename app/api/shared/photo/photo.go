@@ -0,0 +1,153 @@
+package photo
+
+import (
+	"bufio"
+	"bytes"
+	"errors"
+	"fmt"
+	"image"
+	"image/jpeg"
+	"image/png"
+	"io"
+	"path/filepath"
+	"strings"
+	"time"
+)
+
+// jpegQuality - качество JPEG, пересохранённого после поворота
+const jpegQuality = 92
+
+var ErrInvalidImage = errors.New("invalid image")
+
+// Metadata - сведения о фотографии. Width и Height - размеры после поворота
+// по EXIF, TakenAt и Camera - из EXIF, если он был
+type Metadata struct {
+	Width   int
+	Height  int
+	TakenAt *time.Time
+	Camera  string
+}
+
+// Supported - формат файла с именем name умеет обрабатывать Process
+func Supported(name string) bool {
+	switch strings.ToLower(filepath.Ext(name)) {
+	case ".jpg", ".jpeg", ".png":
+		return true
+	}
+	return false
+}
+
+// Process готовит загруженную фотографию к хранению: читает EXIF, поворачивает
+// изображение по тегу Orientation и убирает метаданные (EXIF с координатами
+// GPS, XMP, IPTC, текстовые блоки PNG, дополнительные кадры MPF), сохраняя
+// цветовой профиль. Без поворота изображение не
+// пересжимается - из файла вырезаются только блоки метаданных. Фотография
+// больше maxPixels пикселей отклоняется до распаковки
+func Process(content []byte, name string, maxPixels int64) ([]byte, *Metadata, error) {
+	var stripped, exif, profile []byte
+	var err error
+	switch strings.ToLower(filepath.Ext(name)) {
+	case ".jpg", ".jpeg":
+		stripped, exif, profile, err = stripJPEG(content)
+	case ".png":
+		stripped, exif, profile, err = stripPNG(content)
+	default:
+		return nil, nil, fmt.Errorf("%w: unsupported format %s", ErrInvalidImage, name)
+	}
+	if err != nil {
+		return nil, nil, fmt.Errorf("%w: %s: %v", ErrInvalidImage, name, err)
+	}
+
+	// Повреждённый EXIF не мешает загрузке: он всё равно вырезан
+	tags, _ := parseEXIF(exif)
+	metadata := &Metadata{TakenAt: tags.takenAt, Camera: tags.camera}
+
+	// Размеры из заголовка: распаковывать изображение можно, только убедившись,
+	// что оно поместится в память
+	config, _, err := image.DecodeConfig(bytes.NewReader(stripped))
+	if err != nil {
+		return nil, nil, fmt.Errorf("%w: %s: %v", ErrInvalidImage, name, err)
+	}
+	if int64(config.Width)*int64(config.Height) > maxPixels {
+		return nil, nil, fmt.Errorf("%w: %s: %dx%d is more than %d pixels", ErrInvalidImage, name, config.Width, config.Height, maxPixels)
+	}
+	metadata.Width, metadata.Height = config.Width, config.Height
+
+	if tags.orientation > 1 && tags.orientation <= 8 {
+		stripped, err = reorient(stripped, tags.orientation, profile)
+		if err != nil {
+			return nil, nil, fmt.Errorf("%w: %s: %v", ErrInvalidImage, name, err)
+		}
+		if tags.orientation >= 5 {
+			metadata.Width, metadata.Height = config.Height, config.Width
+		}
+	}
+	return stripped, metadata, nil
+}
+
+// maxMetadata - сколько байт от начала файла TakenAt готов прочитать в
+// поисках EXIF
+const maxMetadata = 1 << 20
+
+// TakenAt - время съёмки из EXIF фотографии name, то же, что сохранит
+// Process. Из r читаются только блоки до данных изображения, не больше
+// maxMetadata байт. nil, если времени в EXIF нет
+func TakenAt(r io.Reader, name string) *time.Time {
+	reader := bufio.NewReader(io.LimitReader(r, maxMetadata))
+	var exif []byte
+	switch strings.ToLower(filepath.Ext(name)) {
+	case ".jpg", ".jpeg":
+		exif, _ = readJPEGEXIF(reader)
+	case ".png":
+		exif, _ = readPNGEXIF(reader)
+	}
+	tags, _ := parseEXIF(exif)
+	return tags.takenAt
+}
+
+// reorient декодирует изображение, поворачивает и кодирует в том же формате.
+// Кодировщики стандартной библиотеки метаданных не пишут, поэтому блоки
+// цветового профиля profile из stripJPEG или stripPNG вставляются в результат
+// отдельно
+func reorient(content []byte, orientation int, profile []byte) ([]byte, error) {
+	src, format, err := image.Decode(bytes.NewReader(content))
+	if err != nil {
+		return nil, err
+	}
+	// Профиль CMYK не подходит к пересохранённому в RGB изображению
+	if _, cmyk := src.(*image.CMYK); cmyk {
+		profile = nil
+	}
+	dst := orient(src, orientation)
+
+	var buf bytes.Buffer
+	switch format {
+	case "jpeg":
+		err = jpeg.Encode(&buf, dst, &jpeg.Options{Quality: jpegQuality})
+	case "png":
+		err = png.Encode(&buf, dst)
+	default:
+		return nil, fmt.Errorf("unexpected format %s", format)
+	}
+	if err != nil {
+		return nil, err
+	}
+	return withProfile(buf.Bytes(), format, profile), nil
+}
+
+// withProfile вставляет блоки profile в только что закодированный файл: в JPEG
+// сразу после SOI, в PNG - после IHDR, до данных изображения
+func withProfile(encoded []byte, format string, profile []byte) []byte {
+	if len(profile) == 0 {
+		return encoded
+	}
+	at := 2
+	if format == "png" {
+		// Сигнатура и IHDR: длина, тип, 13 байт данных и CRC
+		at = len(pngSignature) + 12 + 13
+	}
+	out := make([]byte, 0, len(encoded)+len(profile))
+	out = append(out, encoded[:at]...)
+	out = append(out, profile...)
+	return append(out, encoded[at:]...)
+}
@@ -0,0 +1,200 @@
+package photo
+
+import (
+	"bytes"
+	"encoding/binary"
+	"errors"
+	"image"
+	"io"
+	"testing"
+	"time"
+)
+
+func TestProcess(t *testing.T) {
+	jpegBase := encodeJPEG(t, testImage(4, 3))
+	pngBase := encodePNG(t, testImage(4, 3))
+	afterIHDR := len(pngSignature) + 12 + 13
+	exif := func(orientation uint16) []byte {
+		return segment(markerAPP1, append(append([]byte(nil), jpegEXIF...), tiff(orientation, "2023:01:02 03:04:05")...))
+	}
+	icc := segment(markerAPP2, append(append([]byte(nil), jpegICC...), "\x01\x01profile"...))
+	taken := time.Date(2023, 1, 2, 3, 4, 5, 0, time.Local)
+
+	tests := []struct {
+		name       string
+		file       string
+		content    []byte
+		maxPixels  int64
+		wantWidth  int
+		wantHeight int
+		wantTaken  *time.Time
+		// wantProfile - в результате остаётся профиль ICC
+		wantProfile bool
+		wantErr     bool
+	}{
+		{
+			name:       "jpeg without exif",
+			file:       "a.jpg",
+			content:    jpegBase,
+			maxPixels:  100,
+			wantWidth:  4,
+			wantHeight: 3,
+		},
+		{
+			name:        "jpeg rotated by 90 keeps its profile",
+			file:        "a.JPEG",
+			content:     insertAt(jpegBase, 2, exif(6), icc),
+			maxPixels:   100,
+			wantWidth:   3,
+			wantHeight:  4,
+			wantTaken:   &taken,
+			wantProfile: true,
+		},
+		{
+			name:       "jpeg rotated by 180",
+			file:       "a.jpg",
+			content:    insertAt(jpegBase, 2, exif(3)),
+			maxPixels:  100,
+			wantWidth:  4,
+			wantHeight: 3,
+			wantTaken:  &taken,
+		},
+		{
+			name:       "png with exif",
+			file:       "a.png",
+			content:    insertAt(pngBase, afterIHDR, chunk("eXIf", tiff(8, "2023:01:02 03:04:05"))),
+			maxPixels:  100,
+			wantWidth:  3,
+			wantHeight: 4,
+			wantTaken:  &taken,
+		},
+		{
+			name:      "too many pixels",
+			file:      "a.jpg",
+			content:   jpegBase,
+			maxPixels: 11,
+			wantErr:   true,
+		},
+		{
+			name:      "unsupported format",
+			file:      "a.gif",
+			content:   jpegBase,
+			maxPixels: 100,
+			wantErr:   true,
+		},
+		{
+			name:      "extension does not match content",
+			file:      "a.png",
+			content:   jpegBase,
+			maxPixels: 100,
+			wantErr:   true,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			processed, metadata, err := Process(tt.content, tt.file, tt.maxPixels)
+			if tt.wantErr {
+				if !errors.Is(err, ErrInvalidImage) {
+					t.Fatalf("error = %v, want ErrInvalidImage", err)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			if metadata.Width != tt.wantWidth || metadata.Height != tt.wantHeight {
+				t.Errorf("metadata size %dx%d, want %dx%d", metadata.Width, metadata.Height, tt.wantWidth, tt.wantHeight)
+			}
+			config, _, err := image.DecodeConfig(bytes.NewReader(processed))
+			if err != nil {
+				t.Fatalf("processed file does not decode: %v", err)
+			}
+			if config.Width != tt.wantWidth || config.Height != tt.wantHeight {
+				t.Errorf("processed size %dx%d, want %dx%d", config.Width, config.Height, tt.wantWidth, tt.wantHeight)
+			}
+			if (metadata.TakenAt == nil) != (tt.wantTaken == nil) || metadata.TakenAt != nil && !metadata.TakenAt.Equal(*tt.wantTaken) {
+				t.Errorf("taken at %v, want %v", metadata.TakenAt, tt.wantTaken)
+			}
+			if bytes.Contains(processed, jpegEXIF) || bytes.Contains(processed, []byte("eXIf")) {
+				t.Error("exif is left in the processed file")
+			}
+			if got := bytes.Contains(processed, jpegICC); got != tt.wantProfile {
+				t.Errorf("profile kept = %v, want %v", got, tt.wantProfile)
+			}
+			if got := TakenAt(bytes.NewReader(tt.content), tt.file); (got == nil) != (tt.wantTaken == nil) || got != nil && !got.Equal(*tt.wantTaken) {
+				t.Errorf("TakenAt = %v, want %v", got, tt.wantTaken)
+			}
+		})
+	}
+}
+
+// errAfterMetadata - TakenAt читает дальше блоков метаданных
+var errAfterMetadata = errors.New("read past metadata")
+
+type failingReader struct{}
+
+func (failingReader) Read([]byte) (int, error) { return 0, errAfterMetadata }
+
+// TakenAt находит время съёмки, не дочитывая файл дальше данных изображения
+func TestTakenAtReadsOnlyMetadata(t *testing.T) {
+	jpegBase := encodeJPEG(t, testImage(4, 3))
+	pngBase := encodePNG(t, testImage(4, 3))
+	afterIHDR := len(pngSignature) + 12 + 13
+	dateTime := "2023:01:02 03:04:05"
+	exifSegment := segment(markerAPP1, append(append([]byte(nil), jpegEXIF...), tiff(1, dateTime)...))
+	xmpSegment := segment(markerAPP1, []byte("http://ns.adobe.com/xap/1.0/\x00<x:xmpmeta/>"))
+	taken := time.Date(2023, 1, 2, 3, 4, 5, 0, time.Local)
+
+	jpegWithEXIF := insertAt(jpegBase, 2, xmpSegment, exifSegment)
+	pngWithEXIF := insertAt(pngBase, afterIHDR, chunk("tEXt", []byte("a\x00b")), chunk("eXIf", tiff(1, dateTime)))
+	// Огромный eXIf не читается в память
+	hugeEXIF := binary.BigEndian.AppendUint32(nil, 1<<31)
+	hugeEXIF = append(hugeEXIF, "eXIf"...)
+
+	tests := []struct {
+		name string
+		file string
+		// content - начало файла, после него чтение завершается ошибкой
+		content   []byte
+		wantTaken *time.Time
+	}{
+		{
+			name:      "jpeg up to the scan",
+			file:      "a.jpg",
+			content:   jpegWithEXIF[:bytes.Index(jpegWithEXIF, []byte{0xFF, markerSOS})+2],
+			wantTaken: &taken,
+		},
+		{
+			name:    "jpeg without exif up to the scan",
+			file:    "a.jpg",
+			content: jpegBase[:bytes.Index(jpegBase, []byte{0xFF, markerSOS})+2],
+		},
+		{
+			name:      "png up to the image data",
+			file:      "a.png",
+			content:   pngWithEXIF[:bytes.Index(pngWithEXIF, []byte("IDAT"))+4],
+			wantTaken: &taken,
+		},
+		{
+			name:    "png with a huge exif chunk",
+			file:    "a.png",
+			content: insertAt(pngBase[:afterIHDR], afterIHDR, hugeEXIF),
+		},
+		{
+			name:    "unsupported format",
+			file:    "a.gif",
+			content: jpegWithEXIF,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := TakenAt(io.MultiReader(bytes.NewReader(tt.content), failingReader{}), tt.file)
+			if (got == nil) != (tt.wantTaken == nil) || got != nil && !got.Equal(*tt.wantTaken) {
+				t.Errorf("TakenAt = %v, want %v", got, tt.wantTaken)
+			}
+		})
+	}
+}
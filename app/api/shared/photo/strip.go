@@ -0,0 +1,238 @@
+package photo
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/binary"
+	"errors"
+	"io"
+)
+
+var (
+	jpegEXIF     = []byte("Exif\x00\x00")
+	jpegICC      = []byte("ICC_PROFILE\x00")
+	pngSignature = []byte("\x89PNG\r\n\x1a\n")
+)
+
+// Маркеры JPEG
+const (
+	markerSOI  = 0xD8
+	markerEOI  = 0xD9
+	markerSOS  = 0xDA
+	markerRST0 = 0xD0
+	markerRST7 = 0xD7
+	markerTEM  = 0x01
+	// APP1 - EXIF и XMP, APP2 - цветовой профиль ICC, а также MPF и FlashPix,
+	// APP13 - IPTC
+	markerAPP1  = 0xE1
+	markerAPP2  = 0xE2
+	markerAPP13 = 0xED
+)
+
+// pngColorChunks - блоки PNG, описывающие цвет. Кодировщик PNG их не пишет,
+// reorient переносит их из исходного файла
+var pngColorChunks = map[string]bool{"iCCP": true, "sRGB": true, "gAMA": true, "cHRM": true, "cICP": true}
+
+// stripJPEG вырезает из JPEG блоки APP1, APP13 и APP2, кроме цветового
+// профиля, и всё после EOI основного изображения: там телефоны и камеры
+// хранят дополнительные кадры MPF со своим EXIF. Возвращает данные TIFF из
+// первого блока EXIF и блоки профиля ICC как есть, остальное копируется без
+// изменений
+func stripJPEG(content []byte) (stripped, exif, profile []byte, err error) {
+	if len(content) < 4 || content[0] != 0xFF || content[1] != markerSOI {
+		return nil, nil, nil, errors.New("not a JPEG file")
+	}
+
+	var out bytes.Buffer
+	out.Grow(len(content))
+	out.Write(content[:2])
+	pos := 2
+	for {
+		if pos == len(content) {
+			// Файл без EOI: сжатые данные закончились вместе с файлом
+			return out.Bytes(), exif, profile, nil
+		}
+		if pos+2 > len(content) || content[pos] != 0xFF {
+			return nil, nil, nil, errors.New("broken JPEG marker")
+		}
+		marker := content[pos+1]
+		switch {
+		case marker == 0xFF:
+			// Заполняющий байт перед маркером
+			pos++
+			continue
+		case marker == markerSOI, marker == markerTEM, marker >= markerRST0 && marker <= markerRST7:
+			out.Write(content[pos : pos+2])
+			pos += 2
+			continue
+		case marker == markerEOI:
+			out.Write(content[pos : pos+2])
+			return out.Bytes(), exif, profile, nil
+		}
+
+		if pos+4 > len(content) {
+			return nil, nil, nil, errors.New("truncated JPEG segment")
+		}
+		end := pos + 2 + int(binary.BigEndian.Uint16(content[pos+2:]))
+		if end < pos+4 || end > len(content) {
+			return nil, nil, nil, errors.New("truncated JPEG segment")
+		}
+		switch payload := content[pos+4 : end]; marker {
+		case markerAPP1:
+			if exif == nil && bytes.HasPrefix(payload, jpegEXIF) {
+				exif = payload[len(jpegEXIF):]
+			}
+		case markerAPP2:
+			if bytes.HasPrefix(payload, jpegICC) {
+				out.Write(content[pos:end])
+				profile = append(profile, content[pos:end]...)
+			}
+		case markerAPP13:
+		case markerSOS:
+			// За заголовком скана идут сжатые данные до следующего маркера
+			next := scanEnd(content, end)
+			out.Write(content[pos:next])
+			end = next
+		default:
+			out.Write(content[pos:end])
+		}
+		pos = end
+	}
+}
+
+// scanEnd - позиция первого маркера после сжатых данных скана, начиная с pos.
+// Внутри данных 0xFF экранируется нулём, маркеры RST разделяют интервалы
+func scanEnd(content []byte, pos int) int {
+	for pos+1 < len(content) {
+		if content[pos] != 0xFF {
+			pos++
+			continue
+		}
+		if next := content[pos+1]; next == 0x00 || next >= markerRST0 && next <= markerRST7 {
+			pos += 2
+			continue
+		}
+		return pos
+	}
+	return len(content)
+}
+
+// stripPNG вырезает из PNG блок eXIf и текстовые блоки (в них бывает XMP) и
+// возвращает данные TIFF из eXIf и блоки цвета как есть
+func stripPNG(content []byte) (stripped, exif, profile []byte, err error) {
+	if !bytes.HasPrefix(content, pngSignature) {
+		return nil, nil, nil, errors.New("not a PNG file")
+	}
+
+	var out bytes.Buffer
+	out.Grow(len(content))
+	out.Write(pngSignature)
+	pos := len(pngSignature)
+	for {
+		if pos+12 > len(content) {
+			return nil, nil, nil, errors.New("truncated PNG chunk")
+		}
+		length := uint64(binary.BigEndian.Uint32(content[pos:]))
+		if uint64(pos)+12+length > uint64(len(content)) {
+			return nil, nil, nil, errors.New("truncated PNG chunk")
+		}
+		end := pos + 12 + int(length)
+		switch kind := string(content[pos+4 : pos+8]); kind {
+		case "eXIf":
+			exif = content[pos+8 : end-4]
+		case "tEXt", "zTXt", "iTXt":
+		default:
+			out.Write(content[pos:end])
+			if pngColorChunks[kind] {
+				profile = append(profile, content[pos:end]...)
+			}
+			if kind == "IEND" {
+				return out.Bytes(), exif, profile, nil
+			}
+		}
+		pos = end
+	}
+}
+
+// readJPEGEXIF читает из r блоки JPEG до начала скана и возвращает данные
+// TIFF из первого блока EXIF. Остальные блоки пропускаются
+func readJPEGEXIF(r *bufio.Reader) ([]byte, error) {
+	var soi [2]byte
+	if _, err := io.ReadFull(r, soi[:]); err != nil || soi[0] != 0xFF || soi[1] != markerSOI {
+		return nil, errors.New("not a JPEG file")
+	}
+	for {
+		if b, err := r.ReadByte(); err != nil || b != 0xFF {
+			return nil, errors.New("broken JPEG marker")
+		}
+		marker, err := r.ReadByte()
+		// Заполняющие байты перед маркером
+		for err == nil && marker == 0xFF {
+			marker, err = r.ReadByte()
+		}
+		if err != nil {
+			return nil, errors.New("broken JPEG marker")
+		}
+		switch {
+		case marker == markerSOI, marker == markerTEM, marker >= markerRST0 && marker <= markerRST7:
+			continue
+		case marker == markerEOI, marker == markerSOS:
+			return nil, nil
+		}
+
+		var length [2]byte
+		if _, err := io.ReadFull(r, length[:]); err != nil {
+			return nil, errors.New("truncated JPEG segment")
+		}
+		size := int(binary.BigEndian.Uint16(length[:])) - 2
+		if size < 0 {
+			return nil, errors.New("truncated JPEG segment")
+		}
+		if marker == markerAPP1 {
+			payload := make([]byte, size)
+			if _, err := io.ReadFull(r, payload); err != nil {
+				return nil, errors.New("truncated JPEG segment")
+			}
+			if bytes.HasPrefix(payload, jpegEXIF) {
+				return payload[len(jpegEXIF):], nil
+			}
+			continue
+		}
+		if _, err := r.Discard(size); err != nil {
+			return nil, errors.New("truncated JPEG segment")
+		}
+	}
+}
+
+// readPNGEXIF читает из r блоки PNG до данных изображения и возвращает
+// данные TIFF из eXIf
+func readPNGEXIF(r *bufio.Reader) ([]byte, error) {
+	signature := make([]byte, len(pngSignature))
+	if _, err := io.ReadFull(r, signature); err != nil || !bytes.Equal(signature, pngSignature) {
+		return nil, errors.New("not a PNG file")
+	}
+	for {
+		var header [8]byte
+		if _, err := io.ReadFull(r, header[:]); err != nil {
+			return nil, errors.New("truncated PNG chunk")
+		}
+		length := int64(binary.BigEndian.Uint32(header[:]))
+		switch string(header[4:]) {
+		case "eXIf":
+			if length > maxMetadata {
+				return nil, errors.New("PNG eXIf chunk is too large")
+			}
+			data := make([]byte, length)
+			if _, err := io.ReadFull(r, data); err != nil {
+				return nil, errors.New("truncated PNG chunk")
+			}
+			return data, nil
+		case "IDAT", "IEND":
+			return nil, nil
+		}
+		// Данные блока и CRC
+		if _, err := io.CopyN(io.Discard, r, length+4); err != nil {
+			return nil, errors.New("truncated PNG chunk")
+		}
+	}
+}
@@ -0,0 +1,238 @@
+package photo
+
+import (
+	"bytes"
+	"encoding/binary"
+	"hash/crc32"
+	"image"
+	"image/color"
+	"image/jpeg"
+	"image/png"
+	"testing"
+)
+
+// testImage - w×h с разным цветом у каждого пикселя
+func testImage(w, h int) *image.RGBA {
+	img := image.NewRGBA(image.Rect(0, 0, w, h))
+	for y := 0; y < h; y++ {
+		for x := 0; x < w; x++ {
+			img.Set(x, y, color.RGBA{R: uint8(40 * x), G: uint8(80 * y), B: 200, A: 255})
+		}
+	}
+	return img
+}
+
+func encodeJPEG(t *testing.T, img image.Image) []byte {
+	t.Helper()
+	var buf bytes.Buffer
+	if err := jpeg.Encode(&buf, img, &jpeg.Options{Quality: 90}); err != nil {
+		t.Fatal(err)
+	}
+	return buf.Bytes()
+}
+
+func encodePNG(t *testing.T, img image.Image) []byte {
+	t.Helper()
+	var buf bytes.Buffer
+	if err := png.Encode(&buf, img); err != nil {
+		t.Fatal(err)
+	}
+	return buf.Bytes()
+}
+
+// segment - блок JPEG с маркером marker
+func segment(marker byte, payload []byte) []byte {
+	out := []byte{0xFF, marker, 0, 0}
+	binary.BigEndian.PutUint16(out[2:], uint16(len(payload)+2))
+	return append(out, payload...)
+}
+
+// chunk - блок PNG с верной CRC
+func chunk(kind string, data []byte) []byte {
+	out := binary.BigEndian.AppendUint32(nil, uint32(len(data)))
+	out = append(out, kind...)
+	out = append(out, data...)
+	return binary.BigEndian.AppendUint32(out, crc32.ChecksumIEEE(out[4:]))
+}
+
+// tiff - блок TIFF с Orientation и DateTime в IFD0
+func tiff(orientation uint16, dateTime string) []byte {
+	data := []byte("II*\x00")
+	data = binary.LittleEndian.AppendUint32(data, 8)
+	data = binary.LittleEndian.AppendUint16(data, 2)
+	// Orientation, SHORT, 1 значение
+	data = binary.LittleEndian.AppendUint16(data, tagOrientation)
+	data = binary.LittleEndian.AppendUint16(data, typeShort)
+	data = binary.LittleEndian.AppendUint32(data, 1)
+	data = binary.LittleEndian.AppendUint16(data, orientation)
+	data = binary.LittleEndian.AppendUint16(data, 0)
+	// DateTime, ASCII, строка после IFD
+	data = binary.LittleEndian.AppendUint16(data, tagDateTime)
+	data = binary.LittleEndian.AppendUint16(data, typeASCII)
+	data = binary.LittleEndian.AppendUint32(data, uint32(len(dateTime)+1))
+	data = binary.LittleEndian.AppendUint32(data, 8+2+2*12+4)
+	data = binary.LittleEndian.AppendUint32(data, 0)
+	data = append(data, dateTime...)
+	return append(data, 0)
+}
+
+// insertAt вставляет parts в content по смещению at
+func insertAt(content []byte, at int, parts ...[]byte) []byte {
+	out := append([]byte(nil), content[:at]...)
+	for _, part := range parts {
+		out = append(out, part...)
+	}
+	return append(out, content[at:]...)
+}
+
+func TestStripJPEG(t *testing.T) {
+	base := encodeJPEG(t, testImage(4, 3))
+	exifData := tiff(6, "2023:01:02 03:04:05")
+	exifSegment := segment(markerAPP1, append(append([]byte(nil), jpegEXIF...), exifData...))
+	xmpSegment := segment(markerAPP1, []byte("http://ns.adobe.com/xap/1.0/\x00<x:xmpmeta/>"))
+	iccSegment := segment(markerAPP2, append(append([]byte(nil), jpegICC...), "\x01\x01profile-data"...))
+	mpfSegment := segment(markerAPP2, []byte("MPF\x00II*\x00"))
+	iptcSegment := segment(markerAPP13, []byte("Photoshop 3.0\x00GPS"))
+	// Второй кадр MPF после EOI со своим EXIF
+	trailing := insertAt(base, 2, exifSegment)
+
+	tests := []struct {
+		name        string
+		content     []byte
+		want        []byte
+		wantEXIF    []byte
+		wantProfile []byte
+		wantErr     bool
+	}{
+		{
+			name:    "plain file is unchanged",
+			content: base,
+			want:    base,
+		},
+		{
+			name:     "exif and xmp are cut",
+			content:  insertAt(base, 2, exifSegment, xmpSegment),
+			want:     base,
+			wantEXIF: exifData,
+		},
+		{
+			name:        "icc profile is kept, mpf and iptc are cut",
+			content:     insertAt(base, 2, iccSegment, mpfSegment, iptcSegment),
+			want:        insertAt(base, 2, iccSegment),
+			wantProfile: iccSegment,
+		},
+		{
+			name:        "frames after eoi are dropped",
+			content:     append(insertAt(base, 2, exifSegment, iccSegment, mpfSegment), trailing...),
+			want:        insertAt(base, 2, iccSegment),
+			wantEXIF:    exifData,
+			wantProfile: iccSegment,
+		},
+		{
+			name:    "file without eoi",
+			content: base[:len(base)-2],
+			want:    base[:len(base)-2],
+		},
+		{
+			name:    "not a jpeg",
+			content: encodePNG(t, testImage(2, 2)),
+			wantErr: true,
+		},
+		{
+			name:    "truncated segment",
+			content: append(append([]byte(nil), base[:2]...), exifSegment[:10]...),
+			wantErr: true,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			stripped, exif, profile, err := stripJPEG(tt.content)
+			if tt.wantErr {
+				if err == nil {
+					t.Fatal("want error")
+				}
+				return
+			}
+			if err != nil {
+				t.Fatal(err)
+			}
+			if !bytes.Equal(stripped, tt.want) {
+				t.Errorf("stripped file differs: %d bytes, want %d", len(stripped), len(tt.want))
+			}
+			if !bytes.Equal(exif, tt.wantEXIF) {
+				t.Errorf("exif = %q, want %q", exif, tt.wantEXIF)
+			}
+			if !bytes.Equal(profile, tt.wantProfile) {
+				t.Errorf("profile = %q, want %q", profile, tt.wantProfile)
+			}
+		})
+	}
+}
+
+func TestStripPNG(t *testing.T) {
+	base := encodePNG(t, testImage(4, 3))
+	// Сигнатура и IHDR
+	afterIHDR := len(pngSignature) + 12 + 13
+	exifData := tiff(3, "2023:01:02 03:04:05")
+	exifChunk := chunk("eXIf", exifData)
+	textChunk := chunk("tEXt", []byte("Comment\x00GPS 55.7,37.6"))
+	iccChunk := chunk("iCCP", []byte("icc\x00\x00compressed"))
+	gamaChunk := chunk("gAMA", []byte{0, 0, 0xB1, 0x8F})
+
+	tests := []struct {
+		name        string
+		content     []byte
+		want        []byte
+		wantEXIF    []byte
+		wantProfile []byte
+		wantErr     bool
+	}{
+		{
+			name:    "plain file is unchanged",
+			content: base,
+			want:    base,
+		},
+		{
+			name:        "exif and text are cut, color chunks are kept",
+			content:     insertAt(base, afterIHDR, iccChunk, exifChunk, textChunk, gamaChunk),
+			want:        insertAt(base, afterIHDR, iccChunk, gamaChunk),
+			wantEXIF:    exifData,
+			wantProfile: append(append([]byte(nil), iccChunk...), gamaChunk...),
+		},
+		{
+			name:    "not a png",
+			content: encodeJPEG(t, testImage(2, 2)),
+			wantErr: true,
+		},
+		{
+			name:    "truncated chunk",
+			content: base[:len(base)-6],
+			wantErr: true,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			stripped, exif, profile, err := stripPNG(tt.content)
+			if tt.wantErr {
+				if err == nil {
+					t.Fatal("want error")
+				}
+				return
+			}
+			if err != nil {
+				t.Fatal(err)
+			}
+			if !bytes.Equal(stripped, tt.want) {
+				t.Errorf("stripped file differs: %d bytes, want %d", len(stripped), len(tt.want))
+			}
+			if !bytes.Equal(exif, tt.wantEXIF) {
+				t.Errorf("exif = %q, want %q", exif, tt.wantEXIF)
+			}
+			if !bytes.Equal(profile, tt.wantProfile) {
+				t.Errorf("profile = %q, want %q", profile, tt.wantProfile)
+			}
+		})
+	}
+}
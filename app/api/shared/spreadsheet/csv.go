@@ -0,0 +1,57 @@
+package spreadsheet
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/csv"
+	"errors"
+	"io"
+)
+
+var utf8BOM = []byte("\ufeff")
+
+// readCSV понимает разделители запятую, точку с запятой (так сохраняет
+// Excel с русской локалью) и табуляцию: разделителем считается тот, которого
+// больше всего в первой строке
+func readCSV(r io.Reader) ([]Row, error) {
+	buffered := bufio.NewReaderSize(r, 64<<10)
+	if head, err := buffered.Peek(len(utf8BOM)); err == nil && bytes.Equal(head, utf8BOM) {
+		_, _ = buffered.Discard(len(utf8BOM))
+	}
+
+	reader := csv.NewReader(buffered)
+	reader.Comma = detectDelimiter(buffered)
+	reader.FieldsPerRecord = -1
+	reader.LazyQuotes = true
+
+	var rows []Row
+	for {
+		cells, err := reader.Read()
+		if errors.Is(err, io.EOF) {
+			return rows, nil
+		}
+		if err != nil {
+			return nil, err
+		}
+		line, _ := reader.FieldPos(0)
+		if rows, err = appendRow(rows, line, cells); err != nil {
+			return nil, err
+		}
+	}
+}
+
+func detectDelimiter(r *bufio.Reader) rune {
+	// Peek не сдвигает чтение; первой строки длиннее 64 КБ в таблице не бывает
+	head, _ := r.Peek(64 << 10)
+	if i := bytes.IndexByte(head, '\n'); i >= 0 {
+		head = head[:i]
+	}
+
+	delimiter, best := ',', bytes.Count(head, []byte{','})
+	for _, candidate := range []rune{';', '\t'} {
+		if count := bytes.Count(head, []byte{byte(candidate)}); count > best {
+			delimiter, best = candidate, count
+		}
+	}
+	return delimiter
+}
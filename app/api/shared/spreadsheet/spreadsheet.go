@@ -0,0 +1,67 @@
+package spreadsheet
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"path/filepath"
+	"strings"
+)
+
+// MaxRows ограничивает размер файла: сжатый xlsx маленького размера может
+// развернуться в миллионы строк
+const MaxRows = 5000
+
+var ErrUnsupportedFormat = errors.New("unsupported file format, expected .csv or .xlsx")
+
+// Row - непустая строка таблицы. Number - номер строки в файле, как его
+// показывает табличный редактор (с 1, заголовок тоже считается)
+type Row struct {
+	Number int
+	Cells  []string
+}
+
+// Cell возвращает ячейку без пробелов по краям или пустую строку
+func (r Row) Cell(index int) string {
+	if index < 0 || index >= len(r.Cells) {
+		return ""
+	}
+	return strings.TrimSpace(r.Cells[index])
+}
+
+// Read читает первую страницу файла. Формат определяется по расширению name.
+// Пустые строки пропускаются, номера остальных сохраняются
+func Read(file io.ReaderAt, size int64, name string) ([]Row, error) {
+	var rows []Row
+	var err error
+	switch strings.ToLower(filepath.Ext(name)) {
+	case ".csv":
+		rows, err = readCSV(io.NewSectionReader(file, 0, size))
+	case ".xlsx":
+		rows, err = readXLSX(file, size)
+	default:
+		return nil, ErrUnsupportedFormat
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to read %s: %w", name, err)
+	}
+	return rows, nil
+}
+
+// appendRow добавляет строку, если в ней есть хоть одно значение
+func appendRow(rows []Row, number int, cells []string) ([]Row, error) {
+	empty := true
+	for _, cell := range cells {
+		if strings.TrimSpace(cell) != "" {
+			empty = false
+			break
+		}
+	}
+	if empty {
+		return rows, nil
+	}
+	if len(rows) >= MaxRows {
+		return nil, fmt.Errorf("too many rows, at most %d are allowed", MaxRows)
+	}
+	return append(rows, Row{Number: number, Cells: cells}), nil
+}
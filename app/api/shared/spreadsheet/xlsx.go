@@ -0,0 +1,400 @@
+package spreadsheet
+
+import (
+	"archive/zip"
+	"encoding/xml"
+	"errors"
+	"fmt"
+	"io"
+	"math"
+	"path"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// maxColumn - последний столбец Excel (XFD)
+const maxColumn = 16384
+
+// builtinDateFormats - встроенные форматы ячеек (numFmtId) с датой или временем
+var builtinDateFormats = map[int]bool{
+	14: true, 15: true, 16: true, 17: true, 18: true, 19: true, 20: true, 21: true, 22: true,
+	27: true, 28: true, 29: true, 30: true, 31: true, 32: true, 33: true, 34: true, 35: true, 36: true,
+	45: true, 46: true, 47: true,
+	50: true, 51: true, 52: true, 53: true, 54: true, 55: true, 56: true, 57: true, 58: true,
+}
+
+// workbook - значения читаемой страницы: общая таблица строк, стили ячеек с
+// датами и система дат книги
+type workbook struct {
+	sharedStrings []string
+	dateStyles    map[int]bool
+	date1904      bool
+}
+
+func readXLSX(file io.ReaderAt, size int64) ([]Row, error) {
+	archive, err := zip.NewReader(file, size)
+	if err != nil {
+		return nil, fmt.Errorf("not an xlsx file: %w", err)
+	}
+	parts := make(map[string]*zip.File, len(archive.File))
+	for _, part := range archive.File {
+		parts[part.Name] = part
+	}
+
+	var book workbook
+	sheetPath, err := book.readWorkbook(parts)
+	if err != nil {
+		return nil, err
+	}
+	if err := book.readSharedStrings(parts["xl/sharedStrings.xml"]); err != nil {
+		return nil, err
+	}
+	if err := book.readStyles(parts["xl/styles.xml"]); err != nil {
+		return nil, err
+	}
+
+	sheet, ok := parts[sheetPath]
+	if !ok {
+		return nil, fmt.Errorf("sheet %s is missing", sheetPath)
+	}
+	return book.readSheet(sheet)
+}
+
+// readWorkbook находит путь к первой странице книги
+func (b *workbook) readWorkbook(parts map[string]*zip.File) (string, error) {
+	var book struct {
+		Properties struct {
+			Date1904 string `xml:"date1904,attr"`
+		} `xml:"workbookPr"`
+		Sheets []struct {
+			RelationID string `xml:"http://schemas.openxmlformats.org/officeDocument/2006/relationships id,attr"`
+		} `xml:"sheets>sheet"`
+	}
+	if err := decodePart(parts["xl/workbook.xml"], &book); err != nil {
+		return "", err
+	}
+	if len(book.Sheets) == 0 {
+		return "", errors.New("workbook has no sheets")
+	}
+	b.date1904 = book.Properties.Date1904 == "1" || book.Properties.Date1904 == "true"
+
+	var relationships struct {
+		Items []struct {
+			ID     string `xml:"Id,attr"`
+			Target string `xml:"Target,attr"`
+		} `xml:"Relationship"`
+	}
+	if err := decodePart(parts["xl/_rels/workbook.xml.rels"], &relationships); err != nil {
+		return "", err
+	}
+	for _, relationship := range relationships.Items {
+		if relationship.ID != book.Sheets[0].RelationID {
+			continue
+		}
+		if strings.HasPrefix(relationship.Target, "/") {
+			return strings.TrimPrefix(relationship.Target, "/"), nil
+		}
+		return path.Join("xl", relationship.Target), nil
+	}
+	return "", errors.New("first sheet is not found in workbook relationships")
+}
+
+func (b *workbook) readSharedStrings(part *zip.File) error {
+	if part == nil {
+		return nil
+	}
+	reader, err := part.Open()
+	if err != nil {
+		return err
+	}
+	defer reader.Close()
+
+	decoder := xml.NewDecoder(reader)
+	for {
+		token, err := decoder.Token()
+		if errors.Is(err, io.EOF) {
+			return nil
+		}
+		if err != nil {
+			return fmt.Errorf("invalid shared strings: %w", err)
+		}
+		if start, ok := token.(xml.StartElement); ok && start.Name.Local == "si" {
+			text, err := readText(decoder, start.Name.Local)
+			if err != nil {
+				return fmt.Errorf("invalid shared strings: %w", err)
+			}
+			b.sharedStrings = append(b.sharedStrings, text)
+		}
+	}
+}
+
+// readStyles запоминает индексы стилей ячеек, которые показывают дату:
+// такие ячейки хранят число дней, а не текст
+func (b *workbook) readStyles(part *zip.File) error {
+	b.dateStyles = map[int]bool{}
+	if part == nil {
+		return nil
+	}
+	var styles struct {
+		NumFmts []struct {
+			ID   int    `xml:"numFmtId,attr"`
+			Code string `xml:"formatCode,attr"`
+		} `xml:"numFmts>numFmt"`
+		CellXfs []struct {
+			NumFmtID int `xml:"numFmtId,attr"`
+		} `xml:"cellXfs>xf"`
+	}
+	if err := decodePart(part, &styles); err != nil {
+		return err
+	}
+
+	dateFormats := map[int]bool{}
+	for id := range builtinDateFormats {
+		dateFormats[id] = true
+	}
+	for _, format := range styles.NumFmts {
+		dateFormats[format.ID] = isDateFormat(format.Code)
+	}
+	for i, xf := range styles.CellXfs {
+		if dateFormats[xf.NumFmtID] {
+			b.dateStyles[i] = true
+		}
+	}
+	return nil
+}
+
+func (b *workbook) readSheet(part *zip.File) ([]Row, error) {
+	reader, err := part.Open()
+	if err != nil {
+		return nil, err
+	}
+	defer reader.Close()
+
+	var rows []Row
+	var cells []string
+	number := 0
+	decoder := xml.NewDecoder(reader)
+	for {
+		token, err := decoder.Token()
+		if errors.Is(err, io.EOF) {
+			return rows, nil
+		}
+		if err != nil {
+			return nil, fmt.Errorf("invalid sheet: %w", err)
+		}
+
+		switch element := token.(type) {
+		case xml.StartElement:
+			switch element.Name.Local {
+			case "row":
+				number++
+				if r, err := strconv.Atoi(attr(element, "r")); err == nil {
+					number = r
+				}
+				cells = cells[:0]
+			case "c":
+				column := len(cells)
+				if ref := attr(element, "r"); ref != "" {
+					if column, err = columnIndex(ref); err != nil {
+						return nil, err
+					}
+				}
+				value, err := b.readCell(decoder, element)
+				if err != nil {
+					return nil, err
+				}
+				for len(cells) <= column {
+					cells = append(cells, "")
+				}
+				cells[column] = value
+			}
+		case xml.EndElement:
+			if element.Name.Local == "row" {
+				if rows, err = appendRow(rows, number, append([]string(nil), cells...)); err != nil {
+					return nil, err
+				}
+			}
+		}
+	}
+}
+
+// readCell читает ячейку c до закрывающего тега и возвращает её значение текстом
+func (b *workbook) readCell(decoder *xml.Decoder, cell xml.StartElement) (string, error) {
+	var value, inline string
+	for {
+		token, err := decoder.Token()
+		if err != nil {
+			return "", fmt.Errorf("invalid sheet: %w", err)
+		}
+		switch element := token.(type) {
+		case xml.StartElement:
+			var err error
+			switch element.Name.Local {
+			case "v":
+				value, err = readText(decoder, "v")
+			case "is":
+				inline, err = readText(decoder, "is")
+			default:
+				err = decoder.Skip()
+			}
+			if err != nil {
+				return "", fmt.Errorf("invalid sheet: %w", err)
+			}
+		case xml.EndElement:
+			return b.cellValue(cell, value, inline)
+		}
+	}
+}
+
+func (b *workbook) cellValue(cell xml.StartElement, value, inline string) (string, error) {
+	switch attr(cell, "t") {
+	case "s":
+		index, err := strconv.Atoi(value)
+		if err != nil || index < 0 || index >= len(b.sharedStrings) {
+			return "", fmt.Errorf("cell %s refers to unknown shared string %q", attr(cell, "r"), value)
+		}
+		return b.sharedStrings[index], nil
+	case "inlineStr":
+		return inline, nil
+	case "b":
+		if value == "1" {
+			return "TRUE", nil
+		}
+		return "FALSE", nil
+	case "", "n":
+		style, err := strconv.Atoi(attr(cell, "s"))
+		if err != nil || !b.dateStyles[style] || value == "" {
+			return value, nil
+		}
+		serial, err := strconv.ParseFloat(value, 64)
+		if err != nil {
+			return value, nil
+		}
+		return b.formatDate(serial), nil
+	default:
+		// str (результат формулы), d (дата ISO 8601), e (ошибка)
+		return value, nil
+	}
+}
+
+// formatDate переводит дату Excel (дни от начала эпохи книги) в формат,
+// который понимает shared.ParseDate
+func (b *workbook) formatDate(serial float64) string {
+	epoch := time.Date(1899, time.December, 30, 0, 0, 0, 0, time.UTC)
+	if b.date1904 {
+		epoch = time.Date(1904, time.January, 1, 0, 0, 0, 0, time.UTC)
+	}
+	days := math.Floor(serial)
+	seconds := math.Round((serial - days) * 24 * 60 * 60)
+	date := epoch.AddDate(0, 0, int(days)).Add(time.Duration(seconds) * time.Second)
+	if seconds == 0 {
+		return date.Format("2006-01-02")
+	}
+	return date.Format("2006-01-02 15:04:05")
+}
+
+// isDateFormat - формат ячейки выводит дату или время, если в нём вне кавычек,
+// квадратных скобок и экранирования встречаются d, m, y, h или s
+func isDateFormat(code string) bool {
+	var plain strings.Builder
+	quoted, bracketed, escaped := false, false, false
+	for _, r := range strings.ToLower(code) {
+		switch {
+		case escaped:
+			escaped = false
+		case quoted:
+			quoted = r != '"'
+		case bracketed:
+			bracketed = r != ']'
+		case r == '\\':
+			escaped = true
+		case r == '"':
+			quoted = true
+		case r == '[':
+			bracketed = true
+		default:
+			plain.WriteRune(r)
+		}
+	}
+	format := plain.String()
+	return !strings.Contains(format, "general") && strings.ContainsAny(format, "dmyhs")
+}
+
+// readText собирает текст элемента name до его закрывающего тега: содержимое
+// v или всех t внутри. Текст форматированных фрагментов (r) склеивается,
+// фонетические подсказки (rPh) пропускаются
+func readText(decoder *xml.Decoder, name string) (string, error) {
+	var text strings.Builder
+	inText := name == "v"
+	for {
+		token, err := decoder.Token()
+		if err != nil {
+			return "", err
+		}
+		switch element := token.(type) {
+		case xml.StartElement:
+			switch element.Name.Local {
+			case "t":
+				inText = true
+			case "rPh":
+				if err := decoder.Skip(); err != nil {
+					return "", err
+				}
+			}
+		case xml.CharData:
+			if inText {
+				text.Write(element)
+			}
+		case xml.EndElement:
+			if element.Name.Local == name {
+				return text.String(), nil
+			}
+			if element.Name.Local == "t" {
+				inText = false
+			}
+		}
+	}
+}
+
+// columnIndex - номер столбца по ссылке на ячейку: A1 -> 0, AB7 -> 27
+func columnIndex(ref string) (int, error) {
+	index := 0
+	for _, r := range ref {
+		if r < 'A' || r > 'Z' {
+			break
+		}
+		index = index*26 + int(r-'A'+1)
+		if index > maxColumn {
+			return 0, fmt.Errorf("invalid cell reference %q", ref)
+		}
+	}
+	if index == 0 {
+		return 0, fmt.Errorf("invalid cell reference %q", ref)
+	}
+	return index - 1, nil
+}
+
+func attr(element xml.StartElement, name string) string {
+	for _, a := range element.Attr {
+		if a.Name.Local == name {
+			return a.Value
+		}
+	}
+	return ""
+}
+
+func decodePart(part *zip.File, into any) error {
+	if part == nil {
+		return errors.New("workbook part is missing")
+	}
+	reader, err := part.Open()
+	if err != nil {
+		return err
+	}
+	defer reader.Close()
+	if err := xml.NewDecoder(reader).Decode(into); err != nil {
+		return fmt.Errorf("invalid %s: %w", part.Name, err)
+	}
+	return nil
+}
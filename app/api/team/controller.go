@@ -2,6 +2,9 @@ package team
 
 import (
 	files "federation-backend/app/api/file"
+	"federation-backend/app/api/shared/export"
+	"federation-backend/app/db/models"
+	"log/slog"
 	"net/http"
 	"strconv"
 
@@ -11,6 +14,7 @@ import (
 
 type Controller struct {
 	service *Service
+	logger  *slog.Logger
 }
 
 func (c *Controller) Create(ctx *gin.Context) {
@@ -20,7 +24,7 @@ func (c *Controller) Create(ctx *gin.Context) {
 		return
 	}
 
-	if err := c.service.Create(&dto); err != nil {
+	if err := c.service.Create(ctx.Request.Context(), &dto); err != nil {
 		ctx.JSON(http.StatusInternalServerError, gin.H{"error": err.Error(), "type": "create error"})
 		return
 	}
@@ -35,7 +39,7 @@ func (c *Controller) Get(ctx *gin.Context) {
 		return
 	}
 
-	team, err := c.service.Get(uint(id))
+	team, err := c.service.Get(ctx.Request.Context(), uint(id))
 	if err != nil {
 		ctx.JSON(http.StatusNotFound, gin.H{"error": err.Error()})
 		return
@@ -57,7 +61,7 @@ func (c *Controller) Update(ctx *gin.Context) {
 		return
 	}
 
-	if err := c.service.Update(uint(id), &dto); err != nil {
+	if err := c.service.Update(ctx.Request.Context(), uint(id), &dto); err != nil {
 		ctx.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
 		return
 	}
@@ -72,7 +76,7 @@ func (c *Controller) Delete(ctx *gin.Context) {
 		return
 	}
 
-	if err := c.service.Delete(uint(id)); err != nil {
+	if err := c.service.Delete(ctx.Request.Context(), uint(id)); err != nil {
 		ctx.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
 		return
 	}
@@ -80,8 +84,15 @@ func (c *Controller) Delete(ctx *gin.Context) {
 	ctx.JSON(http.StatusOK, gin.H{"status": "success"})
 }
 
+// GetAll - список команд с фильтрами team_name и sex
 func (c *Controller) GetAll(ctx *gin.Context) {
-	teams, err := c.service.GetAll()
+	query, err := ParseListQuery(ctx.Request.URL.Query())
+	if err != nil {
+		ctx.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	teams, err := c.service.GetAll(ctx.Request.Context(), query)
 	if err != nil {
 		ctx.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
 		return
@@ -90,8 +101,47 @@ func (c *Controller) GetAll(ctx *gin.Context) {
 	ctx.JSON(http.StatusOK, teams)
 }
 
-func NewController(db *gorm.DB, fs *files.Service) *Controller {
+func (c *Controller) RegisterExtraRoutes(router *gin.RouterGroup) {
+	router.GET("/export", c.Export)
+}
+
+// exportHeader - столбцы выгрузки команд
+var exportHeader = []string{"id", "team_name", "sex", "created_at"}
+
+// Export выгружает команды в csv или xlsx (format) с фильтрами GetAll
+func (c *Controller) Export(ctx *gin.Context) {
+	format, err := export.ParseFormat(ctx.Query("format"))
+	if err != nil {
+		ctx.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	query, err := ParseListQuery(ctx.Request.URL.Query())
+	if err != nil {
+		ctx.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	export.Stream(ctx, c.logger, format, "teams", exportHeader, func(write func(row []string) error) error {
+		return c.service.Export(ctx.Request.Context(), query, func(batch []models.Team) error {
+			for _, team := range batch {
+				err := write([]string{
+					strconv.FormatUint(uint64(team.Id), 10),
+					team.TeamName,
+					string(team.Sex),
+					export.Time(team.CreatedAt),
+				})
+				if err != nil {
+					return err
+				}
+			}
+			return nil
+		})
+	})
+}
+
+func NewController(db *gorm.DB, fs *files.Service, logger *slog.Logger) *Controller {
 	return &Controller{
-		service: NewService(db, fs),
+		service: NewService(db, fs, logger),
+		logger:  logger,
 	}
 }
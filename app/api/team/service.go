@@ -1,12 +1,18 @@
 package team
 
 import (
+	"context"
 	"errors"
 	files "federation-backend/app/api/file"
+	"federation-backend/app/api/shared/export"
+	database "federation-backend/app/db"
 	"federation-backend/app/db/models"
 	"federation-backend/app/db/models/enums"
+	"federation-backend/app/logging"
 	"fmt"
+	"log/slog"
 	"mime/multipart"
+	"net/url"
 	"path/filepath"
 
 	"gorm.io/gorm"
@@ -25,19 +31,21 @@ type UpdateTeamDTO struct {
 }
 
 type Service struct {
-	db *gorm.DB
-	fs *files.Service
+	db     *gorm.DB
+	fs     *files.Service
+	logger *slog.Logger
 }
 
-func (s *Service) Create(dto interface{}) error {
+func (s *Service) Create(ctx context.Context, dto interface{}) error {
 	createDTO, ok := dto.(*CreateTeamDTO)
 	if !ok {
 		return errors.New("invalid DTO type")
 	}
 
-	return s.db.Transaction(func(tx *gorm.DB) error {
+	return s.db.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		ctx := database.WithTx(ctx, tx)
 		// Save the logo file
-		logo, err := s.fs.SaveFile(createDTO.TeamLogo)
+		logo, err := s.fs.SaveFile(ctx, createDTO.TeamLogo)
 		if err != nil {
 			return fmt.Errorf("failed to save team logo: %w", err)
 		}
@@ -45,13 +53,13 @@ func (s *Service) Create(dto interface{}) error {
 		team := models.Team{
 			TeamName:   createDTO.TeamName,
 			Sex:        createDTO.Sex,
-			TeamLogoID: logo.Id, // Assuming ID field, not Id
+			TeamLogoID: &logo.Id,
 		}
 
 		if err := tx.Create(&team).Error; err != nil {
 			// Clean up the saved file if team creation fails
-			if deleteErr := s.fs.DeleteFile(filepath.Base(logo.Path)); deleteErr != nil {
-				fmt.Printf("Warning: failed to clean up logo file after team creation failure: %v\n", deleteErr)
+			if deleteErr := s.fs.DeleteFile(ctx, filepath.Base(logo.Path)); deleteErr != nil {
+				s.logger.WarnContext(ctx, "failed to clean up logo file after team creation failure", logging.Entity("team", team.Id), logging.Error(deleteErr))
 			}
 			return fmt.Errorf("failed to create team: %w", err)
 		}
@@ -60,9 +68,9 @@ func (s *Service) Create(dto interface{}) error {
 	})
 }
 
-func (s *Service) Get(id uint) (models.Team, error) {
+func (s *Service) Get(ctx context.Context, id uint) (models.Team, error) {
 	var team models.Team
-	err := s.db.Preload("TeamLogo").First(&team, id).Error
+	err := s.db.WithContext(ctx).Preload("TeamLogo").First(&team, id).Error
 	if err != nil {
 		if errors.Is(err, gorm.ErrRecordNotFound) {
 			return models.Team{}, errors.New("team not found")
@@ -72,13 +80,14 @@ func (s *Service) Get(id uint) (models.Team, error) {
 	return team, nil
 }
 
-func (s *Service) Update(id uint, dto interface{}) error {
+func (s *Service) Update(ctx context.Context, id uint, dto interface{}) error {
 	updateDTO, ok := dto.(*UpdateTeamDTO)
 	if !ok {
 		return errors.New("invalid DTO type")
 	}
 
-	return s.db.Transaction(func(tx *gorm.DB) error {
+	return s.db.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		ctx := database.WithTx(ctx, tx)
 		var team models.Team
 		if err := tx.Preload("TeamLogo").First(&team, id).Error; err != nil {
 			return fmt.Errorf("team not found: %w", err)
@@ -95,7 +104,7 @@ func (s *Service) Update(id uint, dto interface{}) error {
 		// Handle logo update if provided
 		if updateDTO.TeamLogo != nil {
 			// Save new logo first
-			newLogo, err := s.fs.SaveFile(updateDTO.TeamLogo)
+			newLogo, err := s.fs.SaveFile(ctx, updateDTO.TeamLogo)
 			if err != nil {
 				return fmt.Errorf("failed to save new team logo: %w", err)
 			}
@@ -103,30 +112,32 @@ func (s *Service) Update(id uint, dto interface{}) error {
 			// Store the old logo ID for cleanup
 			oldLogoID := team.TeamLogoID
 
-			// Update team with new logo
-			team.TeamLogoID = newLogo.Id
+			// Update team with new logo. Preloaded TeamLogo is replaced too,
+			// otherwise Save would restore the old foreign key from it
+			team.TeamLogoID = &newLogo.Id
+			team.TeamLogo = newLogo
 
 			// Save the team
 			if err := tx.Save(&team).Error; err != nil {
 				// Clean up the new logo if team update fails
-				if deleteErr := s.fs.DeleteFile(filepath.Base(newLogo.Path)); deleteErr != nil {
-					fmt.Printf("Warning: failed to clean up new logo file after update failure: %v\n", deleteErr)
+				if deleteErr := s.fs.DeleteFile(ctx, filepath.Base(newLogo.Path)); deleteErr != nil {
+					s.logger.WarnContext(ctx, "failed to clean up new logo file after update failure", logging.Entity("team", team.Id), logging.Error(deleteErr))
 				}
 				return fmt.Errorf("failed to update team: %w", err)
 			}
 
 			// Delete old logo if it exists
-			if oldLogoID != 0 {
+			if oldLogoID != nil {
 				var oldLogo models.File
-				if err := tx.First(&oldLogo, oldLogoID).Error; err == nil {
+				if err := tx.First(&oldLogo, *oldLogoID).Error; err == nil {
 					// Extract filename from path for deletion
 					filename := filepath.Base(oldLogo.Path)
-					if deleteErr := s.fs.DeleteFile(filename); deleteErr != nil {
-						fmt.Printf("Warning: failed to delete old logo file: %v\n", deleteErr)
+					if deleteErr := s.fs.DeleteFile(ctx, filename); deleteErr != nil {
+						s.logger.WarnContext(ctx, "failed to delete old logo file", logging.Entity("team", team.Id), logging.Error(deleteErr))
 					}
 					// Delete the old file record
 					if deleteErr := tx.Delete(&oldLogo).Error; deleteErr != nil {
-						fmt.Printf("Warning: failed to delete old logo record: %v\n", deleteErr)
+						s.logger.WarnContext(ctx, "failed to delete old logo record", logging.Entity("team", team.Id), logging.Error(deleteErr))
 					}
 				}
 			}
@@ -141,49 +152,89 @@ func (s *Service) Update(id uint, dto interface{}) error {
 	})
 }
 
-func (s *Service) Delete(id uint) error {
-	return s.db.Transaction(func(tx *gorm.DB) error {
+func (s *Service) Delete(ctx context.Context, id uint) error {
+	return s.db.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		ctx := database.WithTx(ctx, tx)
 		var team models.Team
 		if err := tx.Preload("TeamLogo").First(&team, id).Error; err != nil {
 			return fmt.Errorf("team not found: %w", err)
 		}
 
+		// Delete the team first: the logo record is referenced by team_logo_id
+		if err := tx.Delete(&team).Error; err != nil {
+			return fmt.Errorf("failed to delete team: %w", err)
+		}
+
 		// Delete the team logo if it exists
-		if team.TeamLogoID != 0 {
+		if team.TeamLogoID != nil {
 			var logo models.File
-			if err := tx.First(&logo, team.TeamLogoID).Error; err == nil {
+			if err := tx.First(&logo, *team.TeamLogoID).Error; err == nil {
 				// Extract filename from path for deletion
 				filename := filepath.Base(logo.Path)
-				if deleteErr := s.fs.DeleteFile(filename); deleteErr != nil {
-					fmt.Printf("Warning: failed to delete team logo file: %v\n", deleteErr)
+				if deleteErr := s.fs.DeleteFile(ctx, filename); deleteErr != nil {
+					s.logger.WarnContext(ctx, "failed to delete team logo file", logging.Entity("team", team.Id), logging.Error(deleteErr))
 				}
 				// Delete the file record
 				if deleteErr := tx.Delete(&logo).Error; deleteErr != nil {
-					fmt.Printf("Warning: failed to delete logo record: %v\n", deleteErr)
+					s.logger.WarnContext(ctx, "failed to delete logo record", logging.Entity("team", team.Id), logging.Error(deleteErr))
 				}
 			}
 		}
 
-		// Delete the team
-		if err := tx.Delete(&team).Error; err != nil {
-			return fmt.Errorf("failed to delete team: %w", err)
-		}
-
 		return nil
 	})
 }
 
-func (s *Service) GetAll() ([]models.Team, error) {
+// ListQuery - фильтры списка команд
+type ListQuery struct {
+	TeamName string
+	Sex      enums.Sex
+}
+
+// ParseListQuery читает фильтры team_name (точное совпадение) и sex
+func ParseListQuery(values url.Values) (ListQuery, error) {
+	query := ListQuery{
+		TeamName: values.Get("team_name"),
+		Sex:      enums.Sex(values.Get("sex")),
+	}
+	if query.Sex != "" && !query.Sex.Valid() {
+		return ListQuery{}, fmt.Errorf("unknown sex %q", query.Sex)
+	}
+	return query, nil
+}
+
+// filtered - команды под фильтрами query по алфавиту
+func (s *Service) filtered(ctx context.Context, query ListQuery) *gorm.DB {
+	db := s.db.WithContext(ctx).Model(&models.Team{})
+	if query.TeamName != "" {
+		db = db.Where("team_name = ?", query.TeamName)
+	}
+	if query.Sex != "" {
+		db = db.Where("sex = ?", query.Sex)
+	}
+	return db.Order("team_name").Order("id")
+}
+
+func (s *Service) GetAll(ctx context.Context, query ListQuery) ([]models.Team, error) {
 	var teams []models.Team
-	if err := s.db.Preload("TeamLogo").Find(&teams).Error; err != nil {
+	if err := s.filtered(ctx, query).Preload("TeamLogo").Find(&teams).Error; err != nil {
 		return nil, fmt.Errorf("failed to get teams: %w", err)
 	}
 	return teams, nil
 }
 
-func NewService(db *gorm.DB, fs *files.Service) *Service {
+// Export передаёт в fn команды под фильтрами query порциями по export.BatchSize
+func (s *Service) Export(ctx context.Context, query ListQuery, fn func(batch []models.Team) error) error {
+	if err := export.Batches(s.filtered(ctx, query), export.BatchSize, fn); err != nil {
+		return fmt.Errorf("failed to export teams: %w", err)
+	}
+	return nil
+}
+
+func NewService(db *gorm.DB, fs *files.Service, logger *slog.Logger) *Service {
 	return &Service{
-		db: db,
-		fs: fs,
+		db:     db,
+		fs:     fs,
+		logger: logger,
 	}
 }
@@ -2,83 +2,512 @@ package config
 
 import (
 	"fmt"
-	"os"
+	"net/url"
+	"strconv"
+	"time"
+)
+
+const (
+	DriverMySQL    = "mysql"
+	DriverPostgres = "postgres"
+	DriverSQLite   = "sqlite"
+)
+
+const (
+	EnvDevelopment = "development"
+	EnvProduction  = "production"
 )
 
 type DBConfig struct {
-	Driver string
-	Host   string
-	Port   string
-	User   string
-	Pass   string
-	Name   string
+	Driver string `yaml:"driver"`
+	Host   string `yaml:"host"`
+	Port   string `yaml:"port"`
+	User   string `yaml:"user"`
+	Pass   string `yaml:"pass" secret:"true"`
+	// Name - имя базы, для sqlite - путь к файлу базы (или ":memory:")
+	Name    string `yaml:"name"`
+	SSLMode string `yaml:"ssl_mode"`
+
+	MaxOpenConns    int           `yaml:"max_open_conns"`
+	MaxIdleConns    int           `yaml:"max_idle_conns"`
+	ConnMaxLifetime time.Duration `yaml:"conn_max_lifetime"`
+	ConnMaxIdleTime time.Duration `yaml:"conn_max_idle_time"`
+
+	ConnectTimeout time.Duration `yaml:"connect_timeout"`
+	ReadTimeout    time.Duration `yaml:"read_timeout"`
+	WriteTimeout   time.Duration `yaml:"write_timeout"`
+}
+
+type TLSConfig struct {
+	CertFile string `yaml:"cert_file"`
+	KeyFile  string `yaml:"key_file"`
 }
 
 type ServerConfig struct {
-	Port string
-	Host string
+	Port string    `yaml:"port"`
+	Host string    `yaml:"host"`
+	TLS  TLSConfig `yaml:"tls"`
+
+	ReadHeaderTimeout time.Duration `yaml:"read_header_timeout"`
+	// ReadTimeout и WriteTimeout покрывают загрузку всего тела запроса
+	// и отдачу ответа, поэтому должны учитывать большие файлы
+	ReadTimeout  time.Duration `yaml:"read_timeout"`
+	WriteTimeout time.Duration `yaml:"write_timeout"`
+	IdleTimeout  time.Duration `yaml:"idle_timeout"`
+	// ShutdownTimeout - сколько ждать завершения активных запросов при остановке
+	ShutdownTimeout time.Duration `yaml:"shutdown_timeout"`
+	// TrustedProxies - адреса и подсети прокси, которым можно верить в X-Forwarded-For.
+	// Пустой список - IP клиента берётся из соединения
+	TrustedProxies []string `yaml:"trusted_proxies"`
 }
 
 type AppConfig struct {
-	FileStoragePath string
+	// Env - окружение (development или production), от него зависят умолчания других подсистем
+	Env             string `yaml:"env"`
+	FileStoragePath string `yaml:"file_storage_path"`
+}
+
+type CORSConfig struct {
+	// AllowedOrigins - явный список разрешённых origin. Если пуст, используется
+	// список по умолчанию для окружения (см. CORSConfig.Origins)
+	AllowedOrigins []string `yaml:"allowed_origins"`
+	// AllowCredentials разрешает cookies и Authorization в кросс-доменных запросах.
+	// Несовместим с "*" в AllowedOrigins
+	AllowCredentials bool          `yaml:"allow_credentials"`
+	MaxAge           time.Duration `yaml:"max_age"`
+}
+
+type UploadConfig struct {
+	// MaxFileSizeMB ограничивает размер одного загружаемого файла
+	MaxFileSizeMB int64 `yaml:"max_file_size_mb"`
+	// MaxRequestSizeMB ограничивает размер тела запроса целиком
+	MaxRequestSizeMB int64 `yaml:"max_request_size_mb"`
+	// MultipartMemoryMB - сколько multipart-данных держать в памяти, остальное пишется во временные файлы
+	MultipartMemoryMB int64 `yaml:"multipart_memory_mb"`
+	// MaxImageMegapixels ограничивает размер фотографии в пикселях: повёрнутая
+	// по EXIF фотография распаковывается в память целиком
+	MaxImageMegapixels int64 `yaml:"max_image_megapixels"`
+}
+
+// DocumentsConfig - разбор загружаемых документов
+type DocumentsConfig struct {
+	// PreviewCommand - pdftoppm из poppler-utils, рисует превью первой страницы PDF.
+	// Пусто - превью PDF не создаются
+	PreviewCommand string `yaml:"preview_command"`
+	// PreviewWidth - ширина превью в пикселях
+	PreviewWidth   int           `yaml:"preview_width"`
+	PreviewTimeout time.Duration `yaml:"preview_timeout"`
+}
+
+// GalleryConfig - выгрузка альбомов ZIP-архивом
+type GalleryConfig struct {
+	// ArchiveCacheMB - архив альбома, файлы которого вместе занимают не
+	// меньше стольких мегабайт, сохраняется и отдаётся повторно. 0 - архивы
+	// всегда собираются на лету
+	ArchiveCacheMB int64 `yaml:"archive_cache_mb"`
+	// ArchiveCacheDir - каталог сохранённых архивов, по умолчанию .archives
+	// в каталоге файлов
+	ArchiveCacheDir string `yaml:"archive_cache_dir"`
+}
+
+type LogConfig struct {
+	Level  string `yaml:"level"`
+	Format string `yaml:"format"`
+}
+
+type MetricsConfig struct {
+	Enabled bool   `yaml:"enabled"`
+	Path    string `yaml:"path"`
+	// StorageScanInterval - как часто пересчитывать занятое файлами место
+	StorageScanInterval time.Duration `yaml:"storage_scan_interval"`
+}
+
+const (
+	TracingExporterOTLP   = "otlp"
+	TracingExporterStdout = "stdout"
+)
+
+type TracingConfig struct {
+	Enabled bool `yaml:"enabled"`
+	// Exporter - otlp (OTLP/HTTP, например в локальный collector) или stdout
+	Exporter string `yaml:"exporter"`
+	// Endpoint - host:port OTLP/HTTP приёмника. Пустой - берётся из
+	// OTEL_EXPORTER_OTLP_ENDPOINT или localhost:4318
+	Endpoint    string  `yaml:"endpoint"`
+	Insecure    bool    `yaml:"insecure"`
+	ServiceName string  `yaml:"service_name"`
+	SampleRatio float64 `yaml:"sample_ratio"`
+}
+
+type AuthConfig struct {
+	// AdminToken - токен для служебных эндпоинтов (Authorization: Bearer <token>)
+	AdminToken string `yaml:"admin_token" secret:"true"`
+}
+
+const (
+	SMTPSecurityStartTLS = "starttls"
+	SMTPSecurityTLS      = "tls"
+	SMTPSecurityNone     = "none"
+)
+
+type SMTPConfig struct {
+	Host     string `yaml:"host"`
+	Port     int    `yaml:"port"`
+	Username string `yaml:"username"`
+	Password string `yaml:"password" secret:"true"`
+	// From - адрес отправителя, например "Федерация <noreply@example.com>"
+	From string `yaml:"from"`
+	// Security - starttls (обычно порт 587), tls (465) или none (локальный relay)
+	Security string        `yaml:"security"`
+	Timeout  time.Duration `yaml:"timeout"`
+}
+
+type NotifyConfig struct {
+	Enabled bool `yaml:"enabled"`
+	// Recipients - адреса сотрудников, которым приходят новые заявки
+	Recipients []string `yaml:"recipients"`
+	// ConfirmSubmitter - отправлять подтверждение автору заявки, если он указал email
+	ConfirmSubmitter bool `yaml:"confirm_submitter"`
+	// TemplatesDir - каталог с шаблонами, которые заменяют встроенные с тем же именем
+	TemplatesDir string     `yaml:"templates_dir"`
+	SMTP         SMTPConfig `yaml:"smtp"`
+
+	// DeliveryInterval - как часто разбирать очередь писем (outbox)
+	DeliveryInterval time.Duration `yaml:"delivery_interval"`
+	BatchSize        int           `yaml:"batch_size"`
+	// MaxAttempts - после стольких неудачных попыток письмо помечается failed
+	MaxAttempts int `yaml:"max_attempts"`
+	// RetryBackoff удваивается с каждой попыткой, но не больше MaxRetryBackoff
+	RetryBackoff    time.Duration `yaml:"retry_backoff"`
+	MaxRetryBackoff time.Duration `yaml:"max_retry_backoff"`
+	// SentRetention - сколько хранить отправленные письма
+	SentRetention time.Duration `yaml:"sent_retention"`
+}
+
+const (
+	CaptchaRecaptcha = "recaptcha"
+	CaptchaHCaptcha  = "hcaptcha"
+	CaptchaTurnstile = "turnstile"
+)
+
+type CaptchaConfig struct {
+	// Provider - recaptcha, hcaptcha, turnstile или пусто (капча не проверяется)
+	Provider string `yaml:"provider"`
+	Secret   string `yaml:"secret" secret:"true"`
+	// VerifyURL заменяет адрес проверки провайдера, например для тестового стенда
+	VerifyURL string        `yaml:"verify_url"`
+	Timeout   time.Duration `yaml:"timeout"`
+}
+
+// CallbackConfig - защита публичной формы заявок. Нулевой лимит отключает проверку
+type CallbackConfig struct {
+	// IPLimit заявок с одного IP за IPPeriod
+	IPLimit  int           `yaml:"ip_limit"`
+	IPPeriod time.Duration `yaml:"ip_period"`
+	// PhoneLimit заявок на один номер за PhonePeriod
+	PhoneLimit  int           `yaml:"phone_limit"`
+	PhonePeriod time.Duration `yaml:"phone_period"`
+	// DuplicateWindow - повтор заявки того же типа с того же номера в этом окне отклоняется
+	DuplicateWindow time.Duration `yaml:"duplicate_window"`
+	Captcha         CaptchaConfig `yaml:"captcha"`
+}
+
+const (
+	RateLimitBackendMemory = "memory"
+	RateLimitBackendRedis  = "redis"
+)
+
+type RedisConfig struct {
+	Addr     string `yaml:"addr"`
+	Password string `yaml:"password" secret:"true"`
+	DB       int    `yaml:"db"`
+	// KeyPrefix отделяет ключи приложения от чужих в общем Redis
+	KeyPrefix string        `yaml:"key_prefix"`
+	Timeout   time.Duration `yaml:"timeout"`
+}
+
+// RateLimitPolicy - token bucket: до Burst запросов подряд (0 - Limit),
+// затем Limit запросов за Period
+type RateLimitPolicy struct {
+	Limit  int           `yaml:"limit"`
+	Period time.Duration `yaml:"period"`
+	Burst  int           `yaml:"burst"`
+	// Methods - к каким HTTP-методам применяется политика, пусто - ко всем
+	Methods []string `yaml:"methods"`
+}
+
+type RateLimitConfig struct {
+	Enabled bool `yaml:"enabled"`
+	// Backend - memory (у каждого экземпляра свои счётчики) или redis (общие).
+	// Лимиты формы заявок хранятся там же
+	Backend string      `yaml:"backend"`
+	Redis   RedisConfig `yaml:"redis"`
+	// Policies - политики по группам маршрутов: default действует на весь /api,
+	// остальные - на группу с тем же именем (gallery, news, document, ...)
+	Policies map[string]RateLimitPolicy `yaml:"policies"`
 }
 
 type Config struct {
-	DB     DBConfig
-	Server ServerConfig
-	App    AppConfig
+	App       AppConfig       `yaml:"app"`
+	DB        DBConfig        `yaml:"db"`
+	Server    ServerConfig    `yaml:"server"`
+	CORS      CORSConfig      `yaml:"cors"`
+	Upload    UploadConfig    `yaml:"upload"`
+	Documents DocumentsConfig `yaml:"documents"`
+	Gallery   GalleryConfig   `yaml:"gallery"`
+	Log       LogConfig       `yaml:"log"`
+	Metrics   MetricsConfig   `yaml:"metrics"`
+	Tracing   TracingConfig   `yaml:"tracing"`
+	Auth      AuthConfig      `yaml:"auth"`
+	Notify    NotifyConfig    `yaml:"notify"`
+	Callback  CallbackConfig  `yaml:"callback"`
+	RateLimit RateLimitConfig `yaml:"rate_limit"`
 }
 
+// NewConfig возвращает конфигурацию со значениями по умолчанию
 func NewConfig() *Config {
 	return &Config{
+		App: AppConfig{
+			Env:             EnvDevelopment,
+			FileStoragePath: "./files",
+		},
 		DB: DBConfig{
-			Driver: getEnv("DB_DRIVER", "postgres"),
-			Host:   getEnv("DB_HOST", "db"),
-			Port:   getEnv("DB_PORT", "5432"),
-			User:   getEnv("DB_USERNAME", "okada"),
-			Pass:   getEnv("DB_PASSWORD", "root"),
-			Name:   getEnv("DB_NAME", "postgres"),
+			Driver:  DriverMySQL,
+			Host:    "db",
+			Port:    "3306",
+			User:    "root",
+			Pass:    "root",
+			Name:    "federation",
+			SSLMode: "disable",
+
+			MaxOpenConns:    25,
+			MaxIdleConns:    5,
+			ConnMaxLifetime: 30 * time.Minute,
+			ConnMaxIdleTime: 5 * time.Minute,
+
+			ConnectTimeout: 10 * time.Second,
+			ReadTimeout:    30 * time.Second,
+			WriteTimeout:   30 * time.Second,
 		},
 		Server: ServerConfig{
-			Port: getEnv("SERVER_PORT", "8080"),
-			Host: getEnv("SERVER_HOST", "localhost"),
+			Port: "8080",
+			Host: "localhost",
+
+			ReadHeaderTimeout: 10 * time.Second,
+			ReadTimeout:       5 * time.Minute,
+			WriteTimeout:      5 * time.Minute,
+			IdleTimeout:       2 * time.Minute,
+			ShutdownTimeout:   30 * time.Second,
 		},
-		App: AppConfig{
-			FileStoragePath: getEnv("APP_FILE_STORAGE_PATH", "./files"),
+		CORS: CORSConfig{
+			AllowCredentials: true,
+			MaxAge:           10 * time.Minute,
+		},
+		Upload: UploadConfig{
+			MaxFileSizeMB:      20,
+			MaxRequestSizeMB:   200,
+			MultipartMemoryMB:  32,
+			MaxImageMegapixels: 50,
+		},
+		Documents: DocumentsConfig{
+			PreviewCommand: "pdftoppm",
+			PreviewWidth:   600,
+			PreviewTimeout: 30 * time.Second,
+		},
+		Gallery: GalleryConfig{
+			ArchiveCacheMB: 200,
+		},
+		Log: LogConfig{
+			Level:  "info",
+			Format: "text",
+		},
+		Metrics: MetricsConfig{
+			Enabled:             true,
+			Path:                "/metrics",
+			StorageScanInterval: 5 * time.Minute,
+		},
+		Tracing: TracingConfig{
+			Exporter:    TracingExporterOTLP,
+			Insecure:    true,
+			ServiceName: "federation-backend",
+			SampleRatio: 1,
+		},
+		Notify: NotifyConfig{
+			ConfirmSubmitter: true,
+			SMTP: SMTPConfig{
+				Port:     587,
+				Security: SMTPSecurityStartTLS,
+				Timeout:  30 * time.Second,
+			},
+			DeliveryInterval: 15 * time.Second,
+			BatchSize:        50,
+			MaxAttempts:      8,
+			RetryBackoff:     time.Minute,
+			MaxRetryBackoff:  2 * time.Hour,
+			SentRetention:    30 * 24 * time.Hour,
+		},
+		Callback: CallbackConfig{
+			IPLimit:         5,
+			IPPeriod:        time.Hour,
+			PhoneLimit:      3,
+			PhonePeriod:     24 * time.Hour,
+			DuplicateWindow: 10 * time.Minute,
+			Captcha: CaptchaConfig{
+				Timeout: 5 * time.Second,
+			},
+		},
+		RateLimit: RateLimitConfig{
+			Enabled: true,
+			Backend: RateLimitBackendMemory,
+			Redis: RedisConfig{
+				Addr:      "localhost:6379",
+				KeyPrefix: "federation:ratelimit:",
+				Timeout:   time.Second,
+			},
+			Policies: map[string]RateLimitPolicy{
+				"default":  {Limit: 300, Period: time.Minute},
+				"gallery":  {Limit: 30, Period: time.Hour, Burst: 10, Methods: []string{"POST", "PUT"}},
+				"news":     {Limit: 30, Period: time.Hour, Burst: 10, Methods: []string{"POST", "PUT"}},
+				"document": {Limit: 30, Period: time.Hour, Burst: 10, Methods: []string{"POST", "PUT"}},
+			},
 		},
 	}
 }
 
-func (db *DBConfig) GetDBUri() string {
-	return fmt.Sprintf("%s://%s:%s@%s:%s/%s",
-		db.Driver,
-		db.User,
-		db.Pass,
-		db.Host,
-		db.Port,
-		db.Name,
-	)
+// GetDSN строит строку подключения в формате, который ожидает драйвер db.Driver
+func (db *DBConfig) GetDSN() (string, error) {
+	switch db.Driver {
+	case DriverMySQL:
+		params := url.Values{}
+		params.Set("parseTime", "true")
+		params.Set("charset", "utf8mb4")
+		params.Set("loc", "UTC")
+		params.Set("timeout", db.ConnectTimeout.String())
+		params.Set("readTimeout", db.ReadTimeout.String())
+		params.Set("writeTimeout", db.WriteTimeout.String())
+		return fmt.Sprintf("%s:%s@tcp(%s:%s)/%s?%s",
+			db.User,
+			db.Pass,
+			db.Host,
+			db.Port,
+			db.Name,
+			params.Encode(),
+		), nil
+
+	case DriverPostgres:
+		dsn := url.URL{
+			Scheme: "postgres",
+			User:   url.UserPassword(db.User, db.Pass),
+			Host:   db.Host + ":" + db.Port,
+			Path:   "/" + db.Name,
+		}
+		params := url.Values{}
+		params.Set("sslmode", db.SSLMode)
+		params.Set("connect_timeout", strconv.Itoa(int(db.ConnectTimeout.Seconds())))
+		params.Set("TimeZone", "UTC")
+		dsn.RawQuery = params.Encode()
+		return dsn.String(), nil
+
+	case DriverSQLite:
+		params := url.Values{}
+		params.Set("_foreign_keys", "on")
+		params.Set("_busy_timeout", strconv.FormatInt(db.ConnectTimeout.Milliseconds(), 10))
+		return fmt.Sprintf("file:%s?%s", db.Name, params.Encode()), nil
+
+	default:
+		return "", fmt.Errorf("unsupported database driver %q: expected %s, %s or %s", db.Driver, DriverMySQL, DriverPostgres, DriverSQLite)
+	}
 }
 
 func (server *ServerConfig) GetHostURL() string {
 	return fmt.Sprintf("%s:%s", server.Host, server.Port)
 }
 
-func getEnv(key, fallback string) string {
-	if value, ok := os.LookupEnv(key); ok {
-		return value
+func (tls *TLSConfig) Enabled() bool {
+	return tls.CertFile != "" && tls.KeyFile != ""
+}
+
+// defaultOrigins - origin, разрешённые по умолчанию в каждом окружении.
+// В production кросс-доменные запросы запрещены, пока origin не заданы явно
+var defaultOrigins = map[string][]string{
+	EnvDevelopment: {
+		"http://localhost:3000",
+		"http://localhost:5173",
+		"http://127.0.0.1:3000",
+		"http://127.0.0.1:5173",
+	},
+	EnvProduction: {},
+}
+
+// Origins возвращает действующий список разрешённых origin для окружения env
+func (cors *CORSConfig) Origins(env string) []string {
+	if len(cors.AllowedOrigins) > 0 {
+		return cors.AllowedOrigins
 	}
+	return defaultOrigins[env]
+}
 
-	return fallback
+const megabyte = 1 << 20
+
+func (upload *UploadConfig) MaxFileSize() int64 {
+	return upload.MaxFileSizeMB * megabyte
 }
 
+func (upload *UploadConfig) MaxRequestSize() int64 {
+	return upload.MaxRequestSizeMB * megabyte
+}
+
+func (upload *UploadConfig) MaxImagePixels() int64 {
+	return upload.MaxImageMegapixels * 1_000_000
+}
+
+func (upload *UploadConfig) MultipartMemory() int64 {
+	return upload.MultipartMemoryMB * megabyte
+}
+
+func (gallery *GalleryConfig) ArchiveCacheSize() int64 {
+	return gallery.ArchiveCacheMB * megabyte
+}
+
+var Current *Config
 var DB *DBConfig
 var App *AppConfig
 var Server *ServerConfig
+var CORS *CORSConfig
+var Upload *UploadConfig
+var Documents *DocumentsConfig
+var Gallery *GalleryConfig
+var Log *LogConfig
+var Metrics *MetricsConfig
+var Tracing *TracingConfig
+var Auth *AuthConfig
+var Notify *NotifyConfig
+var Callback *CallbackConfig
+var RateLimit *RateLimitConfig
 
-func Init() {
-	cfg := NewConfig()
+// Init загружает конфигурацию из args (см. Load), проверяет её и выставляет
+// глобальные указатели на секции. Возвращает аргументы, оставшиеся после флагов.
+func Init(args []string) ([]string, error) {
+	cfg, rest, err := Load(args)
+	if err != nil {
+		return nil, err
+	}
+
+	Current = cfg
 	DB = &cfg.DB
 	App = &cfg.App
 	Server = &cfg.Server
+	CORS = &cfg.CORS
+	Upload = &cfg.Upload
+	Documents = &cfg.Documents
+	Gallery = &cfg.Gallery
+	Log = &cfg.Log
+	Metrics = &cfg.Metrics
+	Tracing = &cfg.Tracing
+	Auth = &cfg.Auth
+	Notify = &cfg.Notify
+	Callback = &cfg.Callback
+	RateLimit = &cfg.RateLimit
+
+	return rest, nil
 }
@@ -0,0 +1,326 @@
+package config
+
+import (
+	"bytes"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/pelletier/go-toml/v2"
+	"gopkg.in/yaml.v3"
+)
+
+// Load собирает конфигурацию по слоям: значения по умолчанию, файл (YAML или TOML),
+// переменные окружения и флаги командной строки. Каждый следующий слой переопределяет
+// предыдущий. Путь к файлу задаётся флагом -config или переменной CONFIG_FILE.
+// Возвращает проверенную конфигурацию и аргументы, оставшиеся после флагов.
+func Load(args []string) (*Config, []string, error) {
+	// Первый проход нужен только чтобы узнать путь к файлу конфигурации
+	probe := flag.NewFlagSet("federation-backend", flag.ContinueOnError)
+	probe.SetOutput(io.Discard)
+	path := bindFlags(probe, NewConfig())
+	if err := probe.Parse(args); err != nil {
+		// Ошибку покажет основной проход, иначе она напечаталась бы дважды
+		path = new(string)
+	}
+	if *path == "" {
+		*path = os.Getenv("CONFIG_FILE")
+	}
+
+	cfg := NewConfig()
+	if *path != "" {
+		if err := loadFile(*path, cfg); err != nil {
+			return nil, nil, err
+		}
+	}
+
+	if err := applyEnv(cfg); err != nil {
+		return nil, nil, err
+	}
+
+	flags := flag.NewFlagSet("federation-backend", flag.ContinueOnError)
+	bindFlags(flags, cfg)
+	if err := flags.Parse(args); err != nil {
+		return nil, nil, err
+	}
+
+	if err := cfg.Validate(); err != nil {
+		return nil, nil, fmt.Errorf("invalid configuration:\n%w", err)
+	}
+
+	return cfg, flags.Args(), nil
+}
+
+func loadFile(path string, cfg *Config) error {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return fmt.Errorf("failed to read config file: %w", err)
+	}
+
+	switch strings.ToLower(filepath.Ext(path)) {
+	case ".yaml", ".yml":
+	case ".toml":
+		// TOML не умеет разбирать строки вида "10s" в time.Duration, поэтому
+		// документ переводится в YAML и дальше разбирается общим путём
+		var doc map[string]interface{}
+		if err := toml.Unmarshal(data, &doc); err != nil {
+			return fmt.Errorf("failed to parse config file %s: %w", path, err)
+		}
+		if data, err = yaml.Marshal(doc); err != nil {
+			return fmt.Errorf("failed to convert config file %s: %w", path, err)
+		}
+	default:
+		return fmt.Errorf("unsupported config file format %q: expected .yaml, .yml or .toml", filepath.Ext(path))
+	}
+
+	decoder := yaml.NewDecoder(bytes.NewReader(data))
+	decoder.KnownFields(true)
+	if err := decoder.Decode(cfg); err != nil && !errors.Is(err, io.EOF) {
+		return fmt.Errorf("failed to parse config file %s: %w", path, err)
+	}
+
+	return nil
+}
+
+func applyEnv(cfg *Config) error {
+	env := &envLoader{}
+
+	env.string("APP_ENV", &cfg.App.Env)
+	env.string("APP_FILE_STORAGE_PATH", &cfg.App.FileStoragePath)
+
+	env.string("DB_DRIVER", &cfg.DB.Driver)
+	env.string("DB_HOST", &cfg.DB.Host)
+	env.string("DB_PORT", &cfg.DB.Port)
+	env.string("DB_USERNAME", &cfg.DB.User)
+	env.string("DB_PASSWORD", &cfg.DB.Pass)
+	env.string("DB_NAME", &cfg.DB.Name)
+	env.string("DB_SSL_MODE", &cfg.DB.SSLMode)
+	env.int("DB_MAX_OPEN_CONNS", &cfg.DB.MaxOpenConns)
+	env.int("DB_MAX_IDLE_CONNS", &cfg.DB.MaxIdleConns)
+	env.duration("DB_CONN_MAX_LIFETIME", &cfg.DB.ConnMaxLifetime)
+	env.duration("DB_CONN_MAX_IDLE_TIME", &cfg.DB.ConnMaxIdleTime)
+	env.duration("DB_CONNECT_TIMEOUT", &cfg.DB.ConnectTimeout)
+	env.duration("DB_READ_TIMEOUT", &cfg.DB.ReadTimeout)
+	env.duration("DB_WRITE_TIMEOUT", &cfg.DB.WriteTimeout)
+
+	env.string("SERVER_HOST", &cfg.Server.Host)
+	env.string("SERVER_PORT", &cfg.Server.Port)
+	env.string("SERVER_TLS_CERT_FILE", &cfg.Server.TLS.CertFile)
+	env.string("SERVER_TLS_KEY_FILE", &cfg.Server.TLS.KeyFile)
+	env.duration("SERVER_READ_HEADER_TIMEOUT", &cfg.Server.ReadHeaderTimeout)
+	env.duration("SERVER_READ_TIMEOUT", &cfg.Server.ReadTimeout)
+	env.duration("SERVER_WRITE_TIMEOUT", &cfg.Server.WriteTimeout)
+	env.duration("SERVER_IDLE_TIMEOUT", &cfg.Server.IdleTimeout)
+	env.duration("SERVER_SHUTDOWN_TIMEOUT", &cfg.Server.ShutdownTimeout)
+	env.list("SERVER_TRUSTED_PROXIES", &cfg.Server.TrustedProxies)
+
+	env.list("CORS_ALLOWED_ORIGINS", &cfg.CORS.AllowedOrigins)
+	env.bool("CORS_ALLOW_CREDENTIALS", &cfg.CORS.AllowCredentials)
+	env.duration("CORS_MAX_AGE", &cfg.CORS.MaxAge)
+
+	env.int64("UPLOAD_MAX_FILE_SIZE_MB", &cfg.Upload.MaxFileSizeMB)
+	env.int64("UPLOAD_MAX_REQUEST_SIZE_MB", &cfg.Upload.MaxRequestSizeMB)
+	env.int64("UPLOAD_MULTIPART_MEMORY_MB", &cfg.Upload.MultipartMemoryMB)
+	env.int64("UPLOAD_MAX_IMAGE_MEGAPIXELS", &cfg.Upload.MaxImageMegapixels)
+
+	env.string("DOCUMENTS_PREVIEW_COMMAND", &cfg.Documents.PreviewCommand)
+	env.int("DOCUMENTS_PREVIEW_WIDTH", &cfg.Documents.PreviewWidth)
+	env.duration("DOCUMENTS_PREVIEW_TIMEOUT", &cfg.Documents.PreviewTimeout)
+
+	env.int64("GALLERY_ARCHIVE_CACHE_MB", &cfg.Gallery.ArchiveCacheMB)
+	env.string("GALLERY_ARCHIVE_CACHE_DIR", &cfg.Gallery.ArchiveCacheDir)
+
+	env.string("LOG_LEVEL", &cfg.Log.Level)
+	env.string("LOG_FORMAT", &cfg.Log.Format)
+
+	env.bool("METRICS_ENABLED", &cfg.Metrics.Enabled)
+	env.string("METRICS_PATH", &cfg.Metrics.Path)
+	env.duration("METRICS_STORAGE_SCAN_INTERVAL", &cfg.Metrics.StorageScanInterval)
+
+	env.bool("TRACING_ENABLED", &cfg.Tracing.Enabled)
+	env.string("TRACING_EXPORTER", &cfg.Tracing.Exporter)
+	env.string("TRACING_ENDPOINT", &cfg.Tracing.Endpoint)
+	env.bool("TRACING_INSECURE", &cfg.Tracing.Insecure)
+	env.string("TRACING_SERVICE_NAME", &cfg.Tracing.ServiceName)
+	env.float64("TRACING_SAMPLE_RATIO", &cfg.Tracing.SampleRatio)
+
+	env.string("AUTH_ADMIN_TOKEN", &cfg.Auth.AdminToken)
+
+	env.bool("NOTIFY_ENABLED", &cfg.Notify.Enabled)
+	env.list("NOTIFY_RECIPIENTS", &cfg.Notify.Recipients)
+	env.bool("NOTIFY_CONFIRM_SUBMITTER", &cfg.Notify.ConfirmSubmitter)
+	env.string("NOTIFY_TEMPLATES_DIR", &cfg.Notify.TemplatesDir)
+	env.duration("NOTIFY_DELIVERY_INTERVAL", &cfg.Notify.DeliveryInterval)
+	env.int("NOTIFY_BATCH_SIZE", &cfg.Notify.BatchSize)
+	env.int("NOTIFY_MAX_ATTEMPTS", &cfg.Notify.MaxAttempts)
+	env.duration("NOTIFY_RETRY_BACKOFF", &cfg.Notify.RetryBackoff)
+	env.duration("NOTIFY_MAX_RETRY_BACKOFF", &cfg.Notify.MaxRetryBackoff)
+	env.duration("NOTIFY_SENT_RETENTION", &cfg.Notify.SentRetention)
+	env.string("SMTP_HOST", &cfg.Notify.SMTP.Host)
+	env.int("SMTP_PORT", &cfg.Notify.SMTP.Port)
+	env.string("SMTP_USERNAME", &cfg.Notify.SMTP.Username)
+	env.string("SMTP_PASSWORD", &cfg.Notify.SMTP.Password)
+	env.string("SMTP_FROM", &cfg.Notify.SMTP.From)
+	env.string("SMTP_SECURITY", &cfg.Notify.SMTP.Security)
+	env.duration("SMTP_TIMEOUT", &cfg.Notify.SMTP.Timeout)
+
+	env.int("CALLBACK_IP_LIMIT", &cfg.Callback.IPLimit)
+	env.duration("CALLBACK_IP_PERIOD", &cfg.Callback.IPPeriod)
+	env.int("CALLBACK_PHONE_LIMIT", &cfg.Callback.PhoneLimit)
+	env.duration("CALLBACK_PHONE_PERIOD", &cfg.Callback.PhonePeriod)
+	env.duration("CALLBACK_DUPLICATE_WINDOW", &cfg.Callback.DuplicateWindow)
+	env.string("CAPTCHA_PROVIDER", &cfg.Callback.Captcha.Provider)
+	env.string("CAPTCHA_SECRET", &cfg.Callback.Captcha.Secret)
+	env.string("CAPTCHA_VERIFY_URL", &cfg.Callback.Captcha.VerifyURL)
+	env.duration("CAPTCHA_TIMEOUT", &cfg.Callback.Captcha.Timeout)
+
+	env.bool("RATE_LIMIT_ENABLED", &cfg.RateLimit.Enabled)
+	env.string("RATE_LIMIT_BACKEND", &cfg.RateLimit.Backend)
+	env.string("REDIS_ADDR", &cfg.RateLimit.Redis.Addr)
+	env.string("REDIS_PASSWORD", &cfg.RateLimit.Redis.Password)
+	env.int("REDIS_DB", &cfg.RateLimit.Redis.DB)
+	env.string("REDIS_KEY_PREFIX", &cfg.RateLimit.Redis.KeyPrefix)
+	env.duration("REDIS_TIMEOUT", &cfg.RateLimit.Redis.Timeout)
+
+	return errors.Join(env.errs...)
+}
+
+// bindFlags регистрирует флаги поверх текущих значений cfg и возвращает путь к файлу конфигурации
+func bindFlags(flags *flag.FlagSet, cfg *Config) *string {
+	path := flags.String("config", "", "path to a YAML or TOML config file (env CONFIG_FILE)")
+
+	flags.StringVar(&cfg.App.Env, "env", cfg.App.Env, "environment: development or production")
+	flags.StringVar(&cfg.App.FileStoragePath, "storage-path", cfg.App.FileStoragePath, "directory for uploaded files")
+
+	flags.StringVar(&cfg.DB.Driver, "db-driver", cfg.DB.Driver, "database driver: mysql, postgres or sqlite")
+	flags.StringVar(&cfg.DB.Host, "db-host", cfg.DB.Host, "database host")
+	flags.StringVar(&cfg.DB.Port, "db-port", cfg.DB.Port, "database port")
+	flags.StringVar(&cfg.DB.User, "db-user", cfg.DB.User, "database user")
+	flags.StringVar(&cfg.DB.Name, "db-name", cfg.DB.Name, "database name, file path for sqlite")
+
+	flags.StringVar(&cfg.Server.Host, "host", cfg.Server.Host, "address to listen on")
+	flags.StringVar(&cfg.Server.Port, "port", cfg.Server.Port, "port to listen on")
+	flags.StringVar(&cfg.Server.TLS.CertFile, "tls-cert", cfg.Server.TLS.CertFile, "TLS certificate file")
+	flags.StringVar(&cfg.Server.TLS.KeyFile, "tls-key", cfg.Server.TLS.KeyFile, "TLS private key file")
+	flags.DurationVar(&cfg.Server.ShutdownTimeout, "shutdown-timeout", cfg.Server.ShutdownTimeout, "how long to wait for in-flight requests on shutdown")
+	flags.Var((*listFlag)(&cfg.Server.TrustedProxies), "trusted-proxies", "comma-separated list of proxy addresses or CIDRs trusted for X-Forwarded-For")
+
+	flags.Var((*listFlag)(&cfg.CORS.AllowedOrigins), "cors-origins", "comma-separated list of allowed CORS origins")
+
+	flags.Int64Var(&cfg.Upload.MaxFileSizeMB, "upload-max-file-mb", cfg.Upload.MaxFileSizeMB, "max size of a single uploaded file, MB")
+	flags.Int64Var(&cfg.Upload.MaxRequestSizeMB, "upload-max-request-mb", cfg.Upload.MaxRequestSizeMB, "max size of a request body, MB")
+
+	flags.StringVar(&cfg.Log.Level, "log-level", cfg.Log.Level, "log level: debug, info, warn or error")
+	flags.StringVar(&cfg.Log.Format, "log-format", cfg.Log.Format, "log format: text or json")
+
+	flags.BoolVar(&cfg.Tracing.Enabled, "tracing", cfg.Tracing.Enabled, "enable OpenTelemetry tracing")
+	flags.StringVar(&cfg.Tracing.Exporter, "tracing-exporter", cfg.Tracing.Exporter, "trace exporter: otlp or stdout")
+
+	flags.BoolVar(&cfg.RateLimit.Enabled, "rate-limit", cfg.RateLimit.Enabled, "enable per-route rate limiting")
+	flags.StringVar(&cfg.RateLimit.Backend, "rate-limit-backend", cfg.RateLimit.Backend, "rate limit storage: memory or redis")
+
+	flags.BoolVar(&cfg.Notify.Enabled, "notify", cfg.Notify.Enabled, "send email notifications about new callbacks")
+	flags.StringVar(&cfg.Notify.SMTP.Host, "smtp-host", cfg.Notify.SMTP.Host, "SMTP server host")
+	flags.IntVar(&cfg.Notify.SMTP.Port, "smtp-port", cfg.Notify.SMTP.Port, "SMTP server port")
+
+	return path
+}
+
+type envLoader struct {
+	errs []error
+}
+
+func (e *envLoader) string(key string, dst *string) {
+	if value, ok := os.LookupEnv(key); ok {
+		*dst = value
+	}
+}
+
+func (e *envLoader) list(key string, dst *[]string) {
+	if value, ok := os.LookupEnv(key); ok {
+		*dst = splitList(value)
+	}
+}
+
+func (e *envLoader) bool(key string, dst *bool) {
+	if value, ok := os.LookupEnv(key); ok {
+		parsed, err := strconv.ParseBool(value)
+		if err != nil {
+			e.errs = append(e.errs, fmt.Errorf("%s: %q is not a boolean", key, value))
+			return
+		}
+		*dst = parsed
+	}
+}
+
+func (e *envLoader) int(key string, dst *int) {
+	if value, ok := os.LookupEnv(key); ok {
+		parsed, err := strconv.Atoi(value)
+		if err != nil {
+			e.errs = append(e.errs, fmt.Errorf("%s: %q is not an integer", key, value))
+			return
+		}
+		*dst = parsed
+	}
+}
+
+func (e *envLoader) int64(key string, dst *int64) {
+	if value, ok := os.LookupEnv(key); ok {
+		parsed, err := strconv.ParseInt(value, 10, 64)
+		if err != nil {
+			e.errs = append(e.errs, fmt.Errorf("%s: %q is not an integer", key, value))
+			return
+		}
+		*dst = parsed
+	}
+}
+
+func (e *envLoader) float64(key string, dst *float64) {
+	if value, ok := os.LookupEnv(key); ok {
+		parsed, err := strconv.ParseFloat(value, 64)
+		if err != nil {
+			e.errs = append(e.errs, fmt.Errorf("%s: %q is not a number", key, value))
+			return
+		}
+		*dst = parsed
+	}
+}
+
+func (e *envLoader) duration(key string, dst *time.Duration) {
+	if value, ok := os.LookupEnv(key); ok {
+		parsed, err := time.ParseDuration(value)
+		if err != nil {
+			e.errs = append(e.errs, fmt.Errorf("%s: %q is not a duration (e.g. 30s, 5m)", key, value))
+			return
+		}
+		*dst = parsed
+	}
+}
+
+// listFlag - флаг со списком значений через запятую
+type listFlag []string
+
+func (l *listFlag) String() string {
+	return strings.Join(*l, ",")
+}
+
+func (l *listFlag) Set(value string) error {
+	*l = splitList(value)
+	return nil
+}
+
+func splitList(value string) []string {
+	var result []string
+	for _, item := range strings.Split(value, ",") {
+		if item = strings.TrimSpace(item); item != "" {
+			result = append(result, item)
+		}
+	}
+	return result
+}
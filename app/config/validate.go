@@ -0,0 +1,341 @@
+package config
+
+import (
+	"errors"
+	"fmt"
+	"maps"
+	"net"
+	"net/mail"
+	"net/url"
+	"os"
+	"reflect"
+	"slices"
+	"strconv"
+	"strings"
+	"time"
+
+	"gopkg.in/yaml.v3"
+)
+
+const redacted = "******"
+
+// Validate проверяет конфигурацию целиком и возвращает все найденные ошибки сразу
+func (cfg *Config) Validate() error {
+	var errs []error
+	fail := func(field, format string, args ...interface{}) {
+		errs = append(errs, fmt.Errorf("%s: %s", field, fmt.Sprintf(format, args...)))
+	}
+
+	if !slices.Contains([]string{EnvDevelopment, EnvProduction}, cfg.App.Env) {
+		fail("app.env", "must be %q or %q, got %q", EnvDevelopment, EnvProduction, cfg.App.Env)
+	}
+	if cfg.App.FileStoragePath == "" {
+		fail("app.file_storage_path", "must not be empty")
+	}
+
+	switch cfg.DB.Driver {
+	case DriverMySQL, DriverPostgres:
+		if cfg.DB.Host == "" {
+			fail("db.host", "must not be empty")
+		}
+		if !isPort(cfg.DB.Port) {
+			fail("db.port", "must be a number between 1 and 65535, got %q", cfg.DB.Port)
+		}
+		if cfg.DB.User == "" {
+			fail("db.user", "must not be empty")
+		}
+	case DriverSQLite:
+	default:
+		fail("db.driver", "must be %s, %s or %s, got %q", DriverMySQL, DriverPostgres, DriverSQLite, cfg.DB.Driver)
+	}
+	if cfg.DB.Name == "" {
+		fail("db.name", "must not be empty")
+	}
+	if cfg.DB.MaxOpenConns < 0 || cfg.DB.MaxIdleConns < 0 {
+		fail("db.max_open_conns", "connection pool sizes must not be negative")
+	}
+	if cfg.DB.MaxOpenConns > 0 && cfg.DB.MaxIdleConns > cfg.DB.MaxOpenConns {
+		fail("db.max_idle_conns", "must not exceed db.max_open_conns (%d)", cfg.DB.MaxOpenConns)
+	}
+	if cfg.DB.ConnectTimeout <= 0 {
+		fail("db.connect_timeout", "must be positive")
+	}
+
+	if !isPort(cfg.Server.Port) {
+		fail("server.port", "must be a number between 1 and 65535, got %q", cfg.Server.Port)
+	}
+	for _, timeout := range []struct {
+		field string
+		value time.Duration
+	}{
+		{"server.read_header_timeout", cfg.Server.ReadHeaderTimeout},
+		{"server.read_timeout", cfg.Server.ReadTimeout},
+		{"server.write_timeout", cfg.Server.WriteTimeout},
+		{"server.idle_timeout", cfg.Server.IdleTimeout},
+		{"server.shutdown_timeout", cfg.Server.ShutdownTimeout},
+	} {
+		if timeout.value <= 0 {
+			fail(timeout.field, "must be positive")
+		}
+	}
+	if (cfg.Server.TLS.CertFile == "") != (cfg.Server.TLS.KeyFile == "") {
+		fail("server.tls", "cert_file and key_file must be set together")
+	}
+	if cfg.Server.TLS.CertFile != "" {
+		if _, err := os.Stat(cfg.Server.TLS.CertFile); err != nil {
+			fail("server.tls.cert_file", "%v", err)
+		}
+	}
+	if cfg.Server.TLS.KeyFile != "" {
+		if _, err := os.Stat(cfg.Server.TLS.KeyFile); err != nil {
+			fail("server.tls.key_file", "%v", err)
+		}
+	}
+
+	for _, proxy := range cfg.Server.TrustedProxies {
+		if net.ParseIP(proxy) == nil {
+			if _, _, err := net.ParseCIDR(proxy); err != nil {
+				fail("server.trusted_proxies", "%q is not an IP address or CIDR", proxy)
+			}
+		}
+	}
+
+	for _, origin := range cfg.CORS.AllowedOrigins {
+		if origin == "*" {
+			if cfg.CORS.AllowCredentials {
+				fail("cors.allowed_origins", `"*" cannot be combined with cors.allow_credentials, list the origins explicitly`)
+			}
+			continue
+		}
+		parsed, err := url.Parse(origin)
+		if err != nil || (parsed.Scheme != "http" && parsed.Scheme != "https") || parsed.Host == "" || (parsed.Path != "" && parsed.Path != "/") {
+			fail("cors.allowed_origins", "%q is not an origin like https://example.com", origin)
+		}
+	}
+
+	if cfg.CORS.MaxAge < 0 {
+		fail("cors.max_age", "must not be negative")
+	}
+
+	if cfg.Upload.MaxFileSizeMB <= 0 {
+		fail("upload.max_file_size_mb", "must be positive")
+	}
+	if cfg.Upload.MaxRequestSizeMB < cfg.Upload.MaxFileSizeMB {
+		fail("upload.max_request_size_mb", "must be at least upload.max_file_size_mb (%d)", cfg.Upload.MaxFileSizeMB)
+	}
+	if cfg.Upload.MultipartMemoryMB <= 0 {
+		fail("upload.multipart_memory_mb", "must be positive")
+	}
+	if cfg.Upload.MaxImageMegapixels <= 0 {
+		fail("upload.max_image_megapixels", "must be positive")
+	}
+
+	if cfg.Documents.PreviewCommand != "" {
+		if cfg.Documents.PreviewWidth <= 0 {
+			fail("documents.preview_width", "must be positive")
+		}
+		if cfg.Documents.PreviewTimeout <= 0 {
+			fail("documents.preview_timeout", "must be positive")
+		}
+	}
+
+	if cfg.Gallery.ArchiveCacheMB < 0 {
+		fail("gallery.archive_cache_mb", "must not be negative")
+	}
+
+	if !slices.Contains([]string{"debug", "info", "warn", "error"}, cfg.Log.Level) {
+		fail("log.level", "must be debug, info, warn or error, got %q", cfg.Log.Level)
+	}
+	if !slices.Contains([]string{"text", "json"}, cfg.Log.Format) {
+		fail("log.format", "must be text or json, got %q", cfg.Log.Format)
+	}
+
+	if cfg.Metrics.Enabled {
+		if !strings.HasPrefix(cfg.Metrics.Path, "/") {
+			fail("metrics.path", "must start with /, got %q", cfg.Metrics.Path)
+		}
+		if cfg.Metrics.StorageScanInterval <= 0 {
+			fail("metrics.storage_scan_interval", "must be positive")
+		}
+	}
+
+	if cfg.Tracing.Enabled {
+		if !slices.Contains([]string{TracingExporterOTLP, TracingExporterStdout}, cfg.Tracing.Exporter) {
+			fail("tracing.exporter", "must be %s or %s, got %q", TracingExporterOTLP, TracingExporterStdout, cfg.Tracing.Exporter)
+		}
+		if cfg.Tracing.ServiceName == "" {
+			fail("tracing.service_name", "must not be empty")
+		}
+		if cfg.Tracing.SampleRatio < 0 || cfg.Tracing.SampleRatio > 1 {
+			fail("tracing.sample_ratio", "must be between 0 and 1, got %v", cfg.Tracing.SampleRatio)
+		}
+	}
+
+	if cfg.Auth.AdminToken != "" && len(cfg.Auth.AdminToken) < 16 {
+		fail("auth.admin_token", "must be at least 16 characters long")
+	}
+
+	if cfg.Notify.Enabled {
+		if len(cfg.Notify.Recipients) == 0 {
+			fail("notify.recipients", "must not be empty when notifications are enabled")
+		}
+		for _, recipient := range cfg.Notify.Recipients {
+			if _, err := mail.ParseAddress(recipient); err != nil {
+				fail("notify.recipients", "%q is not an email address", recipient)
+			}
+		}
+		if cfg.Notify.SMTP.Host == "" {
+			fail("notify.smtp.host", "must not be empty")
+		}
+		if cfg.Notify.SMTP.Port <= 0 || cfg.Notify.SMTP.Port > 65535 {
+			fail("notify.smtp.port", "must be between 1 and 65535, got %d", cfg.Notify.SMTP.Port)
+		}
+		if _, err := mail.ParseAddress(cfg.Notify.SMTP.From); err != nil {
+			fail("notify.smtp.from", "%q is not an email address", cfg.Notify.SMTP.From)
+		}
+		if !slices.Contains([]string{SMTPSecurityStartTLS, SMTPSecurityTLS, SMTPSecurityNone}, cfg.Notify.SMTP.Security) {
+			fail("notify.smtp.security", "must be %s, %s or %s, got %q", SMTPSecurityStartTLS, SMTPSecurityTLS, SMTPSecurityNone, cfg.Notify.SMTP.Security)
+		}
+		// net/smtp отказывается передавать пароль без TLS на любой хост, кроме
+		// localhost: с security none такой логин не прошёл бы ни одно письмо
+		if cfg.Notify.SMTP.Security == SMTPSecurityNone && cfg.Notify.SMTP.Username != "" &&
+			!slices.Contains([]string{"localhost", "127.0.0.1", "::1"}, cfg.Notify.SMTP.Host) {
+			fail("notify.smtp.username", "must be empty with security %s unless host is localhost, use %s or %s to authenticate", SMTPSecurityNone, SMTPSecurityStartTLS, SMTPSecurityTLS)
+		}
+		if cfg.Notify.TemplatesDir != "" {
+			if info, err := os.Stat(cfg.Notify.TemplatesDir); err != nil {
+				fail("notify.templates_dir", "%v", err)
+			} else if !info.IsDir() {
+				fail("notify.templates_dir", "%s is not a directory", cfg.Notify.TemplatesDir)
+			}
+		}
+		for _, timeout := range []struct {
+			field string
+			value time.Duration
+		}{
+			{"notify.smtp.timeout", cfg.Notify.SMTP.Timeout},
+			{"notify.delivery_interval", cfg.Notify.DeliveryInterval},
+			{"notify.retry_backoff", cfg.Notify.RetryBackoff},
+			{"notify.sent_retention", cfg.Notify.SentRetention},
+		} {
+			if timeout.value <= 0 {
+				fail(timeout.field, "must be positive")
+			}
+		}
+		if cfg.Notify.MaxRetryBackoff < cfg.Notify.RetryBackoff {
+			fail("notify.max_retry_backoff", "must be at least notify.retry_backoff (%s)", cfg.Notify.RetryBackoff)
+		}
+		if cfg.Notify.BatchSize <= 0 {
+			fail("notify.batch_size", "must be positive")
+		}
+		if cfg.Notify.MaxAttempts <= 0 {
+			fail("notify.max_attempts", "must be positive")
+		}
+	}
+
+	for _, limit := range []struct {
+		field  string
+		limit  int
+		period time.Duration
+	}{
+		{"callback.ip_limit", cfg.Callback.IPLimit, cfg.Callback.IPPeriod},
+		{"callback.phone_limit", cfg.Callback.PhoneLimit, cfg.Callback.PhonePeriod},
+	} {
+		if limit.limit < 0 {
+			fail(limit.field, "must not be negative")
+		}
+		if limit.limit > 0 && limit.period <= 0 {
+			fail(limit.field, "needs a positive period")
+		}
+	}
+	if cfg.Callback.DuplicateWindow < 0 {
+		fail("callback.duplicate_window", "must not be negative")
+	}
+	if captcha := cfg.Callback.Captcha; captcha.Provider != "" {
+		if !slices.Contains([]string{CaptchaRecaptcha, CaptchaHCaptcha, CaptchaTurnstile}, captcha.Provider) {
+			fail("callback.captcha.provider", "must be %s, %s, %s or empty, got %q", CaptchaRecaptcha, CaptchaHCaptcha, CaptchaTurnstile, captcha.Provider)
+		}
+		if captcha.Secret == "" {
+			fail("callback.captcha.secret", "must not be empty when a provider is set")
+		}
+		if captcha.VerifyURL != "" {
+			if parsed, err := url.Parse(captcha.VerifyURL); err != nil || (parsed.Scheme != "http" && parsed.Scheme != "https") || parsed.Host == "" {
+				fail("callback.captcha.verify_url", "%q is not an http(s) URL", captcha.VerifyURL)
+			}
+		}
+		if captcha.Timeout <= 0 {
+			fail("callback.captcha.timeout", "must be positive")
+		}
+	}
+
+	switch cfg.RateLimit.Backend {
+	case RateLimitBackendMemory:
+	case RateLimitBackendRedis:
+		if cfg.RateLimit.Redis.Addr == "" {
+			fail("rate_limit.redis.addr", "must not be empty")
+		}
+		if cfg.RateLimit.Redis.DB < 0 {
+			fail("rate_limit.redis.db", "must not be negative")
+		}
+		if cfg.RateLimit.Redis.Timeout <= 0 {
+			fail("rate_limit.redis.timeout", "must be positive")
+		}
+	default:
+		fail("rate_limit.backend", "must be %s or %s, got %q", RateLimitBackendMemory, RateLimitBackendRedis, cfg.RateLimit.Backend)
+	}
+	for _, name := range slices.Sorted(maps.Keys(cfg.RateLimit.Policies)) {
+		policy := cfg.RateLimit.Policies[name]
+		field := "rate_limit.policies." + name
+		if policy.Limit <= 0 || policy.Period <= 0 {
+			fail(field, "limit and period must be positive")
+		}
+		if policy.Burst < 0 {
+			fail(field+".burst", "must not be negative")
+		}
+		for _, method := range policy.Methods {
+			if !slices.Contains(httpMethods, method) {
+				fail(field+".methods", "%q is not an HTTP method in upper case", method)
+			}
+		}
+	}
+
+	return errors.Join(errs...)
+}
+
+var httpMethods = []string{"GET", "HEAD", "POST", "PUT", "PATCH", "DELETE", "OPTIONS"}
+
+// Redacted возвращает копию конфигурации, в которой поля с тегом secret:"true" скрыты
+func (cfg *Config) Redacted() *Config {
+	clone := *cfg
+	clone.CORS.AllowedOrigins = slices.Clone(cfg.CORS.AllowedOrigins)
+	clone.Notify.Recipients = slices.Clone(cfg.Notify.Recipients)
+	clone.Server.TrustedProxies = slices.Clone(cfg.Server.TrustedProxies)
+	redact(reflect.ValueOf(&clone).Elem())
+	return &clone
+}
+
+// String печатает действующую конфигурацию в YAML без секретов
+func (cfg *Config) String() string {
+	data, err := yaml.Marshal(cfg.Redacted())
+	if err != nil {
+		return err.Error()
+	}
+	return string(data)
+}
+
+func redact(value reflect.Value) {
+	for i := 0; i < value.NumField(); i++ {
+		field := value.Field(i)
+		switch {
+		case field.Kind() == reflect.Struct:
+			redact(field)
+		case value.Type().Field(i).Tag.Get("secret") == "true" && field.Kind() == reflect.String && field.String() != "":
+			field.SetString(redacted)
+		}
+	}
+}
+
+func isPort(value string) bool {
+	port, err := strconv.Atoi(value)
+	return err == nil && port > 0 && port < 65536
+}
@@ -0,0 +1,222 @@
+package config
+
+import (
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestValidate(t *testing.T) {
+	tests := []struct {
+		name   string
+		modify func(cfg *Config)
+		// wantFields - поля, о которых должна быть ошибка; пусто - конфигурация верна
+		wantFields []string
+	}{
+		{
+			name:   "defaults",
+			modify: func(cfg *Config) {},
+		},
+		{
+			name:       "unknown env",
+			modify:     func(cfg *Config) { cfg.App.Env = "staging" },
+			wantFields: []string{"app.env"},
+		},
+		{
+			name: "sqlite needs no host",
+			modify: func(cfg *Config) {
+				cfg.DB.Driver = DriverSQLite
+				cfg.DB.Host = ""
+				cfg.DB.User = ""
+			},
+		},
+		{
+			name: "mysql needs host and user",
+			modify: func(cfg *Config) {
+				cfg.DB.Host = ""
+				cfg.DB.User = ""
+				cfg.DB.Port = "0"
+			},
+			wantFields: []string{"db.host", "db.user", "db.port"},
+		},
+		{
+			name: "idle connections above open",
+			modify: func(cfg *Config) {
+				cfg.DB.MaxOpenConns = 2
+				cfg.DB.MaxIdleConns = 3
+			},
+			wantFields: []string{"db.max_idle_conns"},
+		},
+		{
+			name:       "zero write timeout",
+			modify:     func(cfg *Config) { cfg.Server.WriteTimeout = 0 },
+			wantFields: []string{"server.write_timeout"},
+		},
+		{
+			name:       "tls cert without key",
+			modify:     func(cfg *Config) { cfg.Server.TLS.CertFile = "cert.pem" },
+			wantFields: []string{"server.tls"},
+		},
+		{
+			name:       "trusted proxy is not an address",
+			modify:     func(cfg *Config) { cfg.Server.TrustedProxies = []string{"10.0.0.0/8", "proxy"} },
+			wantFields: []string{"server.trusted_proxies"},
+		},
+		{
+			name: "wildcard origin with credentials",
+			modify: func(cfg *Config) {
+				cfg.CORS.AllowedOrigins = []string{"*"}
+				cfg.CORS.AllowCredentials = true
+			},
+			wantFields: []string{"cors.allowed_origins"},
+		},
+		{
+			name:       "origin with a path",
+			modify:     func(cfg *Config) { cfg.CORS.AllowedOrigins = []string{"https://example.com/app"} },
+			wantFields: []string{"cors.allowed_origins"},
+		},
+		{
+			name: "request smaller than file",
+			modify: func(cfg *Config) {
+				cfg.Upload.MaxFileSizeMB = 20
+				cfg.Upload.MaxRequestSizeMB = 10
+			},
+			wantFields: []string{"upload.max_request_size_mb"},
+		},
+		{
+			name:       "zero megapixels",
+			modify:     func(cfg *Config) { cfg.Upload.MaxImageMegapixels = 0 },
+			wantFields: []string{"upload.max_image_megapixels"},
+		},
+		{
+			name:       "short admin token",
+			modify:     func(cfg *Config) { cfg.Auth.AdminToken = "short" },
+			wantFields: []string{"auth.admin_token"},
+		},
+		{
+			name: "notify without recipients",
+			modify: func(cfg *Config) {
+				cfg.Notify.Enabled = true
+				cfg.Notify.SMTP.Host = "smtp.example.com"
+			},
+			wantFields: []string{"notify.recipients"},
+		},
+		{
+			name: "smtp login without tls",
+			modify: func(cfg *Config) {
+				cfg.Notify.Enabled = true
+				cfg.Notify.Recipients = []string{"staff@example.com"}
+				cfg.Notify.SMTP.Host = "smtp.example.com"
+				cfg.Notify.SMTP.From = "noreply@example.com"
+				cfg.Notify.SMTP.Security = SMTPSecurityNone
+				cfg.Notify.SMTP.Username = "user"
+			},
+			wantFields: []string{"notify.smtp.username"},
+		},
+		{
+			name: "smtp login without tls on localhost",
+			modify: func(cfg *Config) {
+				cfg.Notify.Enabled = true
+				cfg.Notify.Recipients = []string{"staff@example.com"}
+				cfg.Notify.SMTP.Host = "localhost"
+				cfg.Notify.SMTP.From = "noreply@example.com"
+				cfg.Notify.SMTP.Security = SMTPSecurityNone
+				cfg.Notify.SMTP.Username = "user"
+			},
+		},
+		{
+			name:       "captcha without secret",
+			modify:     func(cfg *Config) { cfg.Callback.Captcha.Provider = CaptchaTurnstile },
+			wantFields: []string{"callback.captcha.secret"},
+		},
+		{
+			name:       "unknown rate limit backend",
+			modify:     func(cfg *Config) { cfg.RateLimit.Backend = "memcached" },
+			wantFields: []string{"rate_limit.backend"},
+		},
+		{
+			name: "bad rate limit policy",
+			modify: func(cfg *Config) {
+				cfg.RateLimit.Policies = map[string]RateLimitPolicy{
+					"news": {Limit: 0, Period: time.Minute, Burst: -1, Methods: []string{"post"}},
+				}
+			},
+			wantFields: []string{"rate_limit.policies.news:", "rate_limit.policies.news.burst", "rate_limit.policies.news.methods"},
+		},
+		{
+			name: "all errors at once",
+			modify: func(cfg *Config) {
+				cfg.App.FileStoragePath = ""
+				cfg.Log.Level = "trace"
+				cfg.Gallery.ArchiveCacheMB = -1
+			},
+			wantFields: []string{"app.file_storage_path", "log.level", "gallery.archive_cache_mb"},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			cfg := NewConfig()
+			tt.modify(cfg)
+			err := cfg.Validate()
+
+			if len(tt.wantFields) == 0 {
+				if err != nil {
+					t.Fatalf("unexpected error: %v", err)
+				}
+				return
+			}
+			if err == nil {
+				t.Fatalf("want errors for %v, got nil", tt.wantFields)
+			}
+			for _, field := range tt.wantFields {
+				if !strings.Contains(err.Error(), field) {
+					t.Errorf("error %q does not mention %s", err, field)
+				}
+			}
+		})
+	}
+}
+
+func TestRedacted(t *testing.T) {
+	cfg := NewConfig()
+	cfg.DB.Pass = "db-secret"
+	cfg.Auth.AdminToken = "admin-secret-token"
+	cfg.Notify.SMTP.Password = ""
+	cfg.Notify.Recipients = []string{"staff@example.com"}
+
+	redactedCfg := cfg.Redacted()
+
+	tests := []struct {
+		name string
+		got  string
+		want string
+	}{
+		{"db password", redactedCfg.DB.Pass, redacted},
+		{"admin token", redactedCfg.Auth.AdminToken, redacted},
+		{"empty secret stays empty", redactedCfg.Notify.SMTP.Password, ""},
+		{"plain field", redactedCfg.DB.User, cfg.DB.User},
+		{"original db password", cfg.DB.Pass, "db-secret"},
+		{"original admin token", cfg.Auth.AdminToken, "admin-secret-token"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if tt.got != tt.want {
+				t.Errorf("got %q, want %q", tt.got, tt.want)
+			}
+		})
+	}
+
+	// Копия не делит срезы с оригиналом
+	redactedCfg.Notify.Recipients[0] = "changed@example.com"
+	if cfg.Notify.Recipients[0] != "staff@example.com" {
+		t.Error("Redacted shares notify.recipients with the original")
+	}
+
+	printed := cfg.String()
+	for _, secret := range []string{"db-secret", "admin-secret-token"} {
+		if strings.Contains(printed, secret) {
+			t.Errorf("String() leaks %q", secret)
+		}
+	}
+}
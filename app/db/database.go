@@ -1 +1,54 @@
 package db
+
+import (
+	"federation-backend/app/config"
+	"fmt"
+
+	"gorm.io/driver/mysql"
+	"gorm.io/driver/postgres"
+	"gorm.io/driver/sqlite"
+	"gorm.io/gorm"
+)
+
+// Open подключается к базе, выбранной в cfg.Driver, и настраивает пул соединений
+func Open(cfg *config.DBConfig, gormConfig *gorm.Config) (*gorm.DB, error) {
+	dsn, err := cfg.GetDSN()
+	if err != nil {
+		return nil, err
+	}
+
+	var dialector gorm.Dialector
+	switch cfg.Driver {
+	case config.DriverMySQL:
+		dialector = mysql.Open(dsn)
+	case config.DriverPostgres:
+		dialector = postgres.Open(dsn)
+	case config.DriverSQLite:
+		dialector = sqlite.Open(dsn)
+	}
+
+	db, err := gorm.Open(dialector, gormConfig)
+	if err != nil {
+		return nil, fmt.Errorf("failed to connect to %s database: %w", cfg.Driver, err)
+	}
+
+	sqlDB, err := db.DB()
+	if err != nil {
+		return nil, fmt.Errorf("failed to get database handle: %w", err)
+	}
+
+	// SQLite не поддерживает параллельную запись, а база в памяти
+	// существует только пока живо её единственное соединение
+	if cfg.Driver == config.DriverSQLite {
+		sqlDB.SetMaxOpenConns(1)
+		sqlDB.SetMaxIdleConns(1)
+		return db, nil
+	}
+
+	sqlDB.SetMaxOpenConns(cfg.MaxOpenConns)
+	sqlDB.SetMaxIdleConns(cfg.MaxIdleConns)
+	sqlDB.SetConnMaxLifetime(cfg.ConnMaxLifetime)
+	sqlDB.SetConnMaxIdleTime(cfg.ConnMaxIdleTime)
+
+	return db, nil
+}
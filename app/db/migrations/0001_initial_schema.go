@@ -0,0 +1,44 @@
+package migrations
+
+import (
+	v1 "federation-backend/app/db/migrations/internal/v1"
+
+	"gorm.io/gorm"
+)
+
+// v1Tables перечислены в порядке зависимостей: при откате удаляются в обратном
+var v1Tables = []interface{}{
+	&v1.User{},
+	&v1.CallBack{},
+	&v1.File{},
+	&v1.Chapter{},
+	&v1.Team{},
+	&v1.Match{},
+	&v1.GalleryItem{},
+	&v1.News{},
+	&v1.Document{},
+}
+
+var v1JoinTables = []string{"match_teams", "gallery_item_images", "news_images"}
+
+// initialSchema создаёт базовую схему. На базах, которые раньше поднимались
+// через AutoMigrate, она только добавляет недостающие таблицы и колонки.
+var initialSchema = Migration{
+	ID: "0001_initial_schema",
+	Up: func(tx *gorm.DB) error {
+		return tx.AutoMigrate(v1Tables...)
+	},
+	Down: func(tx *gorm.DB) error {
+		for _, table := range v1JoinTables {
+			if err := tx.Migrator().DropTable(table); err != nil {
+				return err
+			}
+		}
+		for i := len(v1Tables) - 1; i >= 0; i-- {
+			if err := tx.Migrator().DropTable(v1Tables[i]); err != nil {
+				return err
+			}
+		}
+		return nil
+	},
+}
@@ -0,0 +1,71 @@
+package migrations
+
+import (
+	"time"
+
+	"gorm.io/gorm"
+)
+
+// Снимок затронутых моделей на момент миграции 0002. Имена полей связей
+// совпадают с models.CallBack, поэтому совпадают и имена ограничений
+type callbackWorkflowUser struct {
+	Id uint `gorm:"primaryKey"`
+}
+
+func (callbackWorkflowUser) TableName() string { return "users" }
+
+type callbackWorkflowCallBack struct {
+	Id         uint   `gorm:"primaryKey"`
+	Status     string `gorm:"size:20;not null;default:'new';index"`
+	AssigneeID *uint
+	Assignee   *callbackWorkflowUser  `gorm:"foreignKey:AssigneeID;constraint:OnDelete:SET NULL"`
+	Notes      []callbackWorkflowNote `gorm:"foreignKey:CallbackID;constraint:OnDelete:CASCADE"`
+}
+
+func (callbackWorkflowCallBack) TableName() string { return "call_backs" }
+
+type callbackWorkflowNote struct {
+	Id         uint      `gorm:"primaryKey"`
+	CreatedAt  time.Time `gorm:"autoCreateTime"`
+	UpdatedAt  time.Time `gorm:"autoUpdateTime"`
+	CallbackID uint      `gorm:"not null;index"`
+	AuthorID   *uint
+	Author     *callbackWorkflowUser `gorm:"foreignKey:AuthorID;constraint:OnDelete:SET NULL"`
+	Text       string                `gorm:"type:text;not null"`
+}
+
+func (callbackWorkflowNote) TableName() string { return "callback_notes" }
+
+// callbackWorkflow добавляет заявкам статус, ответственного и заметки.
+// Существующие заявки получают статус new
+var callbackWorkflow = Migration{
+	ID: "0002_callback_workflow",
+	Up: func(tx *gorm.DB) error {
+		return tx.AutoMigrate(&callbackWorkflowCallBack{}, &callbackWorkflowNote{})
+	},
+	Down: func(tx *gorm.DB) error {
+		if err := tx.Migrator().DropTable(&callbackWorkflowNote{}); err != nil {
+			return err
+		}
+		migrator := tx.Migrator()
+		if migrator.HasConstraint(&callbackWorkflowCallBack{}, "Assignee") {
+			if err := migrator.DropConstraint(&callbackWorkflowCallBack{}, "Assignee"); err != nil {
+				return err
+			}
+		}
+		if migrator.HasIndex(&callbackWorkflowCallBack{}, "Status") {
+			if err := migrator.DropIndex(&callbackWorkflowCallBack{}, "Status"); err != nil {
+				return err
+			}
+		}
+		for _, column := range []string{"AssigneeID", "Status"} {
+			if !migrator.HasColumn(&callbackWorkflowCallBack{}, column) {
+				continue
+			}
+			if err := migrator.DropColumn(&callbackWorkflowCallBack{}, column); err != nil {
+				return err
+			}
+		}
+		return nil
+	},
+}
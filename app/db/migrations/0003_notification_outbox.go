@@ -0,0 +1,35 @@
+package migrations
+
+import (
+	"time"
+
+	"gorm.io/gorm"
+)
+
+// Снимок models.OutboxMessage на момент миграции 0003
+type notificationOutboxMessage struct {
+	Id            uint      `gorm:"primaryKey"`
+	CreatedAt     time.Time `gorm:"autoCreateTime"`
+	UpdatedAt     time.Time `gorm:"autoUpdateTime"`
+	Recipient     string    `gorm:"size:255;not null"`
+	Subject       string    `gorm:"size:255;not null"`
+	Body          string    `gorm:"type:text;not null"`
+	Status        string    `gorm:"size:20;not null;default:'pending';index:idx_outbox_due,priority:1"`
+	Attempts      int       `gorm:"not null;default:0"`
+	NextAttemptAt time.Time `gorm:"not null;index:idx_outbox_due,priority:2"`
+	LastError     *string   `gorm:"type:text"`
+	SentAt        *time.Time
+}
+
+func (notificationOutboxMessage) TableName() string { return "outbox_messages" }
+
+// notificationOutbox создаёт очередь исходящих писем
+var notificationOutbox = Migration{
+	ID: "0003_notification_outbox",
+	Up: func(tx *gorm.DB) error {
+		return tx.AutoMigrate(&notificationOutboxMessage{})
+	},
+	Down: func(tx *gorm.DB) error {
+		return tx.Migrator().DropTable(&notificationOutboxMessage{})
+	},
+}
@@ -0,0 +1,16 @@
+package migrations
+
+import "gorm.io/gorm"
+
+// optionalTeamLogo: команда без логотипа теперь хранит NULL в team_logo_id
+// вместо 0, который не проходит внешний ключ. Колонка уже допускает NULL,
+// меняются только данные. Откат ничего не делает: 0 вместо NULL не нужен
+var optionalTeamLogo = Migration{
+	ID: "0004_optional_team_logo",
+	Up: func(tx *gorm.DB) error {
+		return tx.Exec("UPDATE teams SET team_logo_id = NULL WHERE team_logo_id = 0").Error
+	},
+	Down: func(tx *gorm.DB) error {
+		return nil
+	},
+}
@@ -0,0 +1,110 @@
+package migrations
+
+import (
+	"fmt"
+	"time"
+
+	"gorm.io/gorm"
+)
+
+// Снимок моделей на момент миграции 0005
+type documentVersionsUser struct {
+	Id uint `gorm:"primaryKey"`
+}
+
+func (documentVersionsUser) TableName() string { return "users" }
+
+type documentVersionsFile struct {
+	Id        uint      `gorm:"primaryKey"`
+	CreatedAt time.Time `gorm:"autoCreateTime"`
+	UpdatedAt time.Time `gorm:"autoUpdateTime"`
+	Name      string    `gorm:"size:255"`
+	Size      int64
+	Path      string `gorm:"size:500"`
+}
+
+func (documentVersionsFile) TableName() string { return "files" }
+
+type documentVersionsDocument struct {
+	Id       uint                      `gorm:"primaryKey"`
+	Version  uint                      `gorm:"not null;default:0"`
+	Versions []documentVersionsVersion `gorm:"foreignKey:DocumentID;constraint:OnDelete:CASCADE"`
+}
+
+func (documentVersionsDocument) TableName() string { return "documents" }
+
+type documentVersionsVersion struct {
+	Id           uint                 `gorm:"primaryKey"`
+	CreatedAt    time.Time            `gorm:"autoCreateTime"`
+	UpdatedAt    time.Time            `gorm:"autoUpdateTime"`
+	DocumentID   uint                 `gorm:"not null;uniqueIndex:idx_document_version,priority:1"`
+	Number       uint                 `gorm:"not null;uniqueIndex:idx_document_version,priority:2"`
+	FileID       uint                 `gorm:"not null"`
+	File         documentVersionsFile `gorm:"foreignKey:FileID"`
+	UploadedByID *uint
+	UploadedBy   *documentVersionsUser `gorm:"foreignKey:UploadedByID;constraint:OnDelete:SET NULL"`
+	Note         string                `gorm:"type:text"`
+	RestoredFrom *uint
+}
+
+func (documentVersionsVersion) TableName() string { return "document_versions" }
+
+// documentVersions заводит историю версий файлов документов. Текущий файл
+// каждого документа становится его версией 1; если у файла нет записи в
+// files (документ загружали до появления учёта файлов), она создаётся
+var documentVersions = Migration{
+	ID: "0005_document_versions",
+	Up: func(tx *gorm.DB) error {
+		if err := tx.AutoMigrate(&documentVersionsDocument{}, &documentVersionsVersion{}); err != nil {
+			return err
+		}
+
+		var documents []struct {
+			Id        uint
+			CreatedAt time.Time
+			Name      string
+			Size      int64
+			Path      string
+		}
+		err := tx.Table("documents").Select("id, created_at, name, size, path").
+			Where("path <> '' AND version = 0").Scan(&documents).Error
+		if err != nil {
+			return err
+		}
+		for _, document := range documents {
+			var file documentVersionsFile
+			err := tx.Where("path = ?", document.Path).Order("id").Limit(1).Find(&file).Error
+			if err != nil {
+				return err
+			}
+			if file.Id == 0 {
+				file = documentVersionsFile{Name: document.Name, Size: document.Size, Path: document.Path}
+				if err := tx.Create(&file).Error; err != nil {
+					return fmt.Errorf("document %d: %w", document.Id, err)
+				}
+			}
+			version := documentVersionsVersion{
+				CreatedAt:  document.CreatedAt,
+				DocumentID: document.Id,
+				Number:     1,
+				FileID:     file.Id,
+			}
+			if err := tx.Create(&version).Error; err != nil {
+				return fmt.Errorf("document %d: %w", document.Id, err)
+			}
+			if err := tx.Model(&documentVersionsDocument{}).Where("id = ?", document.Id).Update("version", 1).Error; err != nil {
+				return err
+			}
+		}
+		return nil
+	},
+	Down: func(tx *gorm.DB) error {
+		if err := tx.Migrator().DropTable(&documentVersionsVersion{}); err != nil {
+			return err
+		}
+		if tx.Migrator().HasColumn(&documentVersionsDocument{}, "Version") {
+			return tx.Migrator().DropColumn(&documentVersionsDocument{}, "Version")
+		}
+		return nil
+	},
+}
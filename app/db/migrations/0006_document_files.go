@@ -0,0 +1,187 @@
+package migrations
+
+import (
+	"fmt"
+	"time"
+
+	"gorm.io/gorm"
+)
+
+// Снимок моделей на момент миграции 0006. Имена полей связей совпадают с
+// models.Document, поэтому совпадают и имена ограничений
+type documentFilesFile struct {
+	Id uint `gorm:"primaryKey"`
+}
+
+func (documentFilesFile) TableName() string { return "files" }
+
+type documentFilesChapter struct {
+	Id        uint      `gorm:"primaryKey"`
+	CreatedAt time.Time `gorm:"autoCreateTime"`
+	UpdatedAt time.Time `gorm:"autoUpdateTime"`
+	Name      string    `gorm:"size:100"`
+	BarIdx    uint
+	Page      string
+}
+
+func (documentFilesChapter) TableName() string { return "chapters" }
+
+type documentFilesDocument struct {
+	Id        uint `gorm:"primaryKey"`
+	FileID    uint
+	File      documentFilesFile `gorm:"foreignKey:FileID"`
+	ChapterID uint
+	Chapter   documentFilesChapter `gorm:"foreignKey:ChapterID"`
+}
+
+func (documentFilesDocument) TableName() string { return "documents" }
+
+// documentLegacyColumns - колонки, которые документ хранил до 0006: путь и
+// размер файла и категория строкой
+type documentLegacyColumns struct {
+	Size    int64
+	Path    string `gorm:"size:500"`
+	Chapter string
+}
+
+func (documentLegacyColumns) TableName() string { return "documents" }
+
+// documentCategoryNames - названия разделов для категорий, которые были
+// зашиты в enums.Doctype. Прочие значения становятся разделами как есть
+var documentCategoryNames = map[string]string{
+	"rules":       "Правила",
+	"regulations": "Регламенты",
+}
+
+// documentFiles переводит документы на ссылку на files и категории-разделы.
+// Файлом документа становится файл его текущей версии (после 0005 он есть у
+// каждого документа с файлом), категория - раздел страницы documents с тем
+// же названием, недостающие разделы создаются
+var documentFiles = Migration{
+	ID: "0006_document_files",
+	Up: func(tx *gorm.DB) error {
+		migrator := tx.Migrator()
+		for _, column := range []string{"FileID", "ChapterID"} {
+			if migrator.HasColumn(&documentFilesDocument{}, column) {
+				continue
+			}
+			if err := migrator.AddColumn(&documentFilesDocument{}, column); err != nil {
+				return err
+			}
+		}
+		// SQLite добавляет внешний ключ, только пересоздав таблицу, а удаление
+		// старой таблицы documents каскадом удалит версии документов. Поэтому в
+		// SQLite новые колонки остаются без ограничений
+		if tx.Dialector.Name() != "sqlite" {
+			for _, constraint := range []string{"File", "Chapter"} {
+				if migrator.HasConstraint(&documentFilesDocument{}, constraint) {
+					continue
+				}
+				if err := migrator.CreateConstraint(&documentFilesDocument{}, constraint); err != nil {
+					return err
+				}
+			}
+		}
+
+		err := tx.Exec(`UPDATE documents SET file_id = (
+			SELECT v.file_id FROM document_versions v
+			WHERE v.document_id = documents.id AND v.number = documents.version
+		) WHERE version > 0`).Error
+		if err != nil {
+			return err
+		}
+
+		// Без колонки chapter категории уже перенесены: предыдущий запуск
+		// упал, удаляя старые колонки
+		var categories []string
+		if migrator.HasColumn("documents", "chapter") {
+			if err := tx.Table("documents").Distinct().Where("chapter <> ''").Pluck("chapter", &categories).Error; err != nil {
+				return err
+			}
+		}
+		for _, category := range categories {
+			chapter, err := documentChapter(tx, category)
+			if err != nil {
+				return fmt.Errorf("category %q: %w", category, err)
+			}
+			err = tx.Table("documents").Where("chapter = ?", category).Update("chapter_id", chapter.Id).Error
+			if err != nil {
+				return err
+			}
+		}
+
+		return dropDocumentColumns(tx, "size", "path", "chapter")
+	},
+	Down: func(tx *gorm.DB) error {
+		if err := tx.AutoMigrate(&documentLegacyColumns{}); err != nil {
+			return err
+		}
+		migrator := tx.Migrator()
+		// Без file_id данные уже перенесены: предыдущий запуск упал, удаляя
+		// новые колонки
+		if migrator.HasColumn("documents", "file_id") {
+			err := tx.Exec(`UPDATE documents SET
+				size = COALESCE((SELECT f.size FROM files f WHERE f.id = documents.file_id), 0),
+				path = COALESCE((SELECT f.path FROM files f WHERE f.id = documents.file_id), ''),
+				chapter = COALESCE((SELECT c.name FROM chapters c WHERE c.id = documents.chapter_id), '')`).Error
+			if err != nil {
+				return err
+			}
+			for category, name := range documentCategoryNames {
+				if err := tx.Table("documents").Where("chapter = ?", name).Update("chapter", category).Error; err != nil {
+					return err
+				}
+			}
+		}
+
+		for _, constraint := range []string{"File", "Chapter"} {
+			if migrator.HasConstraint(&documentFilesDocument{}, constraint) {
+				if err := migrator.DropConstraint(&documentFilesDocument{}, constraint); err != nil {
+					return err
+				}
+			}
+		}
+		return dropDocumentColumns(tx, "file_id", "chapter_id")
+	},
+}
+
+// dropDocumentColumns удаляет колонки documents через ALTER TABLE DROP COLUMN.
+// Migrator в SQLite пересоздаёт таблицу, что по той же причине, что и в Up,
+// удалило бы версии документов
+func dropDocumentColumns(tx *gorm.DB, columns ...string) error {
+	for _, column := range columns {
+		if !tx.Migrator().HasColumn("documents", column) {
+			continue
+		}
+		if err := tx.Exec("ALTER TABLE documents DROP COLUMN " + column).Error; err != nil {
+			return fmt.Errorf("drop documents.%s: %w", column, err)
+		}
+	}
+	return nil
+}
+
+// documentChapter находит раздел страницы documents для категории или создаёт
+// его последним в панели
+func documentChapter(tx *gorm.DB, category string) (documentFilesChapter, error) {
+	name, ok := documentCategoryNames[category]
+	if !ok {
+		name = category
+	}
+
+	var chapter documentFilesChapter
+	if err := tx.Where("page = ? AND name = ?", "documents", name).Order("id").Limit(1).Find(&chapter).Error; err != nil {
+		return chapter, err
+	}
+	if chapter.Id != 0 {
+		return chapter, nil
+	}
+
+	var last uint
+	err := tx.Model(&documentFilesChapter{}).Where("page = ?", "documents").
+		Select("COALESCE(MAX(bar_idx), 0)").Scan(&last).Error
+	if err != nil {
+		return chapter, err
+	}
+	chapter = documentFilesChapter{Name: name, BarIdx: last + 1, Page: "documents"}
+	return chapter, tx.Create(&chapter).Error
+}
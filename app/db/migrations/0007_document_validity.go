@@ -0,0 +1,50 @@
+package migrations
+
+import (
+	"time"
+
+	"gorm.io/gorm"
+)
+
+// Снимок документа на момент миграции 0007
+type documentValidityDocument struct {
+	Id            uint `gorm:"primaryKey"`
+	EffectiveFrom *time.Time
+	EffectiveTo   *time.Time
+	SupersedesID  *uint
+	Supersedes    *documentValidityDocument `gorm:"foreignKey:SupersedesID;constraint:OnDelete:SET NULL"`
+}
+
+func (documentValidityDocument) TableName() string { return "documents" }
+
+// documentValidity добавляет документам период действия и ссылку на
+// заменённую редакцию. Существующие документы действуют без ограничений
+var documentValidity = Migration{
+	ID: "0007_document_validity",
+	Up: func(tx *gorm.DB) error {
+		migrator := tx.Migrator()
+		for _, column := range []string{"EffectiveFrom", "EffectiveTo", "SupersedesID"} {
+			if migrator.HasColumn(&documentValidityDocument{}, column) {
+				continue
+			}
+			if err := migrator.AddColumn(&documentValidityDocument{}, column); err != nil {
+				return err
+			}
+		}
+		// Как и в 0006: в SQLite ограничение пересоздало бы documents и
+		// каскадом удалило версии документов
+		if tx.Dialector.Name() != "sqlite" && !migrator.HasConstraint(&documentValidityDocument{}, "Supersedes") {
+			return migrator.CreateConstraint(&documentValidityDocument{}, "Supersedes")
+		}
+		return nil
+	},
+	Down: func(tx *gorm.DB) error {
+		migrator := tx.Migrator()
+		if migrator.HasConstraint(&documentValidityDocument{}, "Supersedes") {
+			if err := migrator.DropConstraint(&documentValidityDocument{}, "Supersedes"); err != nil {
+				return err
+			}
+		}
+		return dropDocumentColumns(tx, "effective_from", "effective_to", "supersedes_id")
+	},
+}
@@ -0,0 +1,44 @@
+package migrations
+
+import (
+	"time"
+
+	"gorm.io/gorm"
+)
+
+// Снимок модели на момент миграции 0008
+type documentContentsFile struct {
+	Id uint `gorm:"primaryKey"`
+}
+
+func (documentContentsFile) TableName() string { return "files" }
+
+type documentContentsContent struct {
+	Id         uint                  `gorm:"primaryKey"`
+	CreatedAt  time.Time             `gorm:"autoCreateTime"`
+	UpdatedAt  time.Time             `gorm:"autoUpdateTime"`
+	FileID     uint                  `gorm:"not null;uniqueIndex"`
+	File       *documentContentsFile `gorm:"foreignKey:FileID;constraint:OnDelete:CASCADE"`
+	PageCount  int
+	Title      string `gorm:"size:500"`
+	Author     string `gorm:"size:255"`
+	Text       string `gorm:"size:1048576"`
+	SearchText string `gorm:"size:1048576"`
+	PreviewID  *uint
+	Preview    *documentContentsFile `gorm:"foreignKey:PreviewID;constraint:OnDelete:SET NULL"`
+}
+
+func (documentContentsContent) TableName() string { return "document_contents" }
+
+// documentContents заводит таблицу сведений, извлечённых из файлов
+// документов. Уже загруженные документы разбираются не миграцией, а
+// POST /api/admin/documents/reindex
+var documentContents = Migration{
+	ID: "0008_document_contents",
+	Up: func(tx *gorm.DB) error {
+		return tx.AutoMigrate(&documentContentsContent{})
+	},
+	Down: func(tx *gorm.DB) error {
+		return tx.Migrator().DropTable(&documentContentsContent{})
+	},
+}
@@ -0,0 +1,54 @@
+package migrations
+
+import "gorm.io/gorm"
+
+// Снимок раздела на момент миграции 0009
+type chapterTreeChapter struct {
+	Id       uint                `gorm:"primaryKey"`
+	ParentID *uint               `gorm:"index"`
+	Parent   *chapterTreeChapter `gorm:"foreignKey:ParentID"`
+}
+
+func (chapterTreeChapter) TableName() string { return "chapters" }
+
+// chapterTree делает разделы вложенными. Существующие разделы остаются
+// верхнего уровня, их bar_idx уже пронумерованы по странице
+var chapterTree = Migration{
+	ID: "0009_chapter_tree",
+	Up: func(tx *gorm.DB) error {
+		migrator := tx.Migrator()
+		if !migrator.HasColumn(&chapterTreeChapter{}, "ParentID") {
+			if err := migrator.AddColumn(&chapterTreeChapter{}, "ParentID"); err != nil {
+				return err
+			}
+		}
+		if !migrator.HasIndex(&chapterTreeChapter{}, "ParentID") {
+			if err := migrator.CreateIndex(&chapterTreeChapter{}, "ParentID"); err != nil {
+				return err
+			}
+		}
+		// Как и для documents в 0006: в SQLite ограничение пересоздало бы
+		// chapters вместе с зависящими от неё таблицами
+		if tx.Dialector.Name() != "sqlite" && !migrator.HasConstraint(&chapterTreeChapter{}, "Parent") {
+			return migrator.CreateConstraint(&chapterTreeChapter{}, "Parent")
+		}
+		return nil
+	},
+	Down: func(tx *gorm.DB) error {
+		migrator := tx.Migrator()
+		if migrator.HasConstraint(&chapterTreeChapter{}, "Parent") {
+			if err := migrator.DropConstraint(&chapterTreeChapter{}, "Parent"); err != nil {
+				return err
+			}
+		}
+		if migrator.HasIndex(&chapterTreeChapter{}, "ParentID") {
+			if err := migrator.DropIndex(&chapterTreeChapter{}, "ParentID"); err != nil {
+				return err
+			}
+		}
+		if !migrator.HasColumn("chapters", "parent_id") {
+			return nil
+		}
+		return tx.Exec("ALTER TABLE chapters DROP COLUMN parent_id").Error
+	},
+}
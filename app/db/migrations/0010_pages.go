@@ -0,0 +1,70 @@
+package migrations
+
+import (
+	"time"
+
+	"gorm.io/gorm"
+)
+
+// Снимок страницы на момент миграции 0010
+type pagesPage struct {
+	Id        uint      `gorm:"primaryKey"`
+	CreatedAt time.Time `gorm:"autoCreateTime"`
+	UpdatedAt time.Time `gorm:"autoUpdateTime"`
+	Name      string    `gorm:"size:100;not null"`
+	Slug      string    `gorm:"size:50;not null;uniqueIndex"`
+	Kind      string    `gorm:"size:20;not null"`
+}
+
+func (pagesPage) TableName() string { return "pages" }
+
+// pageDefaults - страницы, которые раньше были зашиты в enums.Page
+var pageDefaults = []pagesPage{
+	{Name: "Новости", Slug: "news", Kind: "news"},
+	{Name: "Галерея", Slug: "gallery", Kind: "gallery"},
+	{Name: "Документы", Slug: "documents", Kind: "documents"},
+}
+
+// pages заводит таблицу страниц с тремя прежними страницами. Если у
+// разделов встречается другая страница, она тоже заводится - с видом
+// news, его можно поменять, пока в разделах нет записей
+var pages = Migration{
+	ID: "0010_pages",
+	Up: func(tx *gorm.DB) error {
+		if err := tx.AutoMigrate(&pagesPage{}); err != nil {
+			return err
+		}
+
+		var used []string
+		if err := tx.Table("chapters").Distinct().Where("page <> ''").Pluck("page", &used).Error; err != nil {
+			return err
+		}
+		rows := append([]pagesPage{}, pageDefaults...)
+		for _, slug := range used {
+			known := false
+			for _, page := range pageDefaults {
+				known = known || page.Slug == slug
+			}
+			if !known {
+				rows = append(rows, pagesPage{Name: slug, Slug: slug, Kind: "news"})
+			}
+		}
+
+		for _, row := range rows {
+			var count int64
+			if err := tx.Model(&pagesPage{}).Where("slug = ?", row.Slug).Count(&count).Error; err != nil {
+				return err
+			}
+			if count > 0 {
+				continue
+			}
+			if err := tx.Create(&row).Error; err != nil {
+				return err
+			}
+		}
+		return nil
+	},
+	Down: func(tx *gorm.DB) error {
+		return tx.Migrator().DropTable(&pagesPage{})
+	},
+}
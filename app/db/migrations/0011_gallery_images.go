@@ -0,0 +1,66 @@
+package migrations
+
+import (
+	"fmt"
+
+	"gorm.io/gorm"
+)
+
+// Снимок изображения альбома на момент миграции 0011
+type galleryImagesImage struct {
+	GalleryItemID uint   `gorm:"primaryKey;autoIncrement:false"`
+	FileID        uint   `gorm:"primaryKey;autoIncrement:false"`
+	Position      uint   `gorm:"not null;default:0"`
+	Caption       string `gorm:"size:500;not null;default:''"`
+	Photographer  string `gorm:"size:255;not null;default:''"`
+}
+
+func (galleryImagesImage) TableName() string { return "gallery_item_images" }
+
+// galleryImages добавляет изображениям альбомов место, подпись и автора.
+// Прежний порядок не хранился, поэтому изображения нумеруются по id файла
+var galleryImages = Migration{
+	ID: "0011_gallery_images",
+	Up: func(tx *gorm.DB) error {
+		migrator := tx.Migrator()
+		for _, column := range []string{"Position", "Caption", "Photographer"} {
+			if migrator.HasColumn(&galleryImagesImage{}, column) {
+				continue
+			}
+			if err := migrator.AddColumn(&galleryImagesImage{}, column); err != nil {
+				return err
+			}
+		}
+
+		var images []galleryImagesImage
+		if err := tx.Order("gallery_item_id, file_id").Find(&images).Error; err != nil {
+			return err
+		}
+		var item, position uint
+		for _, image := range images {
+			if image.GalleryItemID != item {
+				item, position = image.GalleryItemID, 0
+			}
+			position++
+			err := tx.Model(&galleryImagesImage{}).
+				Where("gallery_item_id = ? AND file_id = ?", image.GalleryItemID, image.FileID).
+				Update("position", position).Error
+			if err != nil {
+				return err
+			}
+		}
+		return nil
+	},
+	Down: func(tx *gorm.DB) error {
+		// Как и в 0009, без пересоздания таблицы Migrator-ом
+		for _, column := range []string{"position", "caption", "photographer"} {
+			if !tx.Migrator().HasColumn("gallery_item_images", column) {
+				continue
+			}
+			if err := tx.Exec("ALTER TABLE gallery_item_images DROP COLUMN " + column).Error; err != nil {
+				return fmt.Errorf("drop gallery_item_images.%s: %w", column, err)
+			}
+		}
+		return nil
+	},
+}
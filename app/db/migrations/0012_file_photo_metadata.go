@@ -0,0 +1,49 @@
+package migrations
+
+import (
+	"fmt"
+	"time"
+
+	"gorm.io/gorm"
+)
+
+// Снимок файла на момент миграции 0012
+type filePhotoMetadataFile struct {
+	Id      uint `gorm:"primaryKey"`
+	Width   *int
+	Height  *int
+	TakenAt *time.Time
+	Camera  string `gorm:"size:255;not null;default:''"`
+}
+
+func (filePhotoMetadataFile) TableName() string { return "files" }
+
+// filePhotoMetadata добавляет файлам размеры, время съёмки и камеру из EXIF.
+// Уже загруженные фотографии остаются без них
+var filePhotoMetadata = Migration{
+	ID: "0012_file_photo_metadata",
+	Up: func(tx *gorm.DB) error {
+		migrator := tx.Migrator()
+		for _, column := range []string{"Width", "Height", "TakenAt", "Camera"} {
+			if migrator.HasColumn(&filePhotoMetadataFile{}, column) {
+				continue
+			}
+			if err := migrator.AddColumn(&filePhotoMetadataFile{}, column); err != nil {
+				return err
+			}
+		}
+		return nil
+	},
+	Down: func(tx *gorm.DB) error {
+		// files пересоздавать нельзя: на неё ссылаются почти все таблицы
+		for _, column := range []string{"width", "height", "taken_at", "camera"} {
+			if !tx.Migrator().HasColumn("files", column) {
+				continue
+			}
+			if err := tx.Exec("ALTER TABLE files DROP COLUMN " + column).Error; err != nil {
+				return fmt.Errorf("drop files.%s: %w", column, err)
+			}
+		}
+		return nil
+	},
+}
@@ -0,0 +1,83 @@
+package migrations
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+
+	"gorm.io/gorm"
+)
+
+const usage = "usage: migrate up|down|status|help"
+
+// help - справка `migrate help`
+const help = usage + `
+
+  up      apply all pending migrations in order
+  down    roll back the last applied migration
+  status  show whether each migration is applied
+
+Each migration runs in a transaction together with its schema_migrations row.
+MySQL commits DDL statements implicitly, so a migration that fails there may
+leave the schema partly changed while the migration stays unapplied.
+Migration steps skip columns, indexes and constraints that already exist or
+are already gone, so running the same command again usually finishes the job.
+If it still fails, repair the schema by hand to match the migration in
+app/db/migrations and run the command again.
+`
+
+// Run выполняет подкоманду `migrate`: up применяет все новые миграции,
+// down откатывает последнюю, status печатает состояние каждой версии,
+// help - справку.
+func Run(ctx context.Context, db *gorm.DB, args []string, out io.Writer) error {
+	if len(args) != 1 {
+		return errors.New(usage)
+	}
+
+	migrator := NewMigrator(db)
+
+	switch args[0] {
+	case "up":
+		applied, err := migrator.Up(ctx)
+		for _, id := range applied {
+			fmt.Fprintf(out, "applied %s\n", id)
+		}
+		if err != nil {
+			return err
+		}
+		if len(applied) == 0 {
+			fmt.Fprintln(out, "schema is up to date")
+		}
+		return nil
+
+	case "down":
+		id, err := migrator.Down(ctx)
+		if err != nil {
+			return err
+		}
+		fmt.Fprintf(out, "rolled back %s\n", id)
+		return nil
+
+	case "status":
+		statuses, err := migrator.Status(ctx)
+		if err != nil {
+			return err
+		}
+		for _, status := range statuses {
+			if status.Applied {
+				fmt.Fprintf(out, "[x] %s (applied %s)\n", status.ID, status.AppliedAt.Format("2006-01-02 15:04:05"))
+			} else {
+				fmt.Fprintf(out, "[ ] %s\n", status.ID)
+			}
+		}
+		return nil
+
+	case "help":
+		fmt.Fprint(out, help)
+		return nil
+
+	default:
+		return fmt.Errorf("unknown migrate command %q, %s", args[0], usage)
+	}
+}
@@ -0,0 +1,107 @@
+// Package v1 - снимок моделей на момент перехода с AutoMigrate на
+// версионированные миграции. Типы названы так же, как в пакете models,
+// чтобы имена таблиц и ограничений совпадали с созданными ранее через AutoMigrate.
+// Пакет не меняется: дальнейшие изменения схемы описываются новыми миграциями.
+package v1
+
+import "time"
+
+type Model struct {
+	Id        uint      `gorm:"primaryKey"`
+	CreatedAt time.Time `gorm:"autoCreateTime"`
+	UpdatedAt time.Time `gorm:"autoUpdateTime"`
+}
+
+type User struct {
+	Model
+	Username string `gorm:"uniqueIndex;size:255"`
+	Password string `gorm:"size:255"`
+}
+
+func (User) TableName() string { return "users" }
+
+type CallBack struct {
+	Model
+	Name         string  `gorm:"size:100"`
+	Phone        string  `gorm:"size:20"`
+	Email        *string `gorm:"size:255"`
+	TeamName     *string `gorm:"size:255"`
+	CallbackType string
+}
+
+func (CallBack) TableName() string { return "call_backs" }
+
+type File struct {
+	Model
+	Name string `gorm:"size:255"`
+	Size int64
+	Path string `gorm:"size:500"`
+}
+
+func (File) TableName() string { return "files" }
+
+type Chapter struct {
+	Model
+	Name   string `gorm:"size:100"`
+	BarIdx uint
+	Page   string
+}
+
+func (Chapter) TableName() string { return "chapters" }
+
+type Team struct {
+	Model
+	TeamName   string `gorm:"size:255"`
+	Sex        string `gorm:"default:'male'"`
+	TeamLogoID uint
+	TeamLogo   File `gorm:"foreignKey:TeamLogoID"`
+}
+
+func (Team) TableName() string { return "teams" }
+
+type Match struct {
+	Model
+	League string `gorm:"size:100"`
+	Date   time.Time
+	Sex    string
+	City   string
+	Teams  []*Team `gorm:"many2many:match_teams;joinForeignKey:MatchID;joinReferences:TeamID;constraint:OnDelete:CASCADE;"`
+}
+
+func (Match) TableName() string { return "matches" }
+
+type GalleryItem struct {
+	Model
+	Name      string
+	Date      time.Time
+	PreviewID uint
+	Preview   File   `gorm:"foreignKey:PreviewID"`
+	Images    []File `gorm:"many2many:gallery_item_images;joinForeignKey:GalleryItemID;joinReferences:FileID"`
+	ChapterID uint
+	Chapter   Chapter `gorm:"foreignKey:ChapterID"`
+}
+
+func (GalleryItem) TableName() string { return "gallery_items" }
+
+type News struct {
+	Model
+	Heading     string `gorm:"size:255"`
+	Description string `gorm:"type:text"`
+	Images      []File `gorm:"many2many:news_images;joinForeignKey:NewsID;joinReferences:FileID"`
+	Date        time.Time
+	ChapterID   uint
+	Chapter     Chapter `gorm:"foreignKey:ChapterID"`
+	Links       string
+}
+
+func (News) TableName() string { return "news" }
+
+type Document struct {
+	Model
+	Name    string `gorm:"size:255"`
+	Size    int64
+	Path    string `gorm:"size:500"`
+	Chapter string
+}
+
+func (Document) TableName() string { return "documents" }
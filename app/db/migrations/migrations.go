@@ -0,0 +1,18 @@
+package migrations
+
+// all - упорядоченный список миграций схемы.
+// Новые миграции добавляются только в конец, уже выпущенные не меняются.
+var all = []Migration{
+	initialSchema,
+	callbackWorkflow,
+	notificationOutbox,
+	optionalTeamLogo,
+	documentVersions,
+	documentFiles,
+	documentValidity,
+	documentContents,
+	chapterTree,
+	pages,
+	galleryImages,
+	filePhotoMetadata,
+}
@@ -0,0 +1,181 @@
+package migrations
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"time"
+
+	"gorm.io/gorm"
+)
+
+// Migration описывает одну версию схемы базы данных.
+// ID задаёт порядок применения, поэтому начинается с номера версии.
+type Migration struct {
+	ID   string
+	Up   func(tx *gorm.DB) error
+	Down func(tx *gorm.DB) error
+}
+
+// SchemaMigration - запись о применённой миграции
+type SchemaMigration struct {
+	ID        string    `gorm:"primaryKey;size:255"`
+	AppliedAt time.Time `gorm:"not null"`
+}
+
+func (SchemaMigration) TableName() string {
+	return "schema_migrations"
+}
+
+// Status - состояние одной миграции
+type Status struct {
+	ID        string     `json:"id"`
+	Applied   bool       `json:"applied"`
+	AppliedAt *time.Time `json:"applied_at,omitempty"`
+}
+
+var ErrNothingToRollback = errors.New("no applied migrations to roll back")
+
+type Migrator struct {
+	db         *gorm.DB
+	migrations []Migration
+}
+
+func NewMigrator(db *gorm.DB) *Migrator {
+	return &Migrator{
+		db:         db,
+		migrations: all,
+	}
+}
+
+// Up применяет все ещё не применённые миграции по порядку и возвращает их ID.
+// Транзакция откатывает упавшую миграцию целиком только там, где DDL
+// транзакционен (SQLite, PostgreSQL); в MySQL шаги миграций сами пропускают
+// уже сделанное
+func (m *Migrator) Up(ctx context.Context) ([]string, error) {
+	if err := m.db.WithContext(ctx).AutoMigrate(&SchemaMigration{}); err != nil {
+		return nil, fmt.Errorf("failed to prepare schema_migrations table: %w", err)
+	}
+
+	applied, err := m.applied(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	var done []string
+	for _, migration := range m.migrations {
+		if _, ok := applied[migration.ID]; ok {
+			continue
+		}
+
+		err := m.db.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+			if err := migration.Up(tx); err != nil {
+				return err
+			}
+			return tx.Create(&SchemaMigration{ID: migration.ID, AppliedAt: time.Now()}).Error
+		})
+		if err != nil {
+			return done, fmt.Errorf("failed to apply migration %s: %w", migration.ID, err)
+		}
+		done = append(done, migration.ID)
+	}
+
+	return done, nil
+}
+
+// Down откатывает последнюю применённую миграцию
+func (m *Migrator) Down(ctx context.Context) (string, error) {
+	applied, err := m.applied(ctx)
+	if err != nil {
+		return "", err
+	}
+
+	for i := len(m.migrations) - 1; i >= 0; i-- {
+		migration := m.migrations[i]
+		if _, ok := applied[migration.ID]; !ok {
+			continue
+		}
+
+		err := m.db.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+			if migration.Down != nil {
+				if err := migration.Down(tx); err != nil {
+					return err
+				}
+			}
+			return tx.Delete(&SchemaMigration{ID: migration.ID}).Error
+		})
+		if err != nil {
+			return "", fmt.Errorf("failed to roll back migration %s: %w", migration.ID, err)
+		}
+		return migration.ID, nil
+	}
+
+	return "", ErrNothingToRollback
+}
+
+// Status возвращает состояние всех известных миграций
+func (m *Migrator) Status(ctx context.Context) ([]Status, error) {
+	applied, err := m.applied(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	statuses := make([]Status, 0, len(m.migrations))
+	for _, migration := range m.migrations {
+		status := Status{ID: migration.ID}
+		if record, ok := applied[migration.ID]; ok {
+			status.Applied = true
+			status.AppliedAt = &record.AppliedAt
+		}
+		statuses = append(statuses, status)
+	}
+
+	return statuses, nil
+}
+
+// Pending возвращает ID миграций, которые ещё не были применены
+func (m *Migrator) Pending(ctx context.Context) ([]string, error) {
+	statuses, err := m.Status(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	var pending []string
+	for _, status := range statuses {
+		if !status.Applied {
+			pending = append(pending, status.ID)
+		}
+	}
+	return pending, nil
+}
+
+// EnsureUpToDate возвращает ошибку, если схема базы отстаёт от кода
+func (m *Migrator) EnsureUpToDate(ctx context.Context) error {
+	pending, err := m.Pending(ctx)
+	if err != nil {
+		return err
+	}
+	if len(pending) > 0 {
+		return fmt.Errorf("database schema is not up to date, %d pending migration(s) %v: run `migrate up` first", len(pending), pending)
+	}
+	return nil
+}
+
+func (m *Migrator) applied(ctx context.Context) (map[string]SchemaMigration, error) {
+	db := m.db.WithContext(ctx)
+	// Таблицы ещё нет - значит не применено ни одной миграции
+	if !db.Migrator().HasTable(&SchemaMigration{}) {
+		return map[string]SchemaMigration{}, nil
+	}
+
+	var records []SchemaMigration
+	if err := db.Find(&records).Error; err != nil {
+		return nil, fmt.Errorf("failed to read applied migrations: %w", err)
+	}
+
+	result := make(map[string]SchemaMigration, len(records))
+	for _, record := range records {
+		result[record.ID] = record
+	}
+	return result, nil
+}
@@ -0,0 +1,254 @@
+package migrations
+
+import (
+	"context"
+	"errors"
+	"path/filepath"
+	"slices"
+	"testing"
+
+	"gorm.io/driver/sqlite"
+	"gorm.io/gorm"
+	"gorm.io/gorm/logger"
+)
+
+func openSQLite(t *testing.T) *gorm.DB {
+	t.Helper()
+	db, err := gorm.Open(sqlite.Open(filepath.Join(t.TempDir(), "test.db")), &gorm.Config{Logger: logger.Discard})
+	if err != nil {
+		t.Fatalf("open sqlite: %v", err)
+	}
+	sqlDB, err := db.DB()
+	if err != nil {
+		t.Fatalf("sql db: %v", err)
+	}
+	sqlDB.SetMaxOpenConns(1)
+	t.Cleanup(func() { sqlDB.Close() })
+	return db
+}
+
+// createTable - миграция id, создающая таблицу t_<id>
+func createTable(id string) Migration {
+	table := tableOf(id)
+	return Migration{
+		ID:   id,
+		Up:   func(tx *gorm.DB) error { return tx.Exec("CREATE TABLE " + table + " (id INTEGER PRIMARY KEY)").Error },
+		Down: func(tx *gorm.DB) error { return tx.Exec("DROP TABLE " + table).Error },
+	}
+}
+
+func tableOf(id string) string {
+	return "t_" + id
+}
+
+func appliedIDs(t *testing.T, m *Migrator) []string {
+	t.Helper()
+	statuses, err := m.Status(context.Background())
+	if err != nil {
+		t.Fatalf("status: %v", err)
+	}
+	var ids []string
+	for _, status := range statuses {
+		if status.Applied != (status.AppliedAt != nil) {
+			t.Fatalf("status %s: applied %v with applied_at %v", status.ID, status.Applied, status.AppliedAt)
+		}
+		if status.Applied {
+			ids = append(ids, status.ID)
+		}
+	}
+	return ids
+}
+
+var errBoom = errors.New("boom")
+
+func TestMigrator(t *testing.T) {
+	failing := Migration{
+		ID: "0003_failing",
+		Up: func(tx *gorm.DB) error {
+			if err := tx.Exec("CREATE TABLE " + tableOf("0003_failing") + " (id INTEGER)").Error; err != nil {
+				return err
+			}
+			return errBoom
+		},
+	}
+
+	tests := []struct {
+		name       string
+		migrations []Migration
+		// steps - "up" или "down" по порядку
+		steps       []string
+		wantErr     error
+		wantApplied []string
+		// wantTables и noTables - ID миграций, чьи таблицы должны быть или нет
+		wantTables []string
+		noTables   []string
+	}{
+		{
+			name:       "status before up",
+			migrations: []Migration{createTable("0001_a"), createTable("0002_b")},
+		},
+		{
+			name:        "up applies all in order",
+			migrations:  []Migration{createTable("0001_a"), createTable("0002_b")},
+			steps:       []string{"up"},
+			wantApplied: []string{"0001_a", "0002_b"},
+			wantTables:  []string{"0001_a", "0002_b"},
+		},
+		{
+			name:        "up twice is a no-op",
+			migrations:  []Migration{createTable("0001_a"), createTable("0002_b")},
+			steps:       []string{"up", "up"},
+			wantApplied: []string{"0001_a", "0002_b"},
+		},
+		{
+			name:        "down rolls back the last one",
+			migrations:  []Migration{createTable("0001_a"), createTable("0002_b")},
+			steps:       []string{"up", "down"},
+			wantApplied: []string{"0001_a"},
+			wantTables:  []string{"0001_a"},
+			noTables:    []string{"0002_b"},
+		},
+		{
+			name:       "down past the first one",
+			migrations: []Migration{createTable("0001_a")},
+			steps:      []string{"up", "down", "down"},
+			wantErr:    ErrNothingToRollback,
+			noTables:   []string{"0001_a"},
+		},
+		{
+			name:       "down on an empty database",
+			migrations: []Migration{createTable("0001_a")},
+			steps:      []string{"down"},
+			wantErr:    ErrNothingToRollback,
+		},
+		{
+			name:        "failed migration is rolled back and stops up",
+			migrations:  []Migration{createTable("0001_a"), createTable("0002_b"), failing, createTable("0004_c")},
+			steps:       []string{"up"},
+			wantErr:     errBoom,
+			wantApplied: []string{"0001_a", "0002_b"},
+			noTables:    []string{"0003_failing", "0004_c"},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			db := openSQLite(t)
+			m := &Migrator{db: db, migrations: tt.migrations}
+			ctx := context.Background()
+
+			var err error
+			for _, step := range tt.steps {
+				switch step {
+				case "up":
+					_, err = m.Up(ctx)
+				case "down":
+					_, err = m.Down(ctx)
+				}
+			}
+			if !errors.Is(err, tt.wantErr) {
+				t.Fatalf("error = %v, want %v", err, tt.wantErr)
+			}
+
+			if got := appliedIDs(t, m); !slices.Equal(got, tt.wantApplied) {
+				t.Errorf("applied = %v, want %v", got, tt.wantApplied)
+			}
+			for _, table := range tt.wantTables {
+				if !db.Migrator().HasTable(tableOf(table)) {
+					t.Errorf("table %s is missing", table)
+				}
+			}
+			for _, table := range tt.noTables {
+				if db.Migrator().HasTable(tableOf(table)) {
+					t.Errorf("table %s should not exist", table)
+				}
+			}
+		})
+	}
+}
+
+func TestMigratorUpReturnsApplied(t *testing.T) {
+	m := &Migrator{db: openSQLite(t), migrations: []Migration{createTable("0001_a")}}
+	ctx := context.Background()
+
+	if err := m.EnsureUpToDate(ctx); err == nil {
+		t.Fatal("EnsureUpToDate before up: want error")
+	}
+	done, err := m.Up(ctx)
+	if err != nil || !slices.Equal(done, []string{"0001_a"}) {
+		t.Fatalf("first up = %v, %v", done, err)
+	}
+	done, err = m.Up(ctx)
+	if err != nil || len(done) != 0 {
+		t.Fatalf("second up = %v, %v", done, err)
+	}
+	if err := m.EnsureUpToDate(ctx); err != nil {
+		t.Fatalf("EnsureUpToDate after up: %v", err)
+	}
+
+	m.migrations = append(m.migrations, createTable("0002_b"))
+	pending, err := m.Pending(ctx)
+	if err != nil || !slices.Equal(pending, []string{"0002_b"}) {
+		t.Fatalf("pending = %v, %v", pending, err)
+	}
+}
+
+// Все миграции схемы применяются и откатываются на SQLite
+func TestSchemaMigrationsRoundTrip(t *testing.T) {
+	m := NewMigrator(openSQLite(t))
+	ctx := context.Background()
+
+	var ids []string
+	for _, migration := range all {
+		ids = append(ids, migration.ID)
+	}
+
+	done, err := m.Up(ctx)
+	if err != nil {
+		t.Fatalf("up: %v", err)
+	}
+	if !slices.Equal(done, ids) {
+		t.Fatalf("up applied %v, want %v", done, ids)
+	}
+
+	for i := len(ids) - 1; i >= 0; i-- {
+		id, err := m.Down(ctx)
+		if err != nil {
+			t.Fatalf("down %s: %v", ids[i], err)
+		}
+		if id != ids[i] {
+			t.Fatalf("down rolled back %s, want %s", id, ids[i])
+		}
+	}
+	if _, err := m.Down(ctx); !errors.Is(err, ErrNothingToRollback) {
+		t.Fatalf("down on empty schema = %v, want ErrNothingToRollback", err)
+	}
+
+	if _, err := m.Up(ctx); err != nil {
+		t.Fatalf("up after full rollback: %v", err)
+	}
+	if err := m.EnsureUpToDate(ctx); err != nil {
+		t.Fatal(err)
+	}
+}
+
+// В MySQL DDL фиксируется сразу, поэтому упавшая миграция может оказаться
+// применённой частично. Каждый шаг пропускает уже сделанное, и повторный
+// запуск Up или Down проходит
+func TestSchemaMigrationsRerun(t *testing.T) {
+	db := openSQLite(t)
+	for _, migration := range all {
+		for run := 1; run <= 2; run++ {
+			if err := migration.Up(db); err != nil {
+				t.Fatalf("up %s, run %d: %v", migration.ID, run, err)
+			}
+		}
+	}
+	for i := len(all) - 1; i >= 0; i-- {
+		for run := 1; run <= 2; run++ {
+			if err := all[i].Down(db); err != nil {
+				t.Fatalf("down %s, run %d: %v", all[i].ID, run, err)
+			}
+		}
+	}
+}
@@ -5,14 +5,53 @@ type CallbackType string
 
 const (
 	TeamApplication CallbackType = "team_application"
-	CallbackRequest              = "callback_request"
+	CallbackRequest CallbackType = "callback_request"
 )
 
+// CallbackStatus - этап обработки заявки сотрудниками
+type CallbackStatus string
+
+const (
+	CallbackStatusNew        CallbackStatus = "new"
+	CallbackStatusInProgress CallbackStatus = "in_progress"
+	CallbackStatusContacted  CallbackStatus = "contacted"
+	CallbackStatusAccepted   CallbackStatus = "accepted"
+	CallbackStatusRejected   CallbackStatus = "rejected"
+	CallbackStatusSpam       CallbackStatus = "spam"
+)
+
+var CallbackTypes = []CallbackType{TeamApplication, CallbackRequest}
+
+var CallbackStatuses = []CallbackStatus{
+	CallbackStatusNew,
+	CallbackStatusInProgress,
+	CallbackStatusContacted,
+	CallbackStatusAccepted,
+	CallbackStatusRejected,
+	CallbackStatusSpam,
+}
+
 type CallBack struct {
 	Model
-	Name         string       `json:"name" gorm:"size:100"`
-	Phone        string       `json:"phone" gorm:"size:20"`
-	Email        *string      `json:"email" gorm:"size:255"`
-	TeamName     *string      `json:"team_name" gorm:"size:255"`
-	CallbackType CallbackType `json:"callback_type"`
+	Name         string         `json:"name" gorm:"size:100"`
+	Phone        string         `json:"phone" gorm:"size:20"`
+	Email        *string        `json:"email" gorm:"size:255"`
+	TeamName     *string        `json:"team_name" gorm:"size:255"`
+	CallbackType CallbackType   `json:"callback_type"`
+	Status       CallbackStatus `json:"status" gorm:"size:20;not null;default:'new';index"`
+	// AssigneeID - сотрудник, который ведёт заявку
+	AssigneeID *uint `json:"assignee_id"`
+	Assignee   *User `json:"-" gorm:"foreignKey:AssigneeID;constraint:OnDelete:SET NULL"`
+	// Notes - внутренние заметки, в JSON заявки не попадают: их отдаёт только
+	// GET /callback/:id/notes
+	Notes []CallbackNote `json:"-" gorm:"foreignKey:CallbackID;constraint:OnDelete:CASCADE"`
+}
+
+// CallbackNote - внутренняя заметка сотрудника по заявке
+type CallbackNote struct {
+	Model
+	CallbackID uint   `json:"callback_id" gorm:"not null;index"`
+	AuthorID   *uint  `json:"author_id"`
+	Author     *User  `json:"-" gorm:"foreignKey:AuthorID;constraint:OnDelete:SET NULL"`
+	Text       string `json:"text" gorm:"type:text;not null"`
 }
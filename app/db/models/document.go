@@ -1,11 +1,63 @@
 // document.go
 package models
 
-import "federation-backend/app/db/models/enums"
+import "time"
 
+// Document - опубликованный документ. Категория - раздел (Chapter) со страницей documents
 type Document struct {
 	Model
-	File
-	Name    string        `gorm:"size:255"`
-	Chapter enums.Doctype `json:"chapter"`
+	Name string `json:"name" gorm:"size:255"`
+	// FileID - файл текущей версии
+	FileID    uint    `json:"file_id"`
+	File      File    `json:"file" gorm:"foreignKey:FileID"`
+	ChapterID uint    `json:"chapter_id"`
+	Chapter   Chapter `json:"chapter" gorm:"foreignKey:ChapterID"`
+	// EffectiveFrom и EffectiveTo - период действия редакции, [from, to).
+	// nil - без ограничения с этой стороны
+	EffectiveFrom *time.Time `json:"effective_from"`
+	EffectiveTo   *time.Time `json:"effective_to"`
+	// SupersedesID - предыдущая редакция, которую заменяет документ
+	SupersedesID *uint     `json:"supersedes_id"`
+	Supersedes   *Document `json:"-" gorm:"foreignKey:SupersedesID;constraint:OnDelete:SET NULL"`
+	// Content - сведения, извлечённые из файла текущей версии
+	Content *DocumentContent `json:"content,omitempty" gorm:"foreignKey:FileID;references:FileID"`
+	// Version - номер текущей версии
+	Version  uint              `json:"version" gorm:"not null;default:0"`
+	Versions []DocumentVersion `json:"-" gorm:"foreignKey:DocumentID;constraint:OnDelete:CASCADE"`
+}
+
+// DocumentVersion - загруженная редакция файла документа. Старые версии
+// не удаляются при замене файла, к любой можно вернуться
+type DocumentVersion struct {
+	Model
+	DocumentID uint `json:"document_id" gorm:"not null;uniqueIndex:idx_document_version,priority:1"`
+	// Number - порядковый номер версии внутри документа, с 1
+	Number uint `json:"number" gorm:"not null;uniqueIndex:idx_document_version,priority:2"`
+	FileID uint `json:"file_id" gorm:"not null"`
+	File   File `json:"file" gorm:"foreignKey:FileID"`
+	// UploadedByID - сотрудник, загрузивший версию
+	UploadedByID *uint  `json:"uploaded_by_id"`
+	UploadedBy   *User  `json:"-" gorm:"foreignKey:UploadedByID;constraint:OnDelete:SET NULL"`
+	Note         string `json:"note" gorm:"type:text"`
+	// RestoredFrom - номер версии, файл которой восстановлен этой версией
+	RestoredFrom *uint `json:"restored_from,omitempty"`
+}
+
+// DocumentContent - сведения, извлечённые из файла документа при загрузке.
+// Привязаны к файлу, поэтому общие для всех версий, которые на него ссылаются
+type DocumentContent struct {
+	Model
+	FileID uint  `json:"file_id" gorm:"not null;uniqueIndex"`
+	File   *File `json:"-" gorm:"foreignKey:FileID;constraint:OnDelete:CASCADE"`
+	// PageCount - число страниц, у XLSX - число листов
+	PageCount int    `json:"page_count"`
+	Title     string `json:"title" gorm:"size:500"`
+	Author    string `json:"author" gorm:"size:255"`
+	// Text - текст документа для поиска, SearchText - он же в нижнем регистре:
+	// LOWER в SQLite не работает с кириллицей
+	Text       string `json:"-" gorm:"size:1048576"`
+	SearchText string `json:"-" gorm:"size:1048576"`
+	// PreviewID - изображение первой страницы
+	PreviewID *uint `json:"preview_id"`
+	Preview   *File `json:"preview,omitempty" gorm:"foreignKey:PreviewID;constraint:OnDelete:SET NULL"`
 }
@@ -0,0 +1,18 @@
+package enums
+
+import "fmt"
+
+// scanString читает строковое значение колонки: драйверы MySQL и Postgres
+// отдают []byte, SQLite - string
+func scanString(value interface{}) (string, error) {
+	switch v := value.(type) {
+	case nil:
+		return "", nil
+	case string:
+		return v, nil
+	case []byte:
+		return string(v), nil
+	default:
+		return "", fmt.Errorf("unsupported value type %T", value)
+	}
+}
@@ -1,20 +1,95 @@
 package enums
 
-import "database/sql/driver"
+import (
+	"database/sql/driver"
+	"encoding/json"
+	"fmt"
+	"regexp"
+)
 
+// Page - slug страницы сайта из таблицы pages. Сами страницы - данные,
+// тип проверяет только формат slug
 type Page string
 
-const (
-	News      Page = "news"
-	Gallery        = "gallery"
-	Documents      = "documents"
-)
+// MaxPageLength - длина колонки pages.slug
+const MaxPageLength = 50
+
+var pagePattern = regexp.MustCompile(`^[a-z0-9]+(-[a-z0-9]+)*$`)
+
+// Valid - p годится как slug: латиница в нижнем регистре, цифры и дефисы
+func (p Page) Valid() bool {
+	return len(p) <= MaxPageLength && pagePattern.MatchString(string(p))
+}
 
 func (p *Page) Scan(value interface{}) error {
-	*p = Page(value.([]byte))
+	raw, err := scanString(value)
+	if err != nil {
+		return fmt.Errorf("scan page: %w", err)
+	}
+	*p = Page(raw)
 	return nil
 }
 
 func (p Page) Value() (driver.Value, error) {
 	return string(p), nil
 }
+
+func (p *Page) UnmarshalJSON(data []byte) error {
+	var raw string
+	if err := json.Unmarshal(data, &raw); err != nil {
+		return fmt.Errorf("page must be a string: %w", err)
+	}
+	return p.UnmarshalParam(raw)
+}
+
+func (p *Page) UnmarshalParam(param string) error {
+	page := Page(param)
+	if page != "" && !page.Valid() {
+		return fmt.Errorf("invalid page %q, expected lowercase latin letters, digits and hyphens", param)
+	}
+	*p = page
+	return nil
+}
+
+// PageKind - какие записи можно размещать в разделах страницы
+type PageKind string
+
+const (
+	NewsPage      PageKind = "news"
+	GalleryPage   PageKind = "gallery"
+	DocumentsPage PageKind = "documents"
+)
+
+func (k PageKind) Valid() bool {
+	return k == NewsPage || k == GalleryPage || k == DocumentsPage
+}
+
+func (k *PageKind) Scan(value interface{}) error {
+	raw, err := scanString(value)
+	if err != nil {
+		return fmt.Errorf("scan page kind: %w", err)
+	}
+	*k = PageKind(raw)
+	return nil
+}
+
+func (k PageKind) Value() (driver.Value, error) {
+	return string(k), nil
+}
+
+func (k *PageKind) UnmarshalJSON(data []byte) error {
+	var raw string
+	if err := json.Unmarshal(data, &raw); err != nil {
+		return fmt.Errorf("page kind must be a string: %w", err)
+	}
+	return k.UnmarshalParam(raw)
+}
+
+func (k *PageKind) UnmarshalParam(param string) error {
+	kind := PageKind(param)
+	if kind != "" && !kind.Valid() {
+		return fmt.Errorf("invalid page kind %q, expected %q, %q or %q", param, NewsPage, GalleryPage, DocumentsPage)
+	}
+	*k = kind
+	return nil
+}
@@ -1,19 +1,52 @@
 package enums
 
-import "database/sql/driver"
+import (
+	"database/sql/driver"
+	"encoding/json"
+	"fmt"
+)
 
 type Sex string
 
 const (
 	Female Sex = "female"
-	Male       = "male"
+	Male   Sex = "male"
 )
 
+// Valid - s одно из известных значений
+func (s Sex) Valid() bool {
+	return s == Female || s == Male
+}
+
 func (s *Sex) Scan(value interface{}) error {
-	*s = Sex(value.([]byte))
+	raw, err := scanString(value)
+	if err != nil {
+		return fmt.Errorf("scan sex: %w", err)
+	}
+	*s = Sex(raw)
 	return nil
 }
 
 func (s Sex) Value() (driver.Value, error) {
 	return string(s), nil
 }
+
+// UnmarshalJSON принимает только известные значения, пустая строка
+// остаётся пустой и проверяется binding:"required"
+func (s *Sex) UnmarshalJSON(data []byte) error {
+	var raw string
+	if err := json.Unmarshal(data, &raw); err != nil {
+		return fmt.Errorf("sex must be a string: %w", err)
+	}
+	return s.UnmarshalParam(raw)
+}
+
+// UnmarshalParam - то же для полей формы и query
+func (s *Sex) UnmarshalParam(param string) error {
+	sex := Sex(param)
+	if sex != "" && !sex.Valid() {
+		return fmt.Errorf("invalid sex %q, expected %q or %q", param, Female, Male)
+	}
+	*s = sex
+	return nil
+}
@@ -1,9 +1,17 @@
 // file.go
 package models
 
+import "time"
+
 type File struct {
 	Model
 	Name string `json:"name" gorm:"size:255"`
 	Size int64  `json:"size"`
 	Path string `json:"path" gorm:"size:500"`
+	// Width и Height - размеры фотографии после поворота по EXIF, TakenAt и
+	// Camera - время съёмки и камера из EXIF. У прочих файлов пустые
+	Width   *int       `json:"width,omitempty"`
+	Height  *int       `json:"height,omitempty"`
+	TakenAt *time.Time `json:"taken_at,omitempty"`
+	Camera  string     `json:"camera,omitempty" gorm:"size:255;not null;default:''"`
 }
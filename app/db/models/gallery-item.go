@@ -1,15 +1,47 @@
-// gallery-item.go (no changes needed as it uses relations)
+// gallery-item.go
 package models
 
-import "time"
+import (
+	"encoding/json"
+	"time"
+)
 
 type GalleryItem struct {
 	Model
-	Name      string    `json:"name"`
-	Date      time.Time `json:"date"`
-	PreviewID uint
-	Preview   File   `json:"preview" gorm:"foreignKey:PreviewID"`
-	Images    []File `json:"images" gorm:"many2many:gallery_item_images"`
+	Name string    `json:"name"`
+	Date time.Time `json:"date"`
+	// PreviewID - обложка альбома: отдельно загруженный файл или одно из
+	// изображений альбома. nil - у альбома без изображений
+	PreviewID *uint
+	Preview   *File `json:"preview" gorm:"foreignKey:PreviewID"`
+	// Images - изображения в порядке показа
+	Images    []GalleryImage `json:"images" gorm:"foreignKey:GalleryItemID"`
 	ChapterID uint
 	Chapter   Chapter `json:"chapter" gorm:"foreignkey:ChapterID"`
 }
+
+// GalleryImage - изображение в альбоме. Таблица та же, что у прежней связи
+// many2many, к ней добавлены место в альбоме, подпись и автор снимка.
+// В JSON отдаётся записью файла, как до появления подписей, см. MarshalJSON
+type GalleryImage struct {
+	GalleryItemID uint `json:"-" gorm:"primaryKey;autoIncrement:false"`
+	FileID        uint `json:"-" gorm:"primaryKey;autoIncrement:false"`
+	File          File `json:"-" gorm:"foreignKey:FileID"`
+	// Position - место в альбоме, с 1 и без пропусков
+	Position     uint   `json:"position" gorm:"not null;default:0"`
+	Caption      string `json:"caption" gorm:"size:500;not null;default:''"`
+	Photographer string `json:"photographer" gorm:"size:255;not null;default:''"`
+}
+
+func (GalleryImage) TableName() string { return "gallery_item_images" }
+
+// MarshalJSON - запись файла с добавленными position, caption и
+// photographer, чтобы images альбома остались массивом файлов
+func (i GalleryImage) MarshalJSON() ([]byte, error) {
+	return json.Marshal(struct {
+		File
+		Position     uint   `json:"position"`
+		Caption      string `json:"caption"`
+		Photographer string `json:"photographer"`
+	}{i.File, i.Position, i.Caption, i.Photographer})
+}
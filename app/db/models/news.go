@@ -26,9 +26,13 @@ type HistoryItem struct {
 	Year int `json:"year"`
 }
 
+// Chapter - раздел меню страницы. Вложенный раздел (ParentID) всегда на той же
+// странице, что и родитель; BarIdx - порядок среди разделов одного уровня
 type Chapter struct {
 	Model
-	Name   string     `json:"name" gorm:"size:100"`
-	BarIdx uint       `json:"bar_idx"`
-	Page   enums.Page `json:"page"`
+	Name     string     `json:"name" gorm:"size:100"`
+	BarIdx   uint       `json:"bar_idx"`
+	Page     enums.Page `json:"page"`
+	ParentID *uint      `json:"parent_id" gorm:"index"`
+	Parent   *Chapter   `json:"-" gorm:"foreignKey:ParentID"`
 }
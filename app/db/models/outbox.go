@@ -0,0 +1,28 @@
+// outbox.go
+package models
+
+import "time"
+
+type OutboxStatus string
+
+const (
+	OutboxPending OutboxStatus = "pending"
+	OutboxSent    OutboxStatus = "sent"
+	// OutboxFailed - попытки исчерпаны, письмо больше не отправляется
+	OutboxFailed OutboxStatus = "failed"
+)
+
+// OutboxMessage - письмо в очереди на отправку. Записывается в той же транзакции,
+// что и событие, поэтому не теряется при сбое SMTP или перезапуске
+type OutboxMessage struct {
+	Model
+	Recipient string       `json:"recipient" gorm:"size:255;not null"`
+	Subject   string       `json:"subject" gorm:"size:255;not null"`
+	Body      string       `json:"body" gorm:"type:text;not null"`
+	Status    OutboxStatus `json:"status" gorm:"size:20;not null;default:'pending';index:idx_outbox_due,priority:1"`
+	Attempts  int          `json:"attempts" gorm:"not null;default:0"`
+	// NextAttemptAt - не раньше этого времени письмо будет отправлено (снова)
+	NextAttemptAt time.Time  `json:"next_attempt_at" gorm:"not null;index:idx_outbox_due,priority:2"`
+	LastError     *string    `json:"last_error" gorm:"type:text"`
+	SentAt        *time.Time `json:"sent_at"`
+}
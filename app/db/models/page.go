@@ -0,0 +1,12 @@
+package models
+
+import "federation-backend/app/db/models/enums"
+
+// Page - страница сайта. Разделы (Chapter) ссылаются на неё по Slug,
+// Kind определяет, какие записи можно размещать в её разделах
+type Page struct {
+	Model
+	Name string         `json:"name" gorm:"size:100;not null"`
+	Slug enums.Page     `json:"slug" gorm:"size:50;not null;uniqueIndex"`
+	Kind enums.PageKind `json:"kind" gorm:"size:20;not null"`
+}
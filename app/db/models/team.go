@@ -5,8 +5,9 @@ import "federation-backend/app/db/models/enums"
 
 type Team struct {
 	Model
-	TeamName   string    `json:"team_name" gorm:"size:255"`
-	Sex        enums.Sex `json:"sex" gorm:"default:'male'"`
-	TeamLogoID uint      `json:"team_logo_id"`
-	TeamLogo   File      `gorm:"foreignkey:TeamLogoID" json:"logo"`
+	TeamName string    `json:"team_name" gorm:"size:255"`
+	Sex      enums.Sex `json:"sex" gorm:"default:'male'"`
+	// TeamLogoID - nil у команд без логотипа, например загруженных импортом
+	TeamLogoID *uint `json:"team_logo_id"`
+	TeamLogo   *File `gorm:"foreignkey:TeamLogoID" json:"logo"`
 }
@@ -0,0 +1,24 @@
+package db
+
+import (
+	"context"
+
+	"gorm.io/gorm"
+)
+
+type txKey struct{}
+
+// WithTx кладёт транзакцию в контекст, чтобы сервисы, вызванные внутри неё
+// (например files.Service.SaveFile), писали в ту же транзакцию. Иначе им нужно
+// второе соединение, а у SQLite оно единственное и занято транзакцией
+func WithTx(ctx context.Context, tx *gorm.DB) context.Context {
+	return context.WithValue(ctx, txKey{}, tx)
+}
+
+// Conn возвращает транзакцию из ctx, если она есть, иначе db. Результат привязан к ctx
+func Conn(ctx context.Context, db *gorm.DB) *gorm.DB {
+	if tx, ok := ctx.Value(txKey{}).(*gorm.DB); ok {
+		return tx.WithContext(ctx)
+	}
+	return db.WithContext(ctx)
+}
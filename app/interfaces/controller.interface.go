@@ -1,6 +1,18 @@
 package interfaces
 
-import "github.com/gin-gonic/gin"
+import (
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+)
+
+// Methods - HTTP-методы, которые регистрирует RegisterRoutes
+var Methods = []string{
+	http.MethodGet,
+	http.MethodPost,
+	http.MethodPut,
+	http.MethodDelete,
+}
 
 type Controller interface {
 	Create(ctx *gin.Context)
@@ -10,10 +22,20 @@ type Controller interface {
 	Update(ctx *gin.Context)
 }
 
+// RouteExtender - контроллер с маршрутами сверх стандартного CRUD,
+// RegisterRoutes вызывает RegisterExtraRoutes после основных маршрутов
+type RouteExtender interface {
+	RegisterExtraRoutes(router *gin.RouterGroup)
+}
+
 func RegisterRoutes(c Controller, router *gin.RouterGroup) {
 	router.GET("/", c.GetAll)
 	router.GET("/:id", c.Get)
 	router.POST("/", c.Create)
 	router.PUT("/:id", c.Update)
 	router.DELETE("/:id", c.Delete)
+
+	if extender, ok := c.(RouteExtender); ok {
+		extender.RegisterExtraRoutes(router)
+	}
 }
@@ -0,0 +1,106 @@
+package lifecycle
+
+import (
+	"context"
+	"errors"
+	"log/slog"
+	"sync"
+	"time"
+)
+
+// Worker - фоновая задача, которая запускается вместе с сервером
+// и должна завершиться, когда отменяется переданный контекст
+type Worker interface {
+	Name() string
+	Run(ctx context.Context) error
+}
+
+// Manager запускает фоновые задачи и останавливает их при завершении приложения
+type Manager struct {
+	logger  *slog.Logger
+	workers []Worker
+	wg      sync.WaitGroup
+	cancel  context.CancelFunc
+}
+
+func NewManager(logger *slog.Logger) *Manager {
+	return &Manager{logger: logger}
+}
+
+// Add регистрирует задачу. Задачи, добавленные после Start, не запускаются
+func (m *Manager) Add(workers ...Worker) {
+	m.workers = append(m.workers, workers...)
+}
+
+// Start запускает все зарегистрированные задачи в отдельных горутинах
+func (m *Manager) Start(ctx context.Context) {
+	ctx, m.cancel = context.WithCancel(ctx)
+
+	for _, worker := range m.workers {
+		m.wg.Add(1)
+		go func(w Worker) {
+			defer m.wg.Done()
+			m.logger.Info("worker started", slog.String("worker", w.Name()))
+			if err := w.Run(ctx); err != nil && !errors.Is(err, context.Canceled) {
+				m.logger.Error("worker stopped with error", slog.String("worker", w.Name()), slog.Any("error", err))
+				return
+			}
+			m.logger.Info("worker stopped", slog.String("worker", w.Name()))
+		}(worker)
+	}
+}
+
+// Stop отменяет контекст задач и ждёт их завершения, но не дольше ctx
+func (m *Manager) Stop(ctx context.Context) error {
+	if m.cancel == nil {
+		return nil
+	}
+	m.cancel()
+
+	done := make(chan struct{})
+	go func() {
+		m.wg.Wait()
+		close(done)
+	}()
+
+	select {
+	case <-done:
+		return nil
+	case <-ctx.Done():
+		return errors.New("timed out waiting for background workers to stop")
+	}
+}
+
+type periodic struct {
+	name     string
+	interval time.Duration
+	job      func(ctx context.Context) error
+	logger   *slog.Logger
+}
+
+// Periodic оборачивает job в задачу, которая выполняется сразу после старта
+// и затем каждые interval. Ошибки отдельных запусков логируются и не прерывают задачу
+func Periodic(name string, interval time.Duration, logger *slog.Logger, job func(ctx context.Context) error) Worker {
+	return &periodic{name: name, interval: interval, job: job, logger: logger}
+}
+
+func (p *periodic) Name() string {
+	return p.name
+}
+
+func (p *periodic) Run(ctx context.Context) error {
+	ticker := time.NewTicker(p.interval)
+	defer ticker.Stop()
+
+	for {
+		if err := p.job(ctx); err != nil && ctx.Err() == nil {
+			p.logger.ErrorContext(ctx, "worker run failed", slog.String("worker", p.name), slog.Any("error", err))
+		}
+
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		case <-ticker.C:
+		}
+	}
+}
@@ -0,0 +1,93 @@
+package logging
+
+import (
+	"context"
+	"federation-backend/app/config"
+	"io"
+	"log/slog"
+	"strings"
+
+	"go.opentelemetry.io/otel/trace"
+)
+
+// Имена полей, общие для всех записей
+const (
+	KeyRequestID = "request_id"
+	KeyEntity    = "entity"
+	KeyEntityID  = "entity_id"
+	KeyError     = "error"
+	KeyTraceID   = "trace_id"
+)
+
+type requestIDKey struct{}
+
+// New создаёт логгер с уровнем и форматом из cfg. Каждая запись,
+// сделанная через *Context-методы, получает request_id и trace_id из контекста
+func New(cfg *config.LogConfig, out io.Writer) *slog.Logger {
+	options := &slog.HandlerOptions{Level: ParseLevel(cfg.Level)}
+
+	var handler slog.Handler
+	if cfg.Format == "json" {
+		handler = slog.NewJSONHandler(out, options)
+	} else {
+		handler = slog.NewTextHandler(out, options)
+	}
+
+	return slog.New(contextHandler{handler})
+}
+
+func ParseLevel(level string) slog.Level {
+	switch strings.ToLower(level) {
+	case "debug":
+		return slog.LevelDebug
+	case "warn":
+		return slog.LevelWarn
+	case "error":
+		return slog.LevelError
+	default:
+		return slog.LevelInfo
+	}
+}
+
+// WithRequestID сохраняет ID запроса в контексте
+func WithRequestID(ctx context.Context, id string) context.Context {
+	return context.WithValue(ctx, requestIDKey{}, id)
+}
+
+// RequestID возвращает ID запроса из контекста или пустую строку
+func RequestID(ctx context.Context) string {
+	id, _ := ctx.Value(requestIDKey{}).(string)
+	return id
+}
+
+// Entity - поля, которыми помечаются записи о конкретной сущности
+func Entity(entity string, id uint) slog.Attr {
+	return slog.Group("", slog.String(KeyEntity, entity), slog.Uint64(KeyEntityID, uint64(id)))
+}
+
+// Error - поле с текстом ошибки
+func Error(err error) slog.Attr {
+	return slog.Any(KeyError, err)
+}
+
+type contextHandler struct {
+	slog.Handler
+}
+
+func (h contextHandler) Handle(ctx context.Context, record slog.Record) error {
+	if id := RequestID(ctx); id != "" {
+		record.AddAttrs(slog.String(KeyRequestID, id))
+	}
+	if span := trace.SpanContextFromContext(ctx); span.IsValid() {
+		record.AddAttrs(slog.String(KeyTraceID, span.TraceID().String()))
+	}
+	return h.Handler.Handle(ctx, record)
+}
+
+func (h contextHandler) WithAttrs(attrs []slog.Attr) slog.Handler {
+	return contextHandler{h.Handler.WithAttrs(attrs)}
+}
+
+func (h contextHandler) WithGroup(name string) slog.Handler {
+	return contextHandler{h.Handler.WithGroup(name)}
+}
@@ -0,0 +1,33 @@
+package metrics
+
+import "github.com/prometheus/client_golang/prometheus"
+
+// Причины отклонения заявки с публичной формы
+const (
+	CallbackRejectValidation = "validation"
+	CallbackRejectHoneypot   = "honeypot"
+	CallbackRejectCaptcha    = "captcha"
+	CallbackRejectIPLimit    = "ip_limit"
+	CallbackRejectPhoneLimit = "phone_limit"
+	CallbackRejectDuplicate  = "duplicate"
+)
+
+var CallbackRejections = factory.NewCounterVec(prometheus.CounterOpts{
+	Namespace: namespace,
+	Subsystem: "callback",
+	Name:      "rejections_total",
+	Help:      "Callback form submissions rejected by reason.",
+}, []string{"reason"})
+
+func init() {
+	for _, reason := range []string{
+		CallbackRejectValidation,
+		CallbackRejectHoneypot,
+		CallbackRejectCaptcha,
+		CallbackRejectIPLimit,
+		CallbackRejectPhoneLimit,
+		CallbackRejectDuplicate,
+	} {
+		CallbackRejections.WithLabelValues(reason)
+	}
+}
@@ -0,0 +1,64 @@
+package metrics
+
+import "github.com/prometheus/client_golang/prometheus"
+
+// Причины неудачной загрузки файла
+const (
+	UploadFailureExtension = "extension"
+	UploadFailureSize      = "size"
+	UploadFailureStorage   = "storage"
+	UploadFailureDatabase  = "database"
+	// UploadFailureImage - фотографию не удалось разобрать
+	UploadFailureImage = "image"
+)
+
+var (
+	FileUploads = factory.NewCounter(prometheus.CounterOpts{
+		Namespace: namespace,
+		Subsystem: "files",
+		Name:      "uploads_total",
+		Help:      "Files successfully saved to storage.",
+	})
+
+	FileUploadBytes = factory.NewCounter(prometheus.CounterOpts{
+		Namespace: namespace,
+		Subsystem: "files",
+		Name:      "upload_bytes_total",
+		Help:      "Bytes of successfully saved files.",
+	})
+
+	FileUploadFailures = factory.NewCounterVec(prometheus.CounterOpts{
+		Namespace: namespace,
+		Subsystem: "files",
+		Name:      "upload_failures_total",
+		Help:      "Rejected or failed file uploads by reason.",
+	}, []string{"reason"})
+
+	FileDeletes = factory.NewCounter(prometheus.CounterOpts{
+		Namespace: namespace,
+		Subsystem: "files",
+		Name:      "deletes_total",
+		Help:      "Files deleted from storage.",
+	})
+
+	StorageFiles = factory.NewGauge(prometheus.GaugeOpts{
+		Namespace: namespace,
+		Subsystem: "storage",
+		Name:      "files",
+		Help:      "Files in the storage directory as of the last scan.",
+	})
+
+	StorageBytes = factory.NewGauge(prometheus.GaugeOpts{
+		Namespace: namespace,
+		Subsystem: "storage",
+		Name:      "bytes",
+		Help:      "Bytes used by the storage directory as of the last scan.",
+	})
+)
+
+func init() {
+	// Серии с нулями видны сразу, а не после первой ошибки
+	for _, reason := range []string{UploadFailureExtension, UploadFailureSize, UploadFailureStorage, UploadFailureDatabase, UploadFailureImage} {
+		FileUploadFailures.WithLabelValues(reason)
+	}
+}
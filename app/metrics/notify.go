@@ -0,0 +1,39 @@
+package metrics
+
+import "github.com/prometheus/client_golang/prometheus"
+
+// Результаты попытки отправить письмо из outbox
+const (
+	NotificationSent   = "sent"
+	NotificationRetry  = "retry"
+	NotificationFailed = "failed"
+)
+
+var (
+	NotificationsQueued = factory.NewCounter(prometheus.CounterOpts{
+		Namespace: namespace,
+		Subsystem: "notifications",
+		Name:      "queued_total",
+		Help:      "Emails written to the outbox.",
+	})
+
+	NotificationAttempts = factory.NewCounterVec(prometheus.CounterOpts{
+		Namespace: namespace,
+		Subsystem: "notifications",
+		Name:      "attempts_total",
+		Help:      "Outbox delivery attempts by result: sent, retry (will be retried) or failed (attempts exhausted).",
+	}, []string{"result"})
+
+	OutboxPending = factory.NewGauge(prometheus.GaugeOpts{
+		Namespace: namespace,
+		Subsystem: "notifications",
+		Name:      "outbox_pending",
+		Help:      "Emails waiting in the outbox as of the last delivery run.",
+	})
+)
+
+func init() {
+	for _, result := range []string{NotificationSent, NotificationRetry, NotificationFailed} {
+		NotificationAttempts.WithLabelValues(result)
+	}
+}
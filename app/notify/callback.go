@@ -0,0 +1,64 @@
+package notify
+
+import (
+	"context"
+	"fmt"
+	"log/slog"
+	"net/mail"
+
+	"federation-backend/app/config"
+	"federation-backend/app/db/models"
+	"federation-backend/app/logging"
+)
+
+// CallbackNotifier ставит в outbox письма о новой заявке: сотрудникам по шаблону
+// её типа и, если включено и указан email, подтверждение автору
+type CallbackNotifier struct {
+	outbox           *Outbox
+	templates        *Templates
+	recipients       []string
+	confirmSubmitter bool
+	logger           *slog.Logger
+}
+
+func NewCallbackNotifier(outbox *Outbox, templates *Templates, cfg config.NotifyConfig, logger *slog.Logger) *CallbackNotifier {
+	return &CallbackNotifier{
+		outbox:           outbox,
+		templates:        templates,
+		recipients:       cfg.Recipients,
+		confirmSubmitter: cfg.ConfirmSubmitter,
+		logger:           logger,
+	}
+}
+
+// CallbackCreated вызывается внутри транзакции создания заявки, поэтому письма
+// сохраняются вместе с ней или не сохраняются вовсе
+func (n *CallbackNotifier) CallbackCreated(ctx context.Context, callback *models.CallBack) error {
+	subject, body, err := n.templates.Render(string(callback.CallbackType), callback)
+	if err != nil {
+		return err
+	}
+
+	messages := make([]Message, 0, len(n.recipients)+1)
+	for _, recipient := range n.recipients {
+		messages = append(messages, Message{To: recipient, Subject: subject, Body: body})
+	}
+
+	if n.confirmSubmitter && callback.Email != nil && *callback.Email != "" {
+		if _, err := mail.ParseAddress(*callback.Email); err != nil {
+			// Кривой адрес автора не повод терять заявку и письма сотрудникам
+			n.logger.WarnContext(ctx, "confirmation skipped, invalid submitter email", logging.Entity("callback", callback.Id), logging.Error(err))
+		} else {
+			subject, body, err := n.templates.Render(confirmPrefix+string(callback.CallbackType), callback)
+			if err != nil {
+				return err
+			}
+			messages = append(messages, Message{To: *callback.Email, Subject: subject, Body: body})
+		}
+	}
+
+	if err := n.outbox.Enqueue(ctx, messages...); err != nil {
+		return fmt.Errorf("failed to queue notifications for callback %d: %w", callback.Id, err)
+	}
+	return nil
+}
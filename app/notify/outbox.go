@@ -0,0 +1,164 @@
+package notify
+
+import (
+	"context"
+	"fmt"
+	"log/slog"
+	"time"
+
+	"federation-backend/app/config"
+	database "federation-backend/app/db"
+	"federation-backend/app/db/models"
+	"federation-backend/app/logging"
+	"federation-backend/app/metrics"
+
+	"gorm.io/gorm"
+)
+
+// claimLease - на сколько вперёд сдвигается NextAttemptAt письма, взятого в
+// отправку. Другой экземпляр приложения его не возьмёт, а если процесс упадёт
+// посреди отправки, письмо вернётся в очередь после истечения срока
+const claimLease = 5 * time.Minute
+
+// subjectLimit - длина колонки outbox_messages.subject
+const subjectLimit = 255
+
+// Outbox - очередь исходящих писем в базе. Enqueue пишет в транзакцию из ctx,
+// Deliver периодически отправляет накопившееся с повторами и backoff
+type Outbox struct {
+	db     *gorm.DB
+	sender Sender
+	cfg    config.NotifyConfig
+	logger *slog.Logger
+	now    func() time.Time
+}
+
+func NewOutbox(db *gorm.DB, sender Sender, cfg config.NotifyConfig, logger *slog.Logger) *Outbox {
+	return &Outbox{db: db, sender: sender, cfg: cfg, logger: logger, now: time.Now}
+}
+
+func (o *Outbox) Enqueue(ctx context.Context, messages ...Message) error {
+	if len(messages) == 0 {
+		return nil
+	}
+
+	now := o.now().UTC()
+	rows := make([]models.OutboxMessage, 0, len(messages))
+	for _, msg := range messages {
+		rows = append(rows, models.OutboxMessage{
+			Recipient:     msg.To,
+			Subject:       truncate(msg.Subject, subjectLimit),
+			Body:          msg.Body,
+			Status:        models.OutboxPending,
+			NextAttemptAt: now,
+		})
+	}
+	if err := database.Conn(ctx, o.db).Create(&rows).Error; err != nil {
+		return fmt.Errorf("failed to enqueue notifications: %w", err)
+	}
+
+	metrics.NotificationsQueued.Add(float64(len(rows)))
+	return nil
+}
+
+// Deliver отправляет письма, у которых подошло время попытки, и удаляет
+// отправленные старше notify.sent_retention
+func (o *Outbox) Deliver(ctx context.Context) error {
+	now := o.now().UTC()
+
+	var due []models.OutboxMessage
+	err := o.db.WithContext(ctx).
+		Where("status = ? AND next_attempt_at <= ?", models.OutboxPending, now).
+		Order("next_attempt_at, id").
+		Limit(o.cfg.BatchSize).
+		Find(&due).Error
+	if err != nil {
+		return fmt.Errorf("failed to load outbox: %w", err)
+	}
+
+	for i := range due {
+		if ctx.Err() != nil {
+			return ctx.Err()
+		}
+		if err := o.deliver(ctx, &due[i], now); err != nil {
+			return err
+		}
+	}
+
+	if err := o.db.WithContext(ctx).
+		Where("status = ? AND sent_at < ?", models.OutboxSent, now.Add(-o.cfg.SentRetention)).
+		Delete(&models.OutboxMessage{}).Error; err != nil {
+		return fmt.Errorf("failed to clean up outbox: %w", err)
+	}
+
+	var pending int64
+	if err := o.db.WithContext(ctx).Model(&models.OutboxMessage{}).Where("status = ?", models.OutboxPending).Count(&pending).Error; err != nil {
+		return fmt.Errorf("failed to count outbox: %w", err)
+	}
+	metrics.OutboxPending.Set(float64(pending))
+	return nil
+}
+
+// deliver отправляет одно письмо. Ошибка отправки письма не возвращается, а
+// записывается в строку; возвращаются только ошибки базы
+func (o *Outbox) deliver(ctx context.Context, msg *models.OutboxMessage, now time.Time) error {
+	claimed := o.db.WithContext(ctx).Model(&models.OutboxMessage{}).
+		Where("id = ? AND status = ? AND next_attempt_at = ?", msg.Id, models.OutboxPending, msg.NextAttemptAt).
+		Update("next_attempt_at", now.Add(claimLease))
+	if claimed.Error != nil {
+		return fmt.Errorf("failed to claim notification: %w", claimed.Error)
+	}
+	if claimed.RowsAffected == 0 {
+		// Письмо уже взял другой экземпляр
+		return nil
+	}
+
+	sendErr := o.sender.Send(ctx, Message{To: msg.Recipient, Subject: msg.Subject, Body: msg.Body})
+	attempts := msg.Attempts + 1
+	updates := map[string]any{"attempts": attempts}
+	attrs := []any{slog.Uint64("outbox_id", uint64(msg.Id)), slog.Int("attempt", attempts)}
+
+	switch {
+	case sendErr == nil:
+		sentAt := o.now().UTC()
+		updates["status"] = models.OutboxSent
+		updates["sent_at"] = &sentAt
+		updates["last_error"] = nil
+		metrics.NotificationAttempts.WithLabelValues(metrics.NotificationSent).Inc()
+		o.logger.InfoContext(ctx, "notification sent", attrs...)
+	case attempts >= o.cfg.MaxAttempts:
+		updates["status"] = models.OutboxFailed
+		updates["last_error"] = sendErr.Error()
+		metrics.NotificationAttempts.WithLabelValues(metrics.NotificationFailed).Inc()
+		o.logger.ErrorContext(ctx, "notification failed, attempts exhausted", append(attrs, logging.Error(sendErr))...)
+	default:
+		next := o.now().UTC().Add(o.backoff(attempts))
+		updates["next_attempt_at"] = next
+		updates["last_error"] = sendErr.Error()
+		metrics.NotificationAttempts.WithLabelValues(metrics.NotificationRetry).Inc()
+		o.logger.WarnContext(ctx, "notification not sent, will retry", append(attrs, slog.Time("next_attempt_at", next), logging.Error(sendErr))...)
+	}
+
+	// Результат записывается и при отменённом ctx, иначе отправленное письмо уйдёт повторно
+	if err := o.db.WithContext(context.WithoutCancel(ctx)).Model(msg).Updates(updates).Error; err != nil {
+		return fmt.Errorf("failed to update notification %d: %w", msg.Id, err)
+	}
+	return nil
+}
+
+// backoff - пауза перед попыткой attempts+1: retry_backoff * 2^(attempts-1), не больше max_retry_backoff
+func (o *Outbox) backoff(attempts int) time.Duration {
+	delay := o.cfg.RetryBackoff
+	for i := 1; i < attempts && delay < o.cfg.MaxRetryBackoff; i++ {
+		delay *= 2
+	}
+	return min(delay, o.cfg.MaxRetryBackoff)
+}
+
+func truncate(value string, limit int) string {
+	runes := []rune(value)
+	if len(runes) <= limit {
+		return value
+	}
+	return string(runes[:limit-1]) + "…"
+}
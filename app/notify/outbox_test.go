@@ -0,0 +1,316 @@
+package notify
+
+import (
+	"bufio"
+	"context"
+	"io"
+	"log/slog"
+	"net"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"federation-backend/app/config"
+	"federation-backend/app/db/models"
+
+	"gorm.io/driver/sqlite"
+	"gorm.io/gorm"
+	"gorm.io/gorm/logger"
+)
+
+// fakeSMTP - SMTP-сервер на 127.0.0.1, который принимает письма или отклоняет
+// RCPT, пока reject включён
+type fakeSMTP struct {
+	listener net.Listener
+
+	mu       sync.Mutex
+	reject   bool
+	received []string
+}
+
+func startFakeSMTP(t *testing.T) *fakeSMTP {
+	t.Helper()
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatalf("listen: %v", err)
+	}
+	server := &fakeSMTP{listener: listener}
+	t.Cleanup(func() { listener.Close() })
+	go func() {
+		for {
+			conn, err := listener.Accept()
+			if err != nil {
+				return
+			}
+			go server.serve(conn)
+		}
+	}()
+	return server
+}
+
+func (f *fakeSMTP) setReject(reject bool) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	f.reject = reject
+}
+
+func (f *fakeSMTP) messages() []string {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	return append([]string(nil), f.received...)
+}
+
+func (f *fakeSMTP) serve(conn net.Conn) {
+	defer conn.Close()
+	reader := bufio.NewReader(conn)
+	reply := func(line string) { io.WriteString(conn, line+"\r\n") }
+
+	reply("220 fake ESMTP")
+	var rcpt string
+	for {
+		line, err := reader.ReadString('\n')
+		if err != nil {
+			return
+		}
+		command := strings.ToUpper(strings.TrimSpace(line))
+		switch {
+		case strings.HasPrefix(command, "EHLO"), strings.HasPrefix(command, "HELO"):
+			reply("250 fake")
+		case strings.HasPrefix(command, "MAIL FROM"):
+			reply("250 ok")
+		case strings.HasPrefix(command, "RCPT TO"):
+			f.mu.Lock()
+			reject := f.reject
+			f.mu.Unlock()
+			if reject {
+				reply("451 try again later")
+				continue
+			}
+			rcpt = strings.TrimSpace(line[len("RCPT TO:"):])
+			reply("250 ok")
+		case command == "DATA":
+			reply("354 go ahead")
+			for {
+				data, err := reader.ReadString('\n')
+				if err != nil {
+					return
+				}
+				if data == ".\r\n" {
+					break
+				}
+			}
+			f.mu.Lock()
+			f.received = append(f.received, rcpt)
+			f.mu.Unlock()
+			reply("250 queued")
+		case command == "QUIT":
+			reply("221 bye")
+			return
+		default:
+			reply("502 not implemented")
+		}
+	}
+}
+
+func newTestOutbox(t *testing.T, server *fakeSMTP) (*Outbox, *gorm.DB, *time.Time) {
+	t.Helper()
+	db, err := gorm.Open(sqlite.Open(filepath.Join(t.TempDir(), "outbox.db")), &gorm.Config{Logger: logger.Discard})
+	if err != nil {
+		t.Fatalf("open sqlite: %v", err)
+	}
+	sqlDB, _ := db.DB()
+	sqlDB.SetMaxOpenConns(1)
+	t.Cleanup(func() { sqlDB.Close() })
+	if err := db.AutoMigrate(&models.OutboxMessage{}); err != nil {
+		t.Fatalf("migrate: %v", err)
+	}
+
+	host, port, _ := net.SplitHostPort(server.listener.Addr().String())
+	portNumber, _ := strconv.Atoi(port)
+	sender, err := NewSMTPSender(config.SMTPConfig{
+		Host:     host,
+		Port:     portNumber,
+		From:     "noreply@example.com",
+		Security: config.SMTPSecurityNone,
+		Timeout:  5 * time.Second,
+	})
+	if err != nil {
+		t.Fatalf("sender: %v", err)
+	}
+
+	cfg := config.NotifyConfig{
+		BatchSize:       10,
+		MaxAttempts:     3,
+		RetryBackoff:    time.Minute,
+		MaxRetryBackoff: 90 * time.Second,
+		SentRetention:   24 * time.Hour,
+	}
+	now := time.Date(2024, 1, 1, 12, 0, 0, 0, time.UTC)
+	outbox := NewOutbox(db, sender, cfg, slog.New(slog.NewTextHandler(io.Discard, nil)))
+	outbox.now = func() time.Time { return now }
+	return outbox, db, &now
+}
+
+func loadMessage(t *testing.T, db *gorm.DB, id uint) models.OutboxMessage {
+	t.Helper()
+	var msg models.OutboxMessage
+	if err := db.First(&msg, id).Error; err != nil {
+		t.Fatalf("load message %d: %v", id, err)
+	}
+	return msg
+}
+
+func TestOutboxBackoff(t *testing.T) {
+	outbox := &Outbox{cfg: config.NotifyConfig{RetryBackoff: time.Minute, MaxRetryBackoff: 5 * time.Minute}}
+	tests := []struct {
+		attempts int
+		want     time.Duration
+	}{
+		{1, time.Minute},
+		{2, 2 * time.Minute},
+		{3, 4 * time.Minute},
+		{4, 5 * time.Minute},
+		{10, 5 * time.Minute},
+	}
+	for _, tt := range tests {
+		if got := outbox.backoff(tt.attempts); got != tt.want {
+			t.Errorf("backoff(%d) = %s, want %s", tt.attempts, got, tt.want)
+		}
+	}
+}
+
+func TestOutboxDeliver(t *testing.T) {
+	tests := []struct {
+		name string
+		// rejects - сколько первых попыток сервер отклоняет
+		rejects      int
+		wantStatus   models.OutboxStatus
+		wantAttempts int
+		wantSent     int
+	}{
+		{name: "sent on first attempt", rejects: 0, wantStatus: models.OutboxSent, wantAttempts: 1, wantSent: 1},
+		{name: "sent after retries", rejects: 2, wantStatus: models.OutboxSent, wantAttempts: 3, wantSent: 1},
+		{name: "dead-lettered after max attempts", rejects: 3, wantStatus: models.OutboxFailed, wantAttempts: 3, wantSent: 0},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			server := startFakeSMTP(t)
+			outbox, db, now := newTestOutbox(t, server)
+			ctx := context.Background()
+
+			if err := outbox.Enqueue(ctx, Message{To: "staff@example.com", Subject: "Новая заявка", Body: "Текст"}); err != nil {
+				t.Fatalf("enqueue: %v", err)
+			}
+			var queued models.OutboxMessage
+			if err := db.First(&queued).Error; err != nil {
+				t.Fatal(err)
+			}
+
+			for attempt := 1; attempt <= outbox.cfg.MaxAttempts; attempt++ {
+				server.setReject(attempt <= tt.rejects)
+				if err := outbox.Deliver(ctx); err != nil {
+					t.Fatalf("deliver %d: %v", attempt, err)
+				}
+				msg := loadMessage(t, db, queued.Id)
+				if msg.Status != models.OutboxPending {
+					break
+				}
+
+				// Пока пауза не прошла, письмо не отправляется повторно
+				if err := outbox.Deliver(ctx); err != nil {
+					t.Fatal(err)
+				}
+				if again := loadMessage(t, db, queued.Id); again.Attempts != attempt {
+					t.Fatalf("attempt %d: retried before backoff, attempts = %d", attempt, again.Attempts)
+				}
+				if want := now.Add(outbox.backoff(attempt)); !msg.NextAttemptAt.Equal(want) {
+					t.Fatalf("attempt %d: next_attempt_at = %s, want %s", attempt, msg.NextAttemptAt, want)
+				}
+				if msg.LastError == nil || !strings.Contains(*msg.LastError, "451") {
+					t.Fatalf("attempt %d: last_error = %v", attempt, msg.LastError)
+				}
+				*now = msg.NextAttemptAt
+			}
+
+			msg := loadMessage(t, db, queued.Id)
+			if msg.Status != tt.wantStatus || msg.Attempts != tt.wantAttempts {
+				t.Fatalf("status %s after %d attempts, want %s after %d", msg.Status, msg.Attempts, tt.wantStatus, tt.wantAttempts)
+			}
+			if tt.wantStatus == models.OutboxSent && (msg.SentAt == nil || msg.LastError != nil) {
+				t.Errorf("sent message: sent_at = %v, last_error = %v", msg.SentAt, msg.LastError)
+			}
+			if tt.wantStatus == models.OutboxFailed && msg.LastError == nil {
+				t.Error("failed message has no last_error")
+			}
+			if got := len(server.messages()); got != tt.wantSent {
+				t.Errorf("server received %d messages, want %d", got, tt.wantSent)
+			}
+		})
+	}
+}
+
+// Письмо, которое уже взял другой экземпляр, не отправляется второй раз
+func TestOutboxClaim(t *testing.T) {
+	server := startFakeSMTP(t)
+	outbox, db, now := newTestOutbox(t, server)
+	ctx := context.Background()
+
+	if err := outbox.Enqueue(ctx, Message{To: "staff@example.com", Subject: "s", Body: "b"}); err != nil {
+		t.Fatal(err)
+	}
+	var stale models.OutboxMessage
+	if err := db.First(&stale).Error; err != nil {
+		t.Fatal(err)
+	}
+
+	// Другой экземпляр сдвинул next_attempt_at, забрав письмо
+	if err := db.Model(&models.OutboxMessage{}).Where("id = ?", stale.Id).Update("next_attempt_at", now.Add(claimLease)).Error; err != nil {
+		t.Fatal(err)
+	}
+	if err := outbox.deliver(ctx, &stale, *now); err != nil {
+		t.Fatalf("deliver: %v", err)
+	}
+	if got := len(server.messages()); got != 0 {
+		t.Fatalf("claimed message was sent %d times", got)
+	}
+	if msg := loadMessage(t, db, stale.Id); msg.Attempts != 0 {
+		t.Fatalf("attempts = %d, want 0", msg.Attempts)
+	}
+}
+
+func TestOutboxCleansUpSent(t *testing.T) {
+	server := startFakeSMTP(t)
+	outbox, db, now := newTestOutbox(t, server)
+	ctx := context.Background()
+
+	if err := outbox.Enqueue(ctx, Message{To: "staff@example.com", Subject: "s", Body: "b"}); err != nil {
+		t.Fatal(err)
+	}
+	if err := outbox.Deliver(ctx); err != nil {
+		t.Fatal(err)
+	}
+
+	count := func() int64 {
+		var n int64
+		db.Model(&models.OutboxMessage{}).Count(&n)
+		return n
+	}
+	*now = now.Add(outbox.cfg.SentRetention / 2)
+	if err := outbox.Deliver(ctx); err != nil {
+		t.Fatal(err)
+	}
+	if count() != 1 {
+		t.Fatal("sent message removed before sent_retention")
+	}
+	*now = now.Add(outbox.cfg.SentRetention)
+	if err := outbox.Deliver(ctx); err != nil {
+		t.Fatal(err)
+	}
+	if count() != 0 {
+		t.Fatal("sent message kept after sent_retention")
+	}
+}
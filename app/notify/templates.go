@@ -0,0 +1,98 @@
+package notify
+
+import (
+	"bytes"
+	"embed"
+	"errors"
+	"fmt"
+	"io/fs"
+	"os"
+	"path"
+	"strings"
+	"text/template"
+
+	"federation-backend/app/db/models"
+)
+
+//go:embed templates/*.tmpl
+var builtinTemplates embed.FS
+
+const templateExt = ".tmpl"
+
+// Шаблон письма сотрудникам называется по типу заявки (team_application.tmpl),
+// подтверждение автору - с префиксом confirm_ (confirm_team_application.tmpl).
+// Каждый файл определяет блоки "subject" и "body"
+const confirmPrefix = "confirm_"
+
+type Templates struct {
+	byName map[string]*template.Template
+}
+
+// LoadTemplates разбирает встроенные шаблоны, файлы *.tmpl из dir (если задан)
+// заменяют встроенные с тем же именем. Шаблоны нужны для каждого типа заявки
+func LoadTemplates(dir string) (*Templates, error) {
+	t := &Templates{byName: map[string]*template.Template{}}
+
+	sub, err := fs.Sub(builtinTemplates, "templates")
+	if err != nil {
+		return nil, err
+	}
+	if err := t.parseDir(sub); err != nil {
+		return nil, err
+	}
+	if dir != "" {
+		if err := t.parseDir(os.DirFS(dir)); err != nil {
+			return nil, err
+		}
+	}
+
+	var errs []error
+	for _, callbackType := range models.CallbackTypes {
+		for _, name := range []string{string(callbackType), confirmPrefix + string(callbackType)} {
+			if _, ok := t.byName[name]; !ok {
+				errs = append(errs, fmt.Errorf("missing template %s%s", name, templateExt))
+			}
+		}
+	}
+	return t, errors.Join(errs...)
+}
+
+func (t *Templates) parseDir(dir fs.FS) error {
+	names, err := fs.Glob(dir, "*"+templateExt)
+	if err != nil {
+		return err
+	}
+	for _, file := range names {
+		tmpl, err := template.ParseFS(dir, file)
+		if err != nil {
+			return fmt.Errorf("failed to parse template %s: %w", file, err)
+		}
+		for _, block := range []string{"subject", "body"} {
+			if tmpl.Lookup(block) == nil {
+				return fmt.Errorf("template %s does not define %q", file, block)
+			}
+		}
+		t.byName[strings.TrimSuffix(path.Base(file), templateExt)] = tmpl
+	}
+	return nil
+}
+
+// Render возвращает тему (в одну строку) и текст письма по шаблону name
+func (t *Templates) Render(name string, data any) (subject, body string, err error) {
+	tmpl, ok := t.byName[name]
+	if !ok {
+		return "", "", fmt.Errorf("unknown template %q", name)
+	}
+
+	var buf bytes.Buffer
+	if err := tmpl.ExecuteTemplate(&buf, "subject", data); err != nil {
+		return "", "", fmt.Errorf("failed to render subject of %s: %w", name, err)
+	}
+	subject = strings.Join(strings.Fields(buf.String()), " ")
+
+	buf.Reset()
+	if err := tmpl.ExecuteTemplate(&buf, "body", data); err != nil {
+		return "", "", fmt.Errorf("failed to render body of %s: %w", name, err)
+	}
+	return subject, strings.TrimSpace(buf.String()) + "\n", nil
+}
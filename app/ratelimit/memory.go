@@ -0,0 +1,55 @@
+package ratelimit
+
+import (
+	"context"
+	"sync"
+	"time"
+)
+
+// Memory хранит вёдра в памяти процесса. Подходит для одного экземпляра
+// приложения: у нескольких экземпляров лимиты считаются независимо
+type Memory struct {
+	mu      sync.Mutex
+	buckets map[string]memoryBucket
+	now     func() time.Time
+}
+
+type memoryBucket struct {
+	bucket
+	// full - когда ведро наполнится целиком и его можно забыть
+	full time.Time
+}
+
+func NewMemory() *Memory {
+	return &Memory{buckets: map[string]memoryBucket{}, now: time.Now}
+}
+
+func (m *Memory) Take(_ context.Context, key string, policy Policy) (Decision, error) {
+	if !policy.Enabled() {
+		return Decision{Allowed: true}, nil
+	}
+
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	now := m.now()
+	state, exists := m.buckets[key]
+	next, decision := take(state.bucket, exists, policy, now)
+	m.buckets[key] = memoryBucket{bucket: next, full: now.Add(decision.Reset)}
+	return decision, nil
+}
+
+// Sweep удаляет полные вёдра: отсутствующее ведро и так считается полным.
+// Подходит для lifecycle.Periodic
+func (m *Memory) Sweep(_ context.Context) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	now := m.now()
+	for key, state := range m.buckets {
+		if !now.Before(state.full) {
+			delete(m.buckets, key)
+		}
+	}
+	return nil
+}
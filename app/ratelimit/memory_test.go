@@ -0,0 +1,140 @@
+package ratelimit
+
+import (
+	"context"
+	"testing"
+	"time"
+)
+
+// clock - часы Memory, которые двигает тест
+type clock struct{ now time.Time }
+
+func (c *clock) Now() time.Time { return c.now }
+
+func newTestMemory() (*Memory, *clock) {
+	c := &clock{now: time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)}
+	m := NewMemory()
+	m.now = c.Now
+	return m, c
+}
+
+func TestMemoryTake(t *testing.T) {
+	// Токен в секунду, до трёх подряд
+	policy := Policy{Limit: 2, Period: 2 * time.Second, Burst: 3}
+
+	type step struct {
+		advance       time.Duration
+		wantAllowed   bool
+		wantRemaining int
+		wantRetry     time.Duration
+		wantReset     time.Duration
+	}
+	tests := []struct {
+		name   string
+		policy Policy
+		steps  []step
+	}{
+		{
+			name:   "burst then refuse",
+			policy: policy,
+			steps: []step{
+				{wantAllowed: true, wantRemaining: 2, wantReset: time.Second},
+				{wantAllowed: true, wantRemaining: 1, wantReset: 2 * time.Second},
+				{wantAllowed: true, wantRemaining: 0, wantReset: 3 * time.Second},
+				{wantAllowed: false, wantRemaining: 0, wantRetry: time.Second, wantReset: 3 * time.Second},
+			},
+		},
+		{
+			name:   "refill after refusal",
+			policy: policy,
+			steps: []step{
+				{wantAllowed: true, wantRemaining: 2, wantReset: time.Second},
+				{wantAllowed: true, wantRemaining: 1, wantReset: 2 * time.Second},
+				{wantAllowed: true, wantRemaining: 0, wantReset: 3 * time.Second},
+				{advance: 500 * time.Millisecond, wantAllowed: false, wantRetry: 500 * time.Millisecond, wantReset: 2500 * time.Millisecond},
+				{advance: 500 * time.Millisecond, wantAllowed: true, wantRemaining: 0, wantReset: 3 * time.Second},
+			},
+		},
+		{
+			name:   "refill stops at burst",
+			policy: policy,
+			steps: []step{
+				{wantAllowed: true, wantRemaining: 2, wantReset: time.Second},
+				{advance: time.Hour, wantAllowed: true, wantRemaining: 2, wantReset: time.Second},
+			},
+		},
+		{
+			name:   "burst defaults to limit",
+			policy: Policy{Limit: 1, Period: time.Second},
+			steps: []step{
+				{wantAllowed: true, wantRemaining: 0, wantReset: time.Second},
+				{wantAllowed: false, wantRemaining: 0, wantRetry: time.Second, wantReset: time.Second},
+			},
+		},
+		{
+			name:   "disabled policy allows everything",
+			policy: Policy{},
+			steps: []step{
+				{wantAllowed: true},
+				{wantAllowed: true},
+			},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			m, c := newTestMemory()
+			for i, s := range tt.steps {
+				c.now = c.now.Add(s.advance)
+				decision, err := m.Take(context.Background(), "key", tt.policy)
+				if err != nil {
+					t.Fatalf("step %d: %v", i, err)
+				}
+				if decision.Allowed != s.wantAllowed || decision.Remaining != s.wantRemaining ||
+					decision.RetryAfter != s.wantRetry || decision.Reset != s.wantReset {
+					t.Fatalf("step %d: got allowed=%v remaining=%d retry=%s reset=%s, want allowed=%v remaining=%d retry=%s reset=%s",
+						i, decision.Allowed, decision.Remaining, decision.RetryAfter, decision.Reset,
+						s.wantAllowed, s.wantRemaining, s.wantRetry, s.wantReset)
+				}
+			}
+		})
+	}
+}
+
+func TestMemoryKeysAreIndependent(t *testing.T) {
+	m, _ := newTestMemory()
+	policy := Policy{Limit: 1, Period: time.Minute}
+	ctx := context.Background()
+
+	if decision, _ := m.Take(ctx, "ip:1", policy); !decision.Allowed {
+		t.Fatal("first request of ip:1 refused")
+	}
+	if decision, _ := m.Take(ctx, "ip:1", policy); decision.Allowed {
+		t.Fatal("second request of ip:1 allowed")
+	}
+	if decision, _ := m.Take(ctx, "ip:2", policy); !decision.Allowed {
+		t.Fatal("ip:2 shares the bucket of ip:1")
+	}
+}
+
+func TestMemorySweep(t *testing.T) {
+	m, c := newTestMemory()
+	policy := Policy{Limit: 1, Period: time.Minute}
+	ctx := context.Background()
+
+	m.Take(ctx, "key", policy)
+	c.now = c.now.Add(30 * time.Second)
+	m.Sweep(ctx)
+	if _, ok := m.buckets["key"]; !ok {
+		t.Fatal("sweep removed a bucket that is not full yet")
+	}
+
+	c.now = c.now.Add(30 * time.Second)
+	m.Sweep(ctx)
+	if _, ok := m.buckets["key"]; ok {
+		t.Fatal("sweep kept a full bucket")
+	}
+	if decision, _ := m.Take(ctx, "key", policy); !decision.Allowed {
+		t.Fatal("swept bucket is not full")
+	}
+}
@@ -0,0 +1,92 @@
+package ratelimit
+
+import (
+	"context"
+	"math"
+	"time"
+)
+
+// Policy - token bucket: в ведре до Burst токенов, за Period добавляется Limit.
+// Каждый запрос забирает один токен. Burst 0 означает Burst = Limit
+type Policy struct {
+	Limit  int
+	Period time.Duration
+	Burst  int
+}
+
+func (p Policy) capacity() float64 {
+	if p.Burst > 0 {
+		return float64(p.Burst)
+	}
+	return float64(p.Limit)
+}
+
+// rate - токенов в наносекунду
+func (p Policy) rate() float64 {
+	return float64(p.Limit) / float64(p.Period)
+}
+
+// Enabled - политика с нулевым лимитом или периодом ничего не ограничивает
+func (p Policy) Enabled() bool {
+	return p.Limit > 0 && p.Period > 0
+}
+
+// Decision - результат попытки взять токен
+type Decision struct {
+	Allowed bool
+	// Limit - ёмкость ведра
+	Limit     int
+	Remaining int
+	// RetryAfter - когда появится следующий токен, если запрос отклонён
+	RetryAfter time.Duration
+	// Reset - через сколько ведро наполнится целиком
+	Reset time.Duration
+}
+
+// Store хранит состояние вёдер по ключу (IP, телефон, пользователь)
+type Store interface {
+	Take(ctx context.Context, key string, policy Policy) (Decision, error)
+}
+
+// bucket - состояние ведра на момент Updated
+type bucket struct {
+	Tokens  float64
+	Updated time.Time
+}
+
+// take пополняет ведро по прошедшему времени и пытается забрать токен
+func take(state bucket, exists bool, policy Policy, now time.Time) (bucket, Decision) {
+	capacity := policy.capacity()
+
+	tokens := capacity
+	if exists {
+		tokens = min(capacity, state.Tokens+float64(now.Sub(state.Updated))*policy.rate())
+	}
+
+	allowed := tokens >= 1
+	if allowed {
+		tokens--
+	}
+	return bucket{Tokens: tokens, Updated: now}, decide(tokens, allowed, policy)
+}
+
+// decide описывает ведро, в котором после попытки осталось tokens токенов
+func decide(tokens float64, allowed bool, policy Policy) Decision {
+	capacity := policy.capacity()
+	rate := policy.rate()
+
+	decision := Decision{
+		Allowed:   allowed,
+		Limit:     int(capacity),
+		Remaining: int(math.Floor(tokens)),
+		Reset:     ceilDuration((capacity - tokens) / rate),
+	}
+	if !allowed {
+		decision.RetryAfter = ceilDuration((1 - tokens) / rate)
+	}
+	return decision
+}
+
+func ceilDuration(nanos float64) time.Duration {
+	return time.Duration(math.Ceil(nanos))
+}
@@ -0,0 +1,81 @@
+package ratelimit
+
+import (
+	"context"
+	"fmt"
+	"strconv"
+	"time"
+
+	"github.com/redis/go-redis/v9"
+)
+
+// takeScript - тот же token bucket, что и take, но атомарно на стороне Redis.
+// Ведро - хеш {tokens, updated (мс)}, живёт до полного наполнения.
+// Время передаёт клиент: у экземпляров приложения часы синхронизированы
+// достаточно точно, а скрипт остаётся детерминированным
+var takeScript = redis.NewScript(`
+local capacity = tonumber(ARGV[1])
+local rate = tonumber(ARGV[2])
+local now = tonumber(ARGV[3])
+
+local state = redis.call("HMGET", KEYS[1], "tokens", "updated")
+local tokens = capacity
+if state[1] then
+	local elapsed = math.max(0, now - tonumber(state[2]))
+	tokens = math.min(capacity, tonumber(state[1]) + elapsed * rate)
+end
+
+local allowed = 0
+if tokens >= 1 then
+	tokens = tokens - 1
+	allowed = 1
+end
+
+redis.call("HSET", KEYS[1], "tokens", tostring(tokens), "updated", now)
+redis.call("PEXPIRE", KEYS[1], math.ceil((capacity - tokens) / rate) + 1)
+return {allowed, tostring(tokens)}
+`)
+
+// Redis хранит вёдра в Redis (или совместимом сервере), поэтому лимиты общие
+// для всех экземпляров приложения
+type Redis struct {
+	client *redis.Client
+	prefix string
+	now    func() time.Time
+}
+
+func NewRedis(client *redis.Client, prefix string) *Redis {
+	return &Redis{client: client, prefix: prefix, now: time.Now}
+}
+
+func (r *Redis) Take(ctx context.Context, key string, policy Policy) (Decision, error) {
+	if !policy.Enabled() {
+		return Decision{Allowed: true}, nil
+	}
+
+	perMillisecond := policy.rate() * float64(time.Millisecond)
+	result, err := takeScript.Run(ctx, r.client, []string{r.prefix + key},
+		policy.capacity(),
+		perMillisecond,
+		r.now().UnixMilli(),
+	).Slice()
+	if err != nil {
+		return Decision{}, fmt.Errorf("rate limit script failed: %w", err)
+	}
+	if len(result) != 2 {
+		return Decision{}, fmt.Errorf("unexpected rate limit script result %v", result)
+	}
+
+	allowed, _ := result[0].(int64)
+	raw, _ := result[1].(string)
+	tokens, err := strconv.ParseFloat(raw, 64)
+	if err != nil {
+		return Decision{}, fmt.Errorf("unexpected token count %q: %w", raw, err)
+	}
+	return decide(tokens, allowed == 1, policy), nil
+}
+
+// Ping проверяет соединение с Redis
+func (r *Redis) Ping(ctx context.Context) error {
+	return r.client.Ping(ctx).Err()
+}
@@ -0,0 +1,164 @@
+package ratelimit
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/alicebob/miniredis/v2"
+	"github.com/redis/go-redis/v9"
+)
+
+// newTestRedis - Redis поверх miniredis с часами, которые двигает тест.
+// Экземпляры с одним server делят вёдра, как экземпляры приложения
+func newTestRedis(t *testing.T, server *miniredis.Miniredis, c *clock) *Redis {
+	t.Helper()
+	client := redis.NewClient(&redis.Options{Addr: server.Addr()})
+	t.Cleanup(func() { client.Close() })
+	r := NewRedis(client, "rl:")
+	r.now = c.Now
+	return r
+}
+
+func TestRedisTake(t *testing.T) {
+	// Токен в секунду, до трёх подряд
+	policy := Policy{Limit: 2, Period: 2 * time.Second, Burst: 3}
+
+	type step struct {
+		advance       time.Duration
+		wantAllowed   bool
+		wantRemaining int
+		wantRetry     time.Duration
+		wantReset     time.Duration
+	}
+	tests := []struct {
+		name   string
+		policy Policy
+		steps  []step
+	}{
+		{
+			name:   "burst then refuse",
+			policy: policy,
+			steps: []step{
+				{wantAllowed: true, wantRemaining: 2, wantReset: time.Second},
+				{wantAllowed: true, wantRemaining: 1, wantReset: 2 * time.Second},
+				{wantAllowed: true, wantRemaining: 0, wantReset: 3 * time.Second},
+				{wantAllowed: false, wantRemaining: 0, wantRetry: time.Second, wantReset: 3 * time.Second},
+			},
+		},
+		{
+			name:   "refill after refusal",
+			policy: policy,
+			steps: []step{
+				{wantAllowed: true, wantRemaining: 2, wantReset: time.Second},
+				{wantAllowed: true, wantRemaining: 1, wantReset: 2 * time.Second},
+				{wantAllowed: true, wantRemaining: 0, wantReset: 3 * time.Second},
+				{advance: 250 * time.Millisecond, wantAllowed: false, wantRetry: 750 * time.Millisecond, wantReset: 2750 * time.Millisecond},
+				{advance: 750 * time.Millisecond, wantAllowed: true, wantRemaining: 0, wantReset: 3 * time.Second},
+			},
+		},
+		{
+			name:   "refill stops at burst",
+			policy: policy,
+			steps: []step{
+				{wantAllowed: true, wantRemaining: 2, wantReset: time.Second},
+				{advance: 500 * time.Millisecond, wantAllowed: true, wantRemaining: 1, wantReset: 1500 * time.Millisecond},
+				{advance: time.Hour, wantAllowed: true, wantRemaining: 2, wantReset: time.Second},
+			},
+		},
+		{
+			name:   "disabled policy allows everything",
+			policy: Policy{},
+			steps: []step{
+				{wantAllowed: true},
+				{wantAllowed: true},
+			},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			server := miniredis.RunT(t)
+			c := &clock{now: time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)}
+			r := newTestRedis(t, server, c)
+			for i, s := range tt.steps {
+				c.now = c.now.Add(s.advance)
+				decision, err := r.Take(context.Background(), "key", tt.policy)
+				if err != nil {
+					t.Fatalf("step %d: %v", i, err)
+				}
+				if decision.Allowed != s.wantAllowed || decision.Remaining != s.wantRemaining ||
+					decision.RetryAfter != s.wantRetry || decision.Reset != s.wantReset {
+					t.Fatalf("step %d: got allowed=%v remaining=%d retry=%s reset=%s, want allowed=%v remaining=%d retry=%s reset=%s",
+						i, decision.Allowed, decision.Remaining, decision.RetryAfter, decision.Reset,
+						s.wantAllowed, s.wantRemaining, s.wantRetry, s.wantReset)
+				}
+			}
+		})
+	}
+}
+
+// Ведро живёт в Redis, пока не наполнится, затем ключ удаляется
+func TestRedisKeyExpires(t *testing.T) {
+	server := miniredis.RunT(t)
+	c := &clock{now: time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)}
+	r := newTestRedis(t, server, c)
+	policy := Policy{Limit: 1, Period: time.Minute, Burst: 2}
+	ctx := context.Background()
+
+	if _, err := r.Take(ctx, "key", policy); err != nil {
+		t.Fatal(err)
+	}
+	if ttl := server.TTL("rl:key"); ttl != time.Minute+time.Millisecond {
+		t.Fatalf("ttl after one token = %s, want %s", ttl, time.Minute+time.Millisecond)
+	}
+	if _, err := r.Take(ctx, "key", policy); err != nil {
+		t.Fatal(err)
+	}
+	if ttl := server.TTL("rl:key"); ttl != 2*time.Minute+time.Millisecond {
+		t.Fatalf("ttl after two tokens = %s, want %s", ttl, 2*time.Minute+time.Millisecond)
+	}
+
+	server.FastForward(2 * time.Minute)
+	if !server.Exists("rl:key") {
+		t.Fatal("bucket expired before it is full")
+	}
+	server.FastForward(time.Millisecond)
+	if server.Exists("rl:key") {
+		t.Fatal("full bucket is kept")
+	}
+
+	c.now = c.now.Add(2 * time.Minute)
+	decision, err := r.Take(ctx, "key", policy)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !decision.Allowed || decision.Remaining != 1 {
+		t.Fatalf("expired bucket: allowed=%v remaining=%d, want a full bucket", decision.Allowed, decision.Remaining)
+	}
+}
+
+func TestRedisInstancesShareBuckets(t *testing.T) {
+	server := miniredis.RunT(t)
+	c := &clock{now: time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)}
+	first, second := newTestRedis(t, server, c), newTestRedis(t, server, c)
+	policy := Policy{Limit: 2, Period: time.Minute}
+	ctx := context.Background()
+
+	if decision, _ := first.Take(ctx, "ip:1", policy); !decision.Allowed || decision.Remaining != 1 {
+		t.Fatalf("first instance: %+v", decision)
+	}
+	if decision, _ := second.Take(ctx, "ip:1", policy); !decision.Allowed || decision.Remaining != 0 {
+		t.Fatalf("second instance does not see the first one's token: %+v", decision)
+	}
+	decision, err := first.Take(ctx, "ip:1", policy)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if decision.Allowed || decision.RetryAfter != 30*time.Second {
+		t.Fatalf("shared bucket: allowed=%v retry=%s, want refusal for 30s", decision.Allowed, decision.RetryAfter)
+	}
+	if decision, _ := second.Take(ctx, "ip:2", policy); !decision.Allowed {
+		t.Fatal("ip:2 shares the bucket of ip:1")
+	}
+}
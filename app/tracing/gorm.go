@@ -0,0 +1,79 @@
+package tracing
+
+import (
+	"errors"
+
+	"go.opentelemetry.io/otel/attribute"
+	semconv "go.opentelemetry.io/otel/semconv/v1.26.0"
+	"go.opentelemetry.io/otel/trace"
+	"gorm.io/gorm"
+)
+
+const spanKey = "tracing:span"
+
+// GormPlugin создаёт спан на каждый запрос GORM дочерним к спану из
+// контекста запроса, поэтому db.WithContext(ctx) обязателен.
+// Подключается через db.Use(tracing.GormPlugin{})
+type GormPlugin struct{}
+
+func (GormPlugin) Name() string {
+	return "tracing"
+}
+
+func (GormPlugin) Initialize(db *gorm.DB) error {
+	callback := db.Callback()
+	return errors.Join(
+		callback.Create().Before("*").Register("tracing:before_create", start("create")),
+		callback.Create().After("*").Register("tracing:after_create", end),
+		callback.Query().Before("*").Register("tracing:before_query", start("query")),
+		callback.Query().After("*").Register("tracing:after_query", end),
+		callback.Update().Before("*").Register("tracing:before_update", start("update")),
+		callback.Update().After("*").Register("tracing:after_update", end),
+		callback.Delete().Before("*").Register("tracing:before_delete", start("delete")),
+		callback.Delete().After("*").Register("tracing:after_delete", end),
+		callback.Row().Before("*").Register("tracing:before_row", start("row")),
+		callback.Row().After("*").Register("tracing:after_row", end),
+		callback.Raw().Before("*").Register("tracing:before_raw", start("raw")),
+		callback.Raw().After("*").Register("tracing:after_raw", end),
+	)
+}
+
+func start(operation string) func(db *gorm.DB) {
+	return func(db *gorm.DB) {
+		ctx := db.Statement.Context
+		if ctx == nil || !trace.SpanFromContext(ctx).SpanContext().IsValid() {
+			// Запросы вне обработки запроса (миграции, фоновые задачи) не трассируются
+			return
+		}
+		_, span := Start(ctx, "gorm."+operation,
+			semconv.DBSystemKey.String(db.Dialector.Name()),
+			semconv.DBOperationName(operation),
+		)
+		db.InstanceSet(spanKey, span)
+	}
+}
+
+func end(db *gorm.DB) {
+	value, ok := db.InstanceGet(spanKey)
+	if !ok {
+		return
+	}
+	span, ok := value.(trace.Span)
+	if !ok {
+		return
+	}
+
+	if table := db.Statement.Table; table != "" {
+		span.SetAttributes(semconv.DBCollectionName(table))
+	}
+	span.SetAttributes(
+		semconv.DBQueryText(db.Statement.SQL.String()),
+		attribute.Int64("db.rows_affected", db.RowsAffected),
+	)
+
+	err := db.Error
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		err = nil
+	}
+	End(span, err)
+}
@@ -1,25 +1,51 @@
 package main
 
 import (
+	"context"
+	"errors"
+	"federation-backend/app/api/callback"
+	"federation-backend/app/api/chapter"
 	"federation-backend/app/api/document"
 	files "federation-backend/app/api/file"
 	galleryItem "federation-backend/app/api/gallery-item"
+	"federation-backend/app/api/health"
 	"federation-backend/app/api/match"
 	"federation-backend/app/api/news"
+	"federation-backend/app/api/page"
+	"federation-backend/app/api/season"
 	"federation-backend/app/api/shared"
 	"federation-backend/app/api/shared/crud"
+	"federation-backend/app/api/shared/middleware"
 	"federation-backend/app/api/team"
 	"federation-backend/app/config"
+	database "federation-backend/app/db"
+	"federation-backend/app/db/migrations"
 	"federation-backend/app/db/models"
 	"federation-backend/app/interfaces"
+	"federation-backend/app/lifecycle"
+	"federation-backend/app/logging"
+	"federation-backend/app/metrics"
+	"federation-backend/app/notify"
+	"federation-backend/app/ratelimit"
+	"federation-backend/app/tracing"
 	"fmt"
 	"log"
+	"log/slog"
+	"net"
+	"net/http"
+	"os"
+	"os/signal"
+	"slices"
+	"syscall"
+	"time"
 
 	"github.com/gin-gonic/gin"
+	"github.com/redis/go-redis/v9"
 	swaggerFiles "github.com/swaggo/files"
 	swagger "github.com/swaggo/gin-swagger"
-	"gorm.io/driver/mysql"
+	"go.opentelemetry.io/contrib/instrumentation/github.com/gin-gonic/gin/otelgin"
 	"gorm.io/gorm"
+	gormlogger "gorm.io/gorm/logger"
 )
 
 // @title           Federation Backend API
@@ -37,88 +63,321 @@ import (
 // @host      localhost:8080
 // @BasePath  /api/v1
 
-func CORSMiddleware() gin.HandlerFunc {
-	return func(c *gin.Context) {
-		c.Writer.Header().Set("Access-Control-Allow-Origin", "*")
-		c.Writer.Header().Set("Access-Control-Allow-Credentials", "true")
-		c.Writer.Header().Set("Access-Control-Allow-Headers", "Content-Type, Content-Length, Accept-Encoding, X-CSRF-Token, Authorization, accept, origin, Cache-Control, X-Requested-With")
-		c.Writer.Header().Set("Access-Control-Allow-Methods", "POST, OPTIONS, GET, PUT")
-		if c.Request.Method == "OPTIONS" {
-			c.AbortWithStatus(204)
-			return
-		}
-		c.Next()
-	}
+func main() {
+	os.Exit(run())
 }
 
-func main() {
-	config.Init()
-	var app = gin.Default()
-	var logger = log.Default()
-	var db, dbErr = gorm.Open(mysql.Open("root:root@tcp(db:3306)/federation?parseTime=true"), &gorm.Config{})
+// run содержит всю работу main и возвращает код выхода, чтобы отложенные
+// вызовы (закрытие пула соединений с базой) выполнялись до os.Exit
+func run() (exitCode int) {
+	args, err := config.Init(os.Args[1:])
+	if err != nil {
+		log.Print(err)
+		return 1
+	}
+
+	logger := logging.New(config.Log, os.Stdout)
+	slog.SetDefault(logger)
 
-	app.Use(CORSMiddleware())
+	// federation-backend config - печатает действующую конфигурацию без секретов
+	if len(args) > 0 && args[0] == "config" {
+		fmt.Print(config.Current)
+		return 0
+	}
+
+	// federation-backend healthcheck - проверка готовности для Docker HEALTHCHECK
+	if len(args) > 0 && args[0] == "healthcheck" {
+		ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+		defer cancel()
+		if err := health.Probe(ctx, readinessURL(config.Server)); err != nil {
+			logger.Error("healthcheck failed", logging.Error(err))
+			return 1
+		}
+		return 0
+	}
+
+	var db, dbErr = database.Open(config.DB, &gorm.Config{Logger: newGormLogger(logger)})
 
 	if dbErr != nil {
-		panic(dbErr)
+		logger.Error("startup failed", logging.Error(dbErr))
+		return 1
 	}
 
-	var fileService, fsrvErr = files.NewService(db, config.App.FileStoragePath)
+	sqlDB, err := db.DB()
+	if err != nil {
+		logger.Error("startup failed", logging.Error(err))
+		return 1
+	}
+	defer func() {
+		if err := sqlDB.Close(); err != nil {
+			logger.Error("failed to close database connections", logging.Error(err))
+		}
+	}()
+
+	if err := db.Use(tracing.GormPlugin{}); err != nil {
+		logger.Error("startup failed", logging.Error(err))
+		return 1
+	}
+	if config.Metrics.Enabled {
+		if err := db.Use(metrics.GormPlugin{}); err != nil {
+			logger.Error("startup failed", logging.Error(err))
+			return 1
+		}
+		if err := metrics.RegisterDBStats(sqlDB, config.DB.Name); err != nil {
+			logger.Error("startup failed", logging.Error(err))
+			return 1
+		}
+	}
+
+	// federation-backend migrate up|down|status|help
+	if len(args) > 0 && args[0] == "migrate" {
+		if err := migrations.Run(context.Background(), db, args[1:], os.Stdout); err != nil {
+			logger.Error("migration command failed", logging.Error(err))
+			return 1
+		}
+		return 0
+	}
+
+	if err := migrations.NewMigrator(db).EnsureUpToDate(context.Background()); err != nil {
+		logger.Error("startup failed", logging.Error(err))
+		return 1
+	}
+
+	shutdownTracing, err := tracing.Setup(context.Background(), config.Tracing, config.App.Env, os.Stdout)
+	if err != nil {
+		logger.Error("startup failed", logging.Error(err))
+		return 1
+	}
+
+	workers := lifecycle.NewManager(logger)
+
+	// Пробы оркестратора и сбор метрик не трассируются и не засоряют access log
+	probePaths := []string{"/healthz", "/readyz", config.Metrics.Path}
+
+	var app = gin.New()
+	// ClientIP (access log, лимиты заявок) верит X-Forwarded-For только от этих прокси
+	if err := app.SetTrustedProxies(config.Server.TrustedProxies); err != nil {
+		logger.Error("startup failed", logging.Error(err))
+		return 1
+	}
+	app.Use(middleware.RequestID())
+	app.Use(otelgin.Middleware(config.Tracing.ServiceName, otelgin.WithFilter(func(request *http.Request) bool {
+		return !slices.Contains(probePaths, request.URL.Path)
+	})))
+	app.Use(middleware.AccessLog(logger, probePaths...))
+	app.Use(middleware.Recovery(logger))
+	if config.Metrics.Enabled {
+		app.Use(middleware.Metrics())
+		app.GET(config.Metrics.Path, gin.WrapH(metrics.Handler()))
+	}
+	app.MaxMultipartMemory = config.Upload.MultipartMemory()
+	app.Use(middleware.CORS(config.CORS, config.App.Env))
+	app.Use(middleware.MaxBodySize(config.Upload.MaxRequestSize()))
+
+	var fileService, fsrvErr = files.NewService(db, config.App.FileStoragePath, *config.Upload, logger)
 
 	if fsrvErr != nil {
-		panic(fsrvErr)
+		logger.Error("startup failed", logging.Error(fsrvErr))
+		return 1
+	}
+
+	if config.Metrics.Enabled {
+		workers.Add(lifecycle.Periodic("storage-usage", config.Metrics.StorageScanInterval, logger, fileService.ReportUsage))
+	}
+
+	// Интерфейсная переменная остаётся nil, если уведомления выключены
+	var callbackNotifier callback.Notifier
+	if config.Notify.Enabled {
+		templates, err := notify.LoadTemplates(config.Notify.TemplatesDir)
+		if err != nil {
+			logger.Error("startup failed", logging.Error(err))
+			return 1
+		}
+		sender, err := notify.NewSMTPSender(config.Notify.SMTP)
+		if err != nil {
+			logger.Error("startup failed", logging.Error(err))
+			return 1
+		}
+		outbox := notify.NewOutbox(db, sender, *config.Notify, logger)
+		workers.Add(lifecycle.Periodic("notification-outbox", config.Notify.DeliveryInterval, logger, outbox.Deliver))
+		callbackNotifier = notify.NewCallbackNotifier(outbox, templates, *config.Notify, logger)
+	}
+
+	var limits ratelimit.Store
+	switch config.RateLimit.Backend {
+	case config.RateLimitBackendRedis:
+		client := redis.NewClient(&redis.Options{
+			Addr:         config.RateLimit.Redis.Addr,
+			Password:     config.RateLimit.Redis.Password,
+			DB:           config.RateLimit.Redis.DB,
+			DialTimeout:  config.RateLimit.Redis.Timeout,
+			ReadTimeout:  config.RateLimit.Redis.Timeout,
+			WriteTimeout: config.RateLimit.Redis.Timeout,
+		})
+		defer client.Close()
+		store := ratelimit.NewRedis(client, config.RateLimit.Redis.KeyPrefix)
+		// Без Redis лимиты маршрутов пропускают запросы, поэтому он не входит в readiness
+		if err := store.Ping(context.Background()); err != nil {
+			logger.Warn("redis is unavailable, rate limits are not enforced", logging.Error(err))
+		}
+		limits = store
+	default:
+		store := ratelimit.NewMemory()
+		workers.Add(lifecycle.Periodic("rate-limit-sweep", time.Minute, logger, store.Sweep))
+		limits = store
 	}
 
-	if err := db.AutoMigrate(
-		&models.User{},
-		&models.CallBack{},
-		&models.Match{},
-		&models.File{},
-		&models.GalleryItem{},
-		&models.News{},
-		&models.Chapter{},
-		&models.Team{},
-		&models.Document{},
-	); err != nil {
-		logger.Fatal(err)
+	// rateLimit - middleware политики name из rate_limit.policies, если она задана
+	rateLimit := func(name string) []gin.HandlerFunc {
+		policy, ok := config.RateLimit.Policies[name]
+		if !config.RateLimit.Enabled || !ok {
+			return nil
+		}
+		return []gin.HandlerFunc{middleware.RateLimit(limits, name, policy, logger)}
 	}
 
-	var api = app.Group("/api")
+	callbackGuard := callback.NewGuard(db, limits, callback.NewCaptchaVerifier(config.Callback.Captcha), *config.Callback, logger)
+
+	healthController := health.NewController(logger,
+		health.Check{Name: "database", Run: sqlDB.PingContext},
+		health.Check{Name: "migrations", Run: migrations.NewMigrator(db).EnsureUpToDate},
+		health.Check{Name: "storage", Run: fileService.CheckWritable},
+	)
+	app.GET("/healthz", healthController.Liveness)
+	app.GET("/readyz", healthController.Readiness)
+
+	// Authenticate стоит перед лимитами: иначе сотрудник считается по IP
+	var api = app.Group("/api", append([]gin.HandlerFunc{middleware.Authenticate(config.Auth.AdminToken)}, rateLimit("default")...)...)
 
 	fileProcessor := shared.NewConcurrentFileProcessor(fileService, logger)
+	chapterController := chapter.NewController(db, logger)
+	documentController := document.NewController(db, fileService, *config.Documents, logger)
 
 	routerController := map[interfaces.Controller]*gin.RouterGroup{
-		crud.NewCrudController[models.User](db, logger):      api.Group("/user"),
-		crud.NewCrudController[models.CallBack](db, logger):  api.Group("/callback"),
-		galleryItem.NewController(db, fileProcessor, logger): api.Group("/gallery"),
-		news.NewController(db, fileProcessor):                api.Group("/news"),
-		crud.NewCrudController[models.Chapter](db, logger):   api.Group("/chapter"),
-		team.NewController(db, fileService):                  api.Group("/team"),
-		match.NewController(db, logger):                      api.Group("/match"),
-		document.NewController(db, fileService):              api.Group("/document"),
+		crud.NewCrudController[models.User](db, logger):                       api.Group("/user", rateLimit("user")...),
+		galleryItem.NewController(db, fileProcessor, *config.Gallery, logger): api.Group("/gallery", rateLimit("gallery")...),
+		news.NewController(db, fileProcessor, logger):                         api.Group("/news", rateLimit("news")...),
+		team.NewController(db, fileService, logger):                           api.Group("/team", rateLimit("team")...),
+		match.NewController(db, logger):                                       api.Group("/match", rateLimit("match")...),
+		page.NewController(db, logger):                                        api.Group("/page", rateLimit("page")...),
+		chapterController:                                                     api.Group("/chapter", rateLimit("chapter")...),
+		documentController:                                                    api.Group("/document", rateLimit("document")...),
 	}
 
-	fileController, err := files.NewController(db, config.App.FileStoragePath)
+	adminOnly := middleware.AdminOnly(config.Auth.AdminToken)
+
+	callbackController := callback.NewController(db, callbackNotifier, callbackGuard, logger)
+	callbackGroup := api.Group("/callback", rateLimit("callback")...)
+	{
+		callbackController.RegisterPublicRoutes(callbackGroup)
+		callbackController.RegisterStaffRoutes(callbackGroup.Group("", adminOnly))
+	}
+
+	fileController, err := files.NewController(db, config.App.FileStoragePath, *config.Upload, logger)
 	if err != nil {
-		logger.Fatal(err)
+		logger.Error("startup failed", logging.Error(err))
+		return 1
 	}
 
-	fileGroup := api.Group("/files")
+	fileGroup := api.Group("/files", rateLimit("files")...)
 	{
 		fileGroup.DELETE("/:filename", fileController.DeleteFile)
 	}
 
-	for controller, router := range routerController {
+	adminGroup := api.Group("/admin", append([]gin.HandlerFunc{adminOnly}, rateLimit("admin")...)...)
+	{
+		adminGroup.GET("/storage", fileController.GetStorageInfo)
+		adminGroup.POST("/import", season.NewController(db, logger).Import)
+		adminGroup.POST("/documents/reindex", documentController.Reindex)
+	}
 
-		fmt.Println("\n\ninitializing router for ", router.BasePath())
+	for controller, router := range routerController {
 		interfaces.RegisterRoutes(controller, router)
-		fmt.Println("router for " + router.BasePath() + " is initialized\n\n")
+		logger.Debug("routes registered", slog.String("group", router.BasePath()))
 	}
 
+	api.GET("/navigation", chapterController.Navigation)
 	api.GET("/swagger/*any", swagger.WrapHandler(swaggerFiles.Handler))
-	api.Static("/files", "./files")
+	// Раздача файлов вне группы api: картинки галереи не должны расходовать лимит default
+	app.Static("/api/files", config.App.FileStoragePath)
+
+	server := &http.Server{
+		Addr:              config.Server.GetHostURL(),
+		Handler:           app,
+		ReadHeaderTimeout: config.Server.ReadHeaderTimeout,
+		ReadTimeout:       config.Server.ReadTimeout,
+		WriteTimeout:      config.Server.WriteTimeout,
+		IdleTimeout:       config.Server.IdleTimeout,
+	}
 
-	if exc := app.Run(":8080"); exc != nil {
-		panic(exc)
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer stop()
+
+	workers.Start(ctx)
+
+	serverErr := make(chan error, 1)
+	go func() {
+		logger.Info("listening", slog.String("addr", server.Addr), slog.Bool("tls", config.Server.TLS.Enabled()))
+		if config.Server.TLS.Enabled() {
+			serverErr <- server.ListenAndServeTLS(config.Server.TLS.CertFile, config.Server.TLS.KeyFile)
+		} else {
+			serverErr <- server.ListenAndServe()
+		}
+	}()
+
+	select {
+	case err := <-serverErr:
+		if !errors.Is(err, http.ErrServerClosed) {
+			logger.Error("http server failed", logging.Error(err))
+			exitCode = 1
+		}
+	case <-ctx.Done():
+		logger.Info("shutting down, waiting for in-flight requests")
+	}
+
+	shutdownCtx, cancel := context.WithTimeout(context.Background(), config.Server.ShutdownTimeout)
+	defer cancel()
+
+	if err := server.Shutdown(shutdownCtx); err != nil {
+		logger.Error("failed to shut down http server gracefully", logging.Error(err))
+		exitCode = 1
+	}
+	if err := workers.Stop(shutdownCtx); err != nil {
+		logger.Error("failed to stop background workers", logging.Error(err))
+		exitCode = 1
+	}
+	if err := shutdownTracing(shutdownCtx); err != nil {
+		logger.Error("failed to flush traces", logging.Error(err))
+		exitCode = 1
+	}
+
+	return exitCode
+}
+
+// newGormLogger пишет медленные и ошибочные запросы в общий логгер,
+// а при уровне debug - все запросы
+func newGormLogger(logger *slog.Logger) gormlogger.Interface {
+	level := gormlogger.Warn
+	if logging.ParseLevel(config.Log.Level) <= slog.LevelDebug {
+		level = gormlogger.Info
+	}
+	return gormlogger.NewSlogLogger(logger, gormlogger.Config{
+		SlowThreshold:             200 * time.Millisecond,
+		LogLevel:                  level,
+		IgnoreRecordNotFoundError: true,
+	})
+}
+
+// readinessURL - адрес /readyz этого же процесса. Сервер, слушающий все
+// интерфейсы, проверяется через loopback
+func readinessURL(server *config.ServerConfig) string {
+	host := server.Host
+	if host == "" || host == "0.0.0.0" || host == "::" {
+		host = "127.0.0.1"
+	}
+	scheme := "http"
+	if server.TLS.Enabled() {
+		scheme = "https"
 	}
+	return scheme + "://" + net.JoinHostPort(host, server.Port) + "/readyz"
 }